@@ -0,0 +1,197 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Achievements are goals defined for a ROM in game.ch8.achievements.toml, kept next to it or in
+// the roms directory of the user's settings like game.ch8.toml. Each is a watch expression over
+// memory and registers, unlocked the first frame it is true, or once it has held for frames in
+// a row:
+//
+//	[[achievement]]
+//	name = "Century"
+//	description = "Score 100 points"
+//	when = "[score] * 100 + [score + 1] * 10 + [score + 2] >= 100"
+//
+//	[[achievement]]
+//	name = "Level 2"
+//	when = "[0x3F0] & 0x80"
+//	frames = 2
+//
+// Labels from -symbols can be used in the expressions. Unlocks are shown on screen and recorded
+// in achievements/<ROM hash>.json under the data directory, so each is only earned once.
+
+type achievementFile struct {
+	Achievements []achievementDef `toml:"achievement"`
+}
+
+type achievementDef struct {
+	Name        string `toml:"name"`
+	Description string `toml:"description"`
+
+	// Watch expression unlocking the achievement when non-zero
+	When string `toml:"when"`
+
+	// Frames in a row When must hold for, 1 if not given
+	Frames int `toml:"frames"`
+}
+
+type achievement struct {
+	achievementDef
+	cond *watchExpr
+
+	// frames When has held for so far
+	held int
+}
+
+// achievements is a peripheral checking a ROM's achievements that are still locked each frame
+type achievements struct {
+	romHash  string
+	pending  []*achievement
+	unlocked map[string]time.Time
+}
+
+// achievementsPath returns where the achievement definitions for a ROM live
+func achievementsPath(romFile string) string {
+	return strings.TrimSuffix(sidecarPath(romFile), ".toml") + ".achievements.toml"
+}
+
+// achievementRecordPath returns where the unlocked achievements of a ROM are recorded
+func achievementRecordPath(romHash string) (string, error) {
+	data, _, err := dataDirs()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(data, "achievements", romHash+".json"), nil
+}
+
+// loadAchievements starts checking the achievements defined for a ROM, if it has any, in place of
+// those of the ROM loaded before. A missing definition file is not an error.
+func (c *Chip8) loadAchievements(romFile string) error {
+	if c.RomHash == "" {
+		return nil
+	}
+	defFile, f, err := readSidecarFile(achievementsPath(romFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var file achievementFile
+	if _, err := toml.Decode(string(f), &file); err != nil {
+		return fmt.Errorf("%s: %w", defFile, err)
+	}
+
+	a := &achievements{romHash: c.RomHash, unlocked: map[string]time.Time{}}
+	if path, err := achievementRecordPath(c.RomHash); err == nil {
+		if b, err := os.ReadFile(path); err == nil {
+			if err := json.Unmarshal(b, &a.unlocked); err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+		}
+	}
+
+	names := map[string]bool{}
+	for i, def := range file.Achievements {
+		if def.Name == "" || def.When == "" {
+			return fmt.Errorf("%s: achievement %d needs a name and a when", defFile, i+1)
+		}
+		if names[def.Name] {
+			return fmt.Errorf("%s: achievement %q is defined twice", defFile, def.Name)
+		}
+		names[def.Name] = true
+
+		cond, err := parseWatchExpr(def.When, c.Symbols)
+		if err != nil {
+			return fmt.Errorf("%s: achievement %q: %w", defFile, def.Name, err)
+		}
+		def.Frames = max(def.Frames, 1)
+		if _, ok := a.unlocked[def.Name]; !ok {
+			a.pending = append(a.pending, &achievement{achievementDef: def, cond: cond})
+		}
+	}
+
+	log.Printf("achievements: %d of %d unlocked", len(file.Achievements)-len(a.pending), len(file.Achievements))
+	if len(a.pending) == 0 {
+		return nil
+	}
+
+	// a checker left from the previous ROM takes on this one's achievements
+	for _, p := range c.Peripherals() {
+		if old, ok := p.(*achievements); ok {
+			*old = *a
+			return nil
+		}
+	}
+	return c.AttachPeripheral(a)
+}
+
+func (a *achievements) Name() string { return "achievements" }
+
+// Frame unlocks the achievements whose conditions have now held for long enough
+func (a *achievements) Frame(c *Chip8) {
+	// another ROM may have been loaded into the machine since
+	if c.RomHash != a.romHash || len(a.pending) == 0 {
+		return
+	}
+
+	locked := a.pending[:0]
+	for _, ach := range a.pending {
+		v, err := ach.cond.root.eval(c)
+		if err != nil {
+			log.Printf("achievement %q: %v, no longer checked", ach.Name, err)
+			continue
+		}
+		if v == 0 {
+			ach.held = 0
+		} else if ach.held++; ach.held >= ach.Frames {
+			a.unlock(c, ach)
+			continue
+		}
+		locked = append(locked, ach)
+	}
+	a.pending = locked
+}
+
+// unlock shows and records an achievement just earned
+func (a *achievements) unlock(c *Chip8, ach *achievement) {
+	a.unlocked[ach.Name] = time.Now()
+	c.Notify("Achievement unlocked: %s", ach.Name)
+	if ach.Description != "" {
+		log.Printf("achievement unlocked: %s, %s", ach.Name, ach.Description)
+	} else {
+		log.Printf("achievement unlocked: %s", ach.Name)
+	}
+
+	if err := a.save(); err != nil {
+		log.Printf("recording achievement failed: %v", err)
+	}
+}
+
+func (a *achievements) save() error {
+	path, err := achievementRecordPath(a.romHash)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(a.unlocked, "", "\t")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0o644)
+}
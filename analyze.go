@@ -0,0 +1,196 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// opcodePattern names the instruction family an opcode belongs to, e.g. 0x8A34 -> "8XY4"
+func opcodePattern(opcode uint16) string {
+	switch opcode & 0xF000 {
+	case 0x0000:
+		switch {
+		case opcode == 0x00E0, opcode == 0x00EE, opcode >= 0x00FB && opcode <= 0x00FF:
+			return fmt.Sprintf("%04X", opcode)
+		case opcode&0xFFF0 == 0x00C0:
+			return "00CN"
+		case opcode&0xFFF0 == 0x00D0:
+			return "00DN"
+		}
+		return "0NNN"
+	case 0x1000, 0x2000, 0xA000, 0xB000:
+		return fmt.Sprintf("%XNNN", opcode>>12)
+	case 0x3000, 0x4000, 0x6000, 0x7000, 0xC000:
+		return fmt.Sprintf("%XXNN", opcode>>12)
+	case 0x5000, 0x8000, 0x9000:
+		return fmt.Sprintf("%XXY%X", opcode>>12, opcode&0xF)
+	case 0xD000:
+		if opcode&0xF == 0 {
+			return "DXY0"
+		}
+		return "DXYN"
+	case 0xE000, 0xF000:
+		if opcode == 0xF000 || opcode == 0xF002 {
+			return fmt.Sprintf("%04X", opcode)
+		}
+		return fmt.Sprintf("%XX%02X", opcode>>12, opcode&0xFF)
+	}
+	return fmt.Sprintf("%04X", opcode)
+}
+
+// romReport summarises the static analysis of a ROM
+type romReport struct {
+	size        int
+	space       int
+	codeBytes   int
+	subroutines int
+	histogram   map[string]int
+	features    map[Variant][]string
+	suspicious  []string
+}
+
+// analyzeRom gathers opcode statistics and flags odd constructs over a ROM's reachable code.
+// The name picks the variant whose memory the free space is counted against.
+func analyzeRom(name string, rom []byte, syms *SymbolTable) *romReport {
+	d := disassemble(rom, syms)
+	r := &romReport{
+		size:        len(rom),
+		space:       romSpace(name, rom),
+		subroutines: len(d.calls),
+		histogram:   map[string]int{},
+		features:    map[Variant][]string{},
+	}
+
+	end := int(RamGameStart) + len(rom)
+
+	addrs := make([]uint16, 0, len(d.code))
+	for addr := range d.code {
+		addrs = append(addrs, addr)
+	}
+	sort.Slice(addrs, func(i, j int) bool { return addrs[i] < addrs[j] })
+
+	// I is tracked in memory order, a rough stand-in for the order the program sets it in
+	var i uint16
+	haveI := false
+	for _, addr := range addrs {
+		opcode := d.word(addr)
+		r.codeBytes += int(instructionSize(opcode))
+		r.histogram[opcodePattern(opcode)]++
+
+		if dialect, ok := dialectOf(opcode); ok {
+			r.features[dialect] = append(r.features[dialect], fmt.Sprintf("%s at 0x%03X", opcodePattern(opcode), addr))
+		}
+
+		nnn := opcode & 0x0FFF
+		switch {
+		case opcode&0xF000 == 0x1000, opcode&0xF000 == 0x2000, opcode&0xF000 == 0xB000:
+			if nnn%2 != 0 {
+				r.suspicious = append(r.suspicious, fmt.Sprintf("0x%03X: %s targets odd address 0x%03X", addr, Mnemonic(opcode, d.syms), nnn))
+			}
+			if nnn < RamGameStart || int(nnn) >= end {
+				r.suspicious = append(r.suspicious, fmt.Sprintf("0x%03X: %s leaves the ROM", addr, Mnemonic(opcode, d.syms)))
+			}
+		case opcodePattern(opcode) == "0NNN":
+			r.suspicious = append(r.suspicious, fmt.Sprintf("0x%03X: SYS 0x%03X calls host machine code", addr, nnn))
+		case opcode&0xF000 == 0xA000:
+			i, haveI = nnn, true
+			if d.code[nnn] {
+				r.suspicious = append(r.suspicious, fmt.Sprintf("0x%03X: I points into code at 0x%03X", addr, nnn))
+			}
+		case opcode&0xF0FF == 0xF055, opcode&0xF0FF == 0xF033:
+			// writes through I landing on reachable code rewrite the program itself
+			if !haveI {
+				break
+			}
+			length := uint16(3)
+			if opcode&0xF0FF == 0xF055 {
+				length = (opcode&0x0F00)>>8 + 1
+			}
+			for a := i; a < i+length; a++ {
+				if d.code[a] || d.code[a-1] {
+					r.suspicious = append(r.suspicious, fmt.Sprintf("0x%03X: %s writes over code at 0x%03X (self-modifying)", addr, Mnemonic(opcode, d.syms), a))
+					break
+				}
+			}
+		}
+	}
+
+	return r
+}
+
+// Write prints the report in a human readable form
+func (r *romReport) Write(w io.Writer) {
+	fmt.Fprintf(w, "size:         %d bytes (%d free of %d)\n", r.size, r.space-r.size, r.space)
+	fmt.Fprintf(w, "code (est.):  %d bytes (%.0f%%)\n", r.codeBytes, percent(r.codeBytes, r.size))
+	fmt.Fprintf(w, "data (est.):  %d bytes (%.0f%%)\n", r.size-r.codeBytes, percent(r.size-r.codeBytes, r.size))
+	fmt.Fprintf(w, "subroutines:  %d\n", r.subroutines)
+
+	fmt.Fprintln(w, "\nfeatures:")
+	if len(r.features) == 0 {
+		fmt.Fprintln(w, "  plain CHIP-8 only")
+	}
+	for _, v := range []Variant{VariantSChip, VariantXOChip} {
+		if hits := r.features[v]; len(hits) > 0 {
+			fmt.Fprintf(w, "  %s: %s\n", v, summarizeHits(hits))
+		}
+	}
+
+	fmt.Fprintln(w, "\nopcode usage:")
+	patterns := make([]string, 0, len(r.histogram))
+	for p := range r.histogram {
+		patterns = append(patterns, p)
+	}
+	sort.Slice(patterns, func(i, j int) bool {
+		if r.histogram[patterns[i]] != r.histogram[patterns[j]] {
+			return r.histogram[patterns[i]] > r.histogram[patterns[j]]
+		}
+		return patterns[i] < patterns[j]
+	})
+	for _, p := range patterns {
+		fmt.Fprintf(w, "  %-5s %5d\n", p, r.histogram[p])
+	}
+
+	fmt.Fprintln(w, "\nsuspicious:")
+	if len(r.suspicious) == 0 {
+		fmt.Fprintln(w, "  nothing found")
+	}
+	for _, s := range r.suspicious {
+		fmt.Fprintf(w, "  %s\n", s)
+	}
+}
+
+func percent(part, whole int) float64 {
+	if whole == 0 {
+		return 0
+	}
+	return float64(part) * 100 / float64(whole)
+}
+
+// analyzeCommand implements "chip8 analyze rom.ch8"
+func analyzeCommand(args []string) error {
+	fs := newFlagSet("analyze")
+	symbolFile := fs.String("symbols", "", "symbol listing to label addresses with, overriding any from an .8o source")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return usageError("analyze")
+	}
+
+	img, err := readRomFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	syms := img.Symbols
+	if *symbolFile != "" {
+		if syms, err = LoadSymbols(*symbolFile); err != nil {
+			return err
+		}
+	}
+
+	analyzeRom(img.Name, img.Data, syms).Write(os.Stdout)
+	return nil
+}
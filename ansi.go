@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+)
+
+// ansiWriter writes the display to a stream as lines of half blocks every few frames, for
+// "chip8 ansi": into a terminal, a pipe, a log or an asciinema recording
+type ansiWriter struct {
+	w     *bufio.Writer
+	every uint64
+
+	// move the cursor back to the top left before each frame rather than writing frames below
+	// one another, and draw in the ROM's colors
+	clear, color bool
+
+	last    screenPlane
+	written bool
+}
+
+func (a *ansiWriter) Name() string { return "ANSI output" }
+
+// Frame writes the display when it is time to and it has changed since the last one written
+func (a *ansiWriter) Frame(c *Chip8) {
+	if c.Frame%a.every != 0 || (a.written && c.ScreenState == a.last) {
+		return
+	}
+
+	switch {
+	case a.clear && !a.written:
+		a.w.WriteString("\x1b[H\x1b[2J")
+	case a.clear:
+		a.w.WriteString("\x1b[H")
+	case a.written:
+		a.w.WriteByte('\n')
+	}
+	for _, line := range strings.Split(teachDisplay(&c.ScreenState), "\n") {
+		if a.color {
+			on, off := c.ColorOn, c.ColorOff
+			fmt.Fprintf(a.w, "\x1b[38;2;%d;%d;%dm\x1b[48;2;%d;%d;%dm%s\x1b[0m\n", on.R, on.G, on.B, off.R, off.G, off.B, line)
+		} else {
+			a.w.WriteString(line + "\n")
+		}
+	}
+
+	// stop once the output cannot be written to
+	if err := a.w.Flush(); err != nil {
+		c.IsStopped = true
+	}
+	a.last, a.written = c.ScreenState, true
+}
+
+// ansiCommand implements "chip8 ansi rom.ch8", running the ROM without a window or input and
+// writing its display to stdout
+func ansiCommand(args []string) error {
+	fs := newFlagSet("ansi")
+	fps := fs.Int("fps", 15, "frames a second written, at most 60; frames the same as the last one written are left out")
+	clear := fs.Bool("clear", true, "draw each frame over the last with cursor codes, rather than one after another as plain lines")
+	useColor := fs.Bool("color", false, "draw in the ROM's colors with 24-bit color codes")
+	frames := fs.Uint64("frames", 0, "stop after this many frames, 0 runs until interrupted")
+	variant := fs.String("variant", "", "interpreter variant, defaults to the one detected for the ROM")
+	seed := fs.Int64("seed", 0, "seed for CXNN random numbers, 0 picks one from the clock")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 || *fps < 1 || *fps > FramesPerSecond {
+		return usageError("ansi")
+	}
+
+	c := newMachine()
+	c.LoadDefaultSprites()
+	if err := c.loadRomFile(fs.Arg(0)); err != nil {
+		return err
+	}
+	if err := c.LoadSidecar(fs.Arg(0)); err != nil {
+		return err
+	}
+	if *variant != "" {
+		v, err := ParseVariant(*variant)
+		if err != nil {
+			return err
+		}
+		c.SetVariant(v)
+	}
+	if *seed != 0 {
+		c.SetSeed(*seed)
+	}
+
+	out := &ansiWriter{w: bufio.NewWriter(os.Stdout), every: uint64(FramesPerSecond / *fps), clear: *clear, color: *useColor}
+	if err := c.AttachPeripheral(out); err != nil {
+		return err
+	}
+	if *frames > 0 {
+		c.OnFrame(func(c *Chip8) {
+			if c.Frame >= *frames {
+				c.IsStopped = true
+			}
+		})
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	err := c.Run(ctx)
+	if errors.Is(err, context.Canceled) {
+		return nil
+	}
+	return err
+}
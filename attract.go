@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gopxl/pixel/v2/backends/opengl"
+)
+
+// attractEntry is a ROM in the attract mode playlist, with an optional input recording to play
+// while nobody is at the keyboard
+type attractEntry struct {
+	Rom    string
+	Replay string
+}
+
+// attractMode cycles through a playlist for unattended displays. Each ROM runs for at least
+// Duration; it is only switched away from once nobody has touched a key for Idle, so a visitor
+// who starts playing keeps the game.
+type attractMode struct {
+	Entries  []attractEntry
+	Duration time.Duration
+	Idle     time.Duration
+
+	current    int
+	started    time.Time
+	lastActive time.Time
+	replay     *inputReplay
+}
+
+// attract is set by the attract command and picked up by run
+var attract *attractMode
+
+// loadPlaylist reads a playlist file: one ROM per line, optionally followed by an input
+// recording, relative to the playlist. Blank lines and # comments are ignored.
+func loadPlaylist(file string) ([]attractEntry, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	dir := filepath.Dir(file)
+	rel := func(p string) string {
+		if filepath.IsAbs(p) {
+			return p
+		}
+		return filepath.Join(dir, p)
+	}
+
+	var entries []attractEntry
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		switch len(fields) {
+		case 1:
+			entries = append(entries, attractEntry{Rom: rel(fields[0])})
+		case 2:
+			entries = append(entries, attractEntry{Rom: rel(fields[0]), Replay: rel(fields[1])})
+		default:
+			return nil, fmt.Errorf("%s:%d: expected a ROM and optional recording, found %q", file, n, line)
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// attractCommand implements "chip8 attract playlist.txt" or "chip8 attract a.ch8 b.ch8 ..."
+func attractCommand(args []string) error {
+	fs := newFlagSet("attract")
+	duration := fs.Duration("duration", time.Minute, "minimum time each ROM runs for")
+	idle := fs.Duration("idle", 20*time.Second, "time without key presses before moving to the next ROM")
+	runFlags.VisitAll(func(f *flag.Flag) { fs.Var(f.Value, f.Name, f.Usage) })
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	a := &attractMode{Duration: *duration, Idle: *idle}
+	for _, arg := range fs.Args() {
+		if strings.EqualFold(filepath.Ext(arg), ".txt") {
+			entries, err := loadPlaylist(arg)
+			if err != nil {
+				return err
+			}
+			a.Entries = append(a.Entries, entries...)
+			continue
+		}
+
+		// a recording saved next to the ROM is picked up automatically
+		e := attractEntry{Rom: arg}
+		if keys := strings.TrimSuffix(arg, filepath.Ext(arg)) + ".keys"; fileExists(keys) {
+			e.Replay = keys
+		}
+		a.Entries = append(a.Entries, e)
+	}
+	if len(a.Entries) == 0 {
+		return usageError("attract")
+	}
+
+	// kiosks restart every game from scratch
+	*autosave = false
+	attract = a
+	opengl.Run(run)
+	return nil
+}
+
+func fileExists(file string) bool {
+	_, err := os.Stat(file)
+	return err == nil
+}
+
+// Start begins the first entry, which run has already loaded
+func (a *attractMode) Start(c *Chip8) {
+	a.begin(c)
+}
+
+func (a *attractMode) begin(c *Chip8) {
+	now := time.Now()
+	a.started, a.lastActive = now, now
+	a.replay = nil
+
+	e := a.Entries[a.current]
+	if e.Replay != "" {
+		p, err := loadInputReplay(e.Replay)
+		if err != nil {
+			log.Printf("attract: %v", err)
+			return
+		}
+		p.Start(c)
+		a.replay = p
+	}
+}
+
+// Update runs after input is read each frame: it plays the recording while the keyboard is idle
+// and moves to the next ROM when this one has had its time
+func (a *attractMode) Update(c *Chip8) {
+	now := time.Now()
+	if keyMask(c.KeyPressed) != 0 {
+		// someone is playing, stop the demo input for this game
+		a.lastActive, a.replay = now, nil
+	} else if a.replay != nil {
+		a.replay.Apply(c)
+	}
+
+	if now.Sub(a.started) < a.Duration || now.Sub(a.lastActive) < a.Idle {
+		return
+	}
+
+	for range a.Entries {
+		a.current = (a.current + 1) % len(a.Entries)
+		rom := a.Entries[a.current].Rom
+		if err := c.SwitchRom(rom); err != nil {
+			log.Printf("attract: skipping %s: %v", rom, err)
+			continue
+		}
+		log.Printf("attract: now showing %s", rom)
+		break
+	}
+	a.begin(c)
+}
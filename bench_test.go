@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+// Benchmarks for the interpreter's hot paths, all headless. Names are stable so runs before and
+// after a change can be compared with benchstat:
+//
+//	go test -run '^$' -bench . -count 10 > old.txt
+//	go test -run '^$' -bench . -count 10 > new.txt
+//	benchstat old.txt new.txt
+
+// aluLoop is a tight loop of arithmetic, a skip, I updates and a jump, no drawing
+var aluLoop = []byte{
+	0x60, 0x00, // 200: V0 = 0
+	0x61, 0x01, // 202: V1 = 1
+	0x80, 0x14, // 204: V0 += V1
+	0x81, 0x02, // 206: V1 &= V0
+	0x30, 0x00, // 208: skip if V0 == 0
+	0x72, 0x01, // 20A: V2 += 1
+	0xA3, 0x00, // 20C: I = 300
+	0xF0, 0x1E, // 20E: I += V0
+	0x12, 0x04, // 210: jump 204
+}
+
+// drawLoop draws a font glyph across the screen, moving it along a pixel each time
+var drawLoop = []byte{
+	0x00, 0xE0, // 200: clear
+	0xA0, 0x00, // 202: I = glyph 0
+	0xD0, 0x15, // 204: draw 5 rows at V0, V1
+	0x70, 0x01, // 206: V0 += 1
+	0x71, 0x01, // 208: V1 += 1
+	0x12, 0x04, // 20A: jump 204
+}
+
+var decodeSink Opcode
+
+func benchMachine(rom []byte) *Chip8 {
+	c := newMachine()
+	c.LoadDefaultSprites()
+	c.LoadRom(rom)
+	c.SetSeed(1)
+	return c
+}
+
+// The benchmarks report allocations: steady-state frames are meant to make none, so a GC pause
+// never lands in the middle of a game on a slow host.
+
+// BenchmarkDecode decodes every opcode in turn, without the decode cache
+func BenchmarkDecode(b *testing.B) {
+	c := benchMachine(aluLoop)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		decodeSink = c.decode(uint16(i))
+	}
+}
+
+// BenchmarkDispatch runs a frame's worth of instructions at a time and reports the time per
+// instruction, with and without basic blocks
+func BenchmarkDispatch(b *testing.B) {
+	for _, blocks := range []bool{false, true} {
+		b.Run(fmt.Sprintf("blocks=%v", blocks), func(b *testing.B) {
+			c := benchMachine(aluLoop)
+			c.UseBlocks = blocks
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i += c.CyclesPerFrame {
+				c.ExecuteCPU(min(c.CyclesPerFrame, b.N-i))
+			}
+		})
+	}
+}
+
+// BenchmarkDrawSprite draws sprites of several heights, some of them off the screen edges, with
+// sprites clipped and wrapped
+func BenchmarkDrawSprite(b *testing.B) {
+	for _, wrap := range []bool{false, true} {
+		for _, rows := range []uint16{1, 5, 15} {
+			b.Run(fmt.Sprintf("wrap=%v/rows=%d", wrap, rows), func(b *testing.B) {
+				c := benchMachine(drawLoop)
+				c.Quirks.SpritesWrap = wrap
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					c.Vx[0], c.Vx[1] = uint8(i*7), uint8(i*3)
+					c.drawSprite(0xD010 | rows)
+				}
+			})
+		}
+	}
+}
+
+// BenchmarkFrame runs whole frames as Run does, less the pacing: execution, timers, drawing and
+// input, with nothing attached
+func BenchmarkFrame(b *testing.B) {
+	for _, rom := range []struct {
+		name string
+		data []byte
+	}{{"alu", aluLoop}, {"draw", drawLoop}} {
+		b.Run(rom.name, func(b *testing.B) {
+			c := benchMachine(rom.data)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				c.runFrame()
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+// basicBlock is a run of instructions executed as a unit: it ends after the first instruction
+// that can change the flow of control (jumps, calls, returns, skips, FX0A's wait) or write
+// memory, so nothing inside a block can invalidate the rest of it
+type basicBlock struct {
+	instrs []decodedInstruction
+}
+
+// blockCache maps start addresses to blocks. inBlock flags every byte some block was built from;
+// a write to one of those throws all blocks away, which only self-modifying code ever triggers.
+type blockCache struct {
+	blocks  []*basicBlock
+	inBlock []bool
+	used    bool
+}
+
+// endsBlock reports whether an instruction must be the last of its block
+func endsBlock(op Opcode) bool {
+	switch op {
+	case opcode00EE, opcode1NNN, opcode2NNN, opcode3XNN, opcode4XNN, opcode5XY0, opcode9XY0,
+		opcodeBNNN, opcodeEX9E, opcodeEXA1, opcodeFX0A, opcodeFX33, opcodeFX55, opcodeF000:
+		return true
+	}
+	return false
+}
+
+// blockAt returns the block starting at addr, building it on first use
+func (c *Chip8) blockAt(addr uint16) *basicBlock {
+	if b := c.blocks.blocks[addr]; b != nil {
+		return b
+	}
+
+	b := &basicBlock{}
+	for a := addr; int(a)+1 < len(c.MainMemory); a += 2 {
+		opcode := uint16(c.MainMemory[a])<<8 | uint16(c.MainMemory[a+1])
+		op := c.decode(opcode)
+		b.instrs = append(b.instrs, decodedInstruction{opcode, op, true})
+		c.blocks.inBlock[a], c.blocks.inBlock[a+1] = true, true
+		if endsBlock(op) {
+			break
+		}
+	}
+
+	c.blocks.blocks[addr] = b
+	c.blocks.used = true
+	return b
+}
+
+// flushBlocks forgets every block
+func (c *Chip8) flushBlocks() {
+	if c.blocks.used {
+		clear(c.blocks.blocks)
+		clear(c.blocks.inBlock)
+		c.blocks.used = false
+	}
+}
+
+// blocksAllowed reports whether nothing needs to see instructions one at a time
+func (c *Chip8) blocksAllowed() bool {
+	return c.UseBlocks && c.Debugger == nil && c.transpiled == nil &&
+		!c.Events.Has(EventInstruction) && len(c.peripherals.opcodes) == 0
+}
+
+// executeBlocks runs cyclesToExecute instructions a block at a time, splitting the last block
+// when it would overrun
+func (c *Chip8) executeBlocks(cyclesToExecute int) {
+	var pc, opcode uint16
+	defer func() {
+		if r := recover(); r != nil {
+			c.fault(pc, opcode, r)
+		}
+	}()
+
+	for done := 0; done < cyclesToExecute && c.Fault == nil; {
+		pc, opcode = c.PC, 0
+		if int(pc)+1 >= len(c.MainMemory) {
+			// no block runs off the end of memory, the instruction there is fetched on its own
+			c.recent.add(pc)
+			if c.Coverage != nil {
+				c.Coverage.hit(pc)
+			}
+			var op Opcode
+			opcode, op = c.fetchAtEnd()
+			c.execute(op, opcode)
+			done++
+			continue
+		}
+		b := c.blockAt(c.PC)
+
+		instrs := b.instrs
+		if left := cyclesToExecute - done; len(instrs) > left {
+			instrs = instrs[:left]
+		}
+		for _, in := range instrs {
+			pc, opcode = c.PC, in.opcode
+			c.recent.add(pc)
+			if c.Coverage != nil {
+				c.Coverage.hit(pc)
+			}
+			c.PC += 2
+			c.execute(in.op, in.opcode)
+		}
+		done += len(instrs)
+	}
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+)
+
+// A breakpoint can carry actions, run each time it is hit instead of pausing:
+//
+//	on draw log score {V3} at {[I]}; count
+//	on 2A4 dump 300 340; trace on
+//	on crash log V0={V0}; stop
+//
+// Execution carries on afterwards unless one of the actions is stop.
+
+// breakAction is one parsed action; run reports whether the machine should pause
+type breakAction struct {
+	text string
+	run  func(c *Chip8, pc uint16) (stop bool, err error)
+}
+
+// breakActionParsers parse the arguments of each action by name
+var breakActionParsers = map[string]func(c *Chip8, args string) (func(c *Chip8, pc uint16) (bool, error), error){
+	"log":   parseLogAction,
+	"dump":  parseDumpAction,
+	"count": parseCountAction,
+	"trace": parseTraceAction,
+	"stop":  parseStopAction,
+}
+
+// parseBreakActions parses actions separated by semicolons
+func (c *Chip8) parseBreakActions(src string) ([]breakAction, error) {
+	var actions []breakAction
+	for _, text := range strings.Split(src, ";") {
+		text = strings.TrimSpace(text)
+		if text == "" {
+			continue
+		}
+		name, args, _ := strings.Cut(text, " ")
+		parse, ok := breakActionParsers[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown action %q, expected log, dump, count, trace or stop", name)
+		}
+		run, err := parse(c, strings.TrimSpace(args))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		actions = append(actions, breakAction{text, run})
+	}
+	if len(actions) == 0 {
+		return nil, fmt.Errorf("no actions given")
+	}
+	return actions, nil
+}
+
+// runBreakActions runs the actions attached to the breakpoint at pc, reporting whether to pause
+func (c *Chip8) runBreakActions(pc uint16) bool {
+	d := c.Debugger
+	stop := false
+	for _, a := range d.Actions[pc] {
+		s, err := a.run(c, pc)
+		if err != nil {
+			fmt.Fprintf(d.out, "break at %04X: %s: %v\n", pc, a.text, err)
+			return true
+		}
+		stop = stop || s
+	}
+	return stop
+}
+
+// parseLogAction prints a message with {expr} replaced by the value of the watch expression
+func parseLogAction(c *Chip8, args string) (func(*Chip8, uint16) (bool, error), error) {
+	msg := strings.Trim(args, `"`)
+	var literals []string
+	var exprs []*watchExpr
+	for {
+		open := strings.IndexByte(msg, '{')
+		if open < 0 {
+			break
+		}
+		end := strings.IndexByte(msg[open:], '}')
+		if end < 0 {
+			return nil, fmt.Errorf("unclosed { in %q", args)
+		}
+		w, err := parseWatchExpr(msg[open+1:open+end], c.Symbols)
+		if err != nil {
+			return nil, err
+		}
+		literals = append(literals, msg[:open])
+		exprs = append(exprs, w)
+		msg = msg[open+end+1:]
+	}
+
+	return func(c *Chip8, pc uint16) (bool, error) {
+		var b strings.Builder
+		for i, w := range exprs {
+			v, err := w.root.eval(c)
+			if err != nil {
+				return false, err
+			}
+			fmt.Fprintf(&b, "%s%d", literals[i], v)
+		}
+		b.WriteString(msg)
+		fmt.Fprintf(c.Debugger.out, "%04X: %s\n", pc, b.String())
+		return false, nil
+	}, nil
+}
+
+// parseDumpAction writes a memory range to a new timestamped file on each hit
+func parseDumpAction(c *Chip8, args string) (func(*Chip8, uint16) (bool, error), error) {
+	fields := strings.Fields(args)
+	if len(fields) != 2 {
+		return nil, fmt.Errorf("expected a start and end address")
+	}
+	start, err := c.debugAddr(fields[0])
+	if err != nil {
+		return nil, err
+	}
+	end, err := c.debugAddr(fields[1])
+	if err != nil {
+		return nil, err
+	}
+
+	return func(c *Chip8, pc uint16) (bool, error) {
+		binFile := fmt.Sprintf("%s-%d.bin", userFile("dumps", "break", ""), c.Debugger.hits[pc])
+		if _, err := c.DumpMemory(start, end, binFile); err != nil {
+			return false, err
+		}
+		fmt.Fprintf(c.Debugger.out, "%04X: dumped %03X-%03X to %s\n", pc, start, end, binFile)
+		return false, nil
+	}, nil
+}
+
+// parseCountAction counts hits silently; the counts command lists them
+func parseCountAction(c *Chip8, args string) (func(*Chip8, uint16) (bool, error), error) {
+	if args != "" {
+		return nil, fmt.Errorf("takes no arguments")
+	}
+	return func(*Chip8, uint16) (bool, error) { return false, nil }, nil
+}
+
+// parseTraceAction turns tracing on or off, or toggles it, writing to a new file when the
+// emulator was started without -trace
+func parseTraceAction(c *Chip8, args string) (func(*Chip8, uint16) (bool, error), error) {
+	if args != "" && args != "on" && args != "off" {
+		return nil, fmt.Errorf("expected on, off or nothing to toggle")
+	}
+
+	return func(c *Chip8, pc uint16) (bool, error) {
+		on := c.untrace == nil
+		if args != "" {
+			on = args == "on"
+		}
+		if on && c.Trace == nil {
+			file := userFile("traces", "trace", ".log")
+			f, err := os.Create(file)
+			if err != nil {
+				return false, err
+			}
+			log.Printf("tracing to %s", file)
+			c.Trace = f
+		}
+		c.SetTracing(on)
+		return false, nil
+	}, nil
+}
+
+func parseStopAction(c *Chip8, args string) (func(*Chip8, uint16) (bool, error), error) {
+	if args != "" {
+		return nil, fmt.Errorf("takes no arguments")
+	}
+	return func(*Chip8, uint16) (bool, error) { return true, nil }, nil
+}
+
+func debugOn(c *Chip8, args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("usage: %s", debugCommands["on"].usage)
+	}
+	addr, err := c.debugAddr(args[0])
+	if err != nil {
+		return err
+	}
+	actions, err := c.parseBreakActions(strings.Join(args[1:], " "))
+	if err != nil {
+		return err
+	}
+
+	d := c.Debugger
+	d.Breakpoints[uint16(addr)] = true
+	d.Actions[uint16(addr)] = actions
+	fmt.Fprintf(d.out, "breakpoint at %04X with %d actions\n", addr, len(actions))
+	return nil
+}
+
+func debugCounts(c *Chip8, args []string) error {
+	d := c.Debugger
+	addrs := make([]int, 0, len(d.Breakpoints))
+	for addr := range d.Breakpoints {
+		addrs = append(addrs, int(addr))
+	}
+	sort.Ints(addrs)
+
+	for _, addr := range addrs {
+		fmt.Fprintf(d.out, "  %04X %6d hits", addr, d.hits[uint16(addr)])
+		if where := c.Symbols.Describe(uint16(addr)); where != "" {
+			fmt.Fprintf(d.out, " (%s)", where)
+		}
+		for _, a := range d.Actions[uint16(addr)] {
+			fmt.Fprintf(d.out, "  [%s]", a.text)
+		}
+		fmt.Fprintln(d.out)
+	}
+	return nil
+}
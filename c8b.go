@@ -0,0 +1,358 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image/color"
+	"io"
+	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// A .c8b file is a CHIP-8 binary container: one ROM, or builds of it for several platforms,
+// with a list of properties saying how it should be run. Numbers are big-endian:
+//
+//	offset size
+//	0      3    magic "CBF"
+//	3      1    format version, 0
+//	4      2    offset of the property list, 0 when there is none
+//	6           bytecode table, up to the first bytecode or the property list, of entries:
+//	              1 platform, see c8bPlatforms
+//	              2 offset of the bytecode
+//	              2 length of the bytecode
+//
+// The property list runs up to the next bytecode after it or the end of the file, in entries of
+// a tag, a length byte and that many bytes of data. The tags read here are the constants below;
+// others, such as the description or cover art, are skipped. Tags from 0x80 are this emulator's
+// own, for settings the format has no place for; other readers skip them in turn.
+
+const c8bMagic = "CBF"
+
+// c8bPlatforms maps the platform ids of the bytecode table to the variants that run them. The
+// first bytecode of a platform listed here is the one loaded.
+var c8bPlatforms = map[byte]Variant{
+	0x00: VariantChip8,
+	0x04: VariantChip8X,
+	0x06: VariantSChip, // SUPER-CHIP 1.0
+	0x07: VariantSChip, // SUPER-CHIP 1.1
+	0x09: VariantXOChip,
+}
+
+// property tags
+const (
+	c8bName        = 0x00
+	c8bAuthors     = 0x02
+	c8bReleaseDate = 0x04
+	c8bKeyMap      = 0x06
+	c8bPalette     = 0x07
+
+	// instructions per frame, 2 bytes
+	c8bSpeed = 0x80
+
+	// a byte of quirk bits in the order save states keep them, see stateQuirkBits
+	c8bQuirks = 0x81
+)
+
+// c8bBundle is what a .c8b file says about its ROM besides the bytecode
+type c8bBundle struct {
+	Variant Variant
+
+	// Released is zero when the file does not say
+	Name, Authors string
+	Released      time.Time
+
+	// CHIP-8 key to the name of the keyboard key playing it, from pairs of a key and an ASCII
+	// character
+	KeyMap map[byte]string
+
+	// background then foreground; XO-CHIP plane colours after them are ignored
+	Palette []color.RGBA
+
+	// from this emulator's own tags, zero and nil when absent
+	Speed  int
+	Quirks *Quirks
+}
+
+// readC8B extracts the bytecode to run and its settings from a .c8b file
+func readC8B(data []byte) ([]byte, *c8bBundle, error) {
+	if len(data) < 6 || string(data[:3]) != c8bMagic {
+		return nil, nil, errors.New("not a CHIP-8 binary, expected it to start with CBF")
+	}
+	if data[3] != 0 {
+		return nil, nil, fmt.Errorf("CHIP-8 binary version %d is not supported", data[3])
+	}
+	props := int(binary.BigEndian.Uint16(data[4:]))
+	if props > len(data) {
+		return nil, nil, fmt.Errorf("property list at %#x is past the end of the file", props)
+	}
+
+	// the table ends where the first data it points at begins
+	end := len(data)
+	if props != 0 {
+		end = props
+	}
+	var chosen []byte
+	b := &c8bBundle{}
+	var offsets []int
+	for pos := 6; pos+5 <= end; pos += 5 {
+		platform := data[pos]
+		offset := int(binary.BigEndian.Uint16(data[pos+1:]))
+		length := int(binary.BigEndian.Uint16(data[pos+3:]))
+		if offset < pos+5 || offset+length > len(data) {
+			return nil, nil, fmt.Errorf("bytecode for platform %#02x at %#x+%d is outside the file", platform, offset, length)
+		}
+		end = min(end, offset)
+		offsets = append(offsets, offset)
+
+		if v, ok := c8bPlatforms[platform]; ok && chosen == nil {
+			chosen, b.Variant = data[offset:offset+length], v
+		}
+	}
+	if chosen == nil {
+		return nil, nil, errors.New("CHIP-8 binary holds no bytecode for a platform this emulator runs")
+	}
+
+	if props != 0 {
+		propsEnd := len(data)
+		for _, offset := range offsets {
+			if offset > props {
+				propsEnd = min(propsEnd, offset)
+			}
+		}
+		if err := b.readProperties(data[props:propsEnd]); err != nil {
+			return nil, nil, err
+		}
+	}
+	return chosen, b, nil
+}
+
+// readProperties decodes the property list
+func (b *c8bBundle) readProperties(list []byte) error {
+	for len(list) > 0 {
+		if len(list) < 2 || len(list) < 2+int(list[1]) {
+			return errors.New("CHIP-8 binary property list is cut short")
+		}
+		tag, value := list[0], list[2:2+int(list[1])]
+		list = list[2+len(value):]
+
+		switch tag {
+		case c8bName:
+			b.Name = string(value)
+		case c8bAuthors:
+			b.Authors = string(value)
+		case c8bReleaseDate:
+			if len(value) != 4 {
+				return fmt.Errorf("CHIP-8 binary release date is %d bytes, expected 4", len(value))
+			}
+			b.Released = time.Unix(int64(binary.BigEndian.Uint32(value)), 0).UTC()
+		case c8bKeyMap:
+			if len(value)%2 != 0 {
+				return errors.New("CHIP-8 binary key map has a key without a keyboard key")
+			}
+			b.KeyMap = map[byte]string{}
+			for i := 0; i < len(value); i += 2 {
+				if value[i] > 0xF {
+					return fmt.Errorf("CHIP-8 binary key map binds key 0x%X, keys range from 0x0 to 0xF", value[i])
+				}
+				b.KeyMap[value[i]] = string(rune(value[i+1]))
+			}
+		case c8bPalette:
+			if len(value)%3 != 0 {
+				return errors.New("CHIP-8 binary palette is not a list of RGB colours")
+			}
+			for i := 0; i < len(value); i += 3 {
+				b.Palette = append(b.Palette, color.RGBA{value[i], value[i+1], value[i+2], 255})
+			}
+		case c8bSpeed:
+			if len(value) != 2 {
+				return fmt.Errorf("CHIP-8 binary speed is %d bytes, expected 2", len(value))
+			}
+			b.Speed = int(binary.BigEndian.Uint16(value))
+		case c8bQuirks:
+			if len(value) != 1 {
+				return fmt.Errorf("CHIP-8 binary quirks are %d bytes, expected 1", len(value))
+			}
+			b.Quirks = &Quirks{}
+			for i, quirk := range stateQuirkBits(b.Quirks) {
+				*quirk = value[0]&(1<<i) != 0
+			}
+		}
+	}
+	return nil
+}
+
+// applyC8B uses the settings of a .c8b file over those picked for its ROM. Its name and authors
+// are shown like a database entry unless the database knows the ROM already.
+func (c *Chip8) applyC8B(name string, b *c8bBundle) {
+	// a database entry for the same variant may have tuned its quirks
+	if c.Variant != b.Variant {
+		c.SetVariant(b.Variant)
+	}
+
+	if b.Quirks != nil {
+		c.Quirks = *b.Quirks
+	}
+	if b.Speed > 0 {
+		c.CyclesPerFrame = b.Speed
+	}
+
+	if len(b.Palette) >= 2 {
+		c.ColorOff, c.ColorOn = b.Palette[0], b.Palette[1]
+	}
+
+	for chip8Key, keyName := range b.KeyMap {
+		button, ok := parseButton(keyName)
+		if !ok {
+			log.Printf("%s: key map: no keyboard key %q for 0x%X", name, keyName, chip8Key)
+			continue
+		}
+		c.BindKey(button, chip8Key)
+	}
+
+	if c.romCard.info == nil && b.Name != "" {
+		info := &RomInfo{Title: b.Name, Author: b.Authors, Variant: b.Variant.String()}
+		if !b.Released.IsZero() {
+			info.Year = b.Released.Year()
+		}
+		c.romCard.info = info
+		c.romCard.show()
+	}
+	log.Printf("%s: using %s and the settings in the CHIP-8 binary", name, b.Variant)
+}
+
+// writeC8B writes a .c8b file holding rom, to run as the bundle describes: its variant, name,
+// authors, key map, palette, speed and quirks. Keys with names longer than a character, such as
+// the arrow keys, have no place in the format and are left out.
+func writeC8B(w io.Writer, rom []byte, b *c8bBundle) error {
+	platform := -1
+	for id, v := range c8bPlatforms {
+		if v == b.Variant && (platform < 0 || byte(id) > byte(platform)) {
+			platform = int(id)
+		}
+	}
+	if platform < 0 {
+		return fmt.Errorf("no CHIP-8 binary platform runs %s", b.Variant)
+	}
+	if len(rom) > 0xFFFF-11 {
+		return fmt.Errorf("ROM of %d bytes is too big for a CHIP-8 binary", len(rom))
+	}
+
+	var props []byte
+	prop := func(tag byte, value []byte) {
+		if len(value) > 0xFF {
+			value = value[:0xFF]
+		}
+		props = append(append(props, tag, byte(len(value))), value...)
+	}
+	if b.Name != "" {
+		prop(c8bName, []byte(b.Name))
+	}
+	if b.Authors != "" {
+		prop(c8bAuthors, []byte(b.Authors))
+	}
+	if !b.Released.IsZero() {
+		prop(c8bReleaseDate, binary.BigEndian.AppendUint32(nil, uint32(b.Released.Unix())))
+	}
+
+	var keys []byte
+	for _, chip8Key := range slices.Sorted(maps.Keys(b.KeyMap)) {
+		keys = append(keys, chip8Key, b.KeyMap[chip8Key][0])
+	}
+	if len(keys) > 0 {
+		prop(c8bKeyMap, keys)
+	}
+
+	var palette []byte
+	for _, col := range b.Palette {
+		palette = append(palette, col.R, col.G, col.B)
+	}
+	if len(palette) > 0 {
+		prop(c8bPalette, palette)
+	}
+
+	if b.Speed > 0 {
+		prop(c8bSpeed, binary.BigEndian.AppendUint16(nil, uint16(min(b.Speed, 0xFFFF))))
+	}
+	if b.Quirks != nil {
+		var bits byte
+		for i, quirk := range stateQuirkBits(b.Quirks) {
+			if *quirk {
+				bits |= 1 << i
+			}
+		}
+		prop(c8bQuirks, []byte{bits})
+	}
+
+	// header and a one entry bytecode table, then the bytecode and the properties after it
+	const romAt = 11
+	propsAt := romAt + len(rom)
+	if len(props) == 0 || propsAt > 0xFFFF {
+		propsAt = 0
+	}
+	file := []byte(c8bMagic)
+	file = append(file, 0)
+	file = binary.BigEndian.AppendUint16(file, uint16(propsAt))
+	file = append(file, byte(platform))
+	file = binary.BigEndian.AppendUint16(file, romAt)
+	file = binary.BigEndian.AppendUint16(file, uint16(len(rom)))
+	file = append(file, rom...)
+	if propsAt != 0 {
+		file = append(file, props...)
+	}
+	_, err := w.Write(file)
+	return err
+}
+
+// ExportC8B writes the running ROM with the settings it runs with now to a .c8b file, so the
+// game can be shared set up as it is
+func (c *Chip8) ExportC8B(file string) error {
+	if len(c.rom) == 0 {
+		return errors.New("no ROM loaded to export")
+	}
+
+	q := c.Quirks
+	b := &c8bBundle{
+		Variant: c.Variant,
+		Name:    c.romBaseName(),
+		KeyMap:  map[byte]string{},
+		Palette: []color.RGBA{c.ColorOff, c.ColorOn},
+		Speed:   c.CyclesPerFrame,
+		Quirks:  &q,
+	}
+	if info := c.romCard.info; info != nil {
+		b.Name, b.Authors = info.Title, info.Author
+		if info.Year != 0 {
+			b.Released = time.Date(info.Year, time.January, 1, 0, 0, 0, 0, time.UTC)
+		}
+	}
+	for button, chip8Key := range c.KeyMap {
+		// several keyboard keys may play one CHIP-8 key; keep one of them, the same every time
+		if name := button.String(); len(name) == 1 && (b.KeyMap[chip8Key] == "" || name < b.KeyMap[chip8Key]) {
+			b.KeyMap[chip8Key] = name
+		}
+	}
+
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	if err := writeC8B(f, c.rom, b); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// romBaseName is the running ROM's file name without its directory or extension, or "game"
+func (c *Chip8) romBaseName() string {
+	if c.RomFile == "" {
+		return "game"
+	}
+	return strings.TrimSuffix(filepath.Base(c.RomFile), filepath.Ext(c.RomFile))
+}
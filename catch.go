@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// catchpoint pauses before any instruction matching an opcode pattern, wherever it is, for
+// finding where a behaviour comes from without knowing the address. Patterns are written the
+// way opcodes are documented, DXYN or FX0A: hex digits must match and X, Y and N match
+// anything. Patterns ending in NNN can be limited to addresses in a range, e.g. 2NNN 400-4FF
+// for calls into that part of the program.
+type catchpoint struct {
+	pattern     string
+	mask, value uint16
+
+	// the range the NNN field must fall in, both ends included, when ranged
+	ranged bool
+	lo, hi uint16
+}
+
+// parseCatchpoint reads a pattern and an optional range argument, lo-hi as addresses or labels
+func (c *Chip8) parseCatchpoint(args []string) (catchpoint, error) {
+	p := catchpoint{pattern: strings.ToUpper(args[0])}
+	if len(p.pattern) != 4 {
+		return p, fmt.Errorf("pattern %q is not four digits such as DXYN", args[0])
+	}
+	for _, r := range p.pattern {
+		p.mask, p.value = p.mask<<4, p.value<<4
+		switch {
+		case r >= '0' && r <= '9':
+			p.mask, p.value = p.mask|0xF, p.value|uint16(r-'0')
+		case r >= 'A' && r <= 'F':
+			p.mask, p.value = p.mask|0xF, p.value|uint16(r-'A'+10)
+		case r == 'X' || r == 'Y' || r == 'N':
+		default:
+			return p, fmt.Errorf("pattern %q: %q is not a hex digit, X, Y or N", args[0], r)
+		}
+	}
+
+	if len(args) == 1 {
+		return p, nil
+	}
+	if !strings.HasSuffix(p.pattern, "NNN") {
+		return p, fmt.Errorf("only patterns ending in NNN take an address range")
+	}
+	lo, hi, ok := strings.Cut(args[1], "-")
+	if !ok {
+		return p, fmt.Errorf("range %q is not lo-hi", args[1])
+	}
+	from, err := c.debugAddr(lo)
+	if err != nil {
+		return p, err
+	}
+	to, err := c.debugAddr(hi)
+	if err != nil {
+		return p, err
+	}
+	if from > to {
+		from, to = to, from
+	}
+	p.ranged, p.lo, p.hi = true, uint16(from), uint16(to)
+	return p, nil
+}
+
+func (p catchpoint) matches(opcode uint16) bool {
+	if opcode&p.mask != p.value {
+		return false
+	}
+	nnn := opcode & 0x0FFF
+	return !p.ranged || nnn >= p.lo && nnn <= p.hi
+}
+
+func (p catchpoint) String() string {
+	if p.ranged {
+		return fmt.Sprintf("%s %03X-%03X", p.pattern, p.lo, p.hi)
+	}
+	return p.pattern
+}
+
+// caught reports the first catchpoint the opcode about to run at pc matches, pausing on it
+func (c *Chip8) caught(pc uint16) bool {
+	d := c.Debugger
+	opcode := c.word(pc)
+	for _, p := range d.Catches {
+		if p.matches(opcode) {
+			d.Paused = true
+			fmt.Fprintf(d.out, "caught %s at %04X: %s\n", p, pc, Mnemonic(opcode, c.Symbols))
+			c.printWatches()
+			return true
+		}
+	}
+	return false
+}
+
+// debugCatch adds a catchpoint, or lists them with no arguments
+func debugCatch(c *Chip8, args []string) error {
+	d := c.Debugger
+	switch len(args) {
+	case 0:
+		if len(d.Catches) == 0 {
+			fmt.Fprintln(d.out, "no catchpoints")
+		}
+		for _, p := range d.Catches {
+			fmt.Fprintf(d.out, "  %s\n", p)
+		}
+		return nil
+	case 1, 2:
+	default:
+		return fmt.Errorf("usage: %s", debugCommands["catch"].usage)
+	}
+
+	p, err := c.parseCatchpoint(args)
+	if err != nil {
+		return err
+	}
+	d.Catches = append(d.Catches, p)
+	fmt.Fprintf(d.out, "catching %s\n", p)
+	return nil
+}
+
+// debugUncatch removes the catchpoints with a pattern, or all of them
+func debugUncatch(c *Chip8, args []string) error {
+	d := c.Debugger
+	switch len(args) {
+	case 0:
+		d.Catches = nil
+		fmt.Fprintln(d.out, "deleted all catchpoints")
+		return nil
+	case 1:
+	default:
+		return fmt.Errorf("usage: %s", debugCommands["uncatch"].usage)
+	}
+
+	pattern := strings.ToUpper(args[0])
+	kept := d.Catches[:0]
+	for _, p := range d.Catches {
+		if p.pattern != pattern {
+			kept = append(kept, p)
+		}
+	}
+	if len(kept) == len(d.Catches) {
+		return fmt.Errorf("no catchpoint on %s", pattern)
+	}
+	fmt.Fprintf(d.out, "deleted %d catchpoints on %s\n", len(d.Catches)-len(kept), pattern)
+	d.Catches = kept
+	return nil
+}
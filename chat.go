@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// chatQueueSize caps the commands waiting to be played; more arriving while it is full are
+// dropped, so a busy chat cannot build up minutes of backlog
+const chatQueueSize = 16
+
+// chatCommand is a key sequence sent by a chat user, in parseMacro syntax
+type chatCommand struct {
+	user, text string
+	macro      *inputMacro
+}
+
+// chatInput plays key sequences typed in a chat channel, one after another, for "chat plays"
+// streams. Commands are read on their own goroutine and handed to the emulator loop through a
+// channel.
+type chatInput struct {
+	commands chan chatCommand
+
+	// minimum time between two commands from the same user, and the longest sequence accepted
+	cooldown  time.Duration
+	maxFrames int
+	lastSeen  map[string]time.Time
+
+	current *inputMacro
+}
+
+// dialChat connects to a chat given as "irc://[nick[:password]@]host:port/#channel", e.g. Twitch
+// chat at irc.chat.twitch.tv:6667, or "tcp://host:port" for a plain text feed with a command per
+// line, optionally prefixed with "user: "
+func dialChat(rawURL string, cooldown time.Duration, maxFrames int) (*chatInput, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	ci := &chatInput{
+		commands:  make(chan chatCommand, chatQueueSize),
+		cooldown:  cooldown,
+		maxFrames: maxFrames,
+		lastSeen:  map[string]time.Time{},
+	}
+
+	switch u.Scheme {
+	case "irc":
+		channel := "#" + strings.TrimLeft(u.Fragment+strings.TrimPrefix(u.Path, "/"), "#")
+		if channel == "#" {
+			return nil, fmt.Errorf("%s: no channel to join", rawURL)
+		}
+		conn, err := net.Dial("tcp", u.Host)
+		if err != nil {
+			return nil, err
+		}
+
+		// Twitch lets anyone read chat as justinfan followed by digits
+		nick := u.User.Username()
+		if nick == "" {
+			nick = fmt.Sprintf("justinfan%d", time.Now().Unix()%100000)
+		}
+		pass, _ := u.User.Password()
+		go ci.readIRC(conn, nick, pass, channel)
+		log.Printf("chat: joining %s on %s as %s", channel, u.Host, nick)
+
+	case "tcp":
+		conn, err := net.Dial("tcp", u.Host)
+		if err != nil {
+			return nil, err
+		}
+		go ci.readLines(conn)
+		log.Printf("chat: reading commands from %s", u.Host)
+
+	default:
+		return nil, fmt.Errorf("unsupported chat %q, want irc:// or tcp://", rawURL)
+	}
+	return ci, nil
+}
+
+// readIRC logs in, joins channel and offers every message sent to it
+func (ci *chatInput) readIRC(conn net.Conn, nick, pass, channel string) {
+	defer conn.Close()
+
+	if pass != "" {
+		fmt.Fprintf(conn, "PASS %s\r\n", pass)
+	}
+	fmt.Fprintf(conn, "NICK %s\r\nJOIN %s\r\n", nick, channel)
+
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if server, ok := strings.CutPrefix(line, "PING "); ok {
+			fmt.Fprintf(conn, "PONG %s\r\n", server)
+			continue
+		}
+
+		// :nick!user@host PRIVMSG #channel :text
+		prefix, rest, ok := strings.Cut(line, " PRIVMSG ")
+		if !ok {
+			continue
+		}
+		_, text, ok := strings.Cut(rest, " :")
+		if !ok {
+			continue
+		}
+		user, _, _ := strings.Cut(strings.TrimPrefix(prefix, ":"), "!")
+		ci.offer(user, text)
+	}
+	ci.closed(scanner.Err())
+}
+
+// readLines offers each line of a plain text feed
+func (ci *chatInput) readLines(r io.ReadCloser) {
+	defer r.Close()
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		user, text, ok := strings.Cut(scanner.Text(), ": ")
+		if !ok {
+			user, text = "", user
+		}
+		ci.offer(user, text)
+	}
+	ci.closed(scanner.Err())
+}
+
+func (ci *chatInput) closed(err error) {
+	if err != nil {
+		log.Printf("chat: %v", err)
+	}
+	log.Printf("chat: connection closed")
+}
+
+// offer queues a message that parses as a key sequence, unless its sender is still cooling down
+// or the queue is full. Other chatter is ignored.
+func (ci *chatInput) offer(user, text string) {
+	text = strings.TrimSpace(text)
+	m, err := parseMacro(text)
+	if err != nil || len(m.steps) > ci.maxFrames {
+		return
+	}
+
+	now := time.Now()
+	if now.Sub(ci.lastSeen[user]) < ci.cooldown {
+		return
+	}
+	ci.lastSeen[user] = now
+
+	select {
+	case ci.commands <- chatCommand{user, text, m}:
+	default:
+	}
+}
+
+// Apply plays the current command into this frame's input, starting the next queued one once it
+// has finished
+func (ci *chatInput) Apply(c *Chip8) {
+	if ci.current == nil || !ci.current.playing {
+		select {
+		case cmd := <-ci.commands:
+			next := cmd.macro
+			next.playing = true
+			if ci.current != nil {
+				// so the keys the last command ended on are released
+				next.last = ci.current.last
+			}
+			ci.current = next
+			if cmd.user != "" {
+				c.Notify("%s: %s", cmd.user, cmd.text)
+			} else {
+				c.Notify("%s", cmd.text)
+			}
+		default:
+		}
+	}
+
+	if ci.current != nil {
+		ci.current.step(c)
+	}
+}
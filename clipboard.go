@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// parseHexProgram reads bytes written as hex the way snippets are posted: "6005 A20A D015",
+// "0x60, 0x05" or "$60 $05", reporting false if s is anything else
+func parseHexProgram(s string) ([]byte, bool) {
+	var digits strings.Builder
+	fields := strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\n' || r == '\r'
+	})
+	for _, f := range fields {
+		f = strings.TrimPrefix(strings.TrimPrefix(strings.TrimPrefix(f, "0x"), "0X"), "$")
+		if len(f)%2 != 0 {
+			return nil, false
+		}
+		digits.WriteString(f)
+	}
+
+	data, err := hex.DecodeString(digits.String())
+	if err != nil || len(data) == 0 {
+		return nil, false
+	}
+	return data, true
+}
+
+// parsePastedProgram turns pasted text into a ROM: hex bytes, or failing that Octo source
+func parsePastedProgram(s string) (rom []byte, syms *SymbolTable, octo bool, err error) {
+	if data, ok := parseHexProgram(s); ok {
+		return data, nil, false, nil
+	}
+
+	rom, syms, err = assembleOcto(s)
+	if err != nil {
+		return nil, nil, true, fmt.Errorf("neither hex bytes nor Octo source: %w", err)
+	}
+	return rom, syms, true, nil
+}
+
+// LoadClipboard restarts the machine with a program pasted from the clipboard, for trying out
+// snippets from forums and documentation without saving them to a file first
+func (c *Chip8) LoadClipboard() {
+	rom, syms, octo, err := parsePastedProgram(c.Screen.ClipboardText())
+	if err == nil && len(rom) > len(c.MainMemory)-int(RamGameStart) {
+		err = fmt.Errorf("%d bytes do not fit in memory", len(rom))
+	}
+	if err != nil {
+		log.Printf("paste: %v", err)
+		c.Notify("Nothing to load in the clipboard")
+		return
+	}
+
+	name := "clipboard.ch8"
+	if octo {
+		name = "clipboard.8o"
+	}
+	c.Reset()
+	c.configureForRom(name, rom)
+	c.LoadRom(rom)
+	c.Symbols, c.RomFile = syms, ""
+
+	log.Printf("loaded %d bytes from the clipboard", len(rom))
+	c.Notify("Loaded %d bytes from the clipboard", len(rom))
+}
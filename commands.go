@@ -0,0 +1,270 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gopxl/pixel/v2/backends/opengl"
+)
+
+// subcommand is a "chip8 <name>" command with its own flags
+type subcommand struct {
+	usage string
+	help  string
+	run   func(args []string) error
+}
+
+// subcommands is filled in by init so commands can refer back to the table (help does)
+var subcommands map[string]subcommand
+
+func init() {
+	subcommands = map[string]subcommand{
+		"run":         {"run [flags] [rom ...]", "play a ROM, or several in windows side by side; the default when no command is given", runCommand},
+		"debug":       {"debug [flags] [rom]", "play a ROM with debugger commands read from stdin", debugRunCommand},
+		"teach":       {"teach [flags] [rom]", "run a ROM, or a tutorial with captions, slowly in the terminal, showing how each instruction is fetched, decoded and executed", teachCommand},
+		"record":      {"record [flags] [rom]", "play a ROM, recording input for run -replay", recordCommand},
+		"attract":     {"attract [flags] <playlist.txt | rom...>", "cycle through ROMs unattended, for exhibitions", attractCommand},
+		"compare":     {"compare [flags] <rom>", "run a ROM with two quirk presets side by side and report where they diverge", compareCommand},
+		"serve":       {"serve [flags] <rom>", "run a ROM without a window, showing its display and taking input over HTTP", serveCommand},
+		"ssh":         {"ssh [flags] <rom | dir>...", "serve a menu of ROMs to play in the terminal over SSH, e.g. ssh -p 2222 play@host", sshCommand},
+		"ansi":        {"ansi [flags] <rom>", "run a ROM without a window, writing its display to stdout as half-block text for pipes, logs and asciinema", ansiCommand},
+		"fb":          {"fb [flags] <rom>", "run a ROM full-screen on a Linux framebuffer with evdev keys, without X11 or OpenGL", framebufferCommand},
+		"bench":       {"bench [flags] <rom>", "run a ROM headless as fast as possible and report the speed", benchCommand},
+		"selftest":    {"selftest [flags] <rom>", "run a test ROM headless to completion and check its display, for scripts", selftestCommand},
+		"stress":      {"stress [flags]", "run random ROMs headless and log any that panic, fault or hang", stressCommand},
+		"asm":         {"asm [flags] <source.8o>", "assemble Octo source to a ROM and symbol file", asmCommand},
+		"transpile":   {"transpile [flags] <rom>", "compile a ROM to Go source for a build that runs it natively", transpileCommand},
+		"disasm":      {"disasm [flags] <rom>", "write an annotated disassembly to stdout", disasmCommand},
+		"analyze":     {"analyze [flags] <rom>", "report opcode usage, features and suspicious code", analyzeCommand},
+		"trim":        {"trim [flags] <rom> [out]", "strip padding and unreachable bytes from the end of a ROM and report the saving, writing out when given", trimCommand},
+		"state":       {"state <in> [out]", "convert a save state between the binary and JSON formats, printing JSON with no out", stateCommand},
+		"divergence":  {"divergence [flags] <traceA> <traceB>", "find where two -trace logs first differ", divergenceCommand},
+		"sprites":     {"sprites [flags] <rom> <sheet.png>", "export the sprites a ROM draws, and data the analyzer finds, as a labelled PNG sprite sheet", spritesCommand},
+		"sprite-edit": {"sprite-edit [file]", "draw a sprite and export it as bytes", spriteEditCommand},
+		"png-sprites": {"png-sprites [flags] <image.png>", "convert a black and white drawing into sprite bytes as hex, db lines or Octo source", pngSpritesCommand},
+		"font-edit":   {"font-edit [file]", "edit the 16 glyphs of the hex font", fontEditCommand},
+		"help":        {"help [command]", "list commands or describe one", helpCommand},
+	}
+}
+
+// runSubcommand dispatches the command line. Anything that isn't a command name is taken as
+// arguments to run, so "chip8 game.ch8" and "chip8 -trace t.log game.ch8" keep working.
+func runSubcommand(args []string) error {
+	if len(args) > 0 {
+		if cmd, ok := subcommands[args[0]]; ok {
+			return cmd.run(args[1:])
+		}
+		if args[0] == "-h" || args[0] == "-help" || args[0] == "--help" {
+			return helpCommand(nil)
+		}
+	}
+	return runCommand(args)
+}
+
+// newFlagSet creates the flag set for a subcommand, with usage taken from the command table
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() { commandUsage(fs, name) }
+	return fs
+}
+
+func commandUsage(fs *flag.FlagSet, name string) {
+	cmd := subcommands[name]
+	fmt.Fprintf(fs.Output(), "usage: chip8 %s\n\n%s\n", cmd.usage, cmd.help)
+
+	hasFlags := false
+	fs.VisitAll(func(*flag.Flag) { hasFlags = true })
+	if hasFlags {
+		fmt.Fprintln(fs.Output(), "\nflags:")
+		fs.PrintDefaults()
+	}
+}
+
+// usageError reports wrong arguments to a subcommand
+func usageError(name string) error {
+	return fmt.Errorf("usage: chip8 %s", subcommands[name].usage)
+}
+
+func helpCommand(args []string) error {
+	if len(args) == 1 {
+		if _, ok := subcommands[args[0]]; !ok {
+			return fmt.Errorf("unknown command %q, see chip8 help", args[0])
+		}
+		return subcommands[args[0]].run([]string{"-h"})
+	}
+
+	names := make([]string, 0, len(subcommands))
+	for name := range subcommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Println("usage: chip8 <command> [flags] [args]")
+	fmt.Println()
+	for _, name := range names {
+		fmt.Printf("  %-12s %s\n", name, subcommands[name].help)
+	}
+	fmt.Println("\nchip8 help <command> describes a command and its flags")
+	return nil
+}
+
+// parseRunFlags prepares the emulator flags shared by run, debug and record, which take at most
+// maxRoms ROMs
+func parseRunFlags(name string, args []string, maxRoms int) error {
+	runFlags.Init(name, flag.ContinueOnError)
+	runFlags.Usage = func() { commandUsage(runFlags, name) }
+	if err := runFlags.Parse(args); err != nil {
+		return err
+	}
+	if runFlags.NArg() > maxRoms {
+		return usageError(name)
+	}
+	return nil
+}
+
+func runCommand(args []string) error {
+	if err := parseRunFlags("run", args, math.MaxInt); err != nil {
+		return err
+	}
+	if runFlags.NArg() > 1 {
+		if err := checkInstanceFlags(); err != nil {
+			return err
+		}
+	}
+	opengl.Run(run)
+	return nil
+}
+
+// debugRunCommand is run with -debug always on
+func debugRunCommand(args []string) error {
+	if err := parseRunFlags("debug", args, 1); err != nil {
+		return err
+	}
+	*debugEnabled = true
+	opengl.Run(run)
+	return nil
+}
+
+// recordCommand is run with -record defaulting to a timestamped file named after the ROM
+func recordCommand(args []string) error {
+	if err := parseRunFlags("record", args, 1); err != nil {
+		return err
+	}
+	if *recordFile == "" {
+		name := "input"
+		if runFlags.NArg() > 0 {
+			name = strings.TrimSuffix(filepath.Base(runFlags.Arg(0)), filepath.Ext(runFlags.Arg(0)))
+		}
+		*recordFile = userFile("recordings", name, ".keys")
+	}
+	opengl.Run(run)
+	return nil
+}
+
+// asmCommand implements "chip8 asm prog.8o", writing prog.ch8 and prog.sym
+func asmCommand(args []string) error {
+	fs := newFlagSet("asm")
+	out := fs.String("o", "", "ROM to write, defaults to the source name with a .ch8 extension")
+	symOut := fs.String("sym", "", "symbol file to write, defaults to the ROM name with a .sym extension")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return usageError("asm")
+	}
+
+	src, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	rom, syms, err := assembleOcto(string(src))
+	if err != nil {
+		return fmt.Errorf("%s: %w", fs.Arg(0), err)
+	}
+
+	if *out == "" {
+		*out = strings.TrimSuffix(fs.Arg(0), filepath.Ext(fs.Arg(0))) + ".ch8"
+	}
+	if *symOut == "" {
+		*symOut = strings.TrimSuffix(*out, filepath.Ext(*out)) + ".sym"
+	}
+
+	if err := os.WriteFile(*out, rom, 0o644); err != nil {
+		return err
+	}
+
+	f, err := os.Create(*symOut)
+	if err != nil {
+		return err
+	}
+	if err := syms.Write(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	fmt.Printf("wrote %d bytes to %s, symbols to %s\n", len(rom), *out, *symOut)
+	return nil
+}
+
+// benchCommand implements "chip8 bench rom.ch8", running frames headless without waiting for
+// the display
+func benchCommand(args []string) error {
+	fs := newFlagSet("bench")
+	frames := fs.Uint64("frames", 3600, "frames to run")
+	cycles := fs.Int("cycles", CyclesToExecute, "instructions per frame")
+	variant := fs.String("variant", "", "interpreter variant, defaults to the one detected for the ROM")
+	blocks := fs.Bool("blocks", true, "execute basic blocks from the block cache rather than single instructions")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return usageError("bench")
+	}
+
+	img, err := readRomFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	c := newMachine()
+	c.LoadDefaultSprites()
+	c.configureForImage(img)
+	c.LoadRom(img.Data)
+	c.SetSeed(1)
+	c.CyclesPerFrame = *cycles
+	c.UseBlocks = *blocks
+
+	if *variant != "" {
+		v, err := ParseVariant(*variant)
+		if err != nil {
+			return err
+		}
+		c.SetVariant(v)
+	}
+
+	start := time.Now()
+	for c.Frame < *frames && c.Fault == nil {
+		c.StepFrame()
+	}
+	elapsed := time.Since(start)
+	if c.Fault != nil {
+		return c.Fault
+	}
+
+	instructions := c.Frame * uint64(c.CyclesPerFrame)
+	fmt.Printf("%d frames, %d instructions in %v\n", c.Frame, instructions, elapsed.Round(time.Microsecond))
+	if secs := elapsed.Seconds(); secs > 0 {
+		fmt.Printf("%.0f instructions/s, %.0f frames/s, %.1fx real time\n",
+			float64(instructions)/secs, float64(c.Frame)/secs, float64(c.Frame)/secs/60)
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/gopxl/pixel/v2"
+	"github.com/gopxl/pixel/v2/backends/opengl"
+)
+
+// quirkComparison runs the same ROM on two cores in lockstep, feeding the second the first one's
+// input, watches for the first frame their displays differ and marks the differing pixels
+type quirkComparison struct {
+	a, b *Chip8
+
+	// Frame the last step of b was taken for, and the first frame the displays differed on
+	stepped  uint64
+	diverged uint64
+}
+
+// compareCommand implements "chip8 compare -a chip8 -b schip rom.ch8"
+func compareCommand(args []string) error {
+	fs := newFlagSet("compare")
+	nameA := fs.String("a", "chip8", "quirk preset for the left window, named after its variant")
+	nameB := fs.String("b", "schip", "quirk preset for the right window")
+	seed := fs.Int64("seed", 0, "seed both cores share for CXNN, 0 picks one from the clock")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return usageError("compare")
+	}
+
+	va, err := ParseVariant(*nameA)
+	if err != nil {
+		return err
+	}
+	vb, err := ParseVariant(*nameB)
+	if err != nil {
+		return err
+	}
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+
+	var runErr error
+	opengl.Run(func() {
+		runErr = runComparison(fs.Arg(0), va, vb, *seed)
+	})
+	return runErr
+}
+
+func runComparison(romFile string, va, vb Variant, seed int64) error {
+	q := &quirkComparison{}
+	for _, v := range []Variant{va, vb} {
+		c := NewChip8(windowOptions{})
+		c.LoadDefaultSprites()
+		c.LoadRomFile(romFile)
+		c.SetVariant(v)
+		c.SetSeed(seed)
+		c.Screen.SetTitle(fmt.Sprintf("Go - Chip8 Interpreter - %s quirks", v))
+
+		if q.a == nil {
+			q.a = c
+		} else {
+			q.b = c
+		}
+	}
+	q.b.Screen.SetPos(q.a.Screen.GetPos().Add(pixel.V(q.a.Screen.Bounds().W(), 0)))
+
+	q.a.OnFrame(q.frame)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if err := q.a.Run(ctx); err != nil && !errors.Is(err, context.Canceled) {
+		return err
+	}
+	if q.b.Fault != nil {
+		return q.b.Fault
+	}
+	if q.diverged == 0 {
+		log.Printf("displays matched for all %d frames", q.a.Frame)
+	}
+	return nil
+}
+
+// frame runs b for every frame a ran, with the keys a saw during it, then compares displays.
+// b's own window only shows its screen; input and the pause menu belong to a's.
+func (q *quirkComparison) frame(a *Chip8) {
+	b := q.b
+	b.Lock()
+	defer b.Unlock()
+
+	if b.Screen.Closed() {
+		a.IsStopped = true
+		return
+	}
+
+	if a.Frame != q.stepped && b.Fault == nil {
+		q.stepped = a.Frame
+		b.StepFrame()
+
+		if q.diverged == 0 && a.ScreenState != b.ScreenState {
+			q.diverged = a.Frame
+			log.Printf("displays diverge at frame %d: %d pixels differ", a.Frame, screenDiff(&a.ScreenState, &b.ScreenState))
+			a.Notify("Displays diverge at frame %d", a.Frame)
+			b.Notify("Displays diverge at frame %d", a.Frame)
+		}
+		q.highlightDifferences()
+	}
+	b.DrawScreen()
+
+	b.KeyPressed = a.KeyPressed
+	b.KeyJustReleased = a.KeyJustReleased
+}
+
+// highlightDifferences marks the pixels the two displays disagree on in both windows
+func (q *quirkComparison) highlightDifferences() {
+	var diff screenPlane
+	for row := range diff {
+		diff[row] = q.a.ScreenState[row] ^ q.b.ScreenState[row]
+	}
+	for _, c := range []*Chip8{q.a, q.b} {
+		if c.Highlight != diff {
+			c.Highlight = diff
+			c.screenDirty = true
+		}
+	}
+}
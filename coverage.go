@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// coverage marks the memory addresses an instruction was fetched from, a bit per byte
+type coverage struct {
+	bits []byte
+}
+
+// newCoverage makes empty coverage of size bytes of memory
+func newCoverage(size int) *coverage {
+	return &coverage{bits: make([]byte, (size+7)/8)}
+}
+
+// size is how many bytes of memory the coverage covers
+func (cv *coverage) size() int {
+	return len(cv.bits) * 8
+}
+
+// clone copies the coverage so far
+func (cv *coverage) clone() *coverage {
+	return &coverage{bits: slices.Clone(cv.bits)}
+}
+
+// hit marks both bytes of the instruction at pc as executed
+func (cv *coverage) hit(pc uint16) {
+	if int(pc)+1 < cv.size() {
+		cv.bits[pc>>3] |= 1 << (pc & 7)
+		pc++
+		cv.bits[pc>>3] |= 1 << (pc & 7)
+	}
+}
+
+// has reports whether the byte at addr was executed; nothing was when there is no coverage
+func (cv *coverage) has(addr uint16) bool {
+	return cv != nil && int(addr) < cv.size() && cv.bits[addr>>3]&(1<<(addr&7)) != 0
+}
+
+// Write lists the executed addresses as ranges, one "0x200-0x21F" per line, after a header
+// naming the ROM they belong to
+func (cv *coverage) Write(w io.Writer, romHash string) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "# chip8 coverage, %s\n", time.Now().Format(time.RFC3339))
+	fmt.Fprintf(bw, "sha1=%s\n", romHash)
+
+	for addr := 0; addr < cv.size(); addr++ {
+		if !cv.has(uint16(addr)) {
+			continue
+		}
+		start := addr
+		for addr+1 < cv.size() && cv.has(uint16(addr+1)) {
+			addr++
+		}
+		fmt.Fprintf(bw, "0x%03X-0x%03X\n", start, addr)
+	}
+	return bw.Flush()
+}
+
+// readCoverage loads a file written by coverage.Write for size bytes of memory, returning it and
+// the ROM hash it names
+func readCoverage(r io.Reader, name string, size int) (*coverage, string, error) {
+	cv := newCoverage(size)
+	romHash := ""
+
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if hash, ok := strings.CutPrefix(line, "sha1="); ok {
+			romHash = hash
+			continue
+		}
+
+		from, to, _ := strings.Cut(line, "-")
+		start, err1 := strconv.ParseUint(from, 0, 16)
+		end, err2 := strconv.ParseUint(cmp.Or(to, from), 0, 16)
+		if err1 != nil || err2 != nil || start > end || int(end) >= cv.size() {
+			return nil, "", fmt.Errorf("%s:%d: bad address range %q", name, n, line)
+		}
+		for addr := start; addr <= end; addr++ {
+			cv.bits[addr>>3] |= 1 << (addr & 7)
+		}
+	}
+	return cv, romHash, scanner.Err()
+}
+
+// loadCoverageFile reads the coverage already recorded for the ROM with romHash in size bytes of
+// memory, starting afresh when the file does not exist yet
+func loadCoverageFile(file, romHash string, size int) (*coverage, error) {
+	f, err := os.Open(file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return newCoverage(size), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	cv, hash, err := readCoverage(f, file, size)
+	if err != nil {
+		return nil, err
+	}
+	if hash != "" && hash != romHash {
+		return nil, fmt.Errorf("%s is coverage for another ROM (sha1 %s)", file, hash)
+	}
+	return cv, nil
+}
+
+// WriteCoverageFile saves the addresses executed so far, including any loaded from the file
+func (c *Chip8) WriteCoverageFile(file string) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	if err := c.Coverage.Write(f, c.RomHash); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
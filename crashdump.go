@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// recentLen is how many of the last instructions executed are kept for crash dumps
+const recentLen = 64
+
+// recentInstructions is a ring of the addresses last executed. Only addresses are kept so it
+// costs next to nothing per instruction; the dump disassembles what memory holds there now.
+type recentInstructions struct {
+	pcs [recentLen]uint16
+	n   uint64
+}
+
+func (r *recentInstructions) add(pc uint16) {
+	r.pcs[r.n%recentLen] = pc
+	r.n++
+}
+
+// addrs returns the recorded addresses, oldest first
+func (r *recentInstructions) addrs() []uint16 {
+	if r.n <= recentLen {
+		return append([]uint16(nil), r.pcs[:r.n]...)
+	}
+	i := r.n % recentLen
+	return append(append([]uint16(nil), r.pcs[i:]...), r.pcs[:i]...)
+}
+
+// WriteCrashDump writes a text file describing the machine after a fault: the fault, registers,
+// stack, the instructions leading up to it and an image of memory, returning the file's path
+func (c *Chip8) WriteCrashDump() (string, error) {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# chip8 crash dump, %s\n", time.Now().Format(time.RFC3339))
+	if c.Fault != nil {
+		fmt.Fprintf(&b, "fault=%v\n", c.Fault)
+	}
+	if c.RomFile != "" {
+		fmt.Fprintf(&b, "rom=%s\n", c.RomFile)
+	}
+	fmt.Fprintf(&b, "sha1=%s\n", c.RomHash)
+	fmt.Fprintf(&b, "frame=%d\n", c.Frame)
+	b.WriteString(strings.SplitN(c.stateSummary(0, len(c.MainMemory)), "\n", 2)[1])
+
+	fmt.Fprintf(&b, "\n# last %d instructions, oldest first\n", min(c.recent.n, recentLen))
+	for _, pc := range c.recent.addrs() {
+		op := c.word(pc)
+		fmt.Fprintf(&b, "%04X %04X %s", pc, op, Mnemonic(op, c.Symbols))
+		if where := c.Symbols.Describe(pc); where != "" {
+			fmt.Fprintf(&b, " ; %s", where)
+		}
+		b.WriteByte('\n')
+	}
+
+	if c.Fault != nil && len(c.Fault.Stack) > 0 {
+		b.WriteString("\n# go stack\n")
+		b.Write(c.Fault.Stack)
+	}
+
+	b.WriteString("\n# memory\n")
+	c.hexDump(&b, 0, len(c.MainMemory))
+
+	file := userFile("crashes", "crash", ".txt")
+	if err := os.WriteFile(file, []byte(b.String()), 0644); err != nil {
+		return "", err
+	}
+	return file, nil
+}
+
+// reportCrash writes a crash dump for a faulted machine and logs where it went, returning the
+// file or "" when it could not be written
+func (c *Chip8) reportCrash() string {
+	file, err := c.WriteCrashDump()
+	if err != nil {
+		log.Printf("crash dump failed: %v", err)
+		return ""
+	}
+	log.Printf("crash dump written to %s, please attach it to bug reports", file)
+	return file
+}
@@ -0,0 +1,14 @@
+package main
+
+// releaseKey reports a keyboard release of a CHIP-8 key to the game, unless it comes less than
+// KeyDebounce frames after the last one let through, as the chattering releases some keyboards
+// send do. FX0A waits for a release, so each stray one would otherwise pick a menu item.
+// Frames are counted by handleInput from 1, so they keep going while the game is paused and a
+// zero lastRelease means the key was never released.
+func (c *Chip8) releaseKey(key byte) {
+	if c.KeyDebounce > 0 && c.lastRelease[key] != 0 && c.inputFrame-c.lastRelease[key] < uint64(c.KeyDebounce) {
+		return
+	}
+	c.KeyJustReleased[key] = true
+	c.lastRelease[key] = c.inputFrame
+}
@@ -0,0 +1,605 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Debugger reads commands from a terminal and runs them against the machine between frames, so
+// they never race with instruction execution
+type Debugger struct {
+	commands chan string
+	out      io.Writer
+
+	// Paused stops the machine between instructions; steps counts instructions still to run
+	// one at a time while paused
+	Paused bool
+	steps  int
+
+	// Breakpoints pause the machine before the instruction at their address executes, and
+	// Catches before any instruction matching their pattern
+	Breakpoints map[uint16]bool
+	Catches     []catchpoint
+
+	// RegWatches pause the machine when a register changes; lastPC is the instruction that
+	// was about to run when they were last looked at, valid once lastRan is set
+	RegWatches []*regWatch
+	lastPC     uint16
+	lastRan    bool
+
+	// Actions run when their breakpoint is hit, which then only pauses if one says to; hits
+	// counts how often each breakpoint was reached
+	Actions map[uint16][]breakAction
+	hits    map[uint16]int
+
+	// resumed lets the first instruction after continue or step run even if it has a breakpoint
+	resumed bool
+
+	// returning is set by next and finish, which run until a return brings SP down to
+	// returnDepth
+	returning   bool
+	returnDepth uint8
+
+	// runningTo is set by until, which runs to the instruction at runTo as if it had a one off
+	// breakpoint
+	runningTo bool
+	runTo     uint16
+
+	// Watches are shown after every step and break, and every frame in the terminal UI
+	Watches []*watchExpr
+
+	// monitorNext is where m carries on from
+	monitorNext uint16
+
+	// search is the last memory search, which refine narrows down
+	search *memSearch
+
+	// views receives a copy of the machine state every frame when a UI is attached
+	views chan debugView
+}
+
+// debugView is a machine snapshot plus debugger state for a UI running on another goroutine
+type debugView struct {
+	*Snapshot
+	Paused      bool
+	Breakpoints map[uint16]bool
+	Symbols     *SymbolTable
+	Watches     []watchValue
+	Coverage    *coverage
+
+	// FontLen and RomLen are how much memory the font, from 0, and the ROM, from
+	// RamGameStart, take up
+	FontLen, RomLen int
+
+	// the keys as the instructions of the last frame saw them
+	KeyPressed, KeyJustReleased [16]bool
+}
+
+// debugCommand is a single debugger command and its help text
+type debugCommand struct {
+	usage string
+	help  string
+	run   func(c *Chip8, args []string) error
+}
+
+// debugCommands is filled in by init so commands can refer back to the table (help does)
+var debugCommands map[string]debugCommand
+
+func init() {
+	debugCommands = map[string]debugCommand{
+		"help":     {"help", "list commands", debugHelp},
+		"dump":     {"dump [start end] [file]", "write memory (default all of it) to a binary file plus a register summary", debugDump},
+		"break":    {"break addr", "pause before the instruction at addr executes", debugBreak},
+		"on":       {"on addr action[; action...]", "run log msg {expr}, dump start end, count, trace [on|off] or stop at addr, without pausing unless stop", debugOn},
+		"catch":    {"catch [pattern [lo-hi]]", "pause before any instruction like pattern, e.g. DXYN, FX0A or 2NNN 400-4FF; list them with no pattern", debugCatch},
+		"uncatch":  {"uncatch [pattern]", "remove the catchpoints on pattern, or all of them", debugUncatch},
+		"watchreg": {"watchreg [reg [value]]", "pause when V0-VF, I, DT or ST changes, or changes to value; list the watches with no reg", debugWatchReg},
+		"counts":   {"counts", "list breakpoints with their hit counts and actions", debugCounts},
+		"delete":   {"delete [addr]", "remove the breakpoint at addr, or all of them", debugDelete},
+		"pause":    {"pause", "stop the machine", debugPause},
+		"continue": {"continue", "resume after a pause or breakpoint", debugContinue},
+		"goto":     {"goto addr [call]", "continue from addr, with call pushing the current PC so the routine can RET back", debugGoto},
+		"step":     {"step [n]", "run n instructions (default 1) while paused", debugStep},
+		"next":     {"next", "step, running a CALL and the whole subroutine it calls as one step", debugNext},
+		"finish":   {"finish", "run until the current subroutine returns", debugFinish},
+		"until":    {"until addr", "run until the instruction at addr is about to execute", debugUntil},
+		"regs":     {"regs", "show the registers and stack", debugRegs},
+		"set":      {"set V0-VF|I|PC|DT|ST|SP|S0-S15|[addr] value", "change a register, timer, stack entry or memory byte, then show the registers", debugSet},
+		"quit":     {"quit", "stop the emulator", debugQuit},
+		"save":     {"save [file]", "write a save state that can be loaded later or on another machine, as JSON if file ends in .json", debugSave},
+		"load":     {"load file", "restore a save state", debugLoad},
+		"report":   {"report [file]", "write the last saved or loaded state with the input since, to reproduce a bug", debugReport},
+		"replay":   {"replay file", "restore a state written by report and play its input back", debugReplay},
+		"export":   {"export [file]", "write the ROM with the current variant, quirks, speed, palette and keys to a .c8b file to share", debugExport},
+		"watch":    {"watch [expr]", "show expr, e.g. V3*10 + V4 or [I], after every step; list the watches with no expr", debugWatch},
+		"unwatch":  {"unwatch [n|reg]", "remove watch n or the watchreg watches on reg, or all watches", debugUnwatch},
+		"search":   {"search 8|16 [value] | bytes hex...", "find memory holding a value (any, to narrow down with refine) or a byte pattern", debugSearch},
+		"refine":   {"refine value|changed|unchanged|up|down", "keep the addresses of the last search that now hold value or changed that way since", debugRefine},
+
+		// monitor commands, see monitor.go
+		"m": {"m [addr [len]]", "show len (hex, default 40) bytes of memory, continuing on if no addr", monitorMemory},
+		"w": {"w addr byte...", "write hex bytes to memory from addr", monitorWrite},
+		"f": {"f start end byte", "fill memory from start up to end with a hex byte", monitorFill},
+		"t": {"t start end dest", "copy memory from start up to end to dest", monitorTransfer},
+		"r": {"r", "show the registers and stack", debugRegs},
+		"s": {"s [n]", "run n (hex, default 1) instructions now and pause", monitorStep},
+		"g": {"g [addr]", "resume, jumping to addr first if given", monitorGo},
+	}
+}
+
+// NewDebugger starts reading commands, one per line, from in
+func NewDebugger(in io.Reader, out io.Writer) *Debugger {
+	d := newDebugger(out)
+
+	go func() {
+		scanner := bufio.NewScanner(in)
+		for scanner.Scan() {
+			d.commands <- scanner.Text()
+		}
+		close(d.commands)
+	}()
+
+	fmt.Fprintln(out, "debugger ready, type help for a list of commands")
+	return d
+}
+
+func newDebugger(out io.Writer) *Debugger {
+	return &Debugger{
+		commands:    make(chan string, 16),
+		out:         out,
+		Breakpoints: map[uint16]bool{},
+		Actions:     map[uint16][]breakAction{},
+		hits:        map[uint16]int{},
+	}
+}
+
+// shouldBreak is checked before every instruction and reports whether execution must stop
+func (c *Chip8) shouldBreak() bool {
+	d, pc := c.Debugger, c.PC
+	change := ""
+	if len(d.RegWatches) > 0 {
+		change = c.registerChange()
+		d.lastPC, d.lastRan = pc, true
+	}
+
+	if d.resumed {
+		d.resumed = false
+		return false
+	}
+	if d.Paused {
+		return true
+	}
+	if d.returning && c.SP <= d.returnDepth || d.runningTo && pc == d.runTo {
+		d.Paused, d.returning, d.runningTo = true, false, false
+		fmt.Fprintf(d.out, "%04X  %s\n", pc, Mnemonic(c.word(pc), c.Symbols))
+		c.printWatches()
+		return true
+	}
+	if change != "" {
+		d.Paused = true
+		fmt.Fprintln(d.out, change)
+		c.printWatches()
+		return true
+	}
+	if d.Breakpoints[pc] {
+		d.hits[pc]++
+		if len(d.Actions[pc]) > 0 && !c.runBreakActions(pc) {
+			return false
+		}
+		d.Paused = true
+		fmt.Fprintf(d.out, "break at %04X\n", pc)
+		c.printWatches()
+		return true
+	}
+	return len(d.Catches) > 0 && c.caught(pc)
+}
+
+// stepPaused runs the next pending single step, if any, while the machine is paused
+func (c *Chip8) stepPaused() {
+	if c.Debugger.steps == 0 {
+		return
+	}
+	c.Debugger.steps--
+	c.Debugger.resumed = true
+	c.ExecuteCPU(1)
+
+	if c.Debugger.steps == 0 {
+		fmt.Fprintf(c.Debugger.out, "%04X  %s\n", c.PC, Mnemonic(c.word(c.PC), c.Symbols))
+		c.printWatches()
+	}
+}
+
+// word reads the instruction at addr, zero past the end of memory
+func (c *Chip8) word(addr uint16) uint16 {
+	if int(addr)+1 >= len(c.MainMemory) {
+		return 0
+	}
+	return uint16(c.MainMemory[addr])<<8 | uint16(c.MainMemory[addr+1])
+}
+
+// publishView hands the attached UI a copy of the machine state, dropping it if the UI has not
+// caught up with the last one
+func (c *Chip8) publishView() {
+	d := c.Debugger
+	if d.views == nil {
+		return
+	}
+
+	if c.Symbols != nil {
+		// build the lookup tables now so the UI only ever reads them
+		c.Symbols.index()
+	}
+	v := debugView{
+		Snapshot:    c.snapshot(),
+		Paused:      d.Paused,
+		Symbols:     c.Symbols,
+		Watches:     c.watchValues(),
+		Breakpoints: make(map[uint16]bool, len(d.Breakpoints)),
+		FontLen:     len(defaultSprites),
+		RomLen:      len(c.rom),
+
+		KeyPressed:      c.KeyPressed,
+		KeyJustReleased: c.KeyJustReleased,
+	}
+	if c.Font != nil {
+		v.FontLen = len(c.Font)
+	}
+	for addr := range d.Breakpoints {
+		v.Breakpoints[addr] = true
+	}
+	if c.Coverage != nil {
+		v.Coverage = c.Coverage.clone()
+	}
+
+	select {
+	case d.views <- v:
+	default:
+	}
+}
+
+// runDebugCommands executes every command typed since the last frame
+func (c *Chip8) runDebugCommands() {
+	for {
+		select {
+		case line, ok := <-c.Debugger.commands:
+			if !ok {
+				return
+			}
+			if err := c.debugCommand(line); err != nil {
+				fmt.Fprintf(c.Debugger.out, "error: %v\n", err)
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (c *Chip8) debugCommand(line string) error {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return nil
+	}
+
+	cmd, ok := debugCommands[fields[0]]
+	if !ok {
+		return fmt.Errorf("unknown command %q, type help for a list", fields[0])
+	}
+	return cmd.run(c, fields[1:])
+}
+
+// debugAddr parses an address argument: a label, label+offset or hexadecimal number
+func (c *Chip8) debugAddr(arg string) (int, error) {
+	addr, err := c.Symbols.Resolve(arg)
+	return int(addr), err
+}
+
+func debugHelp(c *Chip8, args []string) error {
+	names := make([]string, 0, len(debugCommands))
+	for name := range debugCommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(c.Debugger.out, "  %-28s %s\n", debugCommands[name].usage, debugCommands[name].help)
+	}
+	return nil
+}
+
+func debugDump(c *Chip8, args []string) error {
+	start, end := 0, len(c.MainMemory)
+	binFile := userFile("dumps", "memdump", ".bin")
+
+	var err error
+	switch len(args) {
+	case 0:
+	case 1:
+		binFile = args[0]
+	case 2, 3:
+		if start, err = c.debugAddr(args[0]); err != nil {
+			return err
+		}
+		if end, err = c.debugAddr(args[1]); err != nil {
+			return err
+		}
+		if len(args) == 3 {
+			binFile = args[2]
+		}
+	default:
+		return fmt.Errorf("usage: %s", debugCommands["dump"].usage)
+	}
+
+	summaryFile, err := c.DumpMemory(start, end, binFile)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.Debugger.out, "wrote %d bytes to %s, summary in %s\n", end-start, binFile, summaryFile)
+	return nil
+}
+
+func debugSave(c *Chip8, args []string) error {
+	file := userFile("saves", "state", ".c8s")
+	switch len(args) {
+	case 0:
+	case 1:
+		file = args[0]
+	default:
+		return fmt.Errorf("usage: %s", debugCommands["save"].usage)
+	}
+
+	if err := c.WriteStateFile(file); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.Debugger.out, "saved state to %s\n", file)
+	c.Notify("State saved to %s", file)
+	return nil
+}
+
+func debugLoad(c *Chip8, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: %s", debugCommands["load"].usage)
+	}
+
+	if err := c.ReadStateFile(args[0]); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.Debugger.out, "loaded state from %s, PC=%04X\n", args[0], c.PC)
+	c.Notify("State loaded from %s", args[0])
+	return nil
+}
+
+func debugReport(c *Chip8, args []string) error {
+	file := userFile("saves", "report", ".c8s")
+	switch len(args) {
+	case 0:
+	case 1:
+		file = args[0]
+	default:
+		return fmt.Errorf("usage: %s", debugCommands["report"].usage)
+	}
+
+	if err := c.WriteReplayStateFile(file); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.Debugger.out, "wrote state and %d frames of input to %s\n", c.Frame-c.sinceSave.state.Frame, file)
+	return nil
+}
+
+func debugReplay(c *Chip8, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: %s", debugCommands["replay"].usage)
+	}
+	if err := c.ReplayStateFile(args[0]); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.Debugger.out, "replaying from %s, PC=%04X\n", args[0], c.PC)
+	return nil
+}
+
+func debugExport(c *Chip8, args []string) error {
+	file := userFile("cartridges", c.romBaseName(), ".c8b")
+	switch len(args) {
+	case 0:
+	case 1:
+		file = args[0]
+	default:
+		return fmt.Errorf("usage: %s", debugCommands["export"].usage)
+	}
+
+	if err := c.ExportC8B(file); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.Debugger.out, "exported %s with its settings to %s\n", c.romBaseName(), file)
+	return nil
+}
+
+func debugBreak(c *Chip8, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: %s", debugCommands["break"].usage)
+	}
+	addr, err := c.debugAddr(args[0])
+	if err != nil {
+		return err
+	}
+
+	c.Debugger.Breakpoints[uint16(addr)] = true
+	fmt.Fprintf(c.Debugger.out, "breakpoint at %04X\n", addr)
+	return nil
+}
+
+func debugDelete(c *Chip8, args []string) error {
+	switch len(args) {
+	case 0:
+		clear(c.Debugger.Breakpoints)
+		clear(c.Debugger.Actions)
+		fmt.Fprintln(c.Debugger.out, "deleted all breakpoints")
+	case 1:
+		addr, err := c.debugAddr(args[0])
+		if err != nil {
+			return err
+		}
+		if !c.Debugger.Breakpoints[uint16(addr)] {
+			return fmt.Errorf("no breakpoint at %04X", addr)
+		}
+		delete(c.Debugger.Breakpoints, uint16(addr))
+		delete(c.Debugger.Actions, uint16(addr))
+		fmt.Fprintf(c.Debugger.out, "deleted breakpoint at %04X\n", addr)
+	default:
+		return fmt.Errorf("usage: %s", debugCommands["delete"].usage)
+	}
+	return nil
+}
+
+func debugPause(c *Chip8, args []string) error {
+	c.Debugger.Paused = true
+	fmt.Fprintf(c.Debugger.out, "paused at %04X\n", c.PC)
+	return nil
+}
+
+func debugContinue(c *Chip8, args []string) error {
+	if !c.Debugger.Paused {
+		return fmt.Errorf("not paused")
+	}
+	c.Debugger.resume()
+	return nil
+}
+
+// resume lets the machine run on from a pause, dropping any next, finish or until in progress
+func (d *Debugger) resume() {
+	d.Paused, d.steps, d.resumed = false, 0, true
+	d.returning, d.runningTo = false, false
+}
+
+// debugNext steps over a CALL, running the whole subroutine, and is a plain step on anything else
+func debugNext(c *Chip8, args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("usage: %s", debugCommands["next"].usage)
+	}
+	if c.word(c.PC)&0xF000 != 0x2000 {
+		return debugStep(c, nil)
+	}
+	c.runToDepth(c.SP)
+	return nil
+}
+
+// debugFinish runs until the subroutine the machine is in returns
+func debugFinish(c *Chip8, args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("usage: %s", debugCommands["finish"].usage)
+	}
+	if c.SP == 0 {
+		return fmt.Errorf("not in a subroutine")
+	}
+	c.runToDepth(c.SP - 1)
+	return nil
+}
+
+// runToDepth resumes until a return leaves SP at depth, then pauses
+func (c *Chip8) runToDepth(depth uint8) {
+	d := c.Debugger
+	d.resume()
+	d.returning, d.returnDepth = true, depth
+}
+
+// debugUntil runs to addr, the run to cursor of the terminal UI
+func debugUntil(c *Chip8, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: %s", debugCommands["until"].usage)
+	}
+	addr, err := c.debugAddr(args[0])
+	if err != nil {
+		return err
+	}
+	if addr >= len(c.MainMemory) {
+		return fmt.Errorf("%03X is past the end of memory", addr)
+	}
+	d := c.Debugger
+	d.resume()
+	d.runningTo, d.runTo = true, uint16(addr)
+	return nil
+}
+
+// debugGoto moves PC to addr and resumes. With call the current PC is pushed first, as CALL
+// would, so a RET at the end of the routine comes back to where the program was.
+func debugGoto(c *Chip8, args []string) error {
+	if len(args) < 1 || len(args) > 2 || len(args) == 2 && args[1] != "call" {
+		return fmt.Errorf("usage: %s", debugCommands["goto"].usage)
+	}
+	addr, err := c.debugAddr(args[0])
+	if err != nil {
+		return err
+	}
+	if addr >= len(c.MainMemory) {
+		return fmt.Errorf("%03X is past the end of memory", addr)
+	}
+
+	from := c.PC
+	if len(args) == 2 {
+		// callSubroutine's limit, so RET finds what CALL would have left
+		if c.SP >= 15 {
+			return fmt.Errorf("stack full, %d calls deep", c.SP)
+		}
+		c.Stack[c.SP] = c.PC
+		c.SP++
+	}
+	c.PC = uint16(addr)
+
+	d := c.Debugger
+	d.resume()
+	fmt.Fprintf(d.out, "jumped from %04X to %04X\n", from, c.PC)
+	return nil
+}
+
+func debugStep(c *Chip8, args []string) error {
+	n := 1
+	if len(args) == 1 {
+		v, err := strconv.Atoi(args[0])
+		if err != nil || v < 1 {
+			return fmt.Errorf("bad step count %q", args[0])
+		}
+		n = v
+	} else if len(args) > 1 {
+		return fmt.Errorf("usage: %s", debugCommands["step"].usage)
+	}
+
+	c.Debugger.Paused = true
+	c.Debugger.steps = n
+	return nil
+}
+
+func debugRegs(c *Chip8, args []string) error {
+	out := c.Debugger.out
+	fmt.Fprintf(out, "PC=%04X I=%04X SP=%X DT=%02X ST=%02X", c.PC, c.I, c.SP, c.DT, c.ST)
+	if where := c.Symbols.Describe(c.PC); where != "" {
+		fmt.Fprintf(out, " (%s)", where)
+	}
+	fmt.Fprintln(out)
+
+	for i, v := range c.Vx {
+		fmt.Fprintf(out, "V%X=%02X ", i, v)
+		if i == 7 || i == 15 {
+			fmt.Fprintln(out)
+		}
+	}
+
+	fmt.Fprint(out, "stack:")
+	for _, addr := range c.Stack[:c.SP] {
+		fmt.Fprintf(out, " %04X", addr)
+	}
+	fmt.Fprintln(out)
+	return nil
+}
+
+func debugQuit(c *Chip8, args []string) error {
+	c.IsStopped = true
+	return nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// debugSet changes a register, timer, stack entry or memory byte, so a guess about the program
+// can be tried on the spot, typically while paused. Addresses, for PC, I, stack entries and [addr],
+// are labels or hex as elsewhere; other values are decimal unless written as 0x hex.
+func debugSet(c *Chip8, args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: %s", debugCommands["set"].usage)
+	}
+	target, arg := strings.ToUpper(args[0]), args[1]
+
+	value := func(bits int) (uint64, error) {
+		v, err := strconv.ParseUint(arg, 0, bits)
+		if err != nil {
+			return 0, fmt.Errorf("bad %d-bit value %q", bits, arg)
+		}
+		return v, nil
+	}
+	addr := func() (uint16, error) {
+		a, err := c.debugAddr(arg)
+		if err != nil {
+			return 0, err
+		}
+		if a >= len(c.MainMemory) {
+			return 0, fmt.Errorf("%03X is past the end of memory", a)
+		}
+		return uint16(a), nil
+	}
+
+	switch {
+	case len(target) == 2 && target[0] == 'V':
+		r, err := strconv.ParseUint(target[1:], 16, 4)
+		if err != nil {
+			return fmt.Errorf("no register %s", args[0])
+		}
+		v, err := value(8)
+		if err != nil {
+			return err
+		}
+		c.Vx[r] = uint8(v)
+
+	case target == "PC":
+		a, err := addr()
+		if err != nil {
+			return err
+		}
+		c.PC = a
+		// whatever is at the new PC runs next, even with a breakpoint on it
+		c.Debugger.resumed = true
+
+	case target == "I":
+		a, err := c.debugAddr(arg)
+		if err != nil {
+			return err
+		}
+		c.I = uint16(a)
+
+	case target == "DT", target == "ST":
+		v, err := value(8)
+		if err != nil {
+			return err
+		}
+		if target == "DT" {
+			c.DT = uint8(v)
+		} else {
+			c.ST = uint8(v)
+		}
+
+	case target == "SP":
+		v, err := value(8)
+		if err != nil {
+			return err
+		}
+		if v > uint64(len(c.Stack)) {
+			return fmt.Errorf("SP runs from 0 to %d", len(c.Stack))
+		}
+		c.SP = uint8(v)
+
+	case target[0] == 'S' && len(target) > 1:
+		n, err := strconv.Atoi(target[1:])
+		if err != nil || n < 0 || n >= len(c.Stack) {
+			return fmt.Errorf("no stack entry %s, they run from S0 to S%d", args[0], len(c.Stack)-1)
+		}
+		a, err := addr()
+		if err != nil {
+			return err
+		}
+		c.Stack[n] = a
+
+	case strings.HasPrefix(target, "[") && strings.HasSuffix(target, "]"):
+		at, err := c.debugAddr(args[0][1 : len(args[0])-1])
+		if err != nil {
+			return err
+		}
+		if at >= len(c.MainMemory) {
+			return fmt.Errorf("%03X is past the end of memory", at)
+		}
+		v, err := value(8)
+		if err != nil {
+			return err
+		}
+		c.writeMemory(uint16(at), byte(v))
+
+	default:
+		return fmt.Errorf("cannot set %s, see help", args[0])
+	}
+
+	return debugRegs(c, nil)
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// errFetchPastEnd is the fault of a variant that does not wrap when PC runs off the end of memory
+var errFetchPastEnd = errors.New("fetch past the end of memory")
+
+// decodedInstruction is the cached fetch and decode of the instruction at an address
+type decodedInstruction struct {
+	opcode uint16
+	op     Opcode
+	valid  bool
+}
+
+// decodeCache holds an entry per address of MainMemory. Entries are dropped whenever the memory
+// they were decoded from is written, so self-modifying code sees its changes.
+type decodeCache []decodedInstruction
+
+// fetchDecoded returns the instruction at PC, decoding it only on first use, and advances PC
+func (c *Chip8) fetchDecoded() (uint16, Opcode) {
+	if int(c.PC)+1 >= len(c.MainMemory) {
+		return c.fetchAtEnd()
+	}
+	d := &c.decoded[c.PC]
+	if !d.valid {
+		opcode := uint16(c.MainMemory[c.PC])<<8 | uint16(c.MainMemory[c.PC+1])
+		*d = decodedInstruction{opcode, c.decode(opcode), true}
+	}
+	c.PC += 2
+	return d.opcode, d.op
+}
+
+// fetchAtEnd fetches from the last byte of memory, or from past it after a skip or run off the
+// end, wrapping around to the start or faulting as the variant does. It is rare enough to go
+// uncached.
+func (c *Chip8) fetchAtEnd() (uint16, Opcode) {
+	if !c.Variant.fetchWraps() {
+		panic(fmt.Errorf("%w, %s has %d bytes and PC is %04X", errFetchPastEnd, c.Variant, len(c.MainMemory), c.PC))
+	}
+	mask := uint16(len(c.MainMemory) - 1)
+	pc := c.PC & mask
+	opcode := uint16(c.MainMemory[pc])<<8 | uint16(c.MainMemory[(pc+1)&mask])
+	c.PC = (pc + 2) & mask
+	return opcode, c.decode(opcode)
+}
+
+// invalidateDecoded drops the cached instructions covering a written byte: the one starting
+// there and the one starting the byte before
+func (c *Chip8) invalidateDecoded(addr uint16) {
+	c.decoded[addr].valid = false
+	if addr > 0 {
+		c.decoded[addr-1].valid = false
+	}
+	c.dropTranspiledAt(addr)
+	if c.blocks.inBlock[addr] {
+		c.flushBlocks()
+	}
+}
+
+// invalidateAllDecoded empties the cache after memory is replaced wholesale
+func (c *Chip8) invalidateAllDecoded() {
+	clear(c.decoded)
+	c.flushBlocks()
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxChip8RomSize is the space available between RamGameStart and the end of a 4K machine
+const maxChip8RomSize = int(RamEnd-RamGameStart) + 1
+
+// romSpace is how many bytes a ROM has room for on the variant picked for it from its file name
+// and contents, as configureForRom picks one for ROMs the database does not know
+func romSpace(name string, rom []byte) int {
+	v, _ := variantFromExtension(name)
+	if guess, ok := detectVariant(rom, v); ok {
+		v = guess.Variant
+	}
+	return v.memorySize() - int(RamGameStart)
+}
+
+// variantGuess is the outcome of scanning a ROM for dialect-specific features
+type variantGuess struct {
+	Variant Variant
+	Quirks  Quirks
+	Reasons []string
+}
+
+// detectVariant scans a ROM image for opcodes and patterns that only make sense on a particular
+// dialect. Data is scanned as if it were code, so the result is a guess rather than a verdict.
+func detectVariant(rom []byte, fallback Variant) (variantGuess, bool) {
+	var schip, xochip []string
+	var bxnnVx, bxnnV0 int
+
+	for addr := 0; addr+1 < len(rom); addr += 2 {
+		opcode := uint16(rom[addr])<<8 | uint16(rom[addr+1])
+		at := fmt.Sprintf("%04X at 0x%03X", opcode, int(RamGameStart)+addr)
+
+		dialect, ok := dialectOf(opcode)
+		switch {
+		case ok && dialect == VariantSChip:
+			schip = append(schip, at)
+		case ok && dialect == VariantXOChip:
+			xochip = append(xochip, at)
+		case opcode&0xF000 == 0xB000 && opcode&0x0F00 != 0:
+			// BXNN only differs from BNNN when X is non-zero; look back for whichever register
+			// the ROM prepared right before the jump
+			x := (opcode & 0x0F00) >> 8
+			for back := addr - 2; back >= 0 && back >= addr-6; back -= 2 {
+				prev := uint16(rom[back])<<8 | uint16(rom[back+1])
+				if prev&0xF000 != 0x6000 && prev&0xF000 != 0x7000 {
+					continue
+				}
+				switch (prev & 0x0F00) >> 8 {
+				case x:
+					bxnnVx++
+				case 0:
+					bxnnV0++
+				}
+			}
+		}
+	}
+
+	guess := variantGuess{Variant: fallback}
+
+	switch {
+	case len(rom) > maxChip8RomSize && fallback != VariantXOChip:
+		guess.Variant = VariantXOChip
+		guess.Reasons = append(guess.Reasons, fmt.Sprintf("ROM is %d bytes, more than the %d a 4K machine can hold", len(rom), maxChip8RomSize))
+	case len(xochip) > 0 && fallback != VariantXOChip:
+		guess.Variant = VariantXOChip
+		guess.Reasons = append(guess.Reasons, "XO-CHIP-only opcodes "+summarizeHits(xochip))
+	case len(schip) > 0 && fallback != VariantXOChip && fallback != VariantSChip:
+		guess.Variant = VariantSChip
+		guess.Reasons = append(guess.Reasons, "SCHIP-only opcodes "+summarizeHits(schip))
+	}
+
+	guess.Quirks = DefaultQuirks(guess.Variant)
+
+	switch {
+	case bxnnVx > bxnnV0 && !guess.Quirks.JumpUsesVx:
+		guess.Quirks.JumpUsesVx = true
+		guess.Reasons = append(guess.Reasons, fmt.Sprintf("%d BXNN jumps prepare VX rather than V0", bxnnVx))
+	case bxnnV0 > bxnnVx && guess.Quirks.JumpUsesVx:
+		guess.Quirks.JumpUsesVx = false
+		guess.Reasons = append(guess.Reasons, fmt.Sprintf("%d BNNN jumps prepare V0 rather than VX", bxnnV0))
+	}
+
+	return guess, len(guess.Reasons) > 0
+}
+
+// dialectOf reports the extended dialect an opcode belongs to, if it is not part of plain CHIP-8.
+// XO-CHIP includes all of SCHIP, so SCHIP opcodes are reported as SCHIP.
+func dialectOf(opcode uint16) (Variant, bool) {
+	switch {
+	case opcode&0xFFF0 == 0x00C0, opcode >= 0x00FB && opcode <= 0x00FF,
+		opcode&0xF00F == 0xD000, opcode&0xF0FF == 0xF030,
+		opcode&0xF0FF == 0xF075, opcode&0xF0FF == 0xF085:
+		return VariantSChip, true
+	case opcode&0xFFF0 == 0x00D0, opcode&0xF00F == 0x5002, opcode&0xF00F == 0x5003,
+		opcode == 0xF000, opcode&0xF0FF == 0xF001, opcode == 0xF002, opcode&0xF0FF == 0xF03A:
+		return VariantXOChip, true
+	}
+	return VariantChip8, false
+}
+
+// summarizeHits renders the first few matches of a scan followed by a count of the rest
+func summarizeHits(hits []string) string {
+	const shown = 3
+	if len(hits) <= shown {
+		return strings.Join(hits, ", ")
+	}
+	return fmt.Sprintf("%s and %d more", strings.Join(hits[:shown], ", "), len(hits)-shown)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+)
+
+// Files the emulator writes without being given a name go under per-user directories rather
+// than the current one:
+//
+//	Linux and BSD  $XDG_DATA_HOME/chip8 (~/.local/share/chip8), settings in $XDG_CONFIG_HOME/chip8
+//	macOS          ~/Library/Application Support/chip8
+//	Windows        %AppData%\chip8
+//
+// with a subdirectory for each kind: saves, autosave, dumps, recordings, traces, crashes and
+// achievements.
+// Settings for a ROM can be kept in roms/game.ch8.toml under the settings directory when they
+// cannot go next to the ROM.
+// -data-dir puts all of them, settings included, under one directory instead.
+
+// dataDirs returns the base directories for written files and for settings
+func dataDirs() (data, config string, err error) {
+	if *dataDirFlag != "" {
+		return *dataDirFlag, filepath.Join(*dataDirFlag, "config"), nil
+	}
+
+	config, err = os.UserConfigDir()
+	if err != nil {
+		return "", "", err
+	}
+	config = filepath.Join(config, "chip8")
+
+	switch runtime.GOOS {
+	case "windows", "darwin", "ios", "plan9":
+		// one directory holds both, see os.UserConfigDir
+		return config, config, nil
+	}
+	data = os.Getenv("XDG_DATA_HOME")
+	if !filepath.IsAbs(data) {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", "", err
+		}
+		data = filepath.Join(home, ".local", "share")
+	}
+	return filepath.Join(data, "chip8"), config, nil
+}
+
+// userDir returns the directory for one kind of written file, creating it if need be
+func userDir(kind string) (string, error) {
+	data, _, err := dataDirs()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(data, kind)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// configDir returns the directory settings are read from; it need not exist
+func configDir() (string, error) {
+	_, config, err := dataDirs()
+	return config, err
+}
+
+// userFile returns a timestamped path for a file of a kind written without an explicit name,
+// e.g. saves/state-20261014-180441.c8s. Should the directory be unusable the file goes in the
+// current directory as it used to.
+func userFile(kind, prefix, ext string) string {
+	name := fmt.Sprintf("%s-%s%s", prefix, time.Now().Format("20060102-150405"), ext)
+	dir, err := userDir(kind)
+	if err != nil {
+		log.Printf("writing %s to the current directory: %v", name, err)
+		return name
+	}
+	return filepath.Join(dir, name)
+}
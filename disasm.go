@@ -0,0 +1,343 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Mnemonic renders a single opcode in the conventional CHIP-8 assembly notation. Address operands
+// are shown as labels when a symbol table is available.
+func Mnemonic(opcode uint16, syms *SymbolTable) string {
+	x := (opcode & 0x0F00) >> 8
+	y := (opcode & 0x00F0) >> 4
+	n := opcode & 0x000F
+	nn := opcode & 0x00FF
+	nnn := opcode & 0x0FFF
+
+	addr := fmt.Sprintf("0x%03X", nnn)
+	if name, ok := syms.LabelAt(nnn); ok {
+		addr = name
+	}
+
+	switch opcode & 0xF000 {
+	case 0x0000:
+		switch opcode {
+		case 0x00E0:
+			return "CLS"
+		case 0x00EE:
+			return "RET"
+		}
+		return fmt.Sprintf("SYS %s", addr)
+	case 0x1000:
+		return fmt.Sprintf("JP %s", addr)
+	case 0x2000:
+		return fmt.Sprintf("CALL %s", addr)
+	case 0x3000:
+		return fmt.Sprintf("SE V%X, 0x%02X", x, nn)
+	case 0x4000:
+		return fmt.Sprintf("SNE V%X, 0x%02X", x, nn)
+	case 0x5000:
+		if n == 0 {
+			return fmt.Sprintf("SE V%X, V%X", x, y)
+		}
+	case 0x6000:
+		return fmt.Sprintf("LD V%X, 0x%02X", x, nn)
+	case 0x7000:
+		return fmt.Sprintf("ADD V%X, 0x%02X", x, nn)
+	case 0x8000:
+		ops := map[uint16]string{0x0: "LD", 0x1: "OR", 0x2: "AND", 0x3: "XOR", 0x4: "ADD", 0x5: "SUB", 0x6: "SHR", 0x7: "SUBN", 0xE: "SHL"}
+		if op, ok := ops[n]; ok {
+			return fmt.Sprintf("%s V%X, V%X", op, x, y)
+		}
+	case 0x9000:
+		if n == 0 {
+			return fmt.Sprintf("SNE V%X, V%X", x, y)
+		}
+	case 0xA000:
+		return fmt.Sprintf("LD I, %s", addr)
+	case 0xB000:
+		return fmt.Sprintf("JP V0, %s", addr)
+	case 0xC000:
+		return fmt.Sprintf("RND V%X, 0x%02X", x, nn)
+	case 0xD000:
+		return fmt.Sprintf("DRW V%X, V%X, %d", x, y, n)
+	case 0xE000:
+		switch nn {
+		case 0x9E:
+			return fmt.Sprintf("SKP V%X", x)
+		case 0xA1:
+			return fmt.Sprintf("SKNP V%X", x)
+		}
+	case 0xF000:
+		formats := map[uint16]string{
+			0x07: "LD V%X, DT", 0x0A: "LD V%X, K", 0x15: "LD DT, V%X", 0x18: "LD ST, V%X",
+			0x1E: "ADD I, V%X", 0x29: "LD F, V%X", 0x33: "LD B, V%X", 0x55: "LD [I], V%X", 0x65: "LD V%X, [I]",
+		}
+		if format, ok := formats[nn]; ok {
+			return fmt.Sprintf(format, x)
+		}
+	}
+
+	return fmt.Sprintf("DW 0x%04X", opcode)
+}
+
+// disassembly is the result of following a ROM's control flow from its entry point
+type disassembly struct {
+	rom  []byte
+	syms *SymbolTable
+
+	code     map[uint16]bool // start addresses of reachable instructions
+	jumps    map[uint16]bool // targets of 1NNN / BNNN
+	calls    map[uint16]bool // targets of 2NNN
+	dataRefs map[uint16]bool // addresses loaded into I
+
+	executed *coverage // addresses seen running in a session, see addCoverage
+}
+
+// instructionSize reports how many bytes the instruction at addr occupies; XO-CHIP's
+// "i := long NNNN" (F000) carries its operand in the following word
+func instructionSize(opcode uint16) uint16 {
+	if opcode == 0xF000 {
+		return 4
+	}
+	return 2
+}
+
+// disassemble traces every instruction reachable from RamGameStart, collecting jump and call
+// targets and the addresses the program points I at
+func disassemble(rom []byte, syms *SymbolTable) *disassembly {
+	d := &disassembly{
+		rom:      rom,
+		code:     map[uint16]bool{},
+		jumps:    map[uint16]bool{},
+		calls:    map[uint16]bool{},
+		dataRefs: map[uint16]bool{},
+	}
+
+	// an int, as a ROM filling XO-CHIP's memory ends at 0x10000
+	end := int(RamGameStart) + len(rom)
+	pending := []uint16{RamGameStart}
+
+	for len(pending) > 0 {
+		addr := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+
+		if addr < RamGameStart || int(addr)+1 >= end || d.code[addr] {
+			continue
+		}
+		d.code[addr] = true
+
+		opcode := d.word(addr)
+		next := addr + instructionSize(opcode)
+		nnn := opcode & 0x0FFF
+
+		switch {
+		case opcode == 0x00EE, opcode == 0x00FD:
+			// return / exit: nothing follows
+		case opcode&0xF000 == 0x1000:
+			d.jumps[nnn] = true
+			pending = append(pending, nnn)
+		case opcode&0xF000 == 0xB000:
+			// computed jump: only the base of the table is known
+			d.jumps[nnn] = true
+			pending = append(pending, nnn)
+		case opcode&0xF000 == 0x2000:
+			d.calls[nnn] = true
+			pending = append(pending, nnn, next)
+		case opcode&0xF000 == 0x3000, opcode&0xF000 == 0x4000,
+			opcode&0xF00F == 0x5000, opcode&0xF00F == 0x9000,
+			opcode&0xF0FF == 0xE09E, opcode&0xF0FF == 0xE0A1:
+			// skips may land on either of the next two instructions
+			pending = append(pending, next, next+instructionSize(d.word(next)))
+		case opcode&0xF000 == 0xA000:
+			d.dataRefs[nnn] = true
+			pending = append(pending, next)
+		default:
+			pending = append(pending, next)
+		}
+	}
+
+	// start from the supplied symbols and name every other target automatically
+	d.syms = newSymbolTable()
+	if syms != nil {
+		for name, addr := range syms.Labels {
+			d.syms.addLabel(name, addr)
+		}
+	}
+	name := func(addrs map[uint16]bool, prefix string) {
+		for addr := range addrs {
+			if _, ok := d.syms.LabelAt(addr); !ok {
+				d.syms.addLabel(fmt.Sprintf("%s-%03X", prefix, addr), addr)
+			}
+		}
+	}
+	name(d.calls, "sub")
+	name(d.jumps, "label")
+	for addr := range d.dataRefs {
+		if !d.code[addr] {
+			name(map[uint16]bool{addr: true}, "data")
+		}
+	}
+
+	return d
+}
+
+// addCoverage overlays the addresses recorded by run -coverage on the listing. Executed code the
+// trace from the entry point missed, such as the targets of computed jumps, is listed as code:
+// each executed range holds instructions from its first address on.
+func (d *disassembly) addCoverage(cv *coverage) {
+	d.executed = cv
+	end := int(RamGameStart) + len(d.rom)
+	for addr := int(RamGameStart); addr < end; addr++ {
+		if !cv.has(uint16(addr)) || cv.has(uint16(addr-1)) {
+			continue
+		}
+		for pc := addr; pc+1 < end && cv.has(uint16(pc)); pc += int(instructionSize(d.word(uint16(pc)))) {
+			d.code[uint16(pc)] = true
+		}
+	}
+}
+
+// word reads the big-endian instruction at a ROM address, zero past the end
+func (d *disassembly) word(addr uint16) uint16 {
+	offset := int(addr) - int(RamGameStart)
+	if offset < 0 || offset+1 >= len(d.rom) {
+		return 0
+	}
+	return uint16(d.rom[offset])<<8 | uint16(d.rom[offset+1])
+}
+
+// midInstruction reports whether addr falls after the first byte of a reachable instruction
+func (d *disassembly) midInstruction(addr uint16) bool {
+	for back := uint16(1); back <= 3 && back <= addr; back++ {
+		if start := addr - back; d.code[start] && back < instructionSize(d.word(start)) {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteListing renders the ROM as an annotated listing: reachable code as instructions, bytes
+// referenced by I as data with an ASCII-art rendering of the sprite they form, and everything
+// else as raw bytes
+func (d *disassembly) WriteListing(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintf(bw, "; %d bytes, %d reachable instructions, %d subroutines\n",
+		len(d.rom), len(d.code), len(d.calls))
+	if d.executed != nil {
+		ran := 0
+		for addr := range d.code {
+			if d.executed.has(addr) {
+				ran++
+			}
+		}
+		fmt.Fprintf(bw, "; %d of %d instructions executed (%.1f%%), marked *\n",
+			ran, len(d.code), 100*float64(ran)/float64(max(len(d.code), 1)))
+	}
+
+	end := int(RamGameStart) + len(d.rom)
+	inData := false
+
+	for a := int(RamGameStart); a < end; {
+		addr := uint16(a)
+		if name, ok := d.syms.LabelAt(addr); ok {
+			fmt.Fprintf(bw, "\n%s:\n", name)
+		}
+
+		if d.code[addr] {
+			opcode := d.word(addr)
+			size := instructionSize(opcode)
+			mark := " "
+			if d.executed.has(addr) {
+				mark = "*"
+			}
+			if size == 4 {
+				fmt.Fprintf(bw, "%s 0x%03X  %04X %04X  LD I, long 0x%04X\n", mark, addr, opcode, d.word(addr+2), d.word(addr+2))
+			} else {
+				fmt.Fprintf(bw, "%s 0x%03X  %04X       %s\n", mark, addr, opcode, Mnemonic(opcode, d.syms))
+			}
+			a += int(size)
+			inData = false
+			continue
+		}
+
+		if d.dataRefs[addr] {
+			inData = true
+		}
+
+		b := d.rom[addr-RamGameStart]
+		if inData {
+			fmt.Fprintf(bw, "  0x%03X  %02X         DB 0x%02X  ; %s\n", addr, b, b, spriteRow(b))
+			a++
+			continue
+		}
+
+		// unreferenced bytes are dumped 8 per line up to the next code or labelled address
+		var row []string
+		for start := a; a < end && len(row) < 8 && !d.code[uint16(a)] && !d.dataRefs[uint16(a)]; a++ {
+			if _, ok := d.syms.LabelAt(uint16(a)); ok && a != start {
+				break
+			}
+			row = append(row, fmt.Sprintf("0x%02X", d.rom[a-int(RamGameStart)]))
+		}
+		fmt.Fprintf(bw, "  0x%03X             DB %s\n", addr, strings.Join(row, " "))
+	}
+
+	return bw.Flush()
+}
+
+// spriteRow draws one byte of sprite data as eight pixels
+func spriteRow(b byte) string {
+	var row [8]byte
+	for i := range row {
+		if b&(0x80>>i) != 0 {
+			row[i] = '#'
+		} else {
+			row[i] = '.'
+		}
+	}
+	return string(row[:])
+}
+
+// disasmCommand implements "chip8 disasm rom.ch8", writing an annotated listing to stdout
+func disasmCommand(args []string) error {
+	fs := newFlagSet("disasm")
+	symbolFile := fs.String("symbols", "", "symbol listing to label addresses with, overriding any from an .8o source")
+	coverageFile := fs.String("coverage", "", "coverage file from run -coverage, marking the instructions that executed")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return usageError("disasm")
+	}
+
+	img, err := readRomFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	syms := img.Symbols
+	if *symbolFile != "" {
+		if syms, err = LoadSymbols(*symbolFile); err != nil {
+			return err
+		}
+	}
+
+	d := disassemble(img.Data, syms)
+	if *coverageFile != "" {
+		if _, err := os.Stat(*coverageFile); err != nil {
+			return err
+		}
+		// the listing has no variant, and coverage from any of them fits XO-CHIP's memory
+		cv, err := loadCoverageFile(*coverageFile, romSHA1(img.Data), VariantXOChip.memorySize())
+		if err != nil {
+			return err
+		}
+		d.addCoverage(cv)
+	}
+	return d.WriteListing(os.Stdout)
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// traceState is the machine state part of a trace line, ignoring the disassembly and symbols
+// after the "|" that can legitimately differ between runs
+func traceState(line string) string {
+	state, _, _ := strings.Cut(line, " | ")
+	return strings.TrimSpace(state)
+}
+
+// describeTraceDiff names the fields that differ between two trace lines, down to the individual
+// V registers
+func describeTraceDiff(a, b string) string {
+	fa, fb := strings.Fields(traceState(a)), strings.Fields(traceState(b))
+	names := []string{"PC", "opcode"}
+
+	var diffs []string
+	for i := 0; i < len(fa) && i < len(fb); i++ {
+		if fa[i] == fb[i] {
+			continue
+		}
+
+		name := fmt.Sprintf("field %d", i+1)
+		if i < len(names) {
+			name = names[i]
+		} else if key, _, ok := strings.Cut(fa[i], "="); ok {
+			name = key
+		}
+
+		if name == "V" && len(fa[i]) == len(fb[i]) {
+			// two hex digits per register after "V="
+			for r := 0; 2+2*r+2 <= len(fa[i]); r++ {
+				va, vb := fa[i][2+2*r:4+2*r], fb[i][2+2*r:4+2*r]
+				if va != vb {
+					diffs = append(diffs, fmt.Sprintf("V%X %s vs %s", r, va, vb))
+				}
+			}
+			continue
+		}
+		diffs = append(diffs, fmt.Sprintf("%s %s vs %s", name, fa[i], fb[i]))
+	}
+
+	if len(fa) != len(fb) {
+		diffs = append(diffs, "different line layout")
+	}
+	return strings.Join(diffs, ", ")
+}
+
+// findDivergence reads two traces in lockstep and writes the context around the first line
+// where their machine state differs. It reports whether a divergence was found.
+func findDivergence(a, b io.Reader, nameA, nameB string, context int, w io.Writer) (bool, error) {
+	sa, sb := bufio.NewScanner(a), bufio.NewScanner(b)
+	var history []string
+
+	for n := 1; ; n++ {
+		okA, okB := sa.Scan(), sb.Scan()
+		if err := sa.Err(); err != nil {
+			return false, err
+		}
+		if err := sb.Err(); err != nil {
+			return false, err
+		}
+
+		switch {
+		case !okA && !okB:
+			fmt.Fprintf(w, "traces match for all %d instructions\n", n-1)
+			return false, nil
+		case !okA || !okB:
+			shorter := nameA
+			if okA {
+				shorter = nameB
+			}
+			fmt.Fprintf(w, "traces match for %d instructions, then %s ends\n", n-1, shorter)
+			return true, nil
+		}
+
+		la, lb := sa.Text(), sb.Text()
+		if traceState(la) == traceState(lb) {
+			history = append(history, la)
+			if len(history) > context {
+				history = history[1:]
+			}
+			continue
+		}
+
+		fmt.Fprintf(w, "first divergence at instruction %d: %s\n\n", n, describeTraceDiff(la, lb))
+		for i, line := range history {
+			fmt.Fprintf(w, "  %6d    %s\n", n-len(history)+i, line)
+		}
+		fmt.Fprintf(w, "> %6d A  %s\n", n, la)
+		fmt.Fprintf(w, "> %6d B  %s\n", n, lb)
+
+		// a few lines after the split show where each run went
+		for i := 1; i <= context; i++ {
+			if sa.Scan() {
+				fmt.Fprintf(w, "  %6d A  %s\n", n+i, sa.Text())
+			}
+		}
+		for i := 1; i <= context; i++ {
+			if sb.Scan() {
+				fmt.Fprintf(w, "  %6d B  %s\n", n+i, sb.Text())
+			}
+		}
+		fmt.Fprintf(w, "\nA = %s\nB = %s\n", nameA, nameB)
+		return true, nil
+	}
+}
+
+// errTracesDiffer signals a divergence through the exit status without printing anything more
+var errTracesDiffer = errors.New("traces differ")
+
+// divergenceCommand implements "chip8 divergence traceA.log traceB.log", comparing traces written
+// with -trace. It exits with status 1 when the traces differ.
+func divergenceCommand(args []string) error {
+	fs := newFlagSet("divergence")
+	context := fs.Int("context", 5, "lines of context to show around the divergence")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return usageError("divergence")
+	}
+
+	fa, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer fa.Close()
+
+	fb, err := os.Open(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+	defer fb.Close()
+
+	diverged, err := findDivergence(fa, fb, fs.Arg(0), fs.Arg(1), *context, os.Stdout)
+	if err != nil {
+		return err
+	}
+	if diverged {
+		return errTracesDiffer
+	}
+	return nil
+}
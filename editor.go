@@ -0,0 +1,219 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+
+	"github.com/gopxl/pixel/v2"
+	"github.com/gopxl/pixel/v2/backends/opengl"
+)
+
+const (
+	editorCell    = 24
+	editorMaxRows = 16
+	fontGlyphSize = 5
+)
+
+var colorGrid = color.RGBA{0xb0, 0xb3, 0xac, 255}
+
+// spriteEditor edits CHIP-8 sprites pixel by pixel. Data holds one or more sprites of Height bytes
+// each; the font editor is the same tool over the 16 glyphs of the hex font.
+type spriteEditor struct {
+	win *opengl.Window
+
+	Data   []byte
+	Height int
+	Glyph  int // sprite currently being edited
+	Font   bool
+
+	// file saved to with S, if any
+	File string
+}
+
+// spriteEditCommand implements "chip8 sprite-edit [file]": draw a single 8xN sprite
+func spriteEditCommand(args []string) error {
+	return runEditor(args, false)
+}
+
+// fontEditCommand implements "chip8 font-edit [file]": edit the 16 glyphs of the hex font,
+// starting from the built-in one
+func fontEditCommand(args []string) error {
+	return runEditor(args, true)
+}
+
+func runEditor(args []string, font bool) error {
+	name := map[bool]string{false: "sprite-edit", true: "font-edit"}[font]
+	flags := newFlagSet(name)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	args = flags.Args()
+	if len(args) > 1 {
+		return usageError(name)
+	}
+
+	e := &spriteEditor{Font: font, Height: 8}
+	if font {
+		e.Height = fontGlyphSize
+		e.Data = append([]byte(nil), defaultSprites...)
+	}
+
+	if len(args) == 1 {
+		e.File = args[0]
+		data, err := os.ReadFile(e.File)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+		case err != nil:
+			return err
+		case font && len(data) != len(defaultSprites):
+			return fmt.Errorf("%s: a font is %d bytes, file has %d", e.File, len(defaultSprites), len(data))
+		case !font && (len(data) == 0 || len(data) >= editorMaxRows):
+			return fmt.Errorf("%s: sprites are 1 to %d bytes, file has %d", e.File, editorMaxRows-1, len(data))
+		default:
+			e.Data = data
+			if !font {
+				e.Height = len(data)
+			}
+		}
+	}
+	if !font && len(e.Data) == 0 {
+		e.Data = make([]byte, e.Height)
+	}
+
+	var err error
+	opengl.Run(func() { err = e.run() })
+	return err
+}
+
+func (e *spriteEditor) run() error {
+	win, err := opengl.NewWindow(opengl.WindowConfig{
+		Bounds: pixel.R(0, 0, 8*editorCell, editorMaxRows*editorCell),
+	})
+	if err != nil {
+		return err
+	}
+	e.win = win
+
+	for !win.Closed() {
+		if win.JustPressed(pixel.KeyEscape) {
+			break
+		}
+		if err := e.handleInput(); err != nil {
+			return err
+		}
+		e.draw()
+		win.Update()
+	}
+
+	return nil
+}
+
+// sprite returns the bytes of the sprite being edited
+func (e *spriteEditor) sprite() []byte {
+	return e.Data[e.Glyph*e.Height : (e.Glyph+1)*e.Height]
+}
+
+// handleInput: left click sets a pixel, right click clears it. Up/Down resize a sprite,
+// Left/Right pick a glyph in the font editor, C clears, Enter prints the bytes and S saves them.
+func (e *spriteEditor) handleInput() error {
+	w := e.win
+	pos := w.MousePosition()
+	col := int(pos.X / editorCell)
+	row := int((w.Bounds().H() - pos.Y) / editorCell)
+
+	if col >= 0 && col < 8 && row >= 0 && row < e.Height {
+		bit := byte(0x80 >> col)
+		if w.Pressed(pixel.MouseButtonLeft) {
+			e.sprite()[row] |= bit
+		}
+		if w.Pressed(pixel.MouseButtonRight) {
+			e.sprite()[row] &^= bit
+		}
+	}
+
+	switch {
+	case !e.Font && w.JustPressed(pixel.KeyUp) && e.Height > 1:
+		e.Height--
+		e.Data = e.Data[:e.Height]
+	case !e.Font && w.JustPressed(pixel.KeyDown) && e.Height < editorMaxRows-1:
+		e.Height++
+		e.Data = append(e.Data, 0)
+	case e.Font && w.JustPressed(pixel.KeyLeft):
+		e.Glyph = (e.Glyph + 15) % 16
+	case e.Font && w.JustPressed(pixel.KeyRight):
+		e.Glyph = (e.Glyph + 1) % 16
+	case w.JustPressed(pixel.KeyC):
+		clear(e.sprite())
+	case w.JustPressed(pixel.KeyEnter):
+		e.Export(os.Stdout)
+	case w.JustPressed(pixel.KeyS):
+		if e.File == "" {
+			fmt.Fprintln(os.Stderr, "no file given on the command line, use Enter to print the bytes instead")
+			break
+		}
+		if err := os.WriteFile(e.File, e.Data, 0644); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "saved %d bytes to %s\n", len(e.Data), e.File)
+	}
+
+	title := fmt.Sprintf("Sprite Editor - 8x%d", e.Height)
+	if e.Font {
+		title = fmt.Sprintf("Font Editor - glyph %X", e.Glyph)
+	}
+	w.SetTitle(title)
+
+	return nil
+}
+
+func (e *spriteEditor) draw() {
+	img := image.NewRGBA(image.Rect(0, 0, 8*editorCell, editorMaxRows*editorCell))
+	for y := 0; y < editorMaxRows*editorCell; y++ {
+		for x := 0; x < 8*editorCell; x++ {
+			row, col := y/editorCell, x/editorCell
+			switch {
+			case row >= e.Height:
+				img.Set(x, y, colorGutter)
+			case x%editorCell == 0 || y%editorCell == 0:
+				img.Set(x, y, colorGrid)
+			case e.sprite()[row]&(0x80>>col) != 0:
+				img.Set(x, y, colorOn)
+			default:
+				img.Set(x, y, colorOff)
+			}
+		}
+	}
+
+	pic := pixel.PictureDataFromImage(img)
+	e.win.Clear(colorGutter)
+	pixel.NewSprite(pic, pic.Bounds()).Draw(e.win, pixel.IM.Moved(e.win.Bounds().Center()))
+}
+
+// Export writes the sprite (or every font glyph) as hex bytes, usable directly in Octo, followed
+// by an assembler db line
+func (e *spriteEditor) Export(w io.Writer) {
+	for g := 0; g < len(e.Data)/e.Height; g++ {
+		sprite := e.Data[g*e.Height : (g+1)*e.Height]
+		hex := make([]string, len(sprite))
+		for i, b := range sprite {
+			hex[i] = fmt.Sprintf("0x%02X", b)
+		}
+
+		if e.Font {
+			fmt.Fprintf(w, "; glyph %X\n", g)
+		} else {
+			fmt.Fprintf(w, "; 8x%d sprite\n", e.Height)
+		}
+		for _, b := range sprite {
+			fmt.Fprintf(w, ";   %s\n", spriteRow(b))
+		}
+		fmt.Fprintln(w, strings.Join(hex, " "))
+		fmt.Fprintf(w, "db %s\n", strings.Join(hex, ", "))
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"path/filepath"
+	"strings"
+)
+
+// errorWidth is how many characters of the menu font fit across the window, less the margins
+const errorWidth = (ScreenWidth*ScalingFactor - 48) / 7
+
+// showError replaces the game with a page describing what went wrong, which stays up until one
+// of its items is picked: retry, when given, then loading another ROM, settings or quitting.
+// Users started from a desktop icon never see the log, so the page has to say it all.
+func (c *Chip8) showError(title string, details []string, retry func(c *Chip8)) {
+	m := &pauseMenu{title: title, locked: true}
+	for _, line := range details {
+		m.text = append(m.text, wrapText(line, errorWidth)...)
+	}
+
+	if retry != nil {
+		m.items = append(m.items, menuItem{Label: fixedLabel("Retry"), Activate: retry})
+	}
+	m.items = append(m.items,
+		menuItem{Label: fixedLabel("Load another ROM"), Activate: func(c *Chip8) { c.openSubmenu(c.romMenu()) }},
+		menuItem{Label: fixedLabel("Settings"), Activate: func(c *Chip8) { c.openSubmenu(settingsMenu()) }},
+		menuItem{Label: fixedLabel("Quit"), Activate: func(c *Chip8) { c.IsStopped = true }},
+	)
+
+	c.helpShown = false
+	c.menu = m
+}
+
+// showLoadError reports a ROM that could not be loaded, offering to try the same file again
+func (c *Chip8) showLoadError(romFile string, err error) {
+	details := []string{err.Error(), ""}
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		details = append(details, "The file does not exist. Check the path, or load another ROM from its folder.")
+	case strings.EqualFold(filepath.Ext(romFile), ".8o"):
+		details = append(details, "Fix the source where the error points, save it and retry.")
+	default:
+		details = append(details, "ROMs are .ch8 files (or .sc8, .xo8 and the like), Octo .8o source, or a .zip holding one.")
+	}
+
+	// the ROM menu lists the folder the file was meant to be in
+	c.RomFile = romFile
+	c.showError("Could not load "+filepath.Base(romFile), details, func(c *Chip8) {
+		if err := c.SwitchRom(romFile); err != nil {
+			log.Printf("loading %s: %v", romFile, err)
+			c.showLoadError(romFile, err)
+			return
+		}
+		c.CloseMenu()
+	})
+}
+
+// showFault reports a fault in the window instead of stopping, writing a crash dump first when
+// -crash-dump asks for one so the page can say where it went
+func (c *Chip8) showFault() {
+	f := c.Fault
+	details := []string{
+		fmt.Sprintf("PC %03X  opcode %04X  %s", f.PC, f.Opcode, Mnemonic(f.Opcode, c.Symbols)),
+		f.Err.Error(),
+		"",
+	}
+	details = append(details, c.faultSuggestions(f)...)
+
+	if *crashDump {
+		if file := c.reportCrash(); file != "" {
+			details = append(details, "", "Details were saved to "+file)
+		}
+	}
+
+	var retry func(c *Chip8)
+	if c.RomFile != "" {
+		// settings changed on the way are kept, they may be the fix
+		retry = func(c *Chip8) {
+			c.ReloadRomFile(c.RomFile)
+			if c.Fault == nil {
+				c.CloseMenu()
+			}
+		}
+	}
+	c.showError("The program crashed", details, retry)
+}
+
+// faultSuggestions guesses at why an instruction faulted, from what it was doing
+func (c *Chip8) faultSuggestions(f *Fault) []string {
+	var hints []string
+	switch {
+	case int(f.PC)+1 >= len(c.MainMemory):
+		hints = append(hints, "It ran off the end of memory, perhaps after jumping to the wrong place.")
+	case errors.Is(f.Err, errInvalidOpcode):
+		hints = append(hints, "It ran into data rather than code, perhaps after jumping to the wrong place.")
+	case f.Opcode&0xF000 == 0xD000 || f.Opcode&0xF0FF == 0xF055 || f.Opcode&0xF0FF == 0xF065 || f.Opcode&0xF0FF == 0xF033:
+		hints = append(hints, fmt.Sprintf("I (%03X) points too near the end of memory for it.", c.I))
+	}
+	return append(hints, "The ROM may be written for another variant or quirks: change them in Settings, then retry.")
+}
+
+// wrapText breaks s into lines of at most width characters at spaces
+func wrapText(s string, width int) []string {
+	words := strings.Fields(s)
+	if len(words) == 0 {
+		return []string{""}
+	}
+	var lines []string
+	line := words[0]
+	for _, w := range words[1:] {
+		if len(line)+1+len(w) > width {
+			lines = append(lines, line)
+			line = w
+			continue
+		}
+		line += " " + w
+	}
+	return append(lines, line)
+}
@@ -0,0 +1,139 @@
+package main
+
+import "fmt"
+
+// EventKind identifies a type of emulator event
+type EventKind uint8
+
+const (
+	// EventInstruction is published just before each instruction executes, so handlers see the
+	// state the instruction starts from
+	EventInstruction EventKind = iota
+
+	// EventDraw is published after DXYN draws a sprite and after 00E0 clears the screen
+	EventDraw
+
+	// EventKey is published when a CHIP-8 key goes down or comes back up
+	EventKey
+
+	// EventTimerTick is published once per frame after the timers count down
+	EventTimerTick
+
+	// EventFault is published when execution stops on a fault
+	EventFault
+
+	// EventStateLoaded is published after a save state or snapshot replaces the machine state
+	EventStateLoaded
+
+	// EventHalt is published when the program settles into a loop it cannot leave, see spinLoop
+	EventHalt
+
+	eventKinds
+)
+
+var eventKindNames = [...]string{
+	EventInstruction: "instruction",
+	EventDraw:        "draw",
+	EventKey:         "key",
+	EventTimerTick:   "timer",
+	EventFault:       "fault",
+	EventStateLoaded: "state",
+	EventHalt:        "halt",
+}
+
+func (k EventKind) String() string {
+	if int(k) < len(eventKindNames) {
+		return eventKindNames[k]
+	}
+	return fmt.Sprintf("EventKind(%d)", uint8(k))
+}
+
+// Event is implemented by the event types below; handlers type switch on it
+type Event interface {
+	Kind() EventKind
+}
+
+type InstructionEvent struct {
+	PC     uint16
+	Opcode uint16
+}
+
+type DrawEvent struct {
+	X, Y      uint8
+	Height    uint8
+	Collision bool
+	Clear     bool
+}
+
+type KeyEvent struct {
+	Key     byte
+	Pressed bool
+}
+
+type TimerTickEvent struct {
+	Frame  uint64
+	DT, ST uint8
+}
+
+type FaultEvent struct {
+	Fault *Fault
+}
+
+type StateLoadedEvent struct {
+	// file the state came from
+	Source string
+}
+
+type HaltEvent struct {
+	// first address of the loop
+	PC uint16
+}
+
+func (InstructionEvent) Kind() EventKind { return EventInstruction }
+func (DrawEvent) Kind() EventKind        { return EventDraw }
+func (KeyEvent) Kind() EventKind         { return EventKey }
+func (TimerTickEvent) Kind() EventKind   { return EventTimerTick }
+func (FaultEvent) Kind() EventKind       { return EventFault }
+func (StateLoadedEvent) Kind() EventKind { return EventStateLoaded }
+func (HaltEvent) Kind() EventKind        { return EventHalt }
+
+// EventBus delivers events to the handlers subscribed to their kind. Events are published and
+// handled on the emulator goroutine between or during instructions; handlers that hand work to
+// other goroutines must copy what they need. The zero value is ready to use.
+type EventBus struct {
+	handlers [eventKinds][]*eventHandler
+}
+
+type eventHandler struct {
+	fn func(Event)
+}
+
+// Subscribe registers fn for events of one kind, returning a function that removes it again
+func (b *EventBus) Subscribe(kind EventKind, fn func(Event)) (unsubscribe func()) {
+	h := &eventHandler{fn}
+	b.handlers[kind] = append(b.handlers[kind], h)
+
+	return func() {
+		hs := b.handlers[kind]
+		for i := range hs {
+			if hs[i] == h {
+				// copy so a Publish iterating the old slice is unaffected
+				b.handlers[kind] = append(hs[:i:i], hs[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// Has reports whether anything is subscribed to a kind, letting hot paths skip building events
+// nobody will see
+func (b *EventBus) Has(kind EventKind) bool {
+	return len(b.handlers[kind]) > 0
+}
+
+// Publish calls every handler subscribed to the event's kind
+func (b *EventBus) Publish(e Event) {
+	for _, h := range b.handlers[e.Kind()] {
+		h.fn(e)
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"runtime/debug"
+)
+
+// Fault is an instruction that could not be executed, such as one reading past the end of memory
+type Fault struct {
+	PC     uint16
+	Opcode uint16
+	Err    error
+
+	// Stack is the Go stack the panic was recovered on, for crash dumps
+	Stack []byte
+}
+
+func (f *Fault) Error() string {
+	return fmt.Sprintf("fault at %04X executing %04X: %v", f.PC, f.Opcode, f.Err)
+}
+
+func (f *Fault) Unwrap() error {
+	return f.Err
+}
+
+// fault stops the machine on a panic raised while executing an instruction, or stops executing
+// and shows the error screen when there is a window
+func (c *Chip8) fault(pc, opcode uint16, r any) {
+	err, ok := r.(error)
+	if !ok {
+		err = fmt.Errorf("%v", r)
+	}
+
+	c.Fault = &Fault{PC: pc, Opcode: opcode, Err: err, Stack: debug.Stack()}
+	log.Print(c.Fault)
+
+	c.Events.Publish(FaultEvent{c.Fault})
+
+	// with a window the fault is explained there, and the game can be retried without restarting
+	if c.Screen != nil {
+		c.showFault()
+	} else {
+		c.IsStopped = true
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+// endMachine is a machine of the variant with PC at pc and no ROM, the interpreter or the block
+// cache running it
+func endMachine(v Variant, blocks bool, pc uint16) *Chip8 {
+	c := newMachine()
+	c.SetVariant(v)
+	c.UseBlocks = blocks
+	c.PC = pc
+	return c
+}
+
+// TestFetchWrapsAtEnd runs the instruction split across the last byte of memory and address 0 on
+// the variants that wrap
+func TestFetchWrapsAtEnd(t *testing.T) {
+	for _, v := range []Variant{VariantChip8, VariantChip8X, VariantXOChip} {
+		for _, blocks := range []bool{false, true} {
+			c := endMachine(v, blocks, 0)
+			last := uint16(len(c.MainMemory) - 1)
+			c.PC = last
+			c.MainMemory[last], c.MainMemory[0] = 0x6A, 0x42 // VA = 42
+			c.MainMemory[1], c.MainMemory[2] = 0x6B, 0x07    // VB = 07
+
+			c.ExecuteCPU(2)
+			if c.Fault != nil {
+				t.Fatalf("%s blocks=%v: %v", v, blocks, c.Fault)
+			}
+			if c.Vx[0xA] != 0x42 || c.Vx[0xB] != 0x07 || c.PC != 3 {
+				t.Errorf("%s blocks=%v: VA=%02X VB=%02X PC=%04X, want 42, 07 and 0003", v, blocks, c.Vx[0xA], c.Vx[0xB], c.PC)
+			}
+		}
+	}
+}
+
+// TestFetchWrapsPastEnd continues from address 0 when a skip on the last instruction takes PC
+// beyond memory
+func TestFetchWrapsPastEnd(t *testing.T) {
+	for _, blocks := range []bool{false, true} {
+		c := endMachine(VariantChip8, blocks, 0xFFC)
+		copy(c.MainMemory[0xFFC:], []byte{0x30, 0x00, 0x6A, 0x01}) // skip if V0 == 0, VA = 1
+		c.MainMemory[0], c.MainMemory[1] = 0x6B, 0x02              // VB = 2
+
+		c.ExecuteCPU(2)
+		if c.Fault != nil {
+			t.Fatalf("blocks=%v: %v", blocks, c.Fault)
+		}
+		if c.Vx[0xA] != 0 || c.Vx[0xB] != 2 || c.PC != 2 {
+			t.Errorf("blocks=%v: VA=%02X VB=%02X PC=%04X, want 00, 02 and 0002", blocks, c.Vx[0xA], c.Vx[0xB], c.PC)
+		}
+	}
+}
+
+// TestFetchFaultsAtEnd faults on the variants that do not wrap, at the last byte and past it,
+// but still runs the last whole instruction
+func TestFetchFaultsAtEnd(t *testing.T) {
+	for _, v := range []Variant{VariantSChip, VariantDream6800} {
+		for _, blocks := range []bool{false, true} {
+			for _, pc := range []uint16{0xFFF, 0x1000} {
+				c := endMachine(v, blocks, pc)
+				c.ExecuteCPU(1)
+				if c.Fault == nil || !errors.Is(c.Fault, errFetchPastEnd) {
+					t.Errorf("%s blocks=%v PC=%04X: fault %v, want %v", v, blocks, pc, c.Fault, errFetchPastEnd)
+				} else if c.Fault.PC != pc {
+					t.Errorf("%s blocks=%v PC=%04X: fault at %04X", v, blocks, pc, c.Fault.PC)
+				}
+			}
+
+			c := endMachine(v, blocks, 0xFFE)
+			c.MainMemory[0xFFE], c.MainMemory[0xFFF] = 0x6A, 0x42
+			c.ExecuteCPU(1)
+			if c.Fault != nil || c.Vx[0xA] != 0x42 {
+				t.Errorf("%s blocks=%v: last instruction gave VA=%02X, fault %v", v, blocks, c.Vx[0xA], c.Fault)
+			}
+		}
+	}
+}
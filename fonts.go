@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// fontSets holds the hex fonts of the historical interpreters, 5 bytes per glyph 0-F. The font in
+// defaultSprites is the one popularised by Octo and most modern interpreters.
+var fontSets = map[string][]byte{
+	"octo": defaultSprites,
+
+	// COSMAC VIP (CHIP-8)
+	"vip": {
+		0xF0, 0x90, 0x90, 0x90, 0xF0, // 0
+		0x60, 0x20, 0x20, 0x20, 0x70, // 1
+		0xF0, 0x10, 0xF0, 0x80, 0xF0, // 2
+		0xF0, 0x10, 0xF0, 0x10, 0xF0, // 3
+		0xA0, 0xA0, 0xF0, 0x20, 0x20, // 4
+		0xF0, 0x80, 0xF0, 0x10, 0xF0, // 5
+		0xF0, 0x80, 0xF0, 0x90, 0xF0, // 6
+		0xF0, 0x10, 0x10, 0x10, 0x10, // 7
+		0xF0, 0x90, 0xF0, 0x90, 0xF0, // 8
+		0xF0, 0x90, 0xF0, 0x10, 0xF0, // 9
+		0xF0, 0x90, 0xF0, 0x90, 0x90, // A
+		0xF0, 0x50, 0x70, 0x50, 0xF0, // B
+		0xF0, 0x80, 0x80, 0x80, 0xF0, // C
+		0xF0, 0x50, 0x50, 0x50, 0xF0, // D
+		0xF0, 0x80, 0xF0, 0x80, 0xF0, // E
+		0xF0, 0x80, 0xF0, 0x80, 0x80, // F
+	},
+
+	// DREAM 6800 (CHIPOS), three pixels wide
+	"dream6800": {
+		0xE0, 0xA0, 0xA0, 0xA0, 0xE0, // 0
+		0x40, 0x40, 0x40, 0x40, 0x40, // 1
+		0xE0, 0x20, 0xE0, 0x80, 0xE0, // 2
+		0xE0, 0x20, 0xE0, 0x20, 0xE0, // 3
+		0x80, 0xA0, 0xA0, 0xE0, 0x20, // 4
+		0xE0, 0x80, 0xE0, 0x20, 0xE0, // 5
+		0xE0, 0x80, 0xE0, 0xA0, 0xE0, // 6
+		0xE0, 0x20, 0x20, 0x20, 0x20, // 7
+		0xE0, 0xA0, 0xE0, 0xA0, 0xE0, // 8
+		0xE0, 0xA0, 0xE0, 0x20, 0xE0, // 9
+		0xE0, 0xA0, 0xE0, 0xA0, 0xA0, // A
+		0xC0, 0xA0, 0xE0, 0xA0, 0xC0, // B
+		0xE0, 0x80, 0x80, 0x80, 0xE0, // C
+		0xC0, 0xA0, 0xA0, 0xA0, 0xC0, // D
+		0xE0, 0x80, 0xE0, 0x80, 0xE0, // E
+		0xE0, 0x80, 0xC0, 0x80, 0x80, // F
+	},
+
+	// ETI-660, three pixels wide
+	"eti660": {
+		0xE0, 0xA0, 0xA0, 0xA0, 0xE0, // 0
+		0x20, 0x20, 0x20, 0x20, 0x20, // 1
+		0xE0, 0x20, 0xE0, 0x80, 0xE0, // 2
+		0xE0, 0x20, 0xE0, 0x20, 0xE0, // 3
+		0xA0, 0xA0, 0xE0, 0x20, 0x20, // 4
+		0xE0, 0x80, 0xE0, 0x20, 0xE0, // 5
+		0xE0, 0x80, 0xE0, 0xA0, 0xE0, // 6
+		0xE0, 0x20, 0x20, 0x20, 0x20, // 7
+		0xE0, 0xA0, 0xE0, 0xA0, 0xE0, // 8
+		0xE0, 0xA0, 0xE0, 0x20, 0xE0, // 9
+		0xE0, 0xA0, 0xE0, 0xA0, 0xA0, // A
+		0xC0, 0xA0, 0xE0, 0xA0, 0xC0, // B
+		0xE0, 0x80, 0x80, 0x80, 0xE0, // C
+		0xC0, 0xA0, 0xA0, 0xA0, 0xC0, // D
+		0xE0, 0x80, 0xE0, 0x80, 0xE0, // E
+		0xE0, 0x80, 0xC0, 0x80, 0x80, // F
+	},
+}
+
+// LoadFont returns a bundled font set by name, or reads an 80 byte font file such as one saved by
+// the font editor
+func LoadFont(nameOrFile string) ([]byte, error) {
+	if font, ok := fontSets[strings.ToLower(nameOrFile)]; ok {
+		return font, nil
+	}
+
+	font, err := os.ReadFile(nameOrFile)
+	if err != nil {
+		names := make([]string, 0, len(fontSets))
+		for name := range fontSets {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("font %q is neither a bundled font (%s) nor a readable file: %w",
+			nameOrFile, strings.Join(names, ", "), err)
+	}
+	if len(font) != len(defaultSprites) {
+		return nil, fmt.Errorf("%s: a font is %d bytes, file has %d", nameOrFile, len(defaultSprites), len(font))
+	}
+
+	return font, nil
+}
+
+// SetFont replaces the hex font loaded into low memory
+func (c *Chip8) SetFont(font []byte) {
+	c.Font = font
+	c.LoadDefaultSprites()
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"log"
+	"strconv"
+	"time"
+)
+
+// budgetCheckFrames is how often the frame timings are looked at, once a second
+const budgetCheckFrames = 60
+
+// frameBudget measures how much of FrameDuration each frame takes to emulate and draw, so a host
+// too slow to keep up is noticed rather than silently running the game slow
+type frameBudget struct {
+	// moving averages over recent frames that ran instructions: the whole frame, and the part
+	// of it spent executing
+	work, cpu time.Duration
+	samples   int
+
+	// set by runFrame when the frame being timed ran instructions, and how long they took
+	stepped  bool
+	frameCPU time.Duration
+
+	// target is CyclesPerFrame before it was lowered and tuned what it was lowered to, both zero
+	// while it has not been
+	target, tuned int
+
+	warned bool
+}
+
+// record adds the timings of a frame that took work in all, if it executed instructions
+func (b *frameBudget) record(work time.Duration) {
+	if !b.stepped {
+		return
+	}
+	cpu := b.frameCPU
+	b.stepped = false
+
+	if b.samples == 0 {
+		b.work, b.cpu = work, cpu
+	} else {
+		// exponential moving average over roughly the last 16 frames
+		b.work += (work - b.work) / 16
+		b.cpu += (cpu - b.cpu) / 16
+	}
+	b.samples++
+}
+
+// String describes the budget for the input display, e.g. "9.1/16.7 ms"
+func (b *frameBudget) String() string {
+	return string(b.appendText(nil))
+}
+
+// appendText appends the String of the budget to dst, for callers drawing it every frame
+func (b *frameBudget) appendText(dst []byte) []byte {
+	dst = strconv.AppendFloat(dst, b.work.Seconds()*1000, 'f', 1, 64)
+	dst = append(dst, '/')
+	dst = strconv.AppendFloat(dst, FrameDuration.Seconds()*1000, 'f', 1, 64)
+	return append(dst, " ms"...)
+}
+
+// checkFrameBudget runs once a second from Run. When frames overrun it lowers CyclesPerFrame if
+// AutoCycles is set and executing is a good part of the time, otherwise warns once; when there is
+// time to spare again the speed is brought back up towards where it was.
+func (c *Chip8) checkFrameBudget() {
+	b := &c.budget
+	if b.samples < budgetCheckFrames {
+		return
+	}
+	// keep the averages, count afresh towards the next check
+	b.samples = 1
+
+	if b.tuned != 0 && c.CyclesPerFrame != b.tuned {
+		// changed from the menu or a sidecar since, so that is the speed wanted now
+		b.target, b.tuned = 0, 0
+	}
+
+	switch {
+	case b.work > FrameDuration && c.AutoCycles && b.cpu > b.work/4 && c.CyclesPerFrame > 1:
+		if b.target == 0 {
+			b.target = c.CyclesPerFrame
+		}
+		// scale execution to fit what drawing leaves of the frame, at most halving at a time
+		spare := FrameDuration*9/10 - (b.work - b.cpu)
+		cycles := int(float64(c.CyclesPerFrame) * max(spare.Seconds(), 0) / b.cpu.Seconds())
+		cycles = max(cycles, c.CyclesPerFrame/2, b.target/4, 1)
+		if cycles >= c.CyclesPerFrame {
+			cycles = c.CyclesPerFrame - 1
+		}
+
+		log.Printf("host too slow: frames take %v of %v, running %d instructions per frame instead of %d",
+			b.work.Round(time.Microsecond), FrameDuration.Round(time.Microsecond), cycles, b.target)
+		if b.tuned == 0 {
+			c.Notify("Host too slow, speed lowered to %d", cycles)
+		}
+		c.CyclesPerFrame, b.tuned = cycles, cycles
+
+	case b.work > FrameDuration && !b.warned:
+		log.Printf("host too slow: frames take %v of %v, so the game runs at %.0f%% speed",
+			b.work.Round(time.Microsecond), FrameDuration.Round(time.Microsecond), 100*FrameDuration.Seconds()/b.work.Seconds())
+		c.Notify("Host too slow for full speed")
+		b.warned = true
+
+	case b.tuned != 0 && b.work < FrameDuration*6/10:
+		cycles := min(b.target, c.CyclesPerFrame+max(c.CyclesPerFrame/10, 1))
+		c.CyclesPerFrame, b.tuned = cycles, cycles
+		if cycles == b.target {
+			log.Printf("frames fit again, back to %d instructions per frame", cycles)
+			b.target, b.tuned = 0, 0
+		}
+	}
+}
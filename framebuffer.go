@@ -0,0 +1,263 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// fbDisplay draws the CHIP-8 display on a Linux framebuffer device such as /dev/fb0, for a
+// Raspberry Pi console without X11 or OpenGL. The geometry comes from sysfs, which saves the
+// ioctls, and each frame is written whole, scaled up by the biggest whole number that fits and
+// centred. 16-bit RGB565 and 32-bit XRGB framebuffers are supported, the two a Pi offers.
+type fbDisplay struct {
+	f                     *os.File
+	width, height, stride int
+	bpp                   int
+
+	buf  []byte
+	last screenPlane
+	lit  bool // whether last has been drawn
+}
+
+// openFramebuffer opens a framebuffer device and reads its geometry from /sys/class/graphics
+func openFramebuffer(dev string) (*fbDisplay, error) {
+	sys := filepath.Join("/sys/class/graphics", filepath.Base(dev))
+	read := func(name string) (string, error) {
+		b, err := os.ReadFile(filepath.Join(sys, name))
+		return strings.TrimSpace(string(b)), err
+	}
+
+	size, err := read("virtual_size")
+	if err != nil {
+		return nil, fmt.Errorf("%s: no framebuffer geometry: %w", dev, err)
+	}
+	w, h, _ := strings.Cut(size, ",")
+	d := &fbDisplay{}
+	d.width, _ = strconv.Atoi(w)
+	d.height, _ = strconv.Atoi(h)
+	if s, err := read("bits_per_pixel"); err == nil {
+		d.bpp, _ = strconv.Atoi(s)
+	}
+	if s, err := read("stride"); err == nil {
+		d.stride, _ = strconv.Atoi(s)
+	}
+	if d.bpp != 16 && d.bpp != 32 {
+		return nil, fmt.Errorf("%s: %d bits per pixel, only 16 and 32 are supported", dev, d.bpp)
+	}
+	if d.stride == 0 {
+		d.stride = d.width * d.bpp / 8
+	}
+	if d.width < ScreenWidth || d.height < ScreenHeight {
+		return nil, fmt.Errorf("%s: %dx%d is smaller than the CHIP-8 display", dev, d.width, d.height)
+	}
+
+	if d.f, err = os.OpenFile(dev, os.O_WRONLY, 0); err != nil {
+		return nil, err
+	}
+	d.buf = make([]byte, d.stride*d.height)
+	return d, nil
+}
+
+// pixel encodes a colour in the framebuffer's format
+func (d *fbDisplay) pixel(r, g, b uint8) []byte {
+	if d.bpp == 16 {
+		v := uint16(r>>3)<<11 | uint16(g>>2)<<5 | uint16(b>>3)
+		return binary.LittleEndian.AppendUint16(nil, v)
+	}
+	return []byte{b, g, r, 0xFF}
+}
+
+// draw writes the display to the framebuffer if it changed since the last call
+func (d *fbDisplay) draw(c *Chip8) error {
+	if d.lit && c.ScreenState == d.last {
+		return nil
+	}
+	d.last, d.lit = c.ScreenState, true
+
+	scale := min(d.width/ScreenWidth, d.height/ScreenHeight)
+	left := (d.width - ScreenWidth*scale) / 2
+	top := (d.height - ScreenHeight*scale) / 2
+	on := d.pixel(c.ColorOn.R, c.ColorOn.G, c.ColorOn.B)
+	off := d.pixel(c.ColorOff.R, c.ColorOff.G, c.ColorOff.B)
+
+	// one framebuffer row is built per CHIP-8 row and copied down for the rest of its height
+	for y := 0; y < ScreenHeight; y++ {
+		first := d.buf[(top+y*scale)*d.stride:]
+		for x := 0; x < ScreenWidth; x++ {
+			px := off
+			if c.ScreenState.pixel(x, y) {
+				px = on
+			}
+			at := (left + x*scale) * len(px)
+			for range scale {
+				at += copy(first[at:], px)
+			}
+		}
+		for i := 1; i < scale; i++ {
+			copy(d.buf[(top+y*scale+i)*d.stride:], first[:d.stride])
+		}
+	}
+	_, err := d.f.WriteAt(d.buf, 0)
+	return err
+}
+
+// clear blanks the framebuffer, when leaving
+func (d *fbDisplay) clear() {
+	clear(d.buf)
+	d.f.WriteAt(d.buf, 0)
+	d.f.Close()
+}
+
+// evdevKeys maps Linux key codes to the keypad, laid out as defaultKeyMap lays out the keyboard
+var evdevKeys = map[uint16]uint8{
+	2: 0x1, 3: 0x2, 4: 0x3, 5: 0xC, // 1 2 3 4
+	16: 0x4, 17: 0x5, 18: 0x6, 19: 0xD, // Q W E R
+	30: 0x7, 31: 0x8, 32: 0x9, 33: 0xE, // A S D F
+	44: 0xA, 45: 0x0, 46: 0xB, 47: 0xF, // Z X C V
+
+	103: 0x2, 105: 0x4, 106: 0x6, 108: 0x8, // up, left, right, down
+}
+
+const (
+	evdevKeyEvent = 1 // EV_KEY
+	evdevEscape   = 1 // KEY_ESC
+)
+
+// findKeyboard picks the first keyboard udev lists under /dev/input/by-path
+func findKeyboard() (string, error) {
+	kbds, _ := filepath.Glob("/dev/input/by-path/*-event-kbd")
+	if len(kbds) == 0 {
+		return "", errors.New("no keyboard found under /dev/input/by-path, name its event device with -keyboard")
+	}
+	return kbds[0], nil
+}
+
+// readEvdev feeds key presses from an event device into ri until it fails, and cancels quit on
+// Escape. Events are a struct timeval, whose size follows the word size, then a 16-bit type,
+// a 16-bit code and a 32-bit value, 1 for a press, 0 for a release and 2 for a repeat.
+func readEvdev(dev string, ri *remoteInput, quit context.CancelFunc) error {
+	f, err := os.Open(dev)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	timeval := 2 * strconv.IntSize / 8
+	event := make([]byte, timeval+8)
+	for {
+		if _, err := io.ReadFull(f, event); err != nil {
+			return fmt.Errorf("%s: %w", dev, err)
+		}
+		typ := binary.LittleEndian.Uint16(event[timeval:])
+		code := binary.LittleEndian.Uint16(event[timeval+2:])
+		value := int32(binary.LittleEndian.Uint32(event[timeval+4:]))
+		if typ != evdevKeyEvent || value == 2 {
+			continue
+		}
+		if code == evdevEscape {
+			quit()
+			continue
+		}
+		if key, ok := evdevKeys[code]; ok {
+			ri.press(dev, key, value == 1)
+		}
+	}
+}
+
+// framebufferCommand implements "chip8 fb rom.ch8": the ROM runs full-screen on the Linux
+// framebuffer with keys read from evdev, for handhelds and cabinets without a desktop
+func framebufferCommand(args []string) error {
+	fs := newFlagSet("fb")
+	dev := fs.String("device", "/dev/fb0", "framebuffer device to draw on")
+	keyboard := fs.String("keyboard", "", "evdev device to read keys from, defaults to the first keyboard found unless there is a -gpio-keypad")
+	keypad := fs.String("gpio-keypad", "", "also read a 4x4 matrix keypad on these GPIO pins, rows from the top then columns from the left")
+	variant := fs.String("variant", "", "interpreter variant, defaults to the one detected for the ROM")
+	seed := fs.Int64("seed", 0, "seed for CXNN random numbers, 0 picks one from the clock")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return usageError("fb")
+	}
+
+	c := newMachine()
+	c.LoadDefaultSprites()
+	if err := c.loadRomFile(fs.Arg(0)); err != nil {
+		return err
+	}
+	if err := c.LoadSidecar(fs.Arg(0)); err != nil {
+		return err
+	}
+	if *variant != "" {
+		v, err := ParseVariant(*variant)
+		if err != nil {
+			return err
+		}
+		c.SetVariant(v)
+	}
+	if *seed != 0 {
+		c.SetSeed(*seed)
+	}
+
+	if *keyboard == "" && *keypad == "" {
+		var err error
+		if *keyboard, err = findKeyboard(); err != nil {
+			return err
+		}
+	}
+	d, err := openFramebuffer(*dev)
+	if err != nil {
+		return err
+	}
+	defer d.clear()
+	log.Printf("fb: %dx%d at %d bits per pixel", d.width, d.height, d.bpp)
+
+	// the console cursor would blink through the picture
+	fmt.Print("\x1b[?25l")
+	defer fmt.Print("\x1b[?25h")
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if *keyboard != "" {
+		log.Printf("fb: keys from %s, Escape quits", *keyboard)
+		keys := newRemoteInput()
+		go func() {
+			if err := readEvdev(*keyboard, keys, stop); err != nil {
+				log.Printf("fb: %v", err)
+				stop()
+			}
+		}()
+		c.OnFrame(keys.Apply)
+	}
+	if *keypad != "" {
+		if err := c.attachGPIOKeypad(*keypad); err != nil {
+			return err
+		}
+	}
+	c.OnFrame(func(c *Chip8) {
+		if err := d.draw(c); err != nil {
+			log.Printf("fb: %v", err)
+			c.IsStopped = true
+		}
+	})
+
+	err = c.Run(ctx)
+	if errors.Is(err, context.Canceled) {
+		return nil
+	}
+	var f *Fault
+	if errors.As(err, &f) {
+		c.reportCrash()
+	}
+	return err
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// gpioKeypadLayout is the keys of a 4x4 matrix keypad, row by row as on the COSMAC VIP
+var gpioKeypadLayout = [4][4]uint8{
+	{0x1, 0x2, 0x3, 0xC},
+	{0x4, 0x5, 0x6, 0xD},
+	{0x7, 0x8, 0x9, 0xE},
+	{0xA, 0x0, 0xB, 0xF},
+}
+
+const (
+	// gpioScanInterval is how often the whole keypad is scanned
+	gpioScanInterval = 2 * time.Millisecond
+	// gpioDebounceScans is how many scans running a key must read the same before it counts,
+	// long enough for the contacts to stop bouncing
+	gpioDebounceScans = 5
+)
+
+// gpioPin is a pin exported through the sysfs GPIO interface, its value file kept open
+type gpioPin struct {
+	num   int
+	value *os.File
+}
+
+// exportGPIO exports a pin and sets its direction, "in" or "high" for an output starting high.
+// The sysfs interface cannot set pull-ups, which the column pins need: on a Pi they are set
+// with a gpio=...=pu line in config.txt.
+func exportGPIO(num int, direction string) (*gpioPin, error) {
+	dir := fmt.Sprintf("/sys/class/gpio/gpio%d", num)
+	if _, err := os.Stat(dir); errors.Is(err, os.ErrNotExist) {
+		if err := os.WriteFile("/sys/class/gpio/export", []byte(strconv.Itoa(num)), 0); err != nil {
+			return nil, fmt.Errorf("exporting GPIO %d: %w", num, err)
+		}
+	}
+
+	// udev takes a moment to make a newly exported pin's files writable
+	var err error
+	for range 50 {
+		if err = os.WriteFile(filepath.Join(dir, "direction"), []byte(direction), 0); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("GPIO %d: %w", num, err)
+	}
+
+	value, err := os.OpenFile(filepath.Join(dir, "value"), os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &gpioPin{num: num, value: value}, nil
+}
+
+func (p *gpioPin) set(high bool) error {
+	v := []byte("0")
+	if high {
+		v[0] = '1'
+	}
+	_, err := p.value.WriteAt(v, 0)
+	return err
+}
+
+func (p *gpioPin) get() (bool, error) {
+	var v [1]byte
+	if _, err := p.value.ReadAt(v[:], 0); err != nil {
+		return false, err
+	}
+	return v[0] == '1', nil
+}
+
+// gpioKeypad scans a 4x4 matrix keypad wired to GPIO pins: each row is driven low in turn and
+// the columns, pulled up, read low where a key joins them to it
+type gpioKeypad struct {
+	rows, cols [4]*gpioPin
+	keys       *remoteInput
+
+	// per key, the debounced state and how many scans in a row have read otherwise
+	down    [16]bool
+	changed [16]int
+}
+
+// openGPIOKeypad sets up a keypad from a list of eight GPIO numbers, the four rows from the top
+// then the four columns from the left
+func openGPIOKeypad(spec string) (*gpioKeypad, error) {
+	fields := strings.Split(spec, ",")
+	if len(fields) != 8 {
+		return nil, fmt.Errorf("keypad pins %q: expected 8 GPIO numbers, 4 rows then 4 columns", spec)
+	}
+	k := &gpioKeypad{keys: newRemoteInput()}
+	for i, f := range fields {
+		num, err := strconv.Atoi(strings.TrimSpace(f))
+		if err != nil {
+			return nil, fmt.Errorf("keypad pins %q: %w", spec, err)
+		}
+		if i < 4 {
+			k.rows[i], err = exportGPIO(num, "high")
+		} else {
+			k.cols[i-4], err = exportGPIO(num, "in")
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return k, nil
+}
+
+// scan reads every key once, reporting those that have settled into a new state
+func (k *gpioKeypad) scan() error {
+	for r, row := range k.rows {
+		if err := row.set(false); err != nil {
+			return err
+		}
+		for col, pin := range k.cols {
+			high, err := pin.get()
+			if err != nil {
+				return err
+			}
+			key := gpioKeypadLayout[r][col]
+			if pressed := !high; pressed == k.down[key] {
+				k.changed[key] = 0
+			} else if k.changed[key]++; k.changed[key] >= gpioDebounceScans {
+				k.down[key], k.changed[key] = pressed, 0
+				k.keys.press(k, key, pressed)
+			}
+		}
+		if err := row.set(true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// run scans the keypad until it fails
+func (k *gpioKeypad) run() {
+	tick := time.NewTicker(gpioScanInterval)
+	defer tick.Stop()
+	for range tick.C {
+		if err := k.scan(); err != nil {
+			log.Printf("gpio keypad: %v", err)
+			return
+		}
+	}
+}
+
+// attachGPIOKeypad starts scanning a keypad and adds its keys to each frame's input
+func (c *Chip8) attachGPIOKeypad(spec string) error {
+	k, err := openGPIOKeypad(spec)
+	if err != nil {
+		return err
+	}
+	go k.run()
+	c.OnFrame(k.keys.Apply)
+	return nil
+}
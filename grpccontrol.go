@@ -0,0 +1,499 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/status"
+)
+
+// The control service is gRPC with JSON messages rather than protocol buffers, so the service is
+// described by hand below instead of generated. Go clients register an encoding.Codec named
+// "json" and call with grpc.CallContentSubtype("json"); the message types are the structs here.
+//
+//	service chip8.Control {
+//	  rpc Status(Empty) returns (Status);
+//	  rpc LoadRom(LoadRomRequest) returns (Status);
+//	  rpc Step(StepRequest) returns (Status);
+//	  rpc Pause(PauseRequest) returns (Status);
+//	  rpc ReadMemory(MemoryRequest) returns (Memory);
+//	  rpc WriteMemory(Memory) returns (Status);
+//	  rpc Frame(Empty) returns (Frame);
+//	  rpc Input(InputRequest) returns (Empty);
+//	  rpc Monitor(MonitorRequest) returns (MonitorOutput);
+//	  rpc Frames(FramesRequest) returns (stream Frame);
+//	  rpc Events(EventsRequest) returns (stream Event);
+//	}
+
+// jsonCodec marshals gRPC messages as JSON
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v any) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v any) error { return json.Unmarshal(data, v) }
+func (jsonCodec) Name() string                       { return "json" }
+
+func init() {
+	encoding.RegisterCodec(jsonCodec{})
+}
+
+type controlEmpty struct{}
+
+// controlStatus is the machine's registers and run state, returned by most calls
+type controlStatus struct {
+	Frame       uint64
+	PC, I       uint16
+	SP          uint8
+	DT, ST      uint8
+	Vx          [16]uint8
+	Paused      bool
+	Halted      bool   `json:",omitempty"`
+	Fault       string `json:",omitempty"`
+	RomFile     string `json:",omitempty"`
+	RomSha1     string `json:",omitempty"`
+	Variant     string
+	Peripherals []string `json:",omitempty"`
+}
+
+// loadRomRequest loads a ROM from a file under the server's -rom-dir, or from Data when it is set
+type loadRomRequest struct {
+	Path string
+	Data []byte
+}
+
+// stepRequest runs Instructions single instructions, then Frames whole frames
+type stepRequest struct {
+	Instructions int
+	Frames       int
+}
+
+type pauseRequest struct {
+	Paused bool
+}
+
+type memoryRequest struct {
+	Addr   uint16
+	Length int
+}
+
+// controlMemory is a block of memory read or to be written
+type controlMemory struct {
+	Addr uint16
+	Data []byte
+}
+
+// controlFrame is the display, one byte per pixel, row by row from the top left
+type controlFrame struct {
+	Frame         uint64
+	Width, Height int
+	Pixels        []byte
+}
+
+type inputRequest struct {
+	Key     uint8
+	Pressed bool
+}
+
+// monitorRequest is a monitor command line, e.g. "m 200 20"
+type monitorRequest struct {
+	Line string
+}
+
+type monitorOutput struct {
+	Text string
+}
+
+// framesRequest streams the display every Every frames (default 1) when it has been redrawn
+type framesRequest struct {
+	Every int
+}
+
+// eventsRequest streams the named event kinds, e.g. "draw" and "key"
+type eventsRequest struct {
+	Kinds []string
+}
+
+type controlEvent struct {
+	Kind  string
+	Event any
+}
+
+// controlMaxSteps bounds a single Step call, which holds the machine while it runs
+const controlMaxSteps = 1 << 20
+
+// controlServer implements the control service against a running machine
+type controlServer struct {
+	c     *Chip8
+	input *remoteInput
+
+	// directory LoadRom may read ROM files from, "" when clients must send the ROM itself
+	romDir string
+
+	// signalled when LoadRom replaces a program that faulted, see waitForReload
+	reloaded chan struct{}
+}
+
+func newControlServer(c *Chip8, input *remoteInput, romDir string) *controlServer {
+	return &controlServer{c: c, input: input, romDir: romDir, reloaded: make(chan struct{}, 1)}
+}
+
+// status describes the machine; the caller holds its lock
+func (s *controlServer) status() *controlStatus {
+	c := s.c
+	st := &controlStatus{
+		Frame: c.Frame, PC: c.PC, I: c.I, SP: c.SP, DT: c.DT, ST: c.ST, Vx: c.Vx,
+		Paused:  c.Paused || c.Debugger != nil && c.Debugger.Paused,
+		Halted:  c.Halted,
+		RomFile: c.RomFile,
+		RomSha1: c.RomHash,
+		Variant: c.Variant.String(),
+	}
+	if c.Fault != nil {
+		st.Fault = c.Fault.Error()
+	}
+	for _, p := range c.Peripherals() {
+		st.Peripherals = append(st.Peripherals, p.Name())
+	}
+	return st
+}
+
+func (s *controlServer) Status(ctx context.Context, _ *controlEmpty) (*controlStatus, error) {
+	s.c.Lock()
+	defer s.c.Unlock()
+	return s.status(), nil
+}
+
+func (s *controlServer) LoadRom(ctx context.Context, req *loadRomRequest) (*controlStatus, error) {
+	if req.Path == "" && len(req.Data) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "need a path or data to load")
+	}
+	// the variant is only picked once loading, which cuts off what that variant has no room for
+	if len(req.Data) > VariantXOChip.memorySize()-int(RamGameStart) {
+		return nil, status.Errorf(codes.InvalidArgument, "%d bytes do not fit in memory", len(req.Data))
+	}
+	file := req.Path
+	if req.Path != "" && len(req.Data) == 0 {
+		if s.romDir == "" {
+			return nil, status.Error(codes.PermissionDenied, "loading ROMs by path needs the server started with -rom-dir, send the ROM's data instead")
+		}
+		if !filepath.IsLocal(req.Path) {
+			return nil, status.Errorf(codes.PermissionDenied, "%q is outside the ROM directory", req.Path)
+		}
+		file = filepath.Join(s.romDir, req.Path)
+		if _, err := readRomFile(file); err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+
+	c := s.c
+	c.Lock()
+	defer c.Unlock()
+
+	c.Reset()
+	if len(req.Data) > 0 {
+		c.configureForRom(req.Path, req.Data)
+		c.LoadRom(req.Data)
+		c.Symbols, c.RomFile = nil, req.Path
+	} else if err := c.loadRomFile(file); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	c.IsStopped = false
+
+	select {
+	case s.reloaded <- struct{}{}:
+	default:
+	}
+	return s.status(), nil
+}
+
+func (s *controlServer) Step(ctx context.Context, req *stepRequest) (*controlStatus, error) {
+	c := s.c
+	c.Lock()
+	defer c.Unlock()
+
+	// frames are checked by division, as multiplying them out could overflow
+	if req.Instructions < 0 || req.Frames < 0 || req.Instructions > controlMaxSteps ||
+		req.Frames > (controlMaxSteps-req.Instructions)/max(c.CyclesPerFrame, 1) {
+		return nil, status.Errorf(codes.InvalidArgument, "can step between 0 and %d instructions at a time", controlMaxSteps)
+	}
+
+	if req.Instructions > 0 {
+		c.ExecuteCPU(req.Instructions)
+	}
+	for range req.Frames {
+		c.StepFrame()
+	}
+	return s.status(), nil
+}
+
+func (s *controlServer) Pause(ctx context.Context, req *pauseRequest) (*controlStatus, error) {
+	s.c.Lock()
+	defer s.c.Unlock()
+
+	s.c.Paused = req.Paused
+	return s.status(), nil
+}
+
+func (s *controlServer) ReadMemory(ctx context.Context, req *memoryRequest) (*controlMemory, error) {
+	c := s.c
+	c.Lock()
+	defer c.Unlock()
+
+	// memory is only sized once locked, as LoadRom may change the variant
+	if req.Length < 0 || int(req.Addr)+req.Length > len(c.MainMemory) {
+		return nil, status.Errorf(codes.OutOfRange, "%03X+%d runs past the end of memory", req.Addr, req.Length)
+	}
+
+	m := &controlMemory{Addr: req.Addr, Data: make([]byte, req.Length)}
+	for i := range m.Data {
+		m.Data[i] = c.readMemory(req.Addr + uint16(i))
+	}
+	return m, nil
+}
+
+func (s *controlServer) WriteMemory(ctx context.Context, req *controlMemory) (*controlStatus, error) {
+	c := s.c
+	c.Lock()
+	defer c.Unlock()
+
+	if int(req.Addr)+len(req.Data) > len(c.MainMemory) {
+		return nil, status.Errorf(codes.OutOfRange, "%03X+%d runs past the end of memory", req.Addr, len(req.Data))
+	}
+
+	for i, v := range req.Data {
+		c.writeMemory(req.Addr+uint16(i), v)
+	}
+	return s.status(), nil
+}
+
+func (s *controlServer) Frame(ctx context.Context, _ *controlEmpty) (*controlFrame, error) {
+	return frameMessage(s.c.Snapshot()), nil
+}
+
+func frameMessage(snap *Snapshot) *controlFrame {
+	f := &controlFrame{Frame: snap.Frame, Width: ScreenWidth, Height: ScreenHeight}
+	f.Pixels = make([]byte, 0, ScreenWidth*ScreenHeight)
+	for _, row := range snap.Screen {
+		f.Pixels = append(f.Pixels, row[:]...)
+	}
+	return f
+}
+
+func (s *controlServer) Input(ctx context.Context, req *inputRequest) (*controlEmpty, error) {
+	if req.Key > 0xF {
+		return nil, status.Errorf(codes.InvalidArgument, "key %d out of range, keys go from 0 to 15", req.Key)
+	}
+	s.input.press(s, req.Key, req.Pressed)
+	return &controlEmpty{}, nil
+}
+
+// controlMonitorCommands are the monitor commands clients may run. The rest of the debugger's
+// table reads and writes files on the server, which the control API does not allow.
+var controlMonitorCommands = map[string]bool{"m": true, "w": true, "r": true, "s": true, "g": true}
+
+// Monitor runs a monitor command and returns what it printed. The machine gets a debugger the
+// first time this is called, so stepping works from then on.
+func (s *controlServer) Monitor(ctx context.Context, req *monitorRequest) (*monitorOutput, error) {
+	if fields := strings.Fields(req.Line); len(fields) > 0 && !controlMonitorCommands[fields[0]] {
+		return nil, status.Errorf(codes.PermissionDenied, "%q is not one of the monitor commands m, w, r, s and g", fields[0])
+	}
+
+	c := s.c
+	c.Lock()
+	defer c.Unlock()
+
+	if c.Debugger == nil {
+		c.Debugger = newDebugger(io.Discard)
+	}
+	var out strings.Builder
+	saved := c.Debugger.out
+	c.Debugger.out = &out
+	defer func() { c.Debugger.out = saved }()
+
+	if err := c.debugCommand(req.Line); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return &monitorOutput{Text: out.String()}, nil
+}
+
+// Frames sends the display whenever it changed, checking every req.Every frames
+func (s *controlServer) Frames(req *framesRequest, stream grpc.ServerStream) error {
+	ticker := time.NewTicker(FrameDuration * time.Duration(max(req.Every, 1)))
+	defer ticker.Stop()
+
+	var last [32][64]uint8
+	first := true
+	for {
+		snap := s.c.Snapshot()
+		if first || snap.Screen != last {
+			if err := stream.SendMsg(frameMessage(snap)); err != nil {
+				return err
+			}
+			last, first = snap.Screen, false
+		}
+
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Events forwards events of the requested kinds as they are published. Events arriving faster
+// than the client reads them are dropped rather than holding up the machine.
+func (s *controlServer) Events(req *eventsRequest, stream grpc.ServerStream) error {
+	var kinds []EventKind
+	for _, name := range req.Kinds {
+		k := EventKind(0)
+		for k < eventKinds && k.String() != name {
+			k++
+		}
+		if k == eventKinds {
+			return status.Errorf(codes.InvalidArgument, "unknown event kind %q", name)
+		}
+		kinds = append(kinds, k)
+	}
+	if len(kinds) == 0 {
+		return status.Error(codes.InvalidArgument, "no event kinds requested")
+	}
+
+	events := make(chan Event, 256)
+	forward := func(e Event) {
+		select {
+		case events <- e:
+		default:
+		}
+	}
+
+	s.c.Lock()
+	var unsubscribe []func()
+	for _, k := range kinds {
+		unsubscribe = append(unsubscribe, s.c.Events.Subscribe(k, forward))
+	}
+	s.c.Unlock()
+
+	defer func() {
+		s.c.Lock()
+		for _, u := range unsubscribe {
+			u()
+		}
+		s.c.Unlock()
+	}()
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case e := <-events:
+			msg := controlEvent{Kind: e.Kind().String(), Event: e}
+			if f, ok := e.(FaultEvent); ok {
+				msg.Event = f.Fault.Error()
+			}
+			if err := stream.SendMsg(msg); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// waitForReload blocks until LoadRom replaces the program, reporting false if ctx ends first
+func (s *controlServer) waitForReload(ctx context.Context) bool {
+	for {
+		select {
+		case <-s.reloaded:
+		case <-ctx.Done():
+			return false
+		}
+
+		// the signal may be left over from a load before the fault
+		s.c.Lock()
+		running := !s.c.IsStopped
+		s.c.Unlock()
+		if running {
+			return true
+		}
+	}
+}
+
+// unaryMethod adapts a controlServer method to the shape grpc.ServiceDesc wants
+func unaryMethod[Req, Resp any](name string, fn func(*controlServer, context.Context, *Req) (*Resp, error)) grpc.MethodDesc {
+	return grpc.MethodDesc{
+		MethodName: name,
+		Handler: func(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+			req := new(Req)
+			if err := dec(req); err != nil {
+				return nil, err
+			}
+			s := srv.(*controlServer)
+			if interceptor == nil {
+				return fn(s, ctx, req)
+			}
+			info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/chip8.Control/" + name}
+			return interceptor(ctx, req, info, func(ctx context.Context, req any) (any, error) {
+				return fn(s, ctx, req.(*Req))
+			})
+		},
+	}
+}
+
+// streamMethod adapts a server streaming controlServer method
+func streamMethod[Req any](name string, fn func(*controlServer, *Req, grpc.ServerStream) error) grpc.StreamDesc {
+	return grpc.StreamDesc{
+		StreamName:    name,
+		ServerStreams: true,
+		Handler: func(srv any, stream grpc.ServerStream) error {
+			req := new(Req)
+			if err := stream.RecvMsg(req); err != nil {
+				return err
+			}
+			return fn(srv.(*controlServer), req, stream)
+		},
+	}
+}
+
+var controlServiceDesc = grpc.ServiceDesc{
+	ServiceName: "chip8.Control",
+	HandlerType: (*any)(nil),
+	Methods: []grpc.MethodDesc{
+		unaryMethod("Status", (*controlServer).Status),
+		unaryMethod("LoadRom", (*controlServer).LoadRom),
+		unaryMethod("Step", (*controlServer).Step),
+		unaryMethod("Pause", (*controlServer).Pause),
+		unaryMethod("ReadMemory", (*controlServer).ReadMemory),
+		unaryMethod("WriteMemory", (*controlServer).WriteMemory),
+		unaryMethod("Frame", (*controlServer).Frame),
+		unaryMethod("Input", (*controlServer).Input),
+		unaryMethod("Monitor", (*controlServer).Monitor),
+	},
+	Streams: []grpc.StreamDesc{
+		streamMethod("Frames", (*controlServer).Frames),
+		streamMethod("Events", (*controlServer).Events),
+	},
+}
+
+// serveControl starts the control service on addr in the background
+func serveControl(addr string, s *controlServer) (*grpc.Server, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	srv := grpc.NewServer(grpc.ForceServerCodec(jsonCodec{}))
+	srv.RegisterService(&controlServiceDesc, s)
+	go func() {
+		if err := srv.Serve(ln); err != nil {
+			log.Printf("control: %v", err)
+		}
+	}()
+	log.Printf("control: gRPC listening on %s", ln.Addr())
+	return srv, nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import "log"
+
+// spinLoop reports whether the program is stuck in a loop it can never leave, and where the loop
+// starts: a jump to itself, the usual way a CHIP-8 program ends, or one instruction that neither
+// branches, draws nor polls the keypad followed by a jump back to it. A loop that draws or skips
+// on a key is left alone, as it may be animating or waiting for input.
+func (c *Chip8) spinLoop() (uint16, bool) {
+	pc := c.PC
+	op := c.word(pc)
+	if jumpsTo(op, pc) {
+		return pc, true
+	}
+
+	// either instruction of a two instruction loop may be the one about to run
+	if pc >= 2 && jumpsTo(op, pc-2) && cannotLeaveLoop(c.word(pc-2)) {
+		return pc - 2, true
+	}
+	if next := pc + 2; cannotLeaveLoop(op) && jumpsTo(c.word(next), pc) {
+		return pc, true
+	}
+	return 0, false
+}
+
+// jumpsTo reports whether op is 1NNN jumping to addr. Addresses above 0xFFF, which XO-CHIP
+// reaches, cannot be the target of a jump.
+func jumpsTo(op, addr uint16) bool {
+	return addr <= 0xFFF && op&0xF000 == 0x1000 && op&0x0FFF == addr
+}
+
+// cannotLeaveLoop reports whether an instruction always falls through to the next one without
+// doing anything visible or reading the keys
+func cannotLeaveLoop(op uint16) bool {
+	switch op & 0xF000 {
+	case 0x6000, 0x7000, 0x8000, 0xA000, 0xC000:
+		return true
+	case 0xF000:
+		// everything but FX0A, which waits for a key, and the long I load, which is 4 bytes
+		return op&0x00FF != 0x0A && op != 0xF000
+	}
+	return false
+}
+
+// checkHalt runs after every frame and reports, once, that the program has settled into a loop:
+// a notification, a halt event and, with HaltPause, a pause so the host stops burning CPU on it
+func (c *Chip8) checkHalt() {
+	pc, spinning := c.spinLoop()
+	if !spinning || c.Halted {
+		c.Halted = spinning
+		return
+	}
+	c.Halted = true
+
+	log.Printf("program halted in a loop at %03X", pc)
+	c.Notify("Program halted")
+	c.Events.Publish(HaltEvent{pc})
+
+	if c.HaltPause {
+		if c.Screen != nil {
+			c.OpenMenu()
+			c.menu.title = "Program halted"
+		} else {
+			c.Paused = true
+		}
+	}
+}
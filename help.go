@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gopxl/pixel/v2"
+)
+
+// hotkey is a function key handled by the emulator rather than passed to the game
+type hotkey struct {
+	key    pixel.Button
+	help   string
+	action func(c *Chip8)
+}
+
+// hotkeys is filled in by init so the help overlay can list the table it is part of
+var hotkeys []hotkey
+
+func init() {
+	hotkeys = []hotkey{
+		{pixel.KeyF1, "show or hide this help", (*Chip8).ToggleHelp},
+		{pixel.KeyF2, "sprite viewer", (*Chip8).ToggleSpriteViewer},
+		{pixel.KeyF3, "frame counter, timing and input display", (*Chip8).ToggleInputDisplay},
+		{pixel.KeyF4, "run hex bytes or Octo source from the clipboard", (*Chip8).LoadClipboard},
+		{pixel.KeyF5, "show the ROM's title, author and controls", (*Chip8).ToggleRomInfo},
+		{pixel.KeyF6, "write session statistics to the -stats file", (*Chip8).writeStatsHotkey},
+		{pixel.KeyF10, "dump memory to a file", (*Chip8).dumpMemoryHotkey},
+	}
+}
+
+// keypadLayout is the hex keypad as it appears on the COSMAC VIP
+var keypadLayout = [4][4]byte{
+	{0x1, 0x2, 0x3, 0xC},
+	{0x4, 0x5, 0x6, 0xD},
+	{0x7, 0x8, 0x9, 0xE},
+	{0xA, 0x0, 0xB, 0xF},
+}
+
+// ToggleHelp shows or hides the hotkey help overlay; the game is paused while it is shown
+func (c *Chip8) ToggleHelp() {
+	c.helpShown = !c.helpShown
+	if !c.helpShown {
+		c.renderScreen()
+	}
+}
+
+// keyName shortens a button name for the keypad grid, e.g. "Up" rather than "KeyUp"
+func keyName(b pixel.Button) string {
+	return strings.TrimPrefix(b.String(), "Key")
+}
+
+// helpText builds the overlay from the hotkey table and the current keymap
+func (c *Chip8) helpText() string {
+	var b strings.Builder
+
+	b.WriteString("Hotkeys\n")
+	pause := "Esc"
+	if !c.bindings.pBound {
+		pause += "/P"
+	}
+	fmt.Fprintf(&b, "  %-6s pause menu\n", pause)
+	for _, h := range hotkeys {
+		fmt.Fprintf(&b, "  %-6s %s\n", keyName(h.key), h.help)
+	}
+
+	bound := map[byte][]string{}
+	for key, chip8Key := range c.KeyMap {
+		bound[chip8Key] = append(bound[chip8Key], keyName(key))
+	}
+
+	b.WriteString("\nKeypad\n")
+	for _, row := range keypadLayout {
+		b.WriteString(" ")
+		for _, k := range row {
+			names := bound[k]
+			sort.Strings(names)
+			label := strings.Join(names, ",")
+			if label == "" {
+				label = "-"
+			}
+			fmt.Fprintf(&b, " %X:%-8s", k, label)
+		}
+		b.WriteString("\n")
+	}
+
+	return b.String()
+}
+
+// drawHelp renders the game with the help overlay over it
+func (c *Chip8) drawHelp() {
+	c.renderScreen()
+	drawMenuOver(c.Screen, &pauseMenu{title: c.helpText()}, c)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"image/color"
+	"strconv"
+
+	"github.com/gopxl/pixel/v2"
+)
+
+// inputCellSize is the side of one keypad key in the input display, in window pixels
+const inputCellSize = 8
+
+// ToggleInputDisplay shows or hides the frame counter, frame timing and held keys in the top right
+// corner, for tool-assisted play, for matching trace lines up with what is on screen and for
+// seeing how much of each frame the host has to spare
+func (c *Chip8) ToggleInputDisplay() {
+	c.inputDisplay = !c.inputDisplay
+	c.screenDirty = true
+}
+
+// drawInputDisplay paints the frame number, the time frames take and a keypad with the keys held during the frame just
+// run lit up, over the screen renderScreen has just redrawn
+func (c *Chip8) drawInputDisplay() {
+	bounds := c.Screen.Bounds()
+	gap := 2.0
+	grid := 4*inputCellSize + 3*gap
+
+	o := c.beginOverlay(pixel.ZV)
+	o.buf = append(o.buf[:0], "frame "...)
+	o.buf = strconv.AppendUint(o.buf, c.Frame, 10)
+	o.buf = append(o.buf, '\n')
+	o.buf = c.budget.appendText(o.buf)
+	o.buf = append(o.buf, '\n')
+	o.buf = strconv.AppendInt(o.buf, int64(c.CyclesPerFrame), 10)
+	o.buf = append(o.buf, " per frame"...)
+	o.txt.Write(o.buf)
+
+	// written with its first baseline at the origin, then moved into the corner
+	b := o.txt.Bounds()
+	width := max(b.W(), grid)
+	at := pixel.V(bounds.Max.X-8-width-b.Min.X, bounds.Max.Y-6-menuAtlas.Ascent())
+
+	// keypad below the label, right aligned with it
+	top := b.Min.Y + at.Y - gap
+	left := bounds.Max.X - 8 - grid
+
+	o.shade.Push(pixel.V(bounds.Max.X-12-width, top-grid-4), pixel.V(bounds.Max.X-4, bounds.Max.Y-2))
+	o.shade.Rectangle(0)
+	o.shade.Draw(c.Screen)
+
+	if o.keyOn == nil || o.keyColors != [2]color.RGBA{c.ColorOn, c.ColorOff} {
+		o.keyOn, o.keyOff = pixel.ToRGBA(c.ColorOn), pixel.ToRGBA(c.ColorOff)
+		o.keyColors = [2]color.RGBA{c.ColorOn, c.ColorOff}
+	}
+	for row, keysInRow := range keypadLayout {
+		for col, k := range keysInRow {
+			o.keys.Color = o.keyOff
+			if c.KeyPressed[k] {
+				o.keys.Color = o.keyOn
+			}
+			minX := left + float64(col)*(inputCellSize+gap)
+			maxY := top - float64(row)*(inputCellSize+gap)
+			o.keys.Push(pixel.V(minX, maxY-inputCellSize), pixel.V(minX+inputCellSize, maxY))
+			o.keys.Rectangle(0)
+		}
+	}
+	o.keys.Draw(c.Screen)
+
+	o.txt.DrawColorMask(c.Screen, pixel.IM.Moved(at), overlayMask)
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Input recordings are text files: a header of "key value" lines giving the ROM hash, seed and
+// speed the recording was made with, and how far into the seed's random numbers it started, then a "frame pressed released" line for every frame the
+// keys changed on, with the keys as 16 bit hex masks (bit n is CHIP-8 key n). Played back with the
+// same ROM and seed, a recording reproduces the run exactly.
+
+// inputRecorder writes the keys pressed each frame to a recording
+type inputRecorder struct {
+	w      *bufio.Writer
+	closer io.Closer
+	last   [2]uint16
+	wrote  bool
+
+	// frame the recording started on, recorded frames count from there
+	base uint64
+}
+
+func newInputRecorder(file string, c *Chip8) (*inputRecorder, error) {
+	f, err := os.Create(file)
+	if err != nil {
+		return nil, err
+	}
+
+	r := recordInput(f, c)
+	r.closer = f
+
+	log.Printf("recording input to %s", file)
+	c.Notify("Recording started")
+	return r, nil
+}
+
+// recordInput starts a recording from the current frame, written to w
+func recordInput(w io.Writer, c *Chip8) *inputRecorder {
+	r := &inputRecorder{w: bufio.NewWriter(w), base: c.Frame}
+	fmt.Fprintf(r.w, "# chip8 input recording\nrom %s\nseed %d\ncycles %d\n", c.RomHash, c.Seed, c.CyclesPerFrame)
+	if c.VIPRand != nil {
+		fmt.Fprintf(r.w, "random vip %04x\n", c.VIPRand.r9)
+	} else if c.RandDraws > 0 {
+		fmt.Fprintf(r.w, "draws %d\n", c.RandDraws)
+	}
+	return r
+}
+
+// keyMask packs a key state array into a bit mask
+func keyMask(keys [16]bool) uint16 {
+	var mask uint16
+	for i, down := range keys {
+		if down {
+			mask |= 1 << i
+		}
+	}
+	return mask
+}
+
+// Record notes the keys for the current frame if they changed since the last one written
+func (r *inputRecorder) Record(c *Chip8) {
+	keys := [2]uint16{keyMask(c.KeyPressed), keyMask(c.KeyJustReleased)}
+	if r.wrote && keys == r.last {
+		return
+	}
+	fmt.Fprintf(r.w, "%d %04x %04x\n", c.Frame-r.base, keys[0], keys[1])
+	r.last, r.wrote = keys, true
+}
+
+// Close flushes the recording and closes the file it is written to, if any
+func (r *inputRecorder) Close() error {
+	err := r.w.Flush()
+	if r.closer != nil {
+		if cerr := r.closer.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// inputEvent is a change of keys taking effect on a frame
+type inputEvent struct {
+	frame             uint64
+	pressed, released uint16
+}
+
+// inputReplay feeds a recording back into the machine in place of the keyboard
+type inputReplay struct {
+	RomHash string
+	Seed    int64
+	Cycles  int
+
+	// recorded with CXNN following the VIP's random routine, and the routine's R9 when it started
+	// if known
+	VIPRandom bool
+	VIPR9     *uint16
+
+	// random numbers drawn from the seed before the recording started
+	Draws uint64
+
+	events  []inputEvent
+	next    int
+	current inputEvent
+	base    uint64
+}
+
+func loadInputReplay(file string) (*inputReplay, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return parseInputReplay(f, file)
+}
+
+// parseInputReplay reads a recording from r, naming it file in errors
+func parseInputReplay(r io.Reader, file string) (*inputReplay, error) {
+	var err error
+	p := &inputReplay{}
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		bad := func(err error) error {
+			return fmt.Errorf("%s:%d: %w", file, n, err)
+		}
+
+		switch fields[0] {
+		case "rom":
+			if len(fields) > 1 {
+				p.RomHash = fields[1]
+			}
+		case "seed":
+			if len(fields) != 2 {
+				return nil, bad(fmt.Errorf("expected seed N"))
+			}
+			if p.Seed, err = strconv.ParseInt(fields[1], 10, 64); err != nil {
+				return nil, bad(err)
+			}
+		case "random":
+			if len(fields) < 2 || len(fields) > 3 || fields[1] != "vip" {
+				return nil, bad(fmt.Errorf("expected random vip [R9]"))
+			}
+			p.VIPRandom = true
+			if len(fields) == 3 {
+				r9, err := strconv.ParseUint(fields[2], 16, 16)
+				if err != nil {
+					return nil, bad(err)
+				}
+				p.VIPR9 = new(uint16)
+				*p.VIPR9 = uint16(r9)
+			}
+		case "draws":
+			if len(fields) != 2 {
+				return nil, bad(fmt.Errorf("expected draws N"))
+			}
+			if p.Draws, err = strconv.ParseUint(fields[1], 10, 64); err != nil {
+				return nil, bad(err)
+			}
+		case "cycles":
+			if len(fields) != 2 {
+				return nil, bad(fmt.Errorf("expected cycles N"))
+			}
+			if p.Cycles, err = strconv.Atoi(fields[1]); err != nil {
+				return nil, bad(err)
+			}
+		default:
+			if len(fields) != 3 {
+				return nil, bad(fmt.Errorf("expected frame pressed released, found %q", line))
+			}
+			var e inputEvent
+			var pressed, released uint64
+			if e.frame, err = strconv.ParseUint(fields[0], 10, 64); err != nil {
+				return nil, bad(err)
+			}
+			if pressed, err = strconv.ParseUint(fields[1], 16, 16); err != nil {
+				return nil, bad(err)
+			}
+			if released, err = strconv.ParseUint(fields[2], 16, 16); err != nil {
+				return nil, bad(err)
+			}
+			e.pressed, e.released = uint16(pressed), uint16(released)
+			p.events = append(p.events, e)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// Start puts the machine in the conditions the recording was made under
+func (p *inputReplay) Start(c *Chip8) {
+	if p.RomHash != "" && p.RomHash != c.RomHash {
+		log.Printf("replay was recorded with a different ROM (%s), playback will drift", p.RomHash)
+	}
+	p.base, p.next, p.current = c.Frame, 0, inputEvent{}
+	if p.VIPRandom != (c.VIPRand != nil) {
+		log.Printf("replay was recorded with a different random number source, playback will drift; -vip-random picks the VIP's")
+	}
+	c.SetSeed(p.Seed)
+	if c.VIPRand != nil && p.VIPR9 != nil {
+		c.VIPRand.r9 = *p.VIPR9
+	} else if c.VIPRand == nil {
+		c.skipRand(p.Draws)
+	}
+	if p.Cycles > 0 {
+		c.CyclesPerFrame = p.Cycles
+	}
+}
+
+// Apply replaces the keyboard state with the recorded keys for the current frame
+func (p *inputReplay) Apply(c *Chip8) {
+	for p.next < len(p.events) && p.events[p.next].frame <= c.Frame-p.base {
+		p.current = p.events[p.next]
+		p.next++
+	}
+
+	for i := range c.KeyPressed {
+		c.KeyPressed[i] = p.current.pressed&(1<<i) != 0
+		c.KeyJustReleased[i] = p.current.released&(1<<i) != 0
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	"github.com/gopxl/pixel/v2"
+)
+
+// singleInstanceFlags are the run flags that only make sense for one machine at a time
+var singleInstanceFlags = []string{"debug", "trace", "snapshot", "record", "replay", "remote", "keypad", "coverage"}
+
+// checkInstanceFlags rejects flags that cannot be shared when running several ROMs
+func checkInstanceFlags() error {
+	var err error
+	runFlags.Visit(func(f *flag.Flag) {
+		if err == nil && slices.Contains(singleInstanceFlags, f.Name) {
+			err = fmt.Errorf("-%s cannot be used with more than one ROM", f.Name)
+		}
+	})
+	return err
+}
+
+// runInstances starts a machine for each ROM, each in its own window placed beside the last, and
+// runs them all until every window is closed or the process is interrupted
+func runInstances(roms []string) {
+	machines := make([]*Chip8, len(roms))
+	for i, rom := range roms {
+		c := setupMachine(rom)
+		c.Screen.SetTitle(fmt.Sprintf("Go - Chip8 Interpreter - %s", filepath.Base(rom)))
+		if i > 0 {
+			prev := machines[i-1].Screen
+			c.Screen.SetPos(prev.GetPos().Add(pixel.V(prev.Bounds().W(), 0)))
+		}
+		machines[i] = c
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	// window calls are handed to the main thread by the backend, so each machine can keep its own
+	// frame pace on its own goroutine
+	var wg sync.WaitGroup
+	for i, c := range machines {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := c.Run(ctx); err != nil && !errors.Is(err, context.Canceled) {
+				log.Printf("%s: %v", roms[i], err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if *autosave {
+		for _, c := range machines {
+			if err := c.Autosave(); err != nil {
+				log.Printf("autosave failed: %v", err)
+			}
+		}
+	}
+}
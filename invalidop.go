@@ -0,0 +1,62 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+// InvalidOpcodePolicy is what executing a word that decodes to no instruction does, usually data
+// run as code after a bad jump, or an instruction of a variant the interpreter does not have
+type InvalidOpcodePolicy uint8
+
+const (
+	// InvalidOpcodesLog skips the word, logging it the first time at each address
+	InvalidOpcodesLog InvalidOpcodePolicy = iota
+	// InvalidOpcodesIgnore skips the word silently
+	InvalidOpcodesIgnore
+	// InvalidOpcodesHalt faults, stopping the program on the word
+	InvalidOpcodesHalt
+)
+
+var invalidOpcodePolicies = map[string]InvalidOpcodePolicy{
+	"log":    InvalidOpcodesLog,
+	"ignore": InvalidOpcodesIgnore,
+	"halt":   InvalidOpcodesHalt,
+}
+
+// errInvalidOpcode is the fault raised under InvalidOpcodesHalt
+var errInvalidOpcode = errors.New("not an instruction")
+
+// ParseInvalidOpcodePolicy looks up a policy by name: log, ignore or halt
+func ParseInvalidOpcodePolicy(name string) (InvalidOpcodePolicy, error) {
+	if p, ok := invalidOpcodePolicies[strings.ToLower(name)]; ok {
+		return p, nil
+	}
+	names := make([]string, 0, len(invalidOpcodePolicies))
+	for n := range invalidOpcodePolicies {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return InvalidOpcodesLog, fmt.Errorf("unknown invalid opcode policy %q, expected %s", name, strings.Join(names, ", "))
+}
+
+// executeInvalid carries out the policy for the word just fetched, which decoded to opcodeInvalid
+func (c *Chip8) executeInvalid(opcode uint16) {
+	pc := c.PC - 2
+	switch c.InvalidOpcodes {
+	case InvalidOpcodesHalt:
+		panic(fmt.Errorf("%04X is %w", opcode, errInvalidOpcode))
+	case InvalidOpcodesLog:
+		if c.invalidLogged[pc] {
+			return
+		}
+		if c.invalidLogged == nil {
+			c.invalidLogged = map[uint16]bool{}
+		}
+		c.invalidLogged[pc] = true
+		log.Printf("%03X: %04X is not an instruction %s has, skipped", pc, opcode, c.Variant)
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"cmp"
+	"slices"
+
+	"github.com/gopxl/pixel/v2"
+)
+
+// keyBinding is one physical key playing one CHIP-8 key
+type keyBinding struct {
+	button pixel.Button
+	key    byte
+}
+
+// keyBindings is KeyMap laid out for handleInput to walk every frame, rebuilt whenever a binding
+// changes rather than every frame
+type keyBindings struct {
+	list []keyBinding
+
+	// P is played by the game, so it does not open the pause menu
+	pBound bool
+}
+
+// BindKey makes button play the CHIP-8 key, alongside any other buttons playing it
+func (c *Chip8) BindKey(button pixel.Button, key byte) {
+	if c.KeyMap == nil {
+		c.KeyMap = map[pixel.Button]byte{}
+	}
+	c.KeyMap[button] = key
+	c.rebuildBindings()
+}
+
+// SetKeyMap replaces every key binding
+func (c *Chip8) SetKeyMap(m map[pixel.Button]byte) {
+	c.KeyMap = m
+	c.rebuildBindings()
+}
+
+// rebuildBindings lays KeyMap out again, in button order so keys are always read the same way
+func (c *Chip8) rebuildBindings() {
+	b := &c.bindings
+	b.list = b.list[:0]
+	for button, key := range c.KeyMap {
+		b.list = append(b.list, keyBinding{button, key})
+	}
+	slices.SortFunc(b.list, func(x, y keyBinding) int { return cmp.Compare(x.button, y.button) })
+	_, b.pBound = c.KeyMap[pixel.KeyP]
+}
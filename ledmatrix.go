@@ -0,0 +1,84 @@
+package main
+
+import (
+	"io"
+	"log"
+	"net"
+	"os"
+	"strings"
+)
+
+// ledMatrixHeader starts every frame sent to an LED matrix: "C8" and the width and height, so a
+// microcontroller reading a serial line can find the start of a frame after missing bytes
+var ledMatrixHeader = []byte{'C', '8', ScreenWidth, ScreenHeight}
+
+// ledMatrix is a peripheral mirroring the display to a 64x32 RGB LED matrix, a common panel
+// size. Each change is sent as ledMatrixHeader and then 64x32 pixels of red, green and blue,
+// row by row from the top left, which a Pi driving the panel or a microcontroller behind a
+// serial port can push straight out. Frames are written on their own goroutine and dropped
+// while the last one is still going, so a slow link never holds up the game.
+type ledMatrix struct {
+	w      io.Writer
+	frames chan []byte
+
+	last screenPlane
+	sent bool
+}
+
+// openLEDMatrix connects to a matrix at udp:host:port, or a serial device or any other file
+// path, whose baud rate is set beforehand with stty
+func openLEDMatrix(target string) (*ledMatrix, error) {
+	var w io.Writer
+	var err error
+	if addr, ok := strings.CutPrefix(target, "udp:"); ok {
+		w, err = net.Dial("udp", addr)
+	} else {
+		w, err = os.OpenFile(target, os.O_WRONLY, 0)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	m := &ledMatrix{w: w, frames: make(chan []byte, 1)}
+	go m.send(target)
+	return m, nil
+}
+
+func (m *ledMatrix) Name() string { return "led matrix" }
+
+// Frame queues the display when it changed
+func (m *ledMatrix) Frame(c *Chip8) {
+	if m.sent && c.ScreenState == m.last {
+		return
+	}
+
+	frame := make([]byte, 0, len(ledMatrixHeader)+3*ScreenWidth*ScreenHeight)
+	frame = append(frame, ledMatrixHeader...)
+	for y := 0; y < ScreenHeight; y++ {
+		for x := 0; x < ScreenWidth; x++ {
+			col := c.ColorOff
+			if c.ScreenState.pixel(x, y) {
+				col = c.ColorOn
+			}
+			frame = append(frame, col.R, col.G, col.B)
+		}
+	}
+
+	select {
+	case m.frames <- frame:
+		m.last, m.sent = c.ScreenState, true
+	default:
+	}
+}
+
+// send writes queued frames, logging only the first failure as an unplugged panel or a UDP
+// receiver that is not up yet fails every frame
+func (m *ledMatrix) send(target string) {
+	failed := false
+	for frame := range m.frames {
+		if _, err := m.w.Write(frame); err != nil && !failed {
+			log.Printf("led matrix %s: %v", target, err)
+			failed = true
+		}
+	}
+}
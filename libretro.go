@@ -0,0 +1,385 @@
+package main
+
+// The emulator also builds as a libretro core, for RetroArch and other libretro frontends:
+//
+//	go build -buildmode=c-shared -o chip8_libretro.so .
+//
+// naming it chip8_libretro.dll on Windows and chip8_libretro.dylib on macOS. The core runs the
+// machine headless and hands the frontend each frame's display, a beep while the sound timer
+// runs, and save states in the usual format. The keypad is read from the first RetroPad and
+// from the keyboard, laid out as defaultKeyMap lays it out. Settings files next to the ROM are
+// applied as when running it directly.
+//
+// The core is the only place the emulator makes sound, so it has no audio device or buffer
+// settings of its own: the frontend owns the output device and its latency (in RetroArch, the
+// audio driver, device and latency settings). The core hands over each frame's samples as the
+// frame ends, so the beep starts within a frame of FX18 plus whatever the frontend buffers. The
+// desktop window plays no sound and has no audio to configure.
+
+/*
+#include <stdbool.h>
+#include <stddef.h>
+#include <stdint.h>
+
+// the parts of libretro.h the core uses
+
+#define RETRO_API_VERSION 1
+
+#define RETRO_DEVICE_JOYPAD 1
+#define RETRO_DEVICE_KEYBOARD 3
+
+#define RETRO_ENVIRONMENT_SET_PIXEL_FORMAT 10
+#define RETRO_PIXEL_FORMAT_XRGB8888 1
+
+#define RETRO_REGION_NTSC 0
+
+struct retro_system_info {
+	const char *library_name;
+	const char *library_version;
+	const char *valid_extensions;
+	bool need_fullpath;
+	bool block_extract;
+};
+
+struct retro_game_geometry {
+	unsigned base_width;
+	unsigned base_height;
+	unsigned max_width;
+	unsigned max_height;
+	float aspect_ratio;
+};
+
+struct retro_system_timing {
+	double fps;
+	double sample_rate;
+};
+
+struct retro_system_av_info {
+	struct retro_game_geometry geometry;
+	struct retro_system_timing timing;
+};
+
+struct retro_game_info {
+	const char *path;
+	const void *data;
+	size_t size;
+	const char *meta;
+};
+
+typedef bool (*retro_environment_t)(unsigned cmd, void *data);
+typedef void (*retro_video_refresh_t)(const void *data, unsigned width, unsigned height, size_t pitch);
+typedef void (*retro_audio_sample_t)(int16_t left, int16_t right);
+typedef size_t (*retro_audio_sample_batch_t)(const int16_t *data, size_t frames);
+typedef void (*retro_input_poll_t)(void);
+typedef int16_t (*retro_input_state_t)(unsigned port, unsigned device, unsigned index, unsigned id);
+
+// Go cannot call C function pointers itself
+
+static inline bool call_environment(retro_environment_t cb, unsigned cmd, void *data) {
+	return cb(cmd, data);
+}
+
+static inline void call_video(retro_video_refresh_t cb, const void *data, unsigned width, unsigned height, size_t pitch) {
+	cb(data, width, height, pitch);
+}
+
+static inline size_t call_audio_batch(retro_audio_sample_batch_t cb, const int16_t *data, size_t frames) {
+	return cb(data, frames);
+}
+
+static inline void call_input_poll(retro_input_poll_t cb) {
+	cb();
+}
+
+static inline int16_t call_input_state(retro_input_state_t cb, unsigned port, unsigned device, unsigned index, unsigned id) {
+	return cb(port, device, index, id);
+}
+*/
+import "C"
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"log"
+	"unsafe"
+)
+
+const (
+	// retroSampleRate is the rate of the beep handed to the frontend
+	retroSampleRate = 44100
+	// retroBeepHz is the pitch of the beep, a square wave
+	retroBeepHz = 440
+)
+
+// retroJoypad maps RetroPad buttons, by RETRO_DEVICE_ID_JOYPAD number, to the keypad: the d-pad
+// to 2, 4, 6 and 8, which most games steer with, and the face buttons to the keys around them
+var retroJoypad = map[uint]uint8{
+	4: 0x2, 5: 0x8, 6: 0x4, 7: 0x6, // up, down, left, right
+	8: 0x5, 0: 0x6, 9: 0x4, 1: 0x0, // A, B, X, Y
+	3: 0xF, 2: 0xE, // start, select
+}
+
+// retroKeyboard maps libretro key codes, which are ASCII for letters and digits, to the keypad
+var retroKeyboard = map[uint]uint8{
+	'1': 0x1, '2': 0x2, '3': 0x3, '4': 0xC,
+	'q': 0x4, 'w': 0x5, 'e': 0x6, 'r': 0xD,
+	'a': 0x7, 's': 0x8, 'd': 0x9, 'f': 0xE,
+	'z': 0xA, 'x': 0x0, 'c': 0xB, 'v': 0xF,
+}
+
+// retro is the state of the core between calls from the frontend, which makes them all from one
+// thread
+var retro struct {
+	environment C.retro_environment_t
+	video       C.retro_video_refresh_t
+	audioBatch  C.retro_audio_sample_batch_t
+	inputPoll   C.retro_input_poll_t
+	inputState  C.retro_input_state_t
+
+	c       *Chip8
+	sysInfo C.struct_retro_system_info
+
+	frame []uint32
+	audio []int16
+	phase int
+	held  [16]bool
+}
+
+//export retro_api_version
+func retro_api_version() C.uint { return C.RETRO_API_VERSION }
+
+//export retro_set_environment
+func retro_set_environment(cb C.retro_environment_t) { retro.environment = cb }
+
+//export retro_set_video_refresh
+func retro_set_video_refresh(cb C.retro_video_refresh_t) { retro.video = cb }
+
+//export retro_set_audio_sample
+func retro_set_audio_sample(cb C.retro_audio_sample_t) {}
+
+//export retro_set_audio_sample_batch
+func retro_set_audio_sample_batch(cb C.retro_audio_sample_batch_t) { retro.audioBatch = cb }
+
+//export retro_set_input_poll
+func retro_set_input_poll(cb C.retro_input_poll_t) { retro.inputPoll = cb }
+
+//export retro_set_input_state
+func retro_set_input_state(cb C.retro_input_state_t) { retro.inputState = cb }
+
+//export retro_init
+func retro_init() {
+	retro.frame = make([]uint32, ScreenWidth*ScreenHeight)
+	retro.audio = make([]int16, 2*retroSampleRate/FramesPerSecond)
+}
+
+//export retro_deinit
+func retro_deinit() {}
+
+//export retro_get_system_info
+func retro_get_system_info(info *C.struct_retro_system_info) {
+	// the strings must outlive the call, so they are made once and kept
+	if retro.sysInfo.library_name == nil {
+		retro.sysInfo = C.struct_retro_system_info{
+			library_name:     C.CString("chip8-go"),
+			library_version:  C.CString("1"),
+			valid_extensions: C.CString("ch8|c8|sc8|xo8|8o|c8b|gif|zip"),
+			// ROMs are read by loadRomFile, which opens zip archives and assembles Octo itself
+			need_fullpath: true,
+			block_extract: true,
+		}
+	}
+	*info = retro.sysInfo
+}
+
+//export retro_get_system_av_info
+func retro_get_system_av_info(info *C.struct_retro_system_av_info) {
+	info.geometry = C.struct_retro_game_geometry{
+		base_width:   ScreenWidth,
+		base_height:  ScreenHeight,
+		max_width:    ScreenWidth,
+		max_height:   ScreenHeight,
+		aspect_ratio: ScreenWidth / ScreenHeight,
+	}
+	info.timing = C.struct_retro_system_timing{fps: FramesPerSecond, sample_rate: retroSampleRate}
+}
+
+//export retro_set_controller_port_device
+func retro_set_controller_port_device(port, device C.uint) {}
+
+//export retro_load_game
+func retro_load_game(game *C.struct_retro_game_info) C.bool {
+	if game == nil || game.path == nil {
+		return false
+	}
+	format := C.uint(C.RETRO_PIXEL_FORMAT_XRGB8888)
+	if !C.call_environment(retro.environment, C.RETRO_ENVIRONMENT_SET_PIXEL_FORMAT, unsafe.Pointer(&format)) {
+		log.Printf("libretro: the frontend cannot show XRGB8888")
+		return false
+	}
+
+	romFile := C.GoString(game.path)
+	c := newMachine()
+	c.LoadDefaultSprites()
+	err := c.loadRomFile(romFile)
+	if err == nil {
+		err = c.LoadSidecar(romFile)
+	}
+	if err != nil {
+		log.Printf("libretro: loading %s: %v", romFile, err)
+		return false
+	}
+	retro.c = c
+	retro.held = [16]bool{}
+	return true
+}
+
+//export retro_load_game_special
+func retro_load_game_special(typ C.uint, info *C.struct_retro_game_info, n C.size_t) C.bool {
+	return false
+}
+
+//export retro_unload_game
+func retro_unload_game() { retro.c = nil }
+
+//export retro_reset
+func retro_reset() {
+	if retro.c == nil {
+		return
+	}
+	rom := retro.c.rom
+	retro.c.Reset()
+	retro.c.LoadRom(rom)
+	// a reset is how the frontend gets a program that stopped running again
+	retro.c.IsStopped = false
+}
+
+//export retro_run
+func retro_run() {
+	c := retro.c
+	if c == nil {
+		return
+	}
+
+	C.call_input_poll(retro.inputPoll)
+	c.handleInput()
+	var held [16]bool
+	for id, key := range retroJoypad {
+		if C.call_input_state(retro.inputState, 0, C.RETRO_DEVICE_JOYPAD, 0, C.uint(id)) != 0 {
+			held[key] = true
+		}
+	}
+	for id, key := range retroKeyboard {
+		if C.call_input_state(retro.inputState, 0, C.RETRO_DEVICE_KEYBOARD, 0, C.uint(id)) != 0 {
+			held[key] = true
+		}
+	}
+	for key := range held {
+		if retro.held[key] && !held[key] {
+			c.releaseKey(byte(key))
+		}
+	}
+	c.KeyPressed, retro.held = held, held
+
+	if c.Fault == nil && !c.IsStopped {
+		c.StepFrame()
+	}
+
+	for y := 0; y < ScreenHeight; y++ {
+		for x := 0; x < ScreenWidth; x++ {
+			col := c.ColorOff
+			if c.ScreenState.pixel(x, y) {
+				col = c.ColorOn
+			}
+			retro.frame[y*ScreenWidth+x] = uint32(col.R)<<16 | uint32(col.G)<<8 | uint32(col.B)
+		}
+	}
+	C.call_video(retro.video, unsafe.Pointer(&retro.frame[0]), ScreenWidth, ScreenHeight, 4*ScreenWidth)
+
+	// the beep keeps its phase from frame to frame so it does not click
+	period := retroSampleRate / retroBeepHz
+	for i := 0; i < len(retro.audio); i += 2 {
+		var v int16
+		if c.ST > 0 {
+			v = 0x1000
+			if retro.phase < period/2 {
+				v = -v
+			}
+		}
+		retro.audio[i], retro.audio[i+1] = v, v
+		retro.phase = (retro.phase + 1) % period
+	}
+	C.call_audio_batch(retro.audioBatch, (*C.int16_t)(unsafe.Pointer(&retro.audio[0])), C.size_t(len(retro.audio)/2))
+}
+
+// retroStateSize is the room given to a save state. The frontend needs the same size every
+// time, and as a state is compressed it is stored after its length in a buffer with room for
+// the worst case.
+func retroStateSize() int {
+	if retro.c == nil {
+		return 0
+	}
+	return 4 + len(retro.c.MainMemory) + 8192
+}
+
+//export retro_serialize_size
+func retro_serialize_size() C.size_t { return C.size_t(retroStateSize()) }
+
+//export retro_serialize
+func retro_serialize(data unsafe.Pointer, size C.size_t) C.bool {
+	if retro.c == nil {
+		return false
+	}
+	var b bytes.Buffer
+	if err := writeState(&b, retro.c.captureState()); err != nil {
+		log.Printf("libretro: saving state: %v", err)
+		return false
+	}
+	buf := unsafe.Slice((*byte)(data), size)
+	if 4+b.Len() > len(buf) {
+		log.Printf("libretro: save state is %d bytes, the frontend gave %d", b.Len(), len(buf)-4)
+		return false
+	}
+	binary.BigEndian.PutUint32(buf, uint32(b.Len()))
+	copy(buf[4:], b.Bytes())
+	return true
+}
+
+//export retro_unserialize
+func retro_unserialize(data unsafe.Pointer, size C.size_t) C.bool {
+	if retro.c == nil || size < 4 {
+		return false
+	}
+	buf := unsafe.Slice((*byte)(data), size)
+	n := binary.BigEndian.Uint32(buf)
+	err := errors.New("length is past the end")
+	if int64(n) <= int64(len(buf)-4) {
+		var s *saveState
+		if s, err = readState(bytes.NewReader(buf[4 : 4+n])); err == nil {
+			err = retro.c.restoreState(s, "libretro")
+		}
+	}
+	if err != nil {
+		log.Printf("libretro: loading state: %v", err)
+		return false
+	}
+	return true
+}
+
+//export retro_cheat_reset
+func retro_cheat_reset() {}
+
+//export retro_cheat_set
+func retro_cheat_set(index C.uint, enabled C.bool, code *C.char) {}
+
+//export retro_get_region
+func retro_get_region() C.uint { return C.RETRO_REGION_NTSC }
+
+//export retro_get_memory_data
+func retro_get_memory_data(id C.uint) unsafe.Pointer {
+	// memory is Go's, which C may not hold on to
+	return nil
+}
+
+//export retro_get_memory_size
+func retro_get_memory_size(id C.uint) C.size_t { return 0 }
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gopxl/pixel/v2"
+)
+
+// turboButton presses a keypad key on and off in a fixed pattern for as long as its physical key
+// is held, for games that want rapid tapping
+type turboButton struct {
+	key     byte
+	on, off int
+
+	// frames the physical key has been held for, and whether the keypad key was down last frame
+	held int
+	down bool
+}
+
+// inputMacro plays a fixed sequence of keypad states, one per frame, when its physical key is
+// pressed. Pressing the key again while it plays starts it over.
+type inputMacro struct {
+	steps []uint16
+
+	next    int
+	playing bool
+	last    uint16
+}
+
+// parseMacro reads a macro written as space separated steps of keys and an optional frame count:
+// "5x3 _x2 4+6" holds 5 for three frames, nothing for two, then 4 and 6 together for one
+func parseMacro(s string) (*inputMacro, error) {
+	m := &inputMacro{}
+	for _, step := range strings.Fields(s) {
+		keys, count, repeated := strings.Cut(step, "x")
+		frames := 1
+		if repeated {
+			n, err := strconv.Atoi(count)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("bad frame count in macro step %q", step)
+			}
+			frames = n
+		}
+
+		var mask uint16
+		if keys != "_" {
+			for _, k := range strings.Split(keys, "+") {
+				v, err := strconv.ParseUint(k, 16, 8)
+				if err != nil || v > 0xF {
+					return nil, fmt.Errorf("bad key %q in macro step %q, keys range from 0 to F", k, step)
+				}
+				mask |= 1 << v
+			}
+		}
+		for range frames {
+			m.steps = append(m.steps, mask)
+		}
+	}
+	if len(m.steps) == 0 {
+		return nil, fmt.Errorf("empty macro")
+	}
+	return m, nil
+}
+
+// applyMacros adds the keys from held turbo buttons and playing macros to this frame's input.
+// A key they let go of counts as released, so FX0A sees it like a real key press.
+func (c *Chip8) applyMacros() {
+	for button, t := range c.Turbo {
+		down := false
+		if c.Screen.Pressed(button) {
+			down = t.held%(t.on+t.off) < t.on
+			t.held++
+		} else {
+			t.held = 0
+		}
+
+		if down {
+			c.KeyPressed[t.key] = true
+		} else if t.down {
+			c.KeyJustReleased[t.key] = true
+		}
+		t.down = down
+	}
+
+	for button, m := range c.Macros {
+		if c.Screen.JustPressed(button) {
+			m.next, m.playing = 0, true
+		}
+		m.step(c)
+	}
+}
+
+// step adds the macro's keys for this frame to c's input, nothing once it has finished, and
+// releases the keys of its last frame that are no longer held
+func (m *inputMacro) step(c *Chip8) {
+	var mask uint16
+	if m.playing {
+		mask = m.steps[m.next]
+		m.next++
+		m.playing = m.next < len(m.steps)
+	}
+
+	for i := range c.KeyPressed {
+		switch bit := uint16(1) << i; {
+		case mask&bit != 0:
+			c.KeyPressed[i] = true
+		case m.last&bit != 0:
+			c.KeyJustReleased[i] = true
+		}
+	}
+	m.last = mask
+}
+
+// bindTurbo makes button auto-repeat key, held for on frames then released for off frames
+func (c *Chip8) bindTurbo(button pixel.Button, key byte, on, off int) {
+	if c.Turbo == nil {
+		c.Turbo = map[pixel.Button]*turboButton{}
+	}
+	c.Turbo[button] = &turboButton{key: key, on: on, off: off}
+}
+
+// bindMacro makes button play m when pressed
+func (c *Chip8) bindMacro(button pixel.Button, m *inputMacro) {
+	if c.Macros == nil {
+		c.Macros = map[pixel.Button]*inputMacro{}
+	}
+	c.Macros[button] = m
+}
@@ -1,10 +1,20 @@
 package main
 
 import (
-	"image"
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"image/color"
+	"io"
+	"log"
+	"math/bits"
 	"math/rand"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/gopxl/pixel/v2"
@@ -23,17 +33,21 @@ const (
 
 	CyclesToExecute = 10
 
-	FrameDuration = time.Second / 60
+	FramesPerSecond = 60
+	FrameDuration   = time.Second / FramesPerSecond
 )
 
 var (
 	colorOff = color.RGBA{0xd1, 0xd4, 0xcd, 255}
 	colorOn  = color.RGBA{0x74, 0x8c, 0xab, 255}
+
+	// colorHighlight marks the pixels set in Highlight
+	colorHighlight = color.RGBA{0xe0, 0x40, 0x40, 255}
 )
 
 type Chip8 struct {
-	// General Accessible Memory
-	MainMemory [0xFFF]byte
+	// General Accessible Memory, Sized For The Variant By SetVariant
+	MainMemory []byte
 
 	// General Purpose 8-Bit Registers (V0-VF)
 	Vx [16]uint8
@@ -47,6 +61,9 @@ type Chip8 struct {
 	// Sound Timer Register
 	ST uint8
 
+	// Sixtieths Of A Timer Tick Counted Towards The Next, For Variants Whose Timers Run Slower
+	timerPhase int
+
 	// Program Counter
 	PC uint16
 
@@ -60,48 +77,586 @@ type Chip8 struct {
 	Screen *opengl.Window
 
 	// Logical Representation Of Screen On/Off State
-	ScreenState [32][64]uint8
+	ScreenState screenPlane
+
+	// Canvas The Screen Is Rendered Through, Its Pixel Buffer And Whether It Is Out Of Date
+	canvas      *opengl.Canvas
+	pixels      []uint8
+	screenDirty bool
+
+	// What The Canvas Last Had Uploaded, So Unchanged Frames Are Not Uploaded Again
+	uploaded canvasFrame
+
+	// How The Canvas Is Scaled Up To The Window, And The Canvas It Is Scaled Onto
+	Filter   ScreenFilter
+	upscaler upscaler
 
 	IsStopped bool
 
+	// Holds Execution While Drawing And Input Carry On, For Embedders And Remote Control
+	Paused bool
+
 	KeyPressed [16]bool
 
 	KeyJustReleased [16]bool
+
+	// Interpreter Dialect And Its Behaviour Toggles
+	Variant Variant
+	Quirks  Quirks
+
+	// Known ROMs And The Settings They Need
+	RomDB RomDatabase
+
+	// Instructions Executed Per Frame
+	CyclesPerFrame int
+
+	// Frames Between Keyboard Releases Of A Key For The Second To Count, See releaseKey; With
+	// KeyRepeat, OS Key Repeat Counts As Pressing The Key Again
+	KeyDebounce int
+	KeyRepeat   bool
+	lastRelease [16]uint64
+	inputFrame  uint64
+
+	// What Running A Word That Is No Instruction Does, And Where Those Were Already Logged
+	InvalidOpcodes InvalidOpcodePolicy
+	invalidLogged  map[uint16]bool
+
+	// Fault On DXYN, FX33, FX55 And FX65 Reaching Past The End Of Memory Rather Than Wrapping
+	StrictMemory bool
+
+	// Set While The Program Is Stuck In A Loop It Cannot Leave, Which Pauses It With HaltPause
+	Halted    bool
+	HaltPause bool
+
+	// Lower CyclesPerFrame While The Host Cannot Keep Up, See checkFrameBudget
+	AutoCycles bool
+	budget     frameBudget
+
+	// Pixel Colors
+	ColorOn  color.RGBA
+	ColorOff color.RGBA
+
+	// Drawn On The Window In Place Of ColorOff When Non-Nil, Transparent If Its Alpha Is 0
+	ChromaKey *color.RGBA
+
+	// Weight Of The Previous Frame When Blending Frames, 0 Disables
+	Blend     float64
+	prevFrame screenPlane
+
+	// Pixels Drawn In colorHighlight Whatever Their State, Such As Where Two Displays Differ
+	Highlight screenPlane
+
+	// Physical Keys Bound To The 16 CHIP-8 Keys, Changed Through BindKey Or SetKeyMap
+	KeyMap   map[pixel.Button]byte
+	bindings keyBindings
+
+	// Physical Keys That Auto-Repeat A CHIP-8 Key Or Play A Sequence Of Them
+	Turbo  map[pixel.Button]*turboButton
+	Macros map[pixel.Button]*inputMacro
+
+	// Hex Font Loaded Into Low Memory, defaultSprites When Nil, And Whether SetVariant Chose It
+	Font        []byte
+	variantFont bool
+
+	// Labels And Source Lines For The Loaded ROM
+	Symbols *SymbolTable
+
+	// Destination For Per-Instruction Trace Lines, Written While Tracing Is On
+	Trace   io.Writer
+	untrace func()
+
+	// Source Of CXNN Random Numbers, The Seed It Started From And The Numbers Drawn Since, Or The
+	// VIP Routine Used Instead
+	Rand      *rand.Rand
+	Seed      int64
+	RandDraws uint64
+	VIPRand   *vipRandom
+
+	// Frames Run Since Start
+	Frame uint64
+
+	// ROM File Running, Its Contents As Loaded And Its SHA-1, Which Keys Its Autosave
+	RomFile string
+	rom     []byte
+	RomHash string
+
+	// IPS Or BPS Patch Applied To RomFile As It Loads
+	Patch string
+
+	// Rolling Hash Of Sampled Frames, See StateHash
+	stateHash uint64
+
+	// Held By The Emulator Loop While It Runs A Frame, See Snapshot
+	lock machineLock
+
+	// Subscribers To Instruction, Draw, Key And Other Events
+	Events EventBus
+
+	// Fault Execution Stopped On, If Any, And The Instructions Leading Up To It
+	Fault  *Fault
+	recent recentInstructions
+
+	// Addresses Executed, Recorded Only When Non-Nil
+	Coverage *coverage
+
+	// Counts For -stats, Kept Only When Non-Nil
+	stats *sessionStats
+
+	// Instructions Already Decoded, By Address
+	decoded decodeCache
+
+	// Run Straight-Line Code A Basic Block At A Time When Nothing Is Watching Single Steps
+	UseBlocks bool
+	blocks    blockCache
+
+	// Ahead Of Time Compiled Code For The Loaded ROM, If This Build Has It
+	transpiled *transpiledRom
+
+	// Extensions Claiming Opcodes, Memory Or Frame Callbacks
+	peripherals peripherals
+
+	// Callbacks Run By Run After Every Frame's Input Is Read, See OnFrame
+	frameHooks []func(c *Chip8)
+
+	// Last Save State And The Input Since, And A Save State's Input Being Replayed
+	sinceSave   *stateBundle
+	stateReplay *inputReplay
+
+	// Set While The Hotkey Help Overlay Is Shown
+	helpShown bool
+
+	// Set While The Frame Counter And Held Keys Are Shown
+	inputDisplay bool
+
+	// Notifications Shown Over The Game
+	osd osd
+
+	// Database Entry Of The Running ROM, Shown Over The Game When It Starts
+	romCard romCard
+
+	// Text And Shapes The Overlays Are Drawn With, Reused Every Frame
+	overlay overlay
+
+	// Pause Menu Page Shown Over The Game, Nil While Playing
+	menu *pauseMenu
+
+	// Terminal Debugger, Nil Unless Enabled
+	Debugger *Debugger
+
+	// Debug Windows, Open When Non-Nil
+	spriteViewer *spriteViewer
 }
 
+// runFlags configure the emulator for the run, debug and record subcommands
+var runFlags = flag.NewFlagSet("run", flag.ContinueOnError)
+
+var romDBFile = runFlags.String("romdb", "", "JSON database of known ROMs used to pick variant and quirks")
+
+var symbolFile = runFlags.String("symbols", "", "symbol listing mapping labels and source lines to addresses")
+
+var traceFile = runFlags.String("trace", "", "write a line per executed instruction to this file")
+
+var fontName = runFlags.String("font", "", "hex font to load: octo, vip, dream6800, eti660 or an 80 byte font file")
+
+var debugEnabled = runFlags.Bool("debug", false, "accept debugger commands on stdin")
+
+var debugTUI = runFlags.Bool("tui", false, "with -debug or the debug command, show a terminal UI instead of reading plain commands")
+
+var snapshotFile = runFlags.String("snapshot", "", "boot from a raw memory image instead of a ROM")
+
+var registersFile = runFlags.String("registers", "", "register file for -snapshot, defaults to the .txt summary next to the image")
+
+var seed = runFlags.Int64("seed", 0, "seed for CXNN random numbers, 0 picks one from the clock")
+
+var vipRandomFile = runFlags.String("vip-random", "", "COSMAC VIP interpreter image whose random routine CXNN follows instead of Go's, seeded by -seed")
+
+var hashEvery = runFlags.Uint64("hash-every", 0, "log a rolling state hash every N frames to check runs are deterministic")
+
+var autosave = runFlags.Bool("autosave", true, "save state on exit and offer to resume it next time the ROM is started; -autosave=false turns it off")
+
+var variantName = runFlags.String("variant", "", "interpreter variant (chip8, schip, xo-chip, chip8x, dream6800); defaults to a guess from the ROM extension")
+
+var blend = runFlags.Float64("blend", 0, "blend each frame with the previous one, giving it this weight (0.5 is an even mix, 0 disables)")
+
+var verifyRom = runFlags.Bool("verify", false, "check ROMs missing from -romdb against its known dumps, warning about truncated, overdumped or modified files")
+
+var filterName = runFlags.String("filter", "nearest", "how the display is scaled up to the window: nearest, smooth or scanlines")
+
+var onTop = runFlags.Bool("on-top", false, "keep the window above other windows, e.g. while streaming")
+
+var chromaKey = runFlags.String("chroma-key", "", "draw unlit pixels on the window in this #rrggbb color for streaming software to key out, or \"transparent\" to leave them see-through")
+
+var gpioKeypadPins = runFlags.String("gpio-keypad", "", "read a 4x4 matrix keypad on these Raspberry Pi GPIO pins, rows from the top then columns from the left, e.g. 5,6,13,19,12,16,20,21")
+
+var matrixTarget = runFlags.String("matrix", "", "mirror the display to a 64x32 RGB LED matrix at udp:host:port or a serial device, each frame \"C8\", 64, 32 and then 64x32 RGB pixels")
+
+var shmFile = runFlags.String("shm", "", "mirror memory, registers and the display to this file each frame for external tools to map, e.g. /dev/shm/chip8; see sharedstate.go for the layout")
+
+var serialAddr = runFlags.String("serial", "", "map a serial output port printing to stdout at this address, e.g. 0xFF0")
+
+var recordFile = runFlags.String("record", "", "write the keys pressed each frame to this file for later -replay")
+
+var chatURL = runFlags.String("chat", "", "take keypad input from chat: irc://[nick[:password]@]host:port/#channel or tcp://host:port")
+
+var chatCooldown = runFlags.Duration("chat-cooldown", time.Second, "with -chat, the least time between two commands from one user")
+
+var chatMaxFrames = runFlags.Int("chat-max-frames", 60, "with -chat, the longest key sequence accepted, in frames")
+
+var remoteAddr = runFlags.String("remote", "", "accept keypad input from WebSocket controllers at ws://addr/input, with a touch keypad at http://addr/")
+
+var keypadPage = runFlags.Bool("keypad", false, "serve a touch keypad for phones, on 127.0.0.1:8080 unless -remote gives an address they can reach such as :8080")
+
+var crashDump = runFlags.Bool("crash-dump", true, "write registers, recent instructions and memory to a file when the program faults")
+
+var coverageFile = runFlags.String("coverage", "", "record which ROM addresses execute and write them to this file on exit, adding to what it already holds; see disasm -coverage")
+
+var statsFile = runFlags.String("stats", "", "write instruction, opcode, draw, frame time and key press counts for the session to this file on exit or with F6, as CSV if it ends in .csv and JSON otherwise; counting slows emulation a little")
+
+var autoCycles = runFlags.Bool("auto-cycles", false, "run fewer instructions per frame while the host is too slow to keep to 60 frames a second, rather than slowing the game")
+
+var invalidOpcodes = runFlags.String("invalid-opcodes", "log", "what running a word that is no instruction does: log it and skip it, ignore it, or halt with a fault")
+
+var strictMemory = runFlags.Bool("strict-memory", false, "fault when DXYN, FX33, FX55 or FX65 reach past the end of memory instead of wrapping around to address 0")
+
+var haltPause = runFlags.Bool("halt-pause", false, "pause when the program ends in a loop it cannot leave, such as a jump to itself")
+
+var debounce = runFlags.Int("debounce", 0, "ignore a key's release if it comes within this many frames of the last, for keyboards that chatter")
+
+var keyRepeat = runFlags.Bool("key-repeat", false, "let the OS repeat held keys, so holding a key scrolls FX0A menus")
+
+var dataDirFlag = runFlags.String("data-dir", "", "keep saves, dumps, recordings and settings under this directory instead of the user's data and config directories")
+
+var patchFile = runFlags.String("patch", "", "IPS or BPS patch to apply to the ROM as it loads, for translations, fixes and hacks")
+
+var replayFile = runFlags.String("replay", "", "play back keys from an input recording made with the record subcommand, or a .c8s save state with input")
+
 func main() {
-	opengl.Run(run)
+	if err := runSubcommand(os.Args[1:]); err != nil {
+		switch {
+		case errors.Is(err, flag.ErrHelp):
+			return
+		case err != errTracesDiffer && err != errSelfTestFailed:
+			fmt.Fprintln(os.Stderr, err)
+		}
+		os.Exit(1)
+	}
 }
 
 func run() {
-	c := NewChip8()
+	romFile := "./flightrunner.ch8"
+	switch {
+	case attract != nil:
+		romFile = attract.Entries[0].Rom
+	case runFlags.NArg() > 1:
+		runInstances(runFlags.Args())
+		return
+	case runFlags.NArg() > 0:
+		romFile = runFlags.Arg(0)
+	}
+
+	c := setupMachine(romFile)
+
+	switch {
+	case *debugEnabled && *debugTUI:
+		c.Debugger = NewTUIDebugger(os.Stdin, os.Stdout)
+	case *debugEnabled:
+		c.Debugger = NewDebugger(os.Stdin, os.Stdout)
+	}
+
+	if *coverageFile != "" {
+		cv, err := loadCoverageFile(*coverageFile, c.RomHash, len(c.MainMemory))
+		if err != nil {
+			panic(err)
+		}
+		c.Coverage = cv
+	}
+
+	if *statsFile != "" {
+		c.startStats(*statsFile)
+	}
+
+	if *traceFile != "" {
+		f, err := os.Create(*traceFile)
+		if err != nil {
+			panic(err)
+		}
+		defer f.Close()
+
+		w := bufio.NewWriter(f)
+		defer w.Flush()
+		c.Trace = w
+		c.SetTracing(true)
+	}
+
+	if strings.EqualFold(filepath.Ext(*replayFile), ".c8s") {
+		if err := c.ReplayStateFile(*replayFile); err != nil {
+			panic(err)
+		}
+	} else if *replayFile != "" {
+		p, err := loadInputReplay(*replayFile)
+		if err != nil {
+			panic(err)
+		}
+		p.Start(c)
+		c.OnFrame(p.Apply)
+		c.Notify("Replaying %s", filepath.Base(*replayFile))
+	}
+
+	if attract != nil {
+		attract.Start(c)
+		c.OnFrame(func(c *Chip8) {
+			if c.menu == nil {
+				attract.Update(c)
+			}
+		})
+	}
+
+	if *chatURL != "" {
+		chat, err := dialChat(*chatURL, *chatCooldown, *chatMaxFrames)
+		if err != nil {
+			panic(err)
+		}
+		c.OnFrame(chat.Apply)
+	}
+
+	if *keypadPage && *remoteAddr == "" {
+		*remoteAddr = "127.0.0.1:8080"
+	}
+	if *remoteAddr != "" {
+		remote, srv, err := serveRemoteInput(*remoteAddr)
+		if err != nil {
+			panic(err)
+		}
+		defer srv.Close()
+		c.OnFrame(remote.Apply)
+
+		if *keypadPage {
+			urls := keypadURLs(*remoteAddr)
+			for _, u := range urls {
+				log.Printf("keypad: open %s on a phone", u)
+			}
+			if len(urls) > 0 {
+				c.Notify("Keypad at %s", urls[0])
+			}
+		}
+	}
+
+	if *recordFile != "" {
+		r, err := newInputRecorder(*recordFile, c)
+		if err != nil {
+			panic(err)
+		}
+		defer r.Close()
+		c.OnFrame(r.Record)
+	}
+
+	// Ctrl-C stops the machine the same way closing the window does, so it still autosaves
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	// a fault has been shown in the window, with any crash dump, before the game was quit
+	c.Run(ctx)
+	if c.Coverage != nil {
+		if err := c.WriteCoverageFile(*coverageFile); err != nil {
+			log.Printf("writing coverage failed: %v", err)
+		}
+	}
+	if c.stats != nil {
+		if err := c.WriteStats(); err != nil {
+			log.Printf("writing statistics failed: %v", err)
+		}
+	}
+	// saved after a fault too, so the session leading up to it is not lost
+	if *autosave && *snapshotFile == "" {
+		if err := c.Autosave(); err != nil {
+			log.Printf("autosave failed: %v", err)
+		}
+	}
+}
+
+// setupMachine opens a window and loads romFile, or the -snapshot image, into it with the
+// settings from runFlags that every instance shares
+func setupMachine(romFile string) *Chip8 {
+	var key *color.RGBA
+	if *chromaKey != "" {
+		col, err := parseChromaKey(*chromaKey)
+		if err != nil {
+			panic(fmt.Errorf("-chroma-key: %w", err))
+		}
+		key = &col
+	}
+	c := NewChip8(windowOptions{OnTop: *onTop, Transparent: key != nil && key.A == 0})
+	c.ChromaKey = key
 
 	c.LoadDefaultSprites()
 
-	c.LoadRomFile("./flightrunner.ch8")
+	if *romDBFile != "" {
+		db, err := LoadRomDatabase(*romDBFile)
+		if err != nil {
+			panic(err)
+		}
+		c.RomDB = db
+	}
+
+	if *snapshotFile != "" {
+		if err := c.LoadSnapshot(*snapshotFile, *registersFile); err != nil {
+			panic(err)
+		}
+	} else {
+		c.Patch = *patchFile
+		err := c.loadRomFile(romFile)
+		if err == nil {
+			err = c.LoadSidecar(romFile)
+		}
+		if err != nil {
+			log.Printf("loading %s: %v", romFile, err)
+			c.showLoadError(romFile, err)
+		}
+	}
+
+	if *autosave && *snapshotFile == "" && c.RomHash != "" {
+		c.offerResume()
+	}
 
-	for !c.Screen.Closed() && !c.IsStopped {
-		cycleStartTime := time.Now()
+	if *variantName != "" {
+		v, err := ParseVariant(*variantName)
+		if err != nil {
+			panic(err)
+		}
+		c.SetVariant(v)
+	}
 
-		c.ExecuteCPU(CyclesToExecute)
+	if *fontName != "" {
+		font, err := LoadFont(*fontName)
+		if err != nil {
+			panic(err)
+		}
+		c.SetFont(font)
+	}
 
-		c.DecrementTimers()
+	if *symbolFile != "" {
+		syms, err := LoadSymbols(*symbolFile)
+		if err != nil {
+			panic(err)
+		}
+		c.Symbols = syms
+	}
 
-		c.DrawScreen()
+	if *vipRandomFile != "" {
+		vip, err := loadVIPRandom(*vipRandomFile)
+		if err != nil {
+			panic(err)
+		}
+		c.VIPRand = vip
+		c.SetSeed(c.Seed)
+	}
+	if *seed != 0 {
+		c.SetSeed(*seed)
+	}
 
-		c.handleInput()
+	if *blend < 0 || *blend >= 1 {
+		panic(fmt.Errorf("-blend must be at least 0 and below 1, got %v", *blend))
+	}
+	c.Blend = *blend
+	filter, err := ParseScreenFilter(*filterName)
+	if err != nil {
+		panic(err)
+	}
+	c.Filter = filter
+	c.HaltPause = *haltPause
+	c.StrictMemory = *strictMemory
+	if c.InvalidOpcodes, err = ParseInvalidOpcodePolicy(*invalidOpcodes); err != nil {
+		panic(err)
+	}
+	if *debounce > 0 {
+		c.KeyDebounce = *debounce
+	}
+	if *keyRepeat {
+		c.KeyRepeat = true
+	}
+
+	// input recordings only play back right at the speed they were made
+	c.AutoCycles = *autoCycles && *recordFile == "" && *replayFile == ""
+
+	if *serialAddr != "" {
+		addr, err := c.Symbols.Resolve(*serialAddr)
+		if err != nil {
+			panic(err)
+		}
+		if err := c.AttachPeripheral(newSerialPort(addr, os.Stdout)); err != nil {
+			panic(err)
+		}
+	}
+
+	if *gpioKeypadPins != "" {
+		if err := c.attachGPIOKeypad(*gpioKeypadPins); err != nil {
+			panic(err)
+		}
+	}
+
+	if *matrixTarget != "" {
+		m, err := openLEDMatrix(*matrixTarget)
+		if err != nil {
+			panic(err)
+		}
+		if err := c.AttachPeripheral(m); err != nil {
+			panic(err)
+		}
+	}
+
+	if *shmFile != "" {
+		s, err := openSharedState(*shmFile)
+		if err != nil {
+			panic(err)
+		}
+		if err := c.AttachPeripheral(s); err != nil {
+			panic(err)
+		}
+	}
+
+	// a mistake in the definitions should not keep the game from being played
+	if *snapshotFile == "" {
+		if err := c.loadAchievements(romFile); err != nil {
+			log.Printf("achievements: %v", err)
+		}
+	}
+
+	if *hashEvery > 0 {
+		log.Printf("hashing state every %d frames, seed %d", *hashEvery, c.Seed)
+
+		var lastHashed uint64
+		c.OnFrame(func(c *Chip8) {
+			if c.Frame != lastHashed && c.Frame%*hashEvery == 0 {
+				lastHashed = c.Frame
+				c.stateHash = c.StateHash(c.stateHash)
+				log.Printf("frame %d state hash %016x", c.Frame, c.stateHash)
+			}
+		})
+	}
 
-		c.Wait(cycleStartTime)
+	// Octo sources are recompiled and restarted whenever they are saved
+	if *snapshotFile == "" && strings.EqualFold(filepath.Ext(romFile), ".8o") {
+		watcher := newSourceWatcher(romFile)
+		c.OnFrame(func(c *Chip8) {
+			if watcher.Changed() {
+				c.ReloadRomFile(romFile)
+			}
+		})
 	}
+
+	return c
 }
 
-func NewChip8() *Chip8 {
+// NewChip8 creates a machine drawing to a new window
+func NewChip8(opts windowOptions) *Chip8 {
 	// create gui screen to render sprites to
 	cfg := opengl.WindowConfig{
-		Title:     "Go - Chip8 Interpreter",
-		Bounds:    pixel.R(0, 0, ScreenWidth*ScalingFactor, ScreenHeight*ScalingFactor),
-		VSync:     false,
-		Resizable: false,
+		Title:                  "Go - Chip8 Interpreter",
+		Bounds:                 pixel.R(0, 0, ScreenWidth*ScalingFactor, ScreenHeight*ScalingFactor),
+		VSync:                  false,
+		Resizable:              false,
+		AlwaysOnTop:            opts.OnTop,
+		TransparentFramebuffer: opts.Transparent,
 	}
 
 	win, err := opengl.NewWindow(cfg)
@@ -111,26 +666,125 @@ func NewChip8() *Chip8 {
 
 	// ensure clean screen state
 	win.SetMatrix(pixel.IM.Scaled(pixel.ZV, 1))
-	win.Clear(colorOff)
+	if opts.Transparent {
+		win.Clear(color.RGBA{})
+	} else {
+		win.Clear(colorOff)
+	}
+
+	// tie screen to Chip8 instance
+	c := newMachine()
+	c.Screen = win
+
+	return c
+}
+
+// newMachine creates a machine with default settings and no window, for headless use such as
+// benchmarks. Drawing only updates ScreenState.
+func newMachine() *Chip8 {
+	c := &Chip8{
+		CyclesPerFrame: CyclesToExecute,
+		ColorOn:        colorOn,
+		ColorOff:       colorOff,
+	}
+	c.SetKeyMap(defaultKeyMap())
+	c.SetVariant(VariantChip8)
+	c.SetSeed(time.Now().UnixNano())
+
+	return c
+}
+
+// SetSeed restarts the random number sequence used by CXNN
+func (c *Chip8) SetSeed(seed int64) {
+	c.Seed = seed
+	c.Rand = rand.New(rand.NewSource(seed))
+	c.RandDraws = 0
+	if c.VIPRand != nil {
+		c.VIPRand.seed(seed)
+	}
+}
 
-	// instantiate and tie screen to Chip8 instance
-	return &Chip8{
-		Screen: win,
+// skipRand draws n numbers the way CXNN does, moving a freshly seeded sequence on to where it was
+// after n CXNN instructions
+func (c *Chip8) skipRand(n uint64) {
+	for range n {
+		c.Rand.Intn(256)
 	}
+	c.RandDraws += n
 }
 
 func (c *Chip8) LoadDefaultSprites() {
-	copy(c.MainMemory[:RamGameStart], defaultSprites)
+	font := c.Font
+	if font == nil {
+		font = defaultSprites
+	}
+	copy(c.MainMemory[:RamGameStart], font)
+	c.invalidateAllDecoded()
 }
 
+// ExecuteCPU runs up to cyclesToExecute instructions, stopping early on a fault
 func (c *Chip8) ExecuteCPU(cyclesToExecute int) {
-	for i := 0; i < cyclesToExecute; i++ {
-		opcode := c.fetch()
-		instruction := c.decode(opcode)
+	if c.blocksAllowed() {
+		c.executeBlocks(cyclesToExecute)
+		return
+	}
+
+	var pc, opcode uint16
+	defer func() {
+		if r := recover(); r != nil {
+			c.fault(pc, opcode, r)
+		}
+	}()
+
+	for i := 0; i < cyclesToExecute && c.Fault == nil; i++ {
+		if c.Debugger != nil && c.shouldBreak() {
+			break
+		}
+
+		pc, opcode = c.PC, 0
+		c.recent.add(pc)
+		if c.Coverage != nil {
+			c.Coverage.hit(pc)
+		}
+		if c.transpiled != nil && !c.Events.Has(EventInstruction) && len(c.peripherals.opcodes) == 0 &&
+			c.transpiled.step(c) {
+			continue
+		}
+
+		var instruction Opcode
+		opcode, instruction = c.fetchDecoded()
+		if c.Events.Has(EventInstruction) {
+			c.Events.Publish(InstructionEvent{pc, opcode})
+		}
+		if len(c.peripherals.opcodes) > 0 && c.executePeripheralOpcode(opcode) {
+			continue
+		}
 		c.execute(instruction, opcode)
 	}
 }
 
+// StepFrame runs one frame's worth of instructions, counts the timers down and calls the frame
+// handlers of attached peripherals
+func (c *Chip8) StepFrame() {
+	c.ExecuteCPU(c.CyclesPerFrame)
+	c.endFrame()
+}
+
+// endFrame does what happens between frames once their instructions have run
+func (c *Chip8) endFrame() {
+	// timers slower than the frame rate skip a tick every few frames
+	c.timerPhase += c.Variant.timerHz()
+	for c.timerPhase >= FramesPerSecond {
+		c.timerPhase -= FramesPerSecond
+		c.DecrementTimers()
+	}
+	if c.VIPRand != nil {
+		c.VIPRand.frame()
+	}
+	c.Frame++
+	c.framePeripherals()
+}
+
 func (c *Chip8) DecrementTimers() {
 	if c.DT > 0 {
 		c.DT--
@@ -139,10 +793,35 @@ func (c *Chip8) DecrementTimers() {
 	if c.ST > 0 {
 		c.ST--
 	}
+
+	if c.Events.Has(EventTimerTick) {
+		c.Events.Publish(TimerTickEvent{c.Frame, c.DT, c.ST})
+	}
 }
 
 func (c *Chip8) DrawScreen() {
+	if c.Screen == nil {
+		return
+	}
+	switch {
+	case c.menu != nil:
+		c.drawMenu()
+	case c.helpShown:
+		c.drawHelp()
+	case c.screenDirty || c.Blend > 0 || c.osd.needsRedraw() || c.romCard.needsRedraw() || c.inputDisplay:
+		c.renderScreen()
+		c.prevFrame = c.ScreenState
+		if c.inputDisplay {
+			c.drawInputDisplay()
+		}
+		c.drawRomCard()
+		c.drawOSD()
+	}
 	c.Screen.Update()
+
+	if c.spriteViewer != nil {
+		c.updateSpriteViewer()
+	}
 }
 
 func (c *Chip8) Wait(cycleStartTime time.Time) {
@@ -152,11 +831,9 @@ func (c *Chip8) Wait(cycleStartTime time.Time) {
 	}
 }
 
-func (c *Chip8) handleInput() {
-	c.KeyPressed = [16]bool{}
-	c.KeyJustReleased = [16]bool{}
-
-	keyMap := map[pixel.Button]byte{
+// defaultKeyMap lays the hex keypad out over the left-hand side of a QWERTY keyboard
+func defaultKeyMap() map[pixel.Button]byte {
+	return map[pixel.Button]byte{
 		pixel.Key1: 0x1, pixel.Key2: 0x2, pixel.Key3: 0x3, pixel.Key4: 0xC,
 		pixel.KeyQ: 0x4, pixel.KeyW: 0x5, pixel.KeyE: 0x6, pixel.KeyR: 0xD,
 		pixel.KeyA: 0x7, pixel.KeyS: 0x8, pixel.KeyD: 0x9, pixel.KeyF: 0xE,
@@ -167,45 +844,229 @@ func (c *Chip8) handleInput() {
 		pixel.KeyRight: 0x6,
 		pixel.KeyDown:  0x8,
 	}
+}
+
+func (c *Chip8) handleInput() {
+	c.KeyPressed = [16]bool{}
+	c.KeyJustReleased = [16]bool{}
+	c.inputFrame++
+
+	if c.Screen == nil {
+		return
+	}
+
+	if c.menu != nil {
+		c.handleMenuInput()
+		return
+	}
+
+	if c.helpShown && c.Screen.JustPressed(pixel.KeyEscape) {
+		c.ToggleHelp()
+		return
+	}
+
+	// P pauses too unless a sidecar keymap gave it to the game
+	if c.Screen.JustPressed(pixel.KeyEscape) || !c.bindings.pBound && c.Screen.JustPressed(pixel.KeyP) ||
+		c.Screen.JoystickPresent(pixel.Joystick1) && c.Screen.JoystickJustPressed(pixel.Joystick1, pixel.GamepadStart) {
+		c.OpenMenu()
+		return
+	}
 
-	if c.Screen.Pressed(pixel.KeyEscape) {
-		c.IsStopped = true
+	for _, h := range hotkeys {
+		if c.Screen.JustPressed(h.key) {
+			h.action(c)
+		}
+	}
+	if c.helpShown {
 		return
 	}
 
-	for key, chip8Key := range keyMap {
-		if c.Screen.Pressed(key) {
-			c.KeyPressed[chip8Key] = true
+	for _, b := range c.bindings.list {
+		if c.Screen.Pressed(b.button) {
+			c.KeyPressed[b.key] = true
 		}
 
-		if c.Screen.JustReleased(key) {
-			c.KeyJustReleased[chip8Key] = true
+		// OS key repeat is ignored unless KeyRepeat asks for held keys to repeat in FX0A menus
+		if c.Screen.JustReleased(b.button) || c.KeyRepeat && c.Screen.Repeated(b.button) {
+			c.releaseKey(b.key)
 		}
 	}
+	c.applyMacros()
 }
 
+// publishKeyChanges reports keys that went down or came up since the held state of last frame
+func (c *Chip8) publishKeyChanges(held [16]bool) {
+	if !c.Events.Has(EventKey) {
+		return
+	}
+	for key := range c.KeyPressed {
+		if c.KeyPressed[key] != held[key] {
+			c.Events.Publish(KeyEvent{byte(key), c.KeyPressed[key]})
+		}
+	}
+}
+
+// LoadRomFile loads a ROM from disk (or from inside a .zip archive) into memory, assembling Octo
+// (.8o) source files on the way
 func (c *Chip8) LoadRomFile(romFile string) {
-	f, err := os.ReadFile(romFile)
-	if err != nil {
+	if err := c.loadRomFile(romFile); err != nil {
 		panic(err)
 	}
+}
+
+func (c *Chip8) loadRomFile(romFile string) error {
+	img, err := readRomFile(romFile)
+	if err != nil {
+		return err
+	}
+
+	// settings are picked for the ROM the patch was made for, which the database knows
+	c.configureForImage(img)
+
+	if c.Patch != "" {
+		if img.Data, err = applyPatchFile(img.Data, c.Patch); err != nil {
+			return err
+		}
+		log.Printf("%s: applied %s", romFile, filepath.Base(c.Patch))
+	}
+	if room := len(c.MainMemory) - int(RamGameStart); len(img.Data) > room {
+		return fmt.Errorf("ROM is %d bytes, %s only has room for %d", len(img.Data), c.Variant, room)
+	}
+
+	c.LoadRom(img.Data)
+	c.Symbols = img.Symbols
+	c.RomFile = romFile
+	return nil
+}
+
+// SwitchRom replaces the running game with another ROM file, autosaving the current one first
+// when autosave is on. Settings from the previous ROM's sidecar file are dropped and the new
+// ROM's applied to the startup defaults, and its achievements checked instead.
+func (c *Chip8) SwitchRom(romFile string) error {
+	if _, err := readRomFile(romFile); err != nil {
+		return err
+	}
+
+	if *autosave {
+		if err := c.Autosave(); err != nil {
+			log.Printf("autosave failed: %v", err)
+		}
+	}
+
+	c.Reset()
+	c.resetSidecarSettings()
+	// a patch is made for one ROM
+	c.Patch = ""
+	if err := c.loadRomFile(romFile); err != nil {
+		return err
+	}
+	if err := c.LoadSidecar(romFile); err != nil {
+		return err
+	}
+	if err := c.loadAchievements(romFile); err != nil {
+		log.Printf("achievements: %v", err)
+	}
+
+	c.Notify("Loaded %s", filepath.Base(romFile))
+	return nil
+}
 
+// LoadRom copies a ROM image into memory and points the program counter at it
+func (c *Chip8) LoadRom(rom []byte) {
 	// dump rom into memory at game start position
-	copy(c.MainMemory[RamGameStart:RamGameStart+uint16(len(f))], f)
+	copy(c.MainMemory[RamGameStart:], rom)
+	c.invalidateAllDecoded()
+
+	c.rom = append([]byte(nil), rom...)
+	c.invalidLogged = nil
+	c.RomHash = romSHA1(rom)
+	c.transpiled = nil
+	if t := transpiledRoms[c.RomHash]; t != nil && t.variant == c.Variant {
+		c.transpiled = t
+	}
 
 	c.PositionProgramCounter(RamGameStart)
 }
 
-func (c *Chip8) PositionProgramCounter(pos uint16) {
-	c.PC = uint16(pos)
+// Reset returns the machine to its power-on state: memory, registers, stack and screen are cleared
+// and the default sprites reloaded. Variant, quirks and other settings are kept.
+func (c *Chip8) Reset() {
+	clear(c.MainMemory)
+	c.rom = nil
+	c.invalidateAllDecoded()
+	c.transpiled = nil
+	c.Vx = [16]uint8{}
+	c.I, c.PC, c.SP = 0, 0, 0
+	c.DT, c.ST, c.timerPhase = 0, 0, 0
+	c.Stack = [16]uint16{}
+	c.KeyPressed = [16]bool{}
+	c.KeyJustReleased = [16]bool{}
+	c.Fault = nil
+	c.recent = recentInstructions{}
+
+	c.clearScreen()
+	c.LoadDefaultSprites()
 }
 
-func (c *Chip8) fetch() uint16 {
-	defer func() {
-		c.PC += 2
-	}()
+// resizeMemory gives the machine size bytes of memory, keeping what fits of its contents, along
+// with caches of the same size
+func (c *Chip8) resizeMemory(size int) {
+	if len(c.MainMemory) == size {
+		return
+	}
+	mem := make([]byte, size)
+	copy(mem, c.MainMemory)
+	c.MainMemory = mem
+	c.decoded = make(decodeCache, size)
+	c.blocks = blockCache{blocks: make([]*basicBlock, size), inBlock: make([]bool, size)}
+}
 
-	return uint16(c.MainMemory[c.PC])<<8 | uint16(c.MainMemory[c.PC+1])
+// configureForRom picks variant and quirks for a ROM, preferring a database match, then the file
+// extension refined by a scan of the ROM's contents
+func (c *Chip8) configureForRom(name string, rom []byte) {
+	if info, ok := c.RomDB.Lookup(rom); ok {
+		v, err := ParseVariant(info.Variant)
+		if err != nil {
+			v = VariantChip8
+		}
+		c.SetVariant(v)
+		if info.Quirks != nil {
+			c.Quirks = *info.Quirks
+		}
+		if info.Font != "" {
+			if font, err := LoadFont(info.Font); err == nil {
+				c.SetFont(font)
+			} else {
+				log.Printf("%s: ignoring database font: %v", name, err)
+			}
+		}
+		log.Printf("%s: recognised as %q, using %s", name, info.Title, c.Variant)
+		c.romCard.info = &info
+		c.romCard.show()
+		return
+	}
+	c.romCard.info = nil
+
+	if *verifyRom {
+		if bad, ok := c.RomDB.Verify(name, rom); ok {
+			log.Printf("%s: looks like a %s, so it may not run properly", name, bad)
+			c.Notify("Bad dump? Looks %s", bad.Problem)
+		}
+	}
+
+	v, _ := variantFromExtension(name)
+	c.SetVariant(v)
+
+	if guess, ok := detectVariant(rom, v); ok {
+		c.SetVariant(guess.Variant)
+		c.Quirks = guess.Quirks
+		log.Printf("%s: not in ROM database, using %s with quirks %+v because %s",
+			name, guess.Variant, guess.Quirks, strings.Join(guess.Reasons, "; "))
+	}
+}
+
+func (c *Chip8) PositionProgramCounter(pos uint16) {
+	c.PC = uint16(pos)
 }
 
 func (c *Chip8) decode(opcode uint16) Opcode {
@@ -270,6 +1131,9 @@ func (c *Chip8) decode(opcode uint16) Opcode {
 			return opcodeEXA1
 		}
 	case 0xF000:
+		if opcode == 0xF000 && c.Variant == VariantXOChip {
+			return opcodeF000
+		}
 		switch opcode & 0x00FF {
 		case 0x0007:
 			return opcodeFX07
@@ -293,13 +1157,13 @@ func (c *Chip8) decode(opcode uint16) Opcode {
 	default:
 	}
 
-	return opcode00E0
+	return opcodeInvalid
 }
 
 func (c *Chip8) execute(opcode Opcode, opcodeRaw uint16) {
 	switch opcode {
 	case opcode00E0:
-		c.clearScreen()
+		c.eraseScreen()
 	case opcode00EE:
 		c.exitSubroutine()
 	case opcode1NNN:
@@ -366,16 +1230,26 @@ func (c *Chip8) execute(opcode Opcode, opcodeRaw uint16) {
 		c.regDump(opcodeRaw)
 	case opcodeFX65:
 		c.regLoad(opcodeRaw)
+	case opcodeF000:
+		c.setILong()
+	case opcodeInvalid:
+		c.executeInvalid(opcodeRaw)
 	}
 }
 
-func (c *Chip8) clearScreen() {
-	c.Screen.Clear(colorOff)
-	for i := range c.ScreenState {
-		c.ScreenState[i] = [64]uint8{}
+// eraseScreen runs 00E0, clearing the display and telling anyone following what is drawn
+func (c *Chip8) eraseScreen() {
+	c.clearScreen()
+	if c.Events.Has(EventDraw) {
+		c.Events.Publish(DrawEvent{Clear: true})
 	}
 }
 
+func (c *Chip8) clearScreen() {
+	c.screenDirty = true
+	c.ScreenState = screenPlane{}
+}
+
 func (c *Chip8) exitSubroutine() {
 	if c.SP <= 0 {
 		return
@@ -399,24 +1273,33 @@ func (c *Chip8) callSubroutine(opcode uint16) {
 	c.PC = uint16(opcode & 0x0FFF)
 }
 
+// skipNext moves PC past the next instruction, all four bytes of it when that is XO-CHIP's
+// i := long
+func (c *Chip8) skipNext() {
+	if c.Variant == VariantXOChip && c.word(c.PC) == 0xF000 {
+		c.PC += 2
+	}
+	c.PC += 2
+}
+
 // checkVxEqlNN skips the next instruction if Vx equals NN
 func (c *Chip8) checkVxEqlNN(opcode uint16) {
 	if c.Vx[(opcode&0x0F00)>>8] == uint8(opcode&0x00FF) {
-		c.PC += 2 // skip next instruction
+		c.skipNext()
 	}
 }
 
 // checkVxNotEqlNN skips the next instruction if Vx does not equal NN
 func (c *Chip8) checkVxNotEqlNN(opcode uint16) {
 	if c.Vx[(opcode&0x0F00)>>8] != uint8(opcode&0x00FF) {
-		c.PC += 2 // skip next instruction
+		c.skipNext()
 	}
 }
 
 // checkVxEqualVy skips the next instruction if Vx equals Vy
 func (c *Chip8) checkVxEqlVy(opcode uint16) {
 	if c.Vx[(opcode&0x0F00)>>8] == c.Vx[(opcode&0x00F0)>>4] {
-		c.PC += 2 // skip next instruction
+		c.skipNext()
 	}
 }
 
@@ -438,72 +1321,102 @@ func (c *Chip8) setVxToVy(opcode uint16) {
 // bitwiseORAssignVxToVy sets 8Bit Register Vx to its value OR'd against 8Bit Register Vy
 func (c *Chip8) bitwiseORAssignVxToVy(opcode uint16) {
 	c.Vx[(opcode&0x0F00)>>8] = c.Vx[(opcode&0x0F00)>>8] | c.Vx[(opcode&0x00F0)>>4]
+	if c.Quirks.VFReset {
+		c.Vx[0xF] = 0
+	}
 }
 
 // bitwiseANDAssignVxToVy sets 8Bit Register Vx to its value AND'd against 8Bit Register Vy
 func (c *Chip8) bitwiseANDAssignVxToVy(opcode uint16) {
 	c.Vx[(opcode&0x0F00)>>8] = c.Vx[(opcode&0x0F00)>>8] & c.Vx[(opcode&0x00F0)>>4]
+	if c.Quirks.VFReset {
+		c.Vx[0xF] = 0
+	}
 }
 
 // bitwiseXORAssignVxToVy sets 8Bit Register Vx to its value XOR'd against 8Bit Register Vy
 func (c *Chip8) bitwiseXORAssignVxToVy(opcode uint16) {
 	c.Vx[(opcode&0x0F00)>>8] = c.Vx[(opcode&0x0F00)>>8] ^ c.Vx[(opcode&0x00F0)>>4]
+	if c.Quirks.VFReset {
+		c.Vx[0xF] = 0
+	}
 }
 
 // addAssignVyToVx increments one of the 8-Bit Registers (Vy) by the value stored in 8Bit Register Vx
 func (c *Chip8) addAssignVyToVx(opcode uint16) {
 	// carry 1 overflow detection logic
+	var carry uint8
 	if c.Vx[(opcode&0x00F0)>>4] > 0xFF-c.Vx[(opcode&0x0F00)>>8] {
-		c.Vx[0xF] = 1 // no overflow detected
+		carry = 1 // no overflow detected
 	} else {
-		c.Vx[0xF] = 0 // overflow detected
+		carry = 0 // overflow detected
 	}
 	c.Vx[(opcode&0x0F00)>>8] = c.Vx[(opcode&0x0F00)>>8] + c.Vx[(opcode&0x00F0)>>4]
+	c.Vx[0xF] = carry // set last so VF as a target ends up holding the flag
 }
 
 // subAssignVyToVx decrements one of the 8-Bit Registers (Vy) by the value stored in 8Bit Register Vx
 func (c *Chip8) subAssignVyToVx(opcode uint16) {
 	// carry 1 underflow detection logic
+	var carry uint8
 	if c.Vx[(opcode&0x00F0)>>4] > c.Vx[(opcode&0x0F00)>>8] {
-		c.Vx[0xF] = 0 // no underflow detected
+		carry = 0 // no underflow detected
 	} else {
-		c.Vx[0xF] = 1 // underflow detected
+		carry = 1 // underflow detected
 	}
 
 	c.Vx[(opcode&0x0F00)>>8] = c.Vx[(opcode&0x0F00)>>8] - c.Vx[(opcode&0x00F0)>>4]
+	c.Vx[0xF] = carry
 }
 
 // rightShiftVxBy1 bitshifts the value in 8Bit Register Vx to the right by 1
 func (c *Chip8) rightShiftVxBy1(opcode uint16) {
-	c.Vx[0xF] = c.Vx[(opcode&0x0F00)>>8] & 0x1
+	if c.Quirks.ShiftUsesVy {
+		c.Vx[(opcode&0x0F00)>>8] = c.Vx[(opcode&0x00F0)>>4]
+	}
+	carry := c.Vx[(opcode&0x0F00)>>8] & 0x1
 	c.Vx[(opcode&0x0F00)>>8] = c.Vx[(opcode&0x0F00)>>8] >> 1
+	c.Vx[0xF] = carry
 }
 
 // setVxToVySubVx assigns 8Bit Register Vx to -> (Vy - Vx)
 func (c *Chip8) setVxToVySubVx(opcode uint16) {
 	// carry 1 underflow detection logic
+	var carry uint8
 	if c.Vx[(opcode&0x0F00)>>8] > c.Vx[(opcode&0x00F0)>>4] {
-		c.Vx[0xF] = 0 // no underflow detected
+		carry = 0 // no underflow detected
 	} else {
-		c.Vx[0xF] = 1 // underflow detected
+		carry = 1 // underflow detected
 	}
 
 	c.Vx[(opcode&0x0F00)>>8] = c.Vx[(opcode&0x00F0)>>4] - c.Vx[(opcode&0x0F00)>>8]
+	c.Vx[0xF] = carry
 }
 
 // leftShiftVxBy1 bitshifts the value in 8Bit Register Vx to the left by 1
 func (c *Chip8) leftShiftVxBy1(opcode uint16) {
-	c.Vx[0xF] = c.Vx[(opcode&0x0F00)>>8] >> 7
+	if c.Quirks.ShiftUsesVy {
+		c.Vx[(opcode&0x0F00)>>8] = c.Vx[(opcode&0x00F0)>>4]
+	}
+	carry := c.Vx[(opcode&0x0F00)>>8] >> 7
 	c.Vx[(opcode&0x0F00)>>8] = c.Vx[(opcode&0x0F00)>>8] << 1
+	c.Vx[0xF] = carry
 }
 
 // checkVxNotEqlVy performs a conditional check on 8Bit Registers if Vx != Vx
 func (c *Chip8) checkVxNotEqlVy(opcode uint16) {
 	if c.Vx[(opcode&0x0F00)>>8] != c.Vx[(opcode&0x00F0)>>4] {
-		c.PC = c.PC + 2
+		c.skipNext()
 	}
 }
 
+// setILong loads I with the 16-bit address in the word after an XO-CHIP F000, reaching memory
+// past the 4K ANNN can address
+func (c *Chip8) setILong() {
+	c.I = c.word(c.PC)
+	c.PC += 2
+}
+
 // setIReg updates memory address I register points to
 func (c *Chip8) setIReg(opcode uint16) {
 	c.I = uint16(opcode & 0x0FFF)
@@ -511,74 +1424,146 @@ func (c *Chip8) setIReg(opcode uint16) {
 
 // pcJump moves program counter to memory address provided in 12 right-most bits in opcode
 func (c *Chip8) pcJump(opcode uint16) {
+	if c.Quirks.JumpUsesVx {
+		c.PC = uint16(c.Vx[(opcode&0x0F00)>>8]) + uint16(opcode&0x0FFF)
+		return
+	}
 	c.PC = uint16(c.Vx[0]) + uint16(opcode&0x0FFF)
 }
 
 // setVxToRand assigns a random unsigned 8-bit integer to 8-bit register Vx
 func (c *Chip8) setVxToRand(opcode uint16) {
-	c.Vx[(opcode&0x0F00)>>8] = uint8(rand.Intn(256)) & uint8(opcode&0x00FF)
+	var r uint8
+	if c.VIPRand != nil {
+		r = c.VIPRand.next()
+	} else {
+		r = uint8(c.Rand.Intn(256))
+		c.RandDraws++
+	}
+	c.Vx[(opcode&0x0F00)>>8] = r & uint8(opcode&0x00FF)
 }
 
-// TODO: NEEDS TO BE CLEANED UP AND MADE MORE EFFICIENT
+// drawSprite XORs an 8 pixel wide sprite of N rows from I onto the display at Vx, Vy, a row at a
+// time, setting VF if it turned any pixel off
 func (c *Chip8) drawSprite(opcode uint16) {
 	x := c.Vx[(opcode&0x0F00)>>8] % 64
 	y := c.Vx[(opcode&0x00F0)>>4] % 32
 	h := opcode & 0x000F
+	c.checkData(int(h))
 	c.Vx[0xF] = 0
-	var j uint16 = 0
-	var i uint16 = 0
-	img := image.NewRGBA(image.Rect(0, 0, ScreenWidth, ScreenHeight))
-
-	for j = 0; j < h; j++ {
-		pixel := c.MainMemory[uint16(c.I)+j]
 
-		if uint8(y)+uint8(j) >= ScreenHeight {
-			continue
-		}
+	for j := uint16(0); j < h; j++ {
+		pixels := c.readMemory(c.I + j)
 
-		for i = 0; i < 8; i++ {
-			if uint8(x)+uint8(i) >= ScreenWidth {
+		row := int(y) + int(j)
+		if row >= ScreenHeight {
+			if !c.Quirks.SpritesWrap {
 				continue
 			}
+			row %= ScreenHeight
+		}
 
-			if (pixel & (0x80 >> i)) != 0 {
-				if c.ScreenState[(uint8(y) + uint8(j))][uint8(x)+uint8(i)] == 1 {
-					c.Vx[0xF] = 1
-				}
-				c.ScreenState[(uint8(y) + uint8(j))][uint8(x)+uint8(i)] ^= 1
-			}
+		// line the sprite up with the row, its right end falling off the edge or round to the left
+		sprite := uint64(pixels) << 56
+		if c.Quirks.SpritesWrap {
+			sprite = bits.RotateLeft64(sprite, -int(x))
+		} else {
+			sprite >>= x
 		}
+		if c.ScreenState[row]&sprite != 0 {
+			c.Vx[0xF] = 1
+		}
+		c.ScreenState[row] ^= sprite
+	}
+
+	// drawn once at the end of the frame however many sprites the frame draws
+	c.screenDirty = true
+
+	if c.Events.Has(EventDraw) {
+		c.Events.Publish(DrawEvent{X: x, Y: y, Height: uint8(h), Collision: c.Vx[0xF] == 1})
+	}
+}
+
+// blendColor mixes weight parts of from into 1-weight parts of to, alpha included so fading
+// into a transparent background fades out
+func blendColor(from, to color.RGBA, weight float64) color.RGBA {
+	mix := func(a, b uint8) uint8 {
+		return uint8(float64(a)*weight + float64(b)*(1-weight) + 0.5)
 	}
+	return color.RGBA{mix(from.R, to.R), mix(from.G, to.G), mix(from.B, to.B), mix(from.A, to.A)}
+}
+
+// renderScreen draws ScreenState to the window through a single 64x32 canvas, scaled up by the
+// GPU with the Filter's shader
+func (c *Chip8) renderScreen() {
+	if c.Screen == nil {
+		return
+	}
+	if c.canvas == nil {
+		c.canvas = opengl.NewCanvas(pixel.R(0, 0, ScreenWidth, ScreenHeight))
+		c.pixels = make([]uint8, 4*ScreenWidth*ScreenHeight)
+	}
+
+	// the canvas keeps its pixels, so while the game is not drawing (or an overlay is what
+	// changed) there is nothing to upload
+	frame := canvasFrame{c.ScreenState, c.prevFrame, c.Highlight, c.ColorOn, c.windowOff(), c.Blend, true}
+	if frame != c.uploaded {
+		c.uploadScreen()
+		c.uploaded = frame
+	}
+
+	c.drawUpscaled()
+	c.screenDirty = false
+}
+
+// canvasFrame is everything the canvas pixels are made from
+type canvasFrame struct {
+	screen, prev      screenPlane
+	highlight         screenPlane
+	colorOn, colorOff color.RGBA
+	blend             float64
+	valid             bool
+}
+
+// uploadScreen fills the canvas with ScreenState in the current colors
+func (c *Chip8) uploadScreen() {
+	// with blending a pixel lit in only one of the last two frames is drawn part way between the
+	// two colors, which hides the flicker of sprites redrawn every frame
+	off := c.windowOff()
+	turnedOn := blendColor(off, c.ColorOn, c.Blend)
+	turnedOff := blendColor(c.ColorOn, off, c.Blend)
 
 	for y := 0; y < 32; y++ {
+		// canvas rows run bottom to top
+		row := c.pixels[4*ScreenWidth*(ScreenHeight-1-y):]
 		for x := 0; x < 64; x++ {
-			if c.ScreenState[y][x] == 1 {
-				img.Set(x, y, colorOn)
-			} else {
-				img.Set(x, y, colorOff)
+			on, wasOn := c.ScreenState.pixel(x, y), c.prevFrame.pixel(x, y)
+			col := off
+			switch {
+			case c.Highlight.pixel(x, y):
+				col = colorHighlight
+			case c.Blend > 0 && on && !wasOn:
+				col = turnedOn
+			case c.Blend > 0 && !on && wasOn:
+				col = turnedOff
+			case on:
+				col = c.ColorOn
 			}
+			row[4*x], row[4*x+1], row[4*x+2], row[4*x+3] = col.R, col.G, col.B, col.A
 		}
 	}
-
-	pic := pixel.PictureDataFromImage(img)
-	sprite := pixel.NewSprite(pic, pic.Bounds())
-
-	mat := pixel.IM.
-		Scaled(pixel.ZV, ScalingFactor).
-		Moved(c.Screen.Bounds().Center())
-
-	sprite.Draw(c.Screen, mat)
+	c.canvas.SetPixels(c.pixels)
 }
 
 func (c *Chip8) keyOpEqlCheck(opcode uint16) {
 	if c.KeyPressed[c.Vx[(opcode&0x0F00)>>8]] {
-		c.PC += 2
+		c.skipNext()
 	}
 }
 
 func (c *Chip8) keyOpNotEqlCheck(opcode uint16) {
 	if !c.KeyPressed[c.Vx[(opcode&0x0F00)>>8]] {
-		c.PC += 2
+		c.skipNext()
 	}
 }
 
@@ -609,10 +1594,12 @@ func (c *Chip8) setSoundTimerToVx(opcode uint16) {
 }
 
 func (c *Chip8) addAssignVxToI(opcode uint16) {
-	if c.I+uint16(c.Vx[(opcode&0x0F00)>>8]) > 0xFFF {
-		c.Vx[0xF] = 1
-	} else {
-		c.Vx[0xF] = 0
+	if c.Quirks.IOverflowSetsVF {
+		if int(c.I)+int(c.Vx[(opcode&0x0F00)>>8]) >= len(c.MainMemory) {
+			c.Vx[0xF] = 1
+		} else {
+			c.Vx[0xF] = 0
+		}
 	}
 	c.I = c.I + uint16(c.Vx[(opcode&0x0F00)>>8])
 }
@@ -658,9 +1645,10 @@ func (c *Chip8) storeBCDToI(opcode uint16) {
 	vxIdx := uint8((opcode & 0x0F00) >> 8)
 	val := uint8(c.Vx[vxIdx])
 
-	c.MainMemory[c.I] = byte(val / 100)
-	c.MainMemory[c.I+1] = byte((val / 10) % 10)
-	c.MainMemory[c.I+2] = byte(val % 10)
+	c.checkData(3)
+	c.writeMemory(c.I, byte(val/100))
+	c.writeMemory(c.I+1, byte((val/10)%10))
+	c.writeMemory(c.I+2, byte(val%10))
 }
 
 func (c *Chip8) regDump(opcode uint16) {
@@ -669,11 +1657,16 @@ func (c *Chip8) regDump(opcode uint16) {
 
 	regICopy := c.I
 
+	c.checkData(int(lastVxReg) + 1)
 	for i <= lastVxReg {
-		c.MainMemory[regICopy] = byte(c.Vx[i])
+		c.writeMemory(regICopy, byte(c.Vx[i]))
 		regICopy++
 		i++
 	}
+
+	if c.Quirks.LoadStoreIncrementsI {
+		c.I = regICopy
+	}
 }
 
 func (c *Chip8) regLoad(opcode uint16) {
@@ -682,9 +1675,14 @@ func (c *Chip8) regLoad(opcode uint16) {
 
 	regICopy := c.I
 
+	c.checkData(int(lastVxReg) + 1)
 	for i <= lastVxReg {
-		c.Vx[i] = uint8(c.MainMemory[regICopy])
+		c.Vx[i] = uint8(c.readMemory(regICopy))
 		regICopy++
 		i++
 	}
+
+	if c.Quirks.LoadStoreIncrementsI {
+		c.I = regICopy
+	}
 }
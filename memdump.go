@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// DumpMemory writes MainMemory[start:end] to binFile and a text summary of the registers next to
+// it (binFile with its extension replaced by .txt), returning the summary's path
+func (c *Chip8) DumpMemory(start, end int, binFile string) (string, error) {
+	if start < 0 || end > len(c.MainMemory) || start >= end {
+		return "", fmt.Errorf("range 0x%03X-0x%03X is outside memory (0x000-0x%03X)", start, end, len(c.MainMemory))
+	}
+
+	if err := os.WriteFile(binFile, c.MainMemory[start:end], 0644); err != nil {
+		return "", err
+	}
+
+	summaryFile := strings.TrimSuffix(binFile, ".bin") + ".txt"
+	if err := os.WriteFile(summaryFile, []byte(c.stateSummary(start, end)), 0644); err != nil {
+		return "", err
+	}
+
+	return summaryFile, nil
+}
+
+// stateSummary renders the registers as key=value lines alongside the dumped memory range
+func (c *Chip8) stateSummary(start, end int) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# chip8 memory dump, %s\n", time.Now().Format(time.RFC3339))
+	fmt.Fprintf(&b, "range=0x%03X-0x%03X\n", start, end)
+	fmt.Fprintf(&b, "variant=%s\n", c.Variant)
+	fmt.Fprintf(&b, "PC=0x%03X\n", c.PC)
+	fmt.Fprintf(&b, "I=0x%03X\n", c.I)
+	fmt.Fprintf(&b, "SP=%d\n", c.SP)
+	fmt.Fprintf(&b, "DT=%d\n", c.DT)
+	fmt.Fprintf(&b, "ST=%d\n", c.ST)
+	for i, v := range c.Vx {
+		fmt.Fprintf(&b, "V%X=0x%02X\n", i, v)
+	}
+
+	stack := make([]string, c.SP)
+	for i := range stack {
+		stack[i] = fmt.Sprintf("0x%03X", c.Stack[i])
+	}
+	fmt.Fprintf(&b, "stack=%s\n", strings.Join(stack, " "))
+
+	if pc := int(c.PC); pc+1 < len(c.MainMemory) {
+		opcode := uint16(c.MainMemory[pc])<<8 | uint16(c.MainMemory[pc+1])
+		fmt.Fprintf(&b, "# next: %04X %s\n", opcode, Mnemonic(opcode, c.Symbols))
+	}
+
+	return b.String()
+}
+
+// dumpMemoryHotkey writes all of memory to a timestamped file and reports where it went
+func (c *Chip8) dumpMemoryHotkey() {
+	binFile := userFile("dumps", "memdump", ".bin")
+	summaryFile, err := c.DumpMemory(0, len(c.MainMemory), binFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "memory dump failed: %v\n", err)
+		c.Notify("Memory dump failed")
+		return
+	}
+	fmt.Fprintf(os.Stderr, "memory dumped to %s (summary in %s)\n", binFile, summaryFile)
+	c.Notify("Memory dumped to %s", binFile)
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// searchShown caps how many matches search and refine list, the rest are only counted
+const searchShown = 16
+
+// memSearch is the result of the last search command: the addresses still in the running and
+// what each held when last looked at, so refine can keep those that changed or did not
+type memSearch struct {
+	// width is the size of the values searched for, 1 or 2 bytes, or 0 for a byte pattern,
+	// which can only be searched afresh
+	width int
+
+	addrs  []uint16
+	values []uint16
+}
+
+// searchValue reads the width byte value at addr, 16-bit values big-endian as FX33 and the
+// instructions store them
+func (c *Chip8) searchValue(addr uint16, width int) uint16 {
+	if width == 1 {
+		return uint16(c.readMemory(addr))
+	}
+	return uint16(c.readMemory(addr))<<8 | uint16(c.readMemory(addr+1))
+}
+
+// parseSearchValue parses a decimal, 0x hexadecimal or 0b binary value that fits in width bytes
+func parseSearchValue(arg string, width int) (uint16, error) {
+	v, err := strconv.ParseUint(arg, 0, 8*width)
+	if err != nil {
+		return 0, fmt.Errorf("bad %d-bit value %q", 8*width, arg)
+	}
+	return uint16(v), nil
+}
+
+// debugSearch starts a search of memory: for an 8 or 16-bit value, for every address when no
+// value is given so refine can narrow them down by how they change, or for a byte pattern
+func debugSearch(c *Chip8, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: %s", debugCommands["search"].usage)
+	}
+
+	s := &memSearch{}
+	switch args[0] {
+	case "8", "16":
+		if len(args) > 2 {
+			return fmt.Errorf("usage: %s", debugCommands["search"].usage)
+		}
+		s.width = 1
+		if args[0] == "16" {
+			s.width = 2
+		}
+		var want uint16
+		if len(args) == 2 {
+			v, err := parseSearchValue(args[1], s.width)
+			if err != nil {
+				return err
+			}
+			want = v
+		}
+		for addr := 0; addr+s.width <= len(c.MainMemory); addr++ {
+			v := c.searchValue(uint16(addr), s.width)
+			if len(args) == 1 || v == want {
+				s.addrs = append(s.addrs, uint16(addr))
+				s.values = append(s.values, v)
+			}
+		}
+
+	case "bytes":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: %s", debugCommands["search"].usage)
+		}
+		pattern := make([]byte, len(args)-1)
+		for i, arg := range args[1:] {
+			v, err := monitorHex(arg, 8)
+			if err != nil {
+				return err
+			}
+			pattern[i] = byte(v)
+		}
+	next:
+		for addr := 0; addr+len(pattern) <= len(c.MainMemory); addr++ {
+			for i, b := range pattern {
+				if c.readMemory(uint16(addr+i)) != b {
+					continue next
+				}
+			}
+			s.addrs = append(s.addrs, uint16(addr))
+			s.values = append(s.values, uint16(pattern[0]))
+		}
+
+	default:
+		return fmt.Errorf("usage: %s", debugCommands["search"].usage)
+	}
+
+	c.Debugger.search = s
+	c.printSearch()
+	return nil
+}
+
+// debugRefine narrows the last search to the addresses now holding a value, or whose value
+// changed, stayed the same, went up or went down since the search or the last refine
+func debugRefine(c *Chip8, args []string) error {
+	s := c.Debugger.search
+	switch {
+	case len(args) != 1:
+		return fmt.Errorf("usage: %s", debugCommands["refine"].usage)
+	case s == nil:
+		return fmt.Errorf("no search to refine, start one with search")
+	case s.width == 0:
+		return fmt.Errorf("byte pattern searches cannot be refined, search again")
+	}
+
+	var keep func(was, now uint16) bool
+	switch args[0] {
+	case "changed":
+		keep = func(was, now uint16) bool { return now != was }
+	case "unchanged":
+		keep = func(was, now uint16) bool { return now == was }
+	case "up":
+		keep = func(was, now uint16) bool { return now > was }
+	case "down":
+		keep = func(was, now uint16) bool { return now < was }
+	default:
+		want, err := parseSearchValue(args[0], s.width)
+		if err != nil {
+			return err
+		}
+		keep = func(_, now uint16) bool { return now == want }
+	}
+
+	addrs, values := s.addrs[:0], s.values[:0]
+	for i, addr := range s.addrs {
+		if now := c.searchValue(addr, s.width); keep(s.values[i], now) {
+			addrs = append(addrs, addr)
+			values = append(values, now)
+		}
+	}
+	s.addrs, s.values = addrs, values
+	c.printSearch()
+	return nil
+}
+
+// printSearch lists the first matches of the last search with their values
+func (c *Chip8) printSearch() {
+	s, out := c.Debugger.search, c.Debugger.out
+	fmt.Fprintf(out, "%d found\n", len(s.addrs))
+	if len(s.addrs) == len(c.MainMemory)-max(s.width, 1)+1 {
+		// a search for any value matches everywhere, listing it says nothing
+		return
+	}
+	for i, addr := range s.addrs[:min(len(s.addrs), searchShown)] {
+		fmt.Fprintf(out, "  %04X", addr)
+		if s.width > 0 {
+			fmt.Fprintf(out, " = %d (0x%0*X)", s.values[i], 2*s.width, s.values[i])
+		}
+		if where := c.Symbols.Describe(addr); where != "" {
+			fmt.Fprintf(out, "  %s", where)
+		}
+		fmt.Fprintln(out)
+	}
+	if len(s.addrs) > searchShown {
+		fmt.Fprintf(out, "  ... and %d more\n", len(s.addrs)-searchShown)
+	}
+}
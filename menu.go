@@ -0,0 +1,246 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"log"
+	"path/filepath"
+	"strings"
+
+	"github.com/gopxl/pixel/v2"
+	"github.com/gopxl/pixel/v2/backends/opengl"
+	"github.com/gopxl/pixel/v2/ext/imdraw"
+	"github.com/gopxl/pixel/v2/ext/text"
+	"golang.org/x/image/font/basicfont"
+)
+
+// menuItem is a line of the pause menu. Label is called every frame so settings can show their
+// current value; Adjust, when set, is bound to left and right.
+type menuItem struct {
+	Label    func(c *Chip8) string
+	Activate func(c *Chip8)
+	Adjust   func(c *Chip8, delta int)
+}
+
+// pauseMenu is a page of the in-window menu; submenus keep a link back to the page they came from
+type pauseMenu struct {
+	title    string
+	text     []string
+	items    []menuItem
+	selected int
+	parent   *pauseMenu
+
+	// locked pages can only be left through their items, as the game cannot simply resume
+	locked bool
+}
+
+var menuAtlas = text.NewAtlas(basicfont.Face7x13, text.ASCII)
+
+var menuShade = color.RGBA{0, 0, 0, 0xC0}
+
+func fixedLabel(s string) func(*Chip8) string {
+	return func(*Chip8) string { return s }
+}
+
+// OpenMenu pauses the game and shows the main menu
+func (c *Chip8) OpenMenu() {
+	c.helpShown = false
+	c.menu = &pauseMenu{
+		title: "Paused",
+		items: []menuItem{
+			{Label: fixedLabel("Resume"), Activate: (*Chip8).CloseMenu},
+			{Label: fixedLabel("Reset"), Activate: func(c *Chip8) {
+				if c.RomFile != "" {
+					c.ReloadRomFile(c.RomFile)
+				}
+				c.CloseMenu()
+			}},
+			{Label: fixedLabel("Load ROM"), Activate: func(c *Chip8) { c.openSubmenu(c.romMenu()) }},
+			{Label: fixedLabel("Settings"), Activate: func(c *Chip8) { c.openSubmenu(settingsMenu()) }},
+			{Label: fixedLabel("Export cartridge"), Activate: (*Chip8).exportMenuItem},
+			{Label: fixedLabel("Quit"), Activate: func(c *Chip8) { c.IsStopped = true }},
+		},
+	}
+}
+
+// exportMenuItem writes the game with its settings to a .c8b file named after the ROM
+func (c *Chip8) exportMenuItem() {
+	file := userFile("cartridges", c.romBaseName(), ".c8b")
+	if err := c.ExportC8B(file); err != nil {
+		log.Printf("export failed: %v", err)
+		c.Notify("Export failed: %v", err)
+		return
+	}
+	log.Printf("exported %s", file)
+	c.Notify("Exported to %s", filepath.Base(file))
+}
+
+// CloseMenu resumes the game, redrawing the screen without the menu over it
+func (c *Chip8) CloseMenu() {
+	c.menu = nil
+	c.renderScreen()
+}
+
+func (c *Chip8) openSubmenu(m *pauseMenu) {
+	m.parent = c.menu
+	c.menu = m
+}
+
+// menuBack returns to the parent page, or resumes from the main menu
+func (c *Chip8) menuBack() {
+	if c.menu.parent == nil {
+		if !c.menu.locked {
+			c.CloseMenu()
+		}
+		return
+	}
+	c.menu = c.menu.parent
+}
+
+// romMenu lists the ROMs next to the one running
+func (c *Chip8) romMenu() *pauseMenu {
+	dir := "."
+	if c.RomFile != "" {
+		dir = filepath.Dir(c.RomFile)
+	}
+
+	m := &pauseMenu{title: "Load ROM from " + dir}
+	files, err := listRoms(dir)
+	if err != nil {
+		log.Printf("listing ROMs: %v", err)
+	}
+
+	for _, name := range files {
+		path := filepath.Join(dir, name)
+		m.items = append(m.items, menuItem{Label: fixedLabel(name), Activate: func(c *Chip8) {
+			if err := c.SwitchRom(path); err != nil {
+				log.Printf("loading %s: %v", path, err)
+				return
+			}
+			c.CloseMenu()
+		}})
+	}
+	m.items = append(m.items, menuItem{Label: fixedLabel("Back"), Activate: (*Chip8).menuBack})
+	return m
+}
+
+// settingsMenu holds the speed, variant and quirk settings of the running game
+func settingsMenu() *pauseMenu {
+	onOff := map[bool]string{false: "off", true: "on"}
+	quirk := func(name string, field func(q *Quirks) *bool) menuItem {
+		toggle := func(c *Chip8) { *field(&c.Quirks) = !*field(&c.Quirks) }
+		return menuItem{
+			Label:    func(c *Chip8) string { return fmt.Sprintf("%s: %s", name, onOff[*field(&c.Quirks)]) },
+			Activate: toggle,
+			Adjust:   func(c *Chip8, _ int) { toggle(c) },
+		}
+	}
+
+	return &pauseMenu{
+		title: "Settings",
+		items: []menuItem{
+			{
+				Label:  func(c *Chip8) string { return fmt.Sprintf("Speed: %d per frame", c.CyclesPerFrame) },
+				Adjust: func(c *Chip8, delta int) { c.CyclesPerFrame = max(1, c.CyclesPerFrame+delta) },
+			},
+			{
+				Label: func(c *Chip8) string { return fmt.Sprintf("Variant: %s", c.Variant) },
+				Adjust: func(c *Chip8, delta int) {
+					n := len(variantNames)
+					c.SetVariant(Variant((int(c.Variant) + delta + n) % n))
+				},
+			},
+			{
+				Label: func(c *Chip8) string { return fmt.Sprintf("Scaling: %s", c.Filter) },
+				Adjust: func(c *Chip8, delta int) {
+					n := len(filterNames)
+					c.Filter = ScreenFilter((int(c.Filter) + delta + n) % n)
+				},
+			},
+			{
+				Label:  func(c *Chip8) string { return fmt.Sprintf("Key debounce: %d frames", c.KeyDebounce) },
+				Adjust: func(c *Chip8, delta int) { c.KeyDebounce = max(0, c.KeyDebounce+delta) },
+			},
+			{
+				Label:    func(c *Chip8) string { return fmt.Sprintf("Key repeat: %s", onOff[c.KeyRepeat]) },
+				Activate: func(c *Chip8) { c.KeyRepeat = !c.KeyRepeat },
+				Adjust:   func(c *Chip8, _ int) { c.KeyRepeat = !c.KeyRepeat },
+			},
+			quirk("VF reset", func(q *Quirks) *bool { return &q.VFReset }),
+			quirk("Load/store moves I", func(q *Quirks) *bool { return &q.LoadStoreIncrementsI }),
+			quirk("Shift uses VY", func(q *Quirks) *bool { return &q.ShiftUsesVy }),
+			quirk("Jump uses VX", func(q *Quirks) *bool { return &q.JumpUsesVx }),
+			quirk("Sprites wrap", func(q *Quirks) *bool { return &q.SpritesWrap }),
+			quirk("I overflow sets VF", func(q *Quirks) *bool { return &q.IOverflowSetsVF }),
+			{Label: fixedLabel("Back"), Activate: (*Chip8).menuBack},
+		},
+	}
+}
+
+// handleMenuInput drives the menu from the keyboard or the first gamepad
+func (c *Chip8) handleMenuInput() {
+	win, m := c.Screen, c.menu
+	pad := func(b pixel.GamepadButton) bool {
+		return win.JoystickPresent(pixel.Joystick1) && win.JoystickJustPressed(pixel.Joystick1, b)
+	}
+	pressed := func(key pixel.Button, b pixel.GamepadButton) bool {
+		return win.JustPressed(key) || win.Repeated(key) || pad(b)
+	}
+
+	switch {
+	case win.JustPressed(pixel.KeyEscape) || win.JustPressed(pixel.KeyBackspace) || pad(pixel.GamepadB):
+		c.menuBack()
+	case pad(pixel.GamepadStart):
+		for m.parent != nil {
+			m = m.parent
+		}
+		if !m.locked {
+			c.CloseMenu()
+		}
+	case pressed(pixel.KeyUp, pixel.GamepadDpadUp):
+		m.selected = (m.selected + len(m.items) - 1) % len(m.items)
+	case pressed(pixel.KeyDown, pixel.GamepadDpadDown):
+		m.selected = (m.selected + 1) % len(m.items)
+	case pressed(pixel.KeyLeft, pixel.GamepadDpadLeft):
+		if adjust := m.items[m.selected].Adjust; adjust != nil {
+			adjust(c, -1)
+		}
+	case pressed(pixel.KeyRight, pixel.GamepadDpadRight):
+		if adjust := m.items[m.selected].Adjust; adjust != nil {
+			adjust(c, 1)
+		}
+	case win.JustPressed(pixel.KeyEnter) || win.JustPressed(pixel.KeySpace) || pad(pixel.GamepadA):
+		if activate := m.items[m.selected].Activate; activate != nil {
+			activate(c)
+		}
+	}
+}
+
+// drawMenu renders the game with the current menu page over it
+func (c *Chip8) drawMenu() {
+	c.renderScreen()
+	drawMenuOver(c.Screen, c.menu, c)
+}
+
+func drawMenuOver(win *opengl.Window, m *pauseMenu, c *Chip8) {
+	shade := imdraw.New(nil)
+	shade.Color = menuShade
+	shade.Push(win.Bounds().Min, win.Bounds().Max)
+	shade.Rectangle(0)
+	shade.Draw(win)
+
+	txt := text.New(pixel.V(24, win.Bounds().H()-32), menuAtlas)
+	txt.Color = colorOff
+	fmt.Fprintf(txt, "%s\n\n", m.title)
+	if len(m.text) > 0 {
+		fmt.Fprintf(txt, "%s\n\n", strings.Join(m.text, "\n"))
+	}
+	for i, item := range m.items {
+		marker := "  "
+		if i == m.selected {
+			marker = "> "
+		}
+		fmt.Fprintf(txt, "%s%s\n", marker, item.Label(c))
+	}
+	txt.Draw(win, pixel.IM)
+}
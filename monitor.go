@@ -0,0 +1,214 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// The monitor commands are one letter debugger commands in the style of the machine monitors of
+// the era, for quick poking at the machine. Every number they take is hexadecimal, and addresses
+// may also be labels.
+
+// monitorDumpLen is how much m shows when no length is given
+const monitorDumpLen = 0x40
+
+// monitorHex parses a hexadecimal argument of at most bits bits
+func monitorHex(arg string, bits int) (uint64, error) {
+	v, err := strconv.ParseUint(strings.TrimPrefix(strings.TrimPrefix(arg, "$"), "0x"), 16, bits)
+	if err != nil {
+		return 0, fmt.Errorf("bad hex number %q", arg)
+	}
+	return v, nil
+}
+
+// monitorMemory dumps memory 16 bytes to a row, carrying on from the last dump when no address
+// is given
+func monitorMemory(c *Chip8, args []string) error {
+	d := c.Debugger
+	addr, n := int(d.monitorNext), monitorDumpLen
+	if len(args) > 2 {
+		return fmt.Errorf("usage: %s", debugCommands["m"].usage)
+	}
+	if len(args) > 0 {
+		a, err := c.debugAddr(args[0])
+		if err != nil {
+			return err
+		}
+		addr = a
+	}
+	if len(args) == 2 {
+		v, err := monitorHex(args[1], 16)
+		if err != nil {
+			return err
+		}
+		n = int(v)
+	}
+	if addr >= len(c.MainMemory) {
+		return fmt.Errorf("%03X is past the end of memory", addr)
+	}
+	end := min(addr+n, len(c.MainMemory))
+
+	c.hexDump(d.out, addr, end)
+	d.monitorNext = uint16(end % len(c.MainMemory))
+	return nil
+}
+
+// hexDump writes memory from start to end 16 bytes to a row, with the printable ones as text
+func (c *Chip8) hexDump(w io.Writer, start, end int) {
+	for row := start; row < end; row += 16 {
+		var hex, text strings.Builder
+		for a := row; a < min(row+16, end); a++ {
+			v := c.readMemory(uint16(a))
+			fmt.Fprintf(&hex, " %02X", v)
+			if v >= ' ' && v <= '~' {
+				text.WriteByte(v)
+			} else {
+				text.WriteByte('.')
+			}
+		}
+		fmt.Fprintf(w, "%04X %-48s  %s\n", row, hex.String(), text.String())
+	}
+}
+
+// monitorWrite stores bytes from addr up
+func monitorWrite(c *Chip8, args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("usage: %s", debugCommands["w"].usage)
+	}
+	addr, err := c.debugAddr(args[0])
+	if err != nil {
+		return err
+	}
+	if addr+len(args)-1 > len(c.MainMemory) {
+		return fmt.Errorf("%03X+%d runs past the end of memory", addr, len(args)-1)
+	}
+
+	data := make([]byte, len(args)-1)
+	for i, arg := range args[1:] {
+		v, err := monitorHex(arg, 8)
+		if err != nil {
+			return err
+		}
+		data[i] = byte(v)
+	}
+	for i, v := range data {
+		c.writeMemory(uint16(addr+i), v)
+	}
+	fmt.Fprintf(c.Debugger.out, "wrote %d bytes at %04X\n", len(data), addr)
+	return nil
+}
+
+// monitorRange parses a start and end address, end not included, for f and t
+func (c *Chip8) monitorRange(from, to string) (int, int, error) {
+	start, err := c.debugAddr(from)
+	if err != nil {
+		return 0, 0, err
+	}
+	end, err := c.debugAddr(to)
+	if err != nil {
+		return 0, 0, err
+	}
+	if end <= start || end > len(c.MainMemory) {
+		return 0, 0, fmt.Errorf("bad range %03X-%03X", start, end)
+	}
+	return start, end, nil
+}
+
+// monitorFill stores a byte everywhere from start up to end
+func monitorFill(c *Chip8, args []string) error {
+	if len(args) != 3 {
+		return fmt.Errorf("usage: %s", debugCommands["f"].usage)
+	}
+	start, end, err := c.monitorRange(args[0], args[1])
+	if err != nil {
+		return err
+	}
+	v, err := monitorHex(args[2], 8)
+	if err != nil {
+		return err
+	}
+	for a := start; a < end; a++ {
+		c.writeMemory(uint16(a), byte(v))
+	}
+	fmt.Fprintf(c.Debugger.out, "filled %04X-%04X with %02X\n", start, end, v)
+	return nil
+}
+
+// monitorTransfer copies the bytes from start up to end to dest, which may overlap them
+func monitorTransfer(c *Chip8, args []string) error {
+	if len(args) != 3 {
+		return fmt.Errorf("usage: %s", debugCommands["t"].usage)
+	}
+	start, end, err := c.monitorRange(args[0], args[1])
+	if err != nil {
+		return err
+	}
+	dest, err := c.debugAddr(args[2])
+	if err != nil {
+		return err
+	}
+	if dest+end-start > len(c.MainMemory) {
+		return fmt.Errorf("%03X+%d runs past the end of memory", dest, end-start)
+	}
+
+	data := make([]byte, end-start)
+	for i := range data {
+		data[i] = c.readMemory(uint16(start + i))
+	}
+	for i, v := range data {
+		c.writeMemory(uint16(dest+i), v)
+	}
+	fmt.Fprintf(c.Debugger.out, "copied %d bytes from %04X to %04X\n", len(data), start, dest)
+	return nil
+}
+
+// monitorStep runs instructions straight away rather than a frame at a time like step, so the
+// result can be read as soon as the command returns
+func monitorStep(c *Chip8, args []string) error {
+	n := uint64(1)
+	switch len(args) {
+	case 0:
+	case 1:
+		v, err := monitorHex(args[0], 32)
+		if err != nil {
+			return err
+		}
+		n = max(v, 1)
+	default:
+		return fmt.Errorf("usage: %s", debugCommands["s"].usage)
+	}
+
+	d := c.Debugger
+	d.Paused, d.steps = true, 0
+	for range n {
+		if c.Fault != nil {
+			return c.Fault
+		}
+		d.resumed = true
+		c.ExecuteCPU(1)
+	}
+	fmt.Fprintf(d.out, "%04X  %s\n", c.PC, Mnemonic(c.word(c.PC), c.Symbols))
+	c.printWatches()
+	return nil
+}
+
+// monitorGo resumes the machine, from addr if one is given
+func monitorGo(c *Chip8, args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("usage: %s", debugCommands["g"].usage)
+	}
+	if len(args) == 1 {
+		addr, err := c.debugAddr(args[0])
+		if err != nil {
+			return err
+		}
+		c.PC = uint16(addr)
+	}
+
+	d := c.Debugger
+	d.resume()
+	fmt.Fprintf(d.out, "running from %04X\n", c.PC)
+	return nil
+}
@@ -0,0 +1,647 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// octoToken is a single whitespace separated word of Octo source along with the line it came from
+type octoToken struct {
+	text string
+	line int
+}
+
+// octoFlow records an open loop or if/begin block while its closing address is unknown
+type octoFlow struct {
+	kind  string
+	addr  uint16   // start of the loop, or the jump to patch for if/begin
+	exits []uint16 // `while` jumps waiting to be pointed past `again`
+}
+
+// octoAssembler compiles the Octo assembly language (https://github.com/JohnEarnest/Octo) into a
+// CHIP-8 ROM. Macros, :calc and string mode are not supported.
+type octoAssembler struct {
+	tokens []octoToken
+	pos    int
+
+	rom  []byte // memory image, indexed from RamGameStart
+	here uint16
+
+	labels    map[string]uint16
+	lines     map[uint16]int // source line each instruction was assembled from
+	constants map[string]int
+	aliases   map[string]uint8
+	flow      []octoFlow
+
+	// during the first pass labels may be referenced before they are defined
+	final bool
+}
+
+// assembleOcto compiles Octo source into a ROM image meant to be loaded at RamGameStart, along
+// with the symbols describing where each label and source line ended up
+func assembleOcto(src string) ([]byte, *SymbolTable, error) {
+	a := &octoAssembler{tokens: tokenizeOcto(src)}
+
+	// the first pass only settles label addresses, the second one emits the final code
+	if err := a.assemble(); err != nil {
+		return nil, nil, err
+	}
+	a.final = true
+	if err := a.assemble(); err != nil {
+		return nil, nil, err
+	}
+
+	syms := newSymbolTable()
+	for name, addr := range a.labels {
+		syms.addLabel(name, addr)
+	}
+	for addr, line := range a.lines {
+		syms.Lines[addr] = line
+	}
+
+	return a.rom, syms, nil
+}
+
+func tokenizeOcto(src string) []octoToken {
+	var tokens []octoToken
+	for i, line := range strings.Split(src, "\n") {
+		if idx := strings.Index(line, "#"); idx >= 0 {
+			line = line[:idx]
+		}
+		for _, word := range strings.Fields(line) {
+			tokens = append(tokens, octoToken{text: word, line: i + 1})
+		}
+	}
+	return tokens
+}
+
+func (a *octoAssembler) assemble() (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if e, ok := r.(octoError); ok {
+				err = e
+				return
+			}
+			panic(r)
+		}
+	}()
+
+	firstPassLabels := a.labels
+	a.pos = 0
+	a.rom = nil
+	a.here = RamGameStart
+	a.labels = map[string]uint16{}
+	a.lines = map[uint16]int{}
+	a.constants = map[string]int{}
+	a.aliases = map[string]uint8{}
+	a.flow = nil
+	if !a.final {
+		firstPassLabels = nil
+	}
+
+	// every Octo program begins with a jump to its main label
+	mainAddr, ok := firstPassLabels["main"]
+	if a.final && !ok {
+		a.fail(octoToken{line: 1}, "no `: main` label defined")
+	}
+	a.emitOp(0x1000 | mainAddr&0x0FFF)
+
+	for a.pos < len(a.tokens) {
+		start, line := a.here, a.tokens[a.pos].line
+		a.statement(firstPassLabels)
+		if a.here > start {
+			a.lines[start] = line
+		}
+	}
+
+	if len(a.flow) > 0 {
+		a.fail(a.tokens[len(a.tokens)-1], "unterminated %s", a.flow[len(a.flow)-1].kind)
+	}
+
+	return nil
+}
+
+type octoError struct {
+	line int
+	msg  string
+}
+
+func (e octoError) Error() string {
+	return fmt.Sprintf("octo: line %d: %s", e.line, e.msg)
+}
+
+func (a *octoAssembler) fail(t octoToken, format string, args ...any) {
+	panic(octoError{line: t.line, msg: fmt.Sprintf(format, args...)})
+}
+
+func (a *octoAssembler) next() octoToken {
+	if a.pos >= len(a.tokens) {
+		last := octoToken{line: 1}
+		if len(a.tokens) > 0 {
+			last = a.tokens[len(a.tokens)-1]
+		}
+		a.fail(last, "unexpected end of file")
+	}
+	t := a.tokens[a.pos]
+	a.pos++
+	return t
+}
+
+func (a *octoAssembler) peek() string {
+	if a.pos >= len(a.tokens) {
+		return ""
+	}
+	return a.tokens[a.pos].text
+}
+
+func (a *octoAssembler) expect(word string) {
+	if t := a.next(); t.text != word {
+		a.fail(t, "expected %q, found %q", word, t.text)
+	}
+}
+
+func (a *octoAssembler) emitByte(b byte) {
+	if a.here < RamGameStart {
+		a.fail(a.tokens[a.pos-1], "cannot emit code below 0x%03X", RamGameStart)
+	}
+	offset := int(a.here - RamGameStart)
+	for len(a.rom) <= offset {
+		a.rom = append(a.rom, 0)
+	}
+	a.rom[offset] = b
+	a.here++
+}
+
+func (a *octoAssembler) emitOp(op uint16) {
+	a.emitByte(byte(op >> 8))
+	a.emitByte(byte(op))
+}
+
+// patchAddr fills in the 12-bit address of a previously emitted jump
+func (a *octoAssembler) patchAddr(at, target uint16) {
+	offset := int(at - RamGameStart)
+	a.rom[offset] = a.rom[offset]&0xF0 | byte(target>>8)&0x0F
+	a.rom[offset+1] = byte(target)
+}
+
+// register parses v0-vf or an alias
+func (a *octoAssembler) register(t octoToken) uint8 {
+	if r, ok := a.tryRegister(t.text); ok {
+		return r
+	}
+	a.fail(t, "expected a register, found %q", t.text)
+	return 0
+}
+
+func (a *octoAssembler) tryRegister(word string) (uint8, bool) {
+	if r, ok := a.aliases[word]; ok {
+		return r, true
+	}
+	lower := strings.ToLower(word)
+	if len(lower) == 2 && lower[0] == 'v' {
+		if r, err := strconv.ParseUint(lower[1:], 16, 8); err == nil {
+			return uint8(r), true
+		}
+	}
+	return 0, false
+}
+
+// value resolves a number literal, constant or label
+func (a *octoAssembler) value(t octoToken, firstPassLabels map[string]uint16) int {
+	if n, ok := parseOctoNumber(t.text); ok {
+		return n
+	}
+	if n, ok := a.constants[t.text]; ok {
+		return n
+	}
+	if addr, ok := a.labels[t.text]; ok {
+		return int(addr)
+	}
+	if addr, ok := firstPassLabels[t.text]; ok {
+		return int(addr)
+	}
+	if !a.final {
+		return 0
+	}
+	a.fail(t, "undefined name %q", t.text)
+	return 0
+}
+
+func parseOctoNumber(word string) (int, bool) {
+	neg := strings.HasPrefix(word, "-")
+	digits := strings.TrimPrefix(word, "-")
+
+	var n int64
+	var err error
+	switch {
+	case strings.HasPrefix(digits, "0x") || strings.HasPrefix(digits, "0X"):
+		n, err = strconv.ParseInt(digits[2:], 16, 32)
+	case strings.HasPrefix(digits, "0b") || strings.HasPrefix(digits, "0B"):
+		n, err = strconv.ParseInt(digits[2:], 2, 32)
+	default:
+		n, err = strconv.ParseInt(digits, 10, 32)
+	}
+	if err != nil || digits == "" {
+		return 0, false
+	}
+
+	if neg {
+		n = -n
+	}
+	return int(n), true
+}
+
+func (a *octoAssembler) byteValue(t octoToken, labels map[string]uint16) uint8 {
+	n := a.value(t, labels)
+	if n < -128 || n > 255 {
+		a.fail(t, "value %d does not fit in a byte", n)
+	}
+	return uint8(n)
+}
+
+func (a *octoAssembler) addrValue(t octoToken, labels map[string]uint16) uint16 {
+	n := a.value(t, labels)
+	if n < 0 || n > 0xFFF {
+		a.fail(t, "address 0x%X does not fit in 12 bits", n)
+	}
+	return uint16(n)
+}
+
+func (a *octoAssembler) nibbleValue(t octoToken, labels map[string]uint16) uint16 {
+	n := a.value(t, labels)
+	if n < 0 || n > 0xF {
+		a.fail(t, "value %d does not fit in a nibble", n)
+	}
+	return uint16(n)
+}
+
+func (a *octoAssembler) defineLabel(t octoToken, name string, addr uint16) {
+	if _, ok := a.labels[name]; ok {
+		a.fail(t, "label %q defined twice", name)
+	}
+	a.labels[name] = addr
+}
+
+func (a *octoAssembler) statement(labels map[string]uint16) {
+	t := a.next()
+
+	switch t.text {
+	case ":":
+		name := a.next()
+		a.defineLabel(name, name.text, a.here)
+	case ":next":
+		name := a.next()
+		a.defineLabel(name, name.text, a.here+1)
+	case ":const":
+		name := a.next()
+		a.constants[name.text] = a.value(a.next(), labels)
+	case ":alias":
+		name := a.next()
+		a.aliases[name.text] = a.register(a.next())
+	case ":org":
+		a.here = uint16(a.value(a.next(), labels))
+	case ":byte":
+		a.emitByte(a.byteValue(a.next(), labels))
+	case ":call":
+		a.emitOp(0x2000 | a.addrValue(a.next(), labels))
+	case ":unpack":
+		hi := a.nibbleValue(a.next(), labels)
+		addr := a.addrValue(a.next(), labels)
+		a.emitOp(0x6000 | hi<<4 | addr>>8)
+		a.emitOp(0x6100 | addr&0xFF)
+	case ":macro", ":calc", ":stringmode", ":assert", ":monitor", ":breakpoint", ":pointer":
+		a.fail(t, "%s is not supported", t.text)
+
+	case "clear":
+		a.emitOp(0x00E0)
+	case "return", ";":
+		a.emitOp(0x00EE)
+	case "exit":
+		a.emitOp(0x00FD)
+	case "lores":
+		a.emitOp(0x00FE)
+	case "hires":
+		a.emitOp(0x00FF)
+	case "scroll-down":
+		a.emitOp(0x00C0 | a.nibbleValue(a.next(), labels))
+	case "scroll-up":
+		a.emitOp(0x00D0 | a.nibbleValue(a.next(), labels))
+	case "scroll-right":
+		a.emitOp(0x00FB)
+	case "scroll-left":
+		a.emitOp(0x00FC)
+	case "jump":
+		a.emitOp(0x1000 | a.addrValue(a.next(), labels))
+	case "jump0":
+		a.emitOp(0xB000 | a.addrValue(a.next(), labels))
+	case "sprite":
+		x := uint16(a.register(a.next()))
+		y := uint16(a.register(a.next()))
+		a.emitOp(0xD000 | x<<8 | y<<4 | a.nibbleValue(a.next(), labels))
+	case "bcd":
+		a.emitOp(0xF033 | uint16(a.register(a.next()))<<8)
+	case "save", "load":
+		a.loadStore(t.text, labels)
+	case "saveflags":
+		a.emitOp(0xF075 | uint16(a.register(a.next()))<<8)
+	case "loadflags":
+		a.emitOp(0xF085 | uint16(a.register(a.next()))<<8)
+	case "delay", "buzzer":
+		a.expect(":=")
+		op := uint16(0xF015)
+		if t.text == "buzzer" {
+			op = 0xF018
+		}
+		a.emitOp(op | uint16(a.register(a.next()))<<8)
+	case "i":
+		a.assignI(labels)
+	case "if":
+		a.conditional(labels)
+	case "else":
+		a.elseBlock(t)
+	case "end":
+		a.endBlock(t)
+	case "loop":
+		a.flow = append(a.flow, octoFlow{kind: "loop", addr: a.here})
+	case "while":
+		a.whileExit(t, labels)
+	case "again":
+		a.again(t)
+
+	default:
+		if r, ok := a.tryRegister(t.text); ok {
+			a.assignRegister(r, labels)
+			return
+		}
+		if n, ok := parseOctoNumber(t.text); ok {
+			a.byteFromInt(t, n)
+			return
+		}
+		if n, ok := a.constants[t.text]; ok {
+			a.byteFromInt(t, n)
+			return
+		}
+		// a bare label name calls it as a subroutine
+		a.emitOp(0x2000 | a.addrValue(t, labels))
+	}
+}
+
+func (a *octoAssembler) byteFromInt(t octoToken, n int) {
+	if n < -128 || n > 255 {
+		a.fail(t, "value %d does not fit in a byte", n)
+	}
+	a.emitByte(uint8(n))
+}
+
+func (a *octoAssembler) loadStore(op string, labels map[string]uint16) {
+	x := uint16(a.register(a.next()))
+	if a.peek() == "-" {
+		a.next()
+		y := uint16(a.register(a.next()))
+		ranged := uint16(0x5002)
+		if op == "load" {
+			ranged = 0x5003
+		}
+		a.emitOp(ranged | x<<8 | y<<4)
+		return
+	}
+
+	single := uint16(0xF055)
+	if op == "load" {
+		single = 0xF065
+	}
+	a.emitOp(single | x<<8)
+}
+
+func (a *octoAssembler) assignI(labels map[string]uint16) {
+	switch op := a.next(); op.text {
+	case ":=":
+		switch a.peek() {
+		case "hex":
+			a.next()
+			a.emitOp(0xF029 | uint16(a.register(a.next()))<<8)
+		case "bighex":
+			a.next()
+			a.emitOp(0xF030 | uint16(a.register(a.next()))<<8)
+		case "long":
+			a.next()
+			t := a.next()
+			n := a.value(t, labels)
+			if n < 0 || n > 0xFFFF {
+				a.fail(t, "address 0x%X does not fit in 16 bits", n)
+			}
+			a.emitOp(0xF000)
+			a.emitOp(uint16(n))
+		default:
+			a.emitOp(0xA000 | a.addrValue(a.next(), labels))
+		}
+	case "+=":
+		a.emitOp(0xF01E | uint16(a.register(a.next()))<<8)
+	default:
+		a.fail(op, "expected := or += after i, found %q", op.text)
+	}
+}
+
+func (a *octoAssembler) assignRegister(x uint8, labels map[string]uint16) {
+	vx := uint16(x) << 8
+	op := a.next()
+	rhs := a.next()
+
+	if op.text == ":=" {
+		switch rhs.text {
+		case "key":
+			a.emitOp(0xF00A | vx)
+			return
+		case "delay":
+			a.emitOp(0xF007 | vx)
+			return
+		case "random":
+			a.emitOp(0xC000 | vx | uint16(a.byteValue(a.next(), labels)))
+			return
+		}
+	}
+
+	if y, ok := a.tryRegister(rhs.text); ok {
+		vy := uint16(y) << 4
+		ops := map[string]uint16{
+			":=": 0x8000, "|=": 0x8001, "&=": 0x8002, "^=": 0x8003,
+			"+=": 0x8004, "-=": 0x8005, ">>=": 0x8006, "=-": 0x8007, "<<=": 0x800E,
+		}
+		code, ok := ops[op.text]
+		if !ok {
+			a.fail(op, "unknown register operator %q", op.text)
+		}
+		a.emitOp(code | vx | vy)
+		return
+	}
+
+	n := a.byteValue(rhs, labels)
+	switch op.text {
+	case ":=":
+		a.emitOp(0x6000 | vx | uint16(n))
+	case "+=":
+		a.emitOp(0x7000 | vx | uint16(n))
+	case "-=":
+		a.emitOp(0x7000 | vx | uint16(-n))
+	default:
+		a.fail(op, "operator %q needs a register operand", op.text)
+	}
+}
+
+// condition emits the instruction(s) that skip the next instruction when the Octo condition is
+// false, so the instruction that follows only runs when it holds. With negate the sense flips.
+func (a *octoAssembler) condition(labels map[string]uint16, negate bool) {
+	x := a.register(a.next())
+	vx := uint16(x) << 8
+	op := a.next()
+
+	switch op.text {
+	case "key", "-key":
+		pressed := op.text == "key"
+		if pressed != negate {
+			a.emitOp(0xE0A1 | vx)
+		} else {
+			a.emitOp(0xE09E | vx)
+		}
+		return
+	}
+
+	rhs := a.next()
+	y, isReg := a.tryRegister(rhs.text)
+
+	switch op.text {
+	case "==", "!=":
+		equal := (op.text == "==") != negate
+		switch {
+		case isReg && equal:
+			a.emitOp(0x9000 | vx | uint16(y)<<4)
+		case isReg:
+			a.emitOp(0x5000 | vx | uint16(y)<<4)
+		case equal:
+			a.emitOp(0x4000 | vx | uint16(a.byteValue(rhs, labels)))
+		default:
+			a.emitOp(0x3000 | vx | uint16(a.byteValue(rhs, labels)))
+		}
+		return
+	case "<", ">", "<=", ">=":
+	default:
+		a.fail(op, "unknown comparison %q", op.text)
+	}
+
+	// comparisons compute lhs - rhs in vf and test the borrow flag it leaves behind:
+	// vf := rhs ; vf =- lhs sets vf to 1 exactly when lhs >= rhs
+	lhsReg, rhsReg, rhsImm := x, y, 0
+	wantFlag := uint16(1)
+	switch op.text {
+	case "<":
+		wantFlag = 0
+	case ">", "<=":
+		if isReg {
+			// flip the operands: lhs > rhs is rhs < lhs, lhs <= rhs is rhs >= lhs
+			lhsReg, rhsReg = y, x
+			if op.text == ">" {
+				wantFlag = 0
+			}
+		} else {
+			// against an immediate: lhs > n is lhs >= n+1, lhs <= n is lhs < n+1
+			rhsImm = int(a.byteValue(rhs, labels)) + 1
+			if rhsImm > 255 {
+				a.fail(rhs, "comparison against %d is always %v", rhsImm-1, op.text == "<=")
+			}
+			if op.text == "<=" {
+				wantFlag = 0
+			}
+		}
+	}
+
+	switch {
+	case isReg:
+		a.emitOp(0x8F00 | uint16(rhsReg)<<4)
+	case rhsImm > 0:
+		a.emitOp(0x6F00 | uint16(rhsImm))
+	default:
+		a.emitOp(0x6F00 | uint16(a.byteValue(rhs, labels)))
+	}
+	a.emitOp(0x8F07 | uint16(lhsReg)<<4)
+
+	if negate {
+		wantFlag ^= 1
+	}
+	// skip the next instruction unless vf holds the wanted flag
+	a.emitOp(0x4F00 | wantFlag)
+}
+
+func (a *octoAssembler) conditional(labels map[string]uint16) {
+	// look ahead for the keyword closing the condition to know which form this is
+	end := a.pos
+	for end < len(a.tokens) && a.tokens[end].text != "then" && a.tokens[end].text != "begin" {
+		end++
+	}
+	if end == len(a.tokens) {
+		a.fail(a.tokens[a.pos-1], "if without then or begin")
+	}
+
+	if a.tokens[end].text == "then" {
+		a.condition(labels, false)
+		a.expect("then")
+		return
+	}
+
+	// if ... begin skips over the body when the condition fails
+	a.condition(labels, true)
+	a.expect("begin")
+	a.flow = append(a.flow, octoFlow{kind: "begin", addr: a.here})
+	a.emitOp(0x1000)
+}
+
+func (a *octoAssembler) elseBlock(t octoToken) {
+	if len(a.flow) == 0 || a.flow[len(a.flow)-1].kind != "begin" {
+		a.fail(t, "else without if ... begin")
+	}
+	top := &a.flow[len(a.flow)-1]
+	skipElse := a.here
+	a.emitOp(0x1000)
+	a.patchAddr(top.addr, a.here)
+	top.kind, top.addr = "else", skipElse
+}
+
+func (a *octoAssembler) endBlock(t octoToken) {
+	if len(a.flow) == 0 || (a.flow[len(a.flow)-1].kind != "begin" && a.flow[len(a.flow)-1].kind != "else") {
+		a.fail(t, "end without if ... begin")
+	}
+	top := a.flow[len(a.flow)-1]
+	a.flow = a.flow[:len(a.flow)-1]
+	a.patchAddr(top.addr, a.here)
+}
+
+func (a *octoAssembler) innermostLoop(t octoToken, word string) *octoFlow {
+	for i := len(a.flow) - 1; i >= 0; i-- {
+		if a.flow[i].kind == "loop" {
+			return &a.flow[i]
+		}
+	}
+	a.fail(t, "%s outside of loop", word)
+	return nil
+}
+
+func (a *octoAssembler) whileExit(t octoToken, labels map[string]uint16) {
+	loop := a.innermostLoop(t, "while")
+	// leave the loop when the condition fails
+	a.condition(labels, true)
+	loop.exits = append(loop.exits, a.here)
+	a.emitOp(0x1000)
+}
+
+func (a *octoAssembler) again(t octoToken) {
+	if len(a.flow) == 0 || a.flow[len(a.flow)-1].kind != "loop" {
+		a.fail(t, "again without loop")
+	}
+	top := a.flow[len(a.flow)-1]
+	a.flow = a.flow[:len(a.flow)-1]
+
+	a.emitOp(0x1000 | top.addr&0x0FFF)
+	for _, exit := range top.exits {
+		a.patchAddr(exit, a.here)
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image/gif"
+	"log"
+)
+
+// Octo shares programs as cartridges: GIF pictures of a cartridge label with the program hidden
+// in them. Every pixel of every frame, left to right and top to bottom, carries two bits of the
+// payload in the low bits of its palette index, most significant bits first, so the picture
+// looks the same to the eye. The payload is a 32-bit big-endian length and then that many bytes
+// of JSON holding the Octo source and the options it runs with.
+
+// octoCart is the JSON payload of a cartridge
+type octoCart struct {
+	Program string      `json:"program"`
+	Options octoOptions `json:"options"`
+}
+
+// octoOptions are the settings Octo saves with a program. Its quirks are named for the
+// deviation from the original interpreter, which Quirks words differently.
+type octoOptions struct {
+	TickRate int `json:"tickrate"`
+
+	// largest program the target allows: 3216 for CHIP-8, 3583 for SUPER-CHIP, 65024 for XO-CHIP
+	MaxSize int `json:"maxSize"`
+
+	FillColor       string `json:"fillColor"`
+	BackgroundColor string `json:"backgroundColor"`
+
+	ShiftQuirks     bool `json:"shiftQuirks"`
+	LoadStoreQuirks bool `json:"loadStoreQuirks"`
+	ClipQuirks      bool `json:"clipQuirks"`
+	JumpQuirks      bool `json:"jumpQuirks"`
+	LogicQuirks     bool `json:"logicQuirks"`
+}
+
+// readOctoCart extracts the payload of a cartridge GIF
+func readOctoCart(data []byte) (*octoCart, error) {
+	g, err := gif.DecodeAll(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("not an Octo cartridge: %w", err)
+	}
+
+	var payload []byte
+	var b byte
+	bits := 0
+	for _, frame := range g.Image {
+		r := frame.Bounds()
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				b = b<<2 | frame.ColorIndexAt(x, y)&3
+				if bits += 2; bits == 8 {
+					payload = append(payload, b)
+					b, bits = 0, 0
+				}
+			}
+		}
+	}
+
+	if len(payload) < 4 {
+		return nil, errors.New("not an Octo cartridge: the picture is too small to hold a program")
+	}
+	size := binary.BigEndian.Uint32(payload)
+	if uint64(size) > uint64(len(payload)-4) {
+		return nil, errors.New("not an Octo cartridge: no program is hidden in the picture")
+	}
+
+	var cart octoCart
+	if err := json.Unmarshal(payload[4:4+size], &cart); err != nil {
+		return nil, fmt.Errorf("Octo cartridge: %w", err)
+	}
+	if cart.Program == "" {
+		return nil, errors.New("Octo cartridge holds no program")
+	}
+	return &cart, nil
+}
+
+// applyOctoOptions sets up the machine the way Octo ran the cartridge's program
+func (c *Chip8) applyOctoOptions(name string, o *octoOptions) {
+	switch {
+	case o.MaxSize > 3583:
+		c.SetVariant(VariantXOChip)
+	case o.MaxSize > 3216:
+		c.SetVariant(VariantSChip)
+	case o.MaxSize > 0:
+		c.SetVariant(VariantChip8)
+	}
+
+	c.Quirks.ShiftUsesVy = !o.ShiftQuirks
+	c.Quirks.LoadStoreIncrementsI = !o.LoadStoreQuirks
+	c.Quirks.SpritesWrap = !o.ClipQuirks
+	c.Quirks.JumpUsesVx = o.JumpQuirks
+	c.Quirks.VFReset = o.LogicQuirks
+
+	if o.TickRate > 0 {
+		c.CyclesPerFrame = o.TickRate
+	}
+
+	if o.FillColor != "" {
+		if col, err := parseHexColor(o.FillColor); err == nil {
+			c.ColorOn = col
+		} else {
+			log.Printf("%s: ignoring cartridge colour: %v", name, err)
+		}
+	}
+	if o.BackgroundColor != "" {
+		if col, err := parseHexColor(o.BackgroundColor); err == nil {
+			c.ColorOff = col
+		} else {
+			log.Printf("%s: ignoring cartridge colour: %v", name, err)
+		}
+	}
+	log.Printf("%s: using %s with the cartridge's options, %d instructions per frame", name, c.Variant, c.CyclesPerFrame)
+}
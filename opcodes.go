@@ -37,4 +37,87 @@ const (
 	opcodeFX33
 	opcodeFX55
 	opcodeFX65
+	opcodeF000
+	// a word that is none of the above, run through the InvalidOpcodePolicy
+	opcodeInvalid
 )
+
+// opcodeNames spells each constant as it appears in source, for generated code
+var opcodeNames = [...]string{
+	opcode00E0: "opcode00E0",
+	opcode00EE: "opcode00EE",
+	opcode1NNN: "opcode1NNN",
+	opcode2NNN: "opcode2NNN",
+	opcode3XNN: "opcode3XNN",
+	opcode4XNN: "opcode4XNN",
+	opcode5XY0: "opcode5XY0",
+	opcode6XNN: "opcode6XNN",
+	opcode7XNN: "opcode7XNN",
+	opcode8XY0: "opcode8XY0",
+	opcode8XY1: "opcode8XY1",
+	opcode8XY2: "opcode8XY2",
+	opcode8XY3: "opcode8XY3",
+	opcode8XY4: "opcode8XY4",
+	opcode8XY5: "opcode8XY5",
+	opcode8XY6: "opcode8XY6",
+	opcode8XY7: "opcode8XY7",
+	opcode8XYE: "opcode8XYE",
+	opcode9XY0: "opcode9XY0",
+	opcodeANNN: "opcodeANNN",
+	opcodeBNNN: "opcodeBNNN",
+	opcodeCXNN: "opcodeCXNN",
+	opcodeDXYN: "opcodeDXYN",
+	opcodeEX9E: "opcodeEX9E",
+	opcodeEXA1: "opcodeEXA1",
+	opcodeFX07: "opcodeFX07",
+	opcodeFX0A: "opcodeFX0A",
+	opcodeFX15: "opcodeFX15",
+	opcodeFX18: "opcodeFX18",
+	opcodeFX1E: "opcodeFX1E",
+	opcodeFX29: "opcodeFX29",
+	opcodeFX33: "opcodeFX33",
+	opcodeFX55: "opcodeFX55",
+	opcodeFX65: "opcodeFX65",
+	opcodeF000: "opcodeF000",
+
+	opcodeInvalid: "opcodeInvalid",
+}
+
+// opcodeHandlers names the method execute hands each instruction's raw opcode to, for generated
+// code. 00E0, 00EE and F000 have handlers that take no opcode and are left out.
+var opcodeHandlers = [...]string{
+	opcode1NNN: "JumpToAddr",
+	opcode2NNN: "callSubroutine",
+	opcode3XNN: "checkVxEqlNN",
+	opcode4XNN: "checkVxNotEqlNN",
+	opcode5XY0: "checkVxEqlVy",
+	opcode6XNN: "setVxToNN",
+	opcode7XNN: "addAssignToVx",
+	opcode8XY0: "setVxToVy",
+	opcode8XY1: "bitwiseORAssignVxToVy",
+	opcode8XY2: "bitwiseANDAssignVxToVy",
+	opcode8XY3: "bitwiseXORAssignVxToVy",
+	opcode8XY4: "addAssignVyToVx",
+	opcode8XY5: "subAssignVyToVx",
+	opcode8XY6: "rightShiftVxBy1",
+	opcode8XY7: "setVxToVySubVx",
+	opcode8XYE: "leftShiftVxBy1",
+	opcode9XY0: "checkVxNotEqlVy",
+	opcodeANNN: "setIReg",
+	opcodeBNNN: "pcJump",
+	opcodeCXNN: "setVxToRand",
+	opcodeDXYN: "drawSprite",
+	opcodeEX9E: "keyOpEqlCheck",
+	opcodeEXA1: "keyOpNotEqlCheck",
+	opcodeFX07: "setVxToDelayTimer",
+	opcodeFX0A: "setVxToKeyPress",
+	opcodeFX15: "setDelayTimerToVx",
+	opcodeFX18: "setSoundTimerToVx",
+	opcodeFX1E: "addAssignVxToI",
+	opcodeFX29: "setIToSpriteAddrVx",
+	opcodeFX33: "storeBCDToI",
+	opcodeFX55: "regDump",
+	opcodeFX65: "regLoad",
+
+	opcodeInvalid: "executeInvalid",
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"time"
+
+	"github.com/gopxl/pixel/v2"
+	"github.com/gopxl/pixel/v2/ext/imdraw"
+	"github.com/gopxl/pixel/v2/ext/text"
+)
+
+// osdDuration is how long a notification stays on screen
+const osdDuration = 2 * time.Second
+
+// osdLimit caps how many notifications are stacked at once, older ones are dropped
+const osdLimit = 4
+
+var osdShade = color.RGBA{0, 0, 0, 0xA0}
+
+// the overlay colors as imdraw and text want them, converted once rather than every frame, and
+// the color mask text is drawn with, which Text.Draw would otherwise make anew each time
+var (
+	overlayShade color.Color = pixel.ToRGBA(osdShade)
+	overlayText  color.Color = pixel.ToRGBA(colorOff)
+	overlayMask  color.Color = pixel.Alpha(1)
+)
+
+// overlay holds the text and shapes the overlays are drawn with. They are drawn one after
+// another and kept from frame to frame, so once their buffers have grown drawing allocates nothing.
+type overlay struct {
+	txt         *text.Text
+	shade, keys *imdraw.IMDraw
+
+	// text written in one go, as every Write makes the Text copy it, and the keypad colors with the colors they were made from
+	buf           []byte
+	keyOn, keyOff color.Color
+	keyColors     [2]color.RGBA
+}
+
+// beginOverlay clears the overlay for drawing another, with text starting at orig
+func (c *Chip8) beginOverlay(orig pixel.Vec) *overlay {
+	o := &c.overlay
+	if o.txt == nil {
+		o.txt = text.New(orig, menuAtlas)
+		o.shade, o.keys = imdraw.New(nil), imdraw.New(nil)
+	}
+	o.txt.Orig = orig
+	o.txt.Clear()
+	o.txt.Color = overlayText
+	o.shade.Clear()
+	o.shade.Color = overlayShade
+	o.keys.Clear()
+	return o
+}
+
+type osdMessage struct {
+	text  string
+	until time.Time
+}
+
+// osd is the list of notifications shown in the bottom left corner of the window
+type osd struct {
+	messages []osdMessage
+
+	// set while something is drawn, so the screen is repainted once more after it expires
+	shown bool
+}
+
+// Notify briefly shows a message over the game, giving hotkeys and mode changes visible feedback
+func (c *Chip8) Notify(format string, args ...any) {
+	m := osdMessage{fmt.Sprintf(format, args...), time.Now().Add(osdDuration)}
+	c.osd.messages = append(c.osd.messages, m)
+	if len(c.osd.messages) > osdLimit {
+		c.osd.messages = c.osd.messages[len(c.osd.messages)-osdLimit:]
+	}
+}
+
+// expire drops notifications whose time is up, reporting whether any are left to draw
+func (o *osd) expire(now time.Time) bool {
+	live := o.messages[:0]
+	for _, m := range o.messages {
+		if now.Before(m.until) {
+			live = append(live, m)
+		}
+	}
+	o.messages = live
+	return len(live) > 0
+}
+
+// needsRedraw reports whether the screen must be repainted this frame to show or clear
+// notifications
+func (o *osd) needsRedraw() bool {
+	return o.shown || len(o.messages) > 0
+}
+
+// drawOSD paints the live notifications over the screen, which renderScreen has just redrawn
+func (c *Chip8) drawOSD() {
+	c.osd.shown = c.osd.expire(time.Now())
+	if !c.osd.shown {
+		return
+	}
+
+	// newest at the bottom
+	line := menuAtlas.LineHeight()
+	o := c.beginOverlay(pixel.V(8, 6+line*float64(len(c.osd.messages)-1)))
+	o.buf = o.buf[:0]
+	for i, m := range c.osd.messages {
+		if i > 0 {
+			o.buf = append(o.buf, '\n')
+		}
+		o.buf = append(o.buf, m.text...)
+	}
+	o.txt.Write(o.buf)
+
+	b := o.txt.Bounds()
+	o.shade.Push(b.Min.Sub(pixel.V(4, 2)), b.Max.Add(pixel.V(4, 2)))
+	o.shade.Rectangle(0)
+	o.shade.Draw(c.Screen)
+
+	o.txt.DrawColorMask(c.Screen, pixel.IM, overlayMask)
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+)
+
+// errPatchTruncated is returned for patch files that end part way through a record
+var errPatchTruncated = errors.New("patch ends early")
+
+// applyPatchFile applies an IPS or BPS patch from file to rom, telling the formats apart by
+// their headers rather than the extension
+func applyPatchFile(rom []byte, file string) ([]byte, error) {
+	patch, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []byte
+	switch {
+	case bytes.HasPrefix(patch, []byte("PATCH")):
+		out, err = applyIPS(rom, patch)
+	case bytes.HasPrefix(patch, []byte("BPS1")):
+		out, err = applyBPS(rom, patch)
+	default:
+		err = errors.New("not an IPS or BPS patch")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath.Base(file), err)
+	}
+	return out, nil
+}
+
+// applyIPS applies an IPS patch: records of a 3 byte offset and 2 byte length followed by the
+// bytes to write there, or a zero length and a run of one byte, up to EOF and an optional size
+// to truncate the result to
+func applyIPS(rom, patch []byte) ([]byte, error) {
+	out := append([]byte(nil), rom...)
+	p := patch[len("PATCH"):]
+	for {
+		if len(p) < 3 {
+			return nil, errPatchTruncated
+		}
+		if string(p[:3]) == "EOF" {
+			p = p[3:]
+			break
+		}
+		if len(p) < 5 {
+			return nil, errPatchTruncated
+		}
+		offset := int(p[0])<<16 | int(p[1])<<8 | int(p[2])
+		size := int(binary.BigEndian.Uint16(p[3:]))
+		p = p[5:]
+
+		var data []byte
+		if size > 0 {
+			if len(p) < size {
+				return nil, errPatchTruncated
+			}
+			data, p = p[:size], p[size:]
+		} else {
+			if len(p) < 3 {
+				return nil, errPatchTruncated
+			}
+			data = bytes.Repeat(p[2:3], int(binary.BigEndian.Uint16(p)))
+			p = p[3:]
+		}
+
+		if end := offset + len(data); end > len(out) {
+			out = append(out, make([]byte, end-len(out))...)
+		}
+		copy(out[offset:], data)
+	}
+
+	if len(p) >= 3 {
+		if size := int(p[0])<<16 | int(p[1])<<8 | int(p[2]); size < len(out) {
+			out = out[:size]
+		}
+	}
+	return out, nil
+}
+
+// bpsReader reads the variable length numbers BPS patches are made of
+type bpsReader struct {
+	p   []byte
+	err error
+}
+
+func (r *bpsReader) byte() byte {
+	if len(r.p) == 0 {
+		r.err = errPatchTruncated
+		return 0
+	}
+	b := r.p[0]
+	r.p = r.p[1:]
+	return b
+}
+
+// bpsMaxShift bounds the digits of a number, well past any size or offset a 16MB patch needs, so
+// a run of continuation bytes cannot overflow it
+const bpsMaxShift = 1 << 42
+
+func (r *bpsReader) number() int {
+	n, shift := 0, 1
+	for r.err == nil {
+		if shift > bpsMaxShift {
+			r.err = errors.New("patch holds a number too large to be a size or offset")
+			return 0
+		}
+		b := r.byte()
+		n += int(b&0x7F) * shift
+		if b&0x80 != 0 {
+			break
+		}
+		shift <<= 7
+		n += shift
+	}
+	return n
+}
+
+// signed reads a relative offset, its sign in the lowest bit
+func (r *bpsReader) signed() int {
+	n := r.number()
+	if n&1 != 0 {
+		return -(n >> 1)
+	}
+	return n >> 1
+}
+
+// applyBPS applies a BPS patch, which unlike IPS carries checksums of the ROM it was made for and
+// of the result, so a patch for another dump or revision is refused rather than garbling it
+func applyBPS(rom, patch []byte) ([]byte, error) {
+	if len(patch) < len("BPS1")+12 {
+		return nil, errPatchTruncated
+	}
+	footer := patch[len(patch)-12:]
+	if crc32.ChecksumIEEE(patch[:len(patch)-4]) != binary.LittleEndian.Uint32(footer[8:]) {
+		return nil, errors.New("patch is corrupt, its checksum does not match")
+	}
+	if crc32.ChecksumIEEE(rom) != binary.LittleEndian.Uint32(footer) {
+		return nil, errors.New("patch is for a different ROM, the checksum does not match")
+	}
+
+	r := &bpsReader{p: patch[len("BPS1") : len(patch)-12]}
+	if r.number() != len(rom) {
+		return nil, errors.New("patch is for a ROM of a different size")
+	}
+	targetSize := r.number()
+	if targetSize > 1<<24 {
+		return nil, errors.New("patched ROM would be over 16MB")
+	}
+	if metadata := r.number(); metadata <= len(r.p) {
+		r.p = r.p[metadata:]
+	} else {
+		return nil, errPatchTruncated
+	}
+
+	out := make([]byte, 0, targetSize)
+
+	sourceAt, targetAt := 0, 0
+	for len(r.p) > 0 && r.err == nil {
+		action := r.number()
+		n := action>>2 + 1
+		if r.err != nil {
+			break
+		}
+		if n <= 0 || len(out)+n > targetSize {
+			return nil, errors.New("patch writes past the size it gives the patched ROM")
+		}
+		switch action & 3 {
+		case 0: // source read, the ROM's bytes at the same offset
+			if len(out)+n > len(rom) {
+				return nil, errors.New("patch reads past the end of the ROM")
+			}
+			out = append(out, rom[len(out):len(out)+n]...)
+		case 1: // target read, bytes from the patch
+			if n > len(r.p) {
+				return nil, errPatchTruncated
+			}
+			out = append(out, r.p[:n]...)
+			r.p = r.p[n:]
+		case 2: // source copy, the ROM's bytes from somewhere else
+			sourceAt += r.signed()
+			if sourceAt < 0 || sourceAt+n > len(rom) {
+				return nil, errors.New("patch copies from outside the ROM")
+			}
+			out = append(out, rom[sourceAt:sourceAt+n]...)
+			sourceAt += n
+		case 3: // target copy, from the output so far and byte by byte as the two can overlap
+			targetAt += r.signed()
+			if targetAt < 0 || targetAt >= len(out) {
+				return nil, errors.New("patch copies from outside the patched ROM")
+			}
+			for range n {
+				out = append(out, out[targetAt])
+				targetAt++
+			}
+		}
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	if len(out) != targetSize || crc32.ChecksumIEEE(out) != binary.LittleEndian.Uint32(footer[4:]) {
+		return nil, errors.New("patched ROM does not match the checksum the patch expects")
+	}
+	return out, nil
+}
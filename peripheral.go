@@ -0,0 +1,141 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Peripheral is an extension attached to the machine. What it does is decided by which of the
+// interfaces below it also implements; a peripheral can implement any combination of them.
+type Peripheral interface {
+	Name() string
+}
+
+// OpcodeHandler peripherals take over instructions before the core decoder sees them, e.g. to
+// give meaning to opcodes in the unused 0NNN space
+type OpcodeHandler interface {
+	Peripheral
+	HandlesOpcode(opcode uint16) bool
+	ExecuteOpcode(c *Chip8, opcode uint16)
+}
+
+// MemoryDevice peripherals answer for an address range, inclusive at both ends. They see the
+// data accesses made by DXYN, FX33, FX55 and FX65; instruction fetches always read MainMemory.
+type MemoryDevice interface {
+	Peripheral
+	MemoryRange() (start, end uint16)
+	Load(addr uint16) byte
+	Store(addr uint16, v byte)
+}
+
+// FrameHandler peripherals are called once at the end of every frame
+type FrameHandler interface {
+	Peripheral
+	Frame(c *Chip8)
+}
+
+// peripherals holds the attached peripherals split by what they do, so the hot paths only have
+// to check a slice length
+type peripherals struct {
+	all     []Peripheral
+	opcodes []OpcodeHandler
+	memory  []MemoryDevice
+	frames  []FrameHandler
+}
+
+// AttachPeripheral adds a peripheral to the machine. Memory ranges may not overlap.
+func (c *Chip8) AttachPeripheral(p Peripheral) error {
+	ph := &c.peripherals
+
+	if m, ok := p.(MemoryDevice); ok {
+		start, end := m.MemoryRange()
+		if start > end || int(end) >= len(c.MainMemory) {
+			return fmt.Errorf("%s: bad memory range %03X-%03X", p.Name(), start, end)
+		}
+		for _, other := range ph.memory {
+			otherStart, otherEnd := other.MemoryRange()
+			if start <= otherEnd && otherStart <= end {
+				return fmt.Errorf("%s: memory range %03X-%03X overlaps %s", p.Name(), start, end, other.Name())
+			}
+		}
+		ph.memory = append(ph.memory, m)
+	}
+	if o, ok := p.(OpcodeHandler); ok {
+		ph.opcodes = append(ph.opcodes, o)
+	}
+	if f, ok := p.(FrameHandler); ok {
+		ph.frames = append(ph.frames, f)
+	}
+
+	ph.all = append(ph.all, p)
+	return nil
+}
+
+// Peripherals lists the attached peripherals in the order they were attached
+func (c *Chip8) Peripherals() []Peripheral {
+	return c.peripherals.all
+}
+
+// executePeripheralOpcode runs an instruction claimed by a peripheral, reporting whether one did
+func (c *Chip8) executePeripheralOpcode(opcode uint16) bool {
+	for _, o := range c.peripherals.opcodes {
+		if o.HandlesOpcode(opcode) {
+			o.ExecuteOpcode(c, opcode)
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Chip8) memoryDevice(addr uint16) MemoryDevice {
+	for _, m := range c.peripherals.memory {
+		if start, end := m.MemoryRange(); addr >= start && addr <= end {
+			return m
+		}
+	}
+	return nil
+}
+
+// readMemory reads a data byte, from a memory device if one is mapped at addr. Addresses past
+// the end of memory wrap around to the start, as the address lines of a smaller machine would.
+func (c *Chip8) readMemory(addr uint16) byte {
+	addr &= uint16(len(c.MainMemory) - 1)
+	if len(c.peripherals.memory) > 0 {
+		if m := c.memoryDevice(addr); m != nil {
+			return m.Load(addr)
+		}
+	}
+	return c.MainMemory[addr]
+}
+
+// writeMemory stores a data byte, to a memory device if one is mapped at addr, wrapping around
+// like readMemory
+func (c *Chip8) writeMemory(addr uint16, v byte) {
+	addr &= uint16(len(c.MainMemory) - 1)
+	if len(c.peripherals.memory) > 0 {
+		if m := c.memoryDevice(addr); m != nil {
+			m.Store(addr, v)
+			return
+		}
+	}
+	c.MainMemory[addr] = v
+	c.invalidateDecoded(addr)
+}
+
+// errDataPastEnd is the fault of an instruction reaching past the end of memory with StrictMemory
+var errDataPastEnd = errors.New("data past the end of memory")
+
+// checkData faults when StrictMemory is on and the n bytes at I an instruction is about to read
+// or write run past the end of memory, which otherwise wrap around to address 0
+func (c *Chip8) checkData(n int) {
+	if c.StrictMemory && int(c.I)+n > len(c.MainMemory) {
+		panic(fmt.Errorf("%w, %d bytes at I %04X with %d in memory", errDataPastEnd, n, c.I, len(c.MainMemory)))
+	}
+}
+
+// framePeripherals gives every frame handler its per-frame callback
+func (c *Chip8) framePeripherals() {
+	for _, f := range c.peripherals.frames {
+		f.Frame(c)
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"os"
+	"strings"
+)
+
+// importedSprite is one 8 pixel wide strip cut from an image, at x, y in it
+type importedSprite struct {
+	x, y int
+	data []byte
+}
+
+// parseRegion reads a -region of x,y,w,h pixels
+func parseRegion(s string) (image.Rectangle, error) {
+	var x, y, w, h int
+	if _, err := fmt.Sscanf(s, "%d,%d,%d,%d", &x, &y, &w, &h); err != nil || w < 1 || h < 1 {
+		return image.Rectangle{}, fmt.Errorf("bad -region %q, want x,y,w,h", s)
+	}
+	return image.Rect(x, y, x+w, y+h), nil
+}
+
+// pixelOn reports whether a pixel is drawn: opaque and darker than mid grey, as ink on paper,
+// or lighter with invert for art drawn the way the screen shows it
+func pixelOn(c color.Color, invert bool) bool {
+	if _, _, _, a := c.RGBA(); a < 0x8000 {
+		return false
+	}
+	return color.GrayModel.Convert(c).(color.Gray).Y < 0x80 != invert
+}
+
+// cutSprites cuts an area of an image into sprites 8 pixels wide and at most height rows tall,
+// left to right then top to bottom, padding the right-most column with unset pixels
+func cutSprites(img image.Image, area image.Rectangle, height int, invert bool) []importedSprite {
+	var sprites []importedSprite
+	for y := area.Min.Y; y < area.Max.Y; y += height {
+		for x := area.Min.X; x < area.Max.X; x += 8 {
+			s := importedSprite{x: x, y: y}
+			for row := y; row < min(y+height, area.Max.Y); row++ {
+				b := byte(0)
+				for bit := 0; bit < 8 && x+bit < area.Max.X; bit++ {
+					if pixelOn(img.At(x+bit, row), invert) {
+						b |= 0x80 >> bit
+					}
+				}
+				s.data = append(s.data, b)
+			}
+			sprites = append(sprites, s)
+		}
+	}
+	return sprites
+}
+
+// writeSprites writes sprites as bare hex bytes, assembler db lines or Octo labels, each
+// headed by a drawing of it in comments
+func writeSprites(w io.Writer, sprites []importedSprite, format, name string) {
+	comment := ";"
+	if format == "octo" {
+		comment = "#"
+	}
+	for i, s := range sprites {
+		hex := make([]string, len(s.data))
+		for j, b := range s.data {
+			hex[j] = fmt.Sprintf("0x%02X", b)
+		}
+		label := name
+		if len(sprites) > 1 {
+			label = fmt.Sprintf("%s%d", name, i)
+		}
+
+		fmt.Fprintf(w, "%s 8x%d sprite from %d,%d\n", comment, len(s.data), s.x, s.y)
+		for _, b := range s.data {
+			fmt.Fprintf(w, "%s   %s\n", comment, spriteRow(b))
+		}
+		switch format {
+		case "db":
+			fmt.Fprintf(w, "%s: db %s\n", label, strings.Join(hex, ", "))
+		case "octo":
+			fmt.Fprintf(w, ": %s\n  %s\n", label, strings.Join(hex, " "))
+		default:
+			fmt.Fprintln(w, strings.Join(hex, " "))
+		}
+	}
+}
+
+// pngSpritesCommand implements "chip8 png-sprites image.png": the sprites in a drawing,
+// as bytes to paste into a program
+func pngSpritesCommand(args []string) error {
+	fs := newFlagSet("png-sprites")
+	region := fs.String("region", "", "part of the image to convert as x,y,w,h pixels, all of it by default")
+	height := fs.Int("height", 15, "rows per sprite, taller areas are cut into several")
+	format := fs.String("format", "hex", "output as hex bytes, db assembler lines or octo source")
+	name := fs.String("name", "sprite", "label for db and octo output, numbered when there are several sprites")
+	invert := fs.Bool("invert", false, "draw the light pixels rather than the dark ones")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return usageError("png-sprites")
+	}
+	if *height < 1 || *height > 15 {
+		return fmt.Errorf("-height %d: DXYN draws 1 to 15 rows", *height)
+	}
+	switch *format {
+	case "hex", "db", "octo":
+	default:
+		return fmt.Errorf("-format %q: want hex, db or octo", *format)
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		return fmt.Errorf("%s: %w", fs.Arg(0), err)
+	}
+
+	area := img.Bounds()
+	if *region != "" {
+		r, err := parseRegion(*region)
+		if err != nil {
+			return err
+		}
+		if area = r.Add(img.Bounds().Min).Intersect(img.Bounds()); area.Empty() {
+			return fmt.Errorf("-region %q is outside the %dx%d image", *region, img.Bounds().Dx(), img.Bounds().Dy())
+		}
+	}
+
+	writeSprites(os.Stdout, cutSprites(img, area, *height, *invert), *format, *name)
+	return nil
+}
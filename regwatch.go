@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// regWatch pauses the machine when a register or timer changes, or only when it changes to a
+// particular value. Registers are looked at before every instruction, so the instruction that
+// changed one is the one that ran last; timers also change as frames end.
+type regWatch struct {
+	name string
+	get  func(c *Chip8) uint16
+
+	// pause only on a change to value
+	matchValue bool
+	value      uint16
+
+	last uint16
+}
+
+// watchableRegister finds the accessor for a register name: V0 to VF, I, DT or ST
+func watchableRegister(name string) (func(c *Chip8) uint16, bool) {
+	switch name = strings.ToUpper(name); {
+	case name == "I":
+		return func(c *Chip8) uint16 { return c.I }, true
+	case name == "DT":
+		return func(c *Chip8) uint16 { return uint16(c.DT) }, true
+	case name == "ST":
+		return func(c *Chip8) uint16 { return uint16(c.ST) }, true
+	case len(name) == 2 && name[0] == 'V':
+		r, err := strconv.ParseUint(name[1:], 16, 4)
+		if err != nil {
+			return nil, false
+		}
+		return func(c *Chip8) uint16 { return uint16(c.Vx[r]) }, true
+	}
+	return nil, false
+}
+
+func (w *regWatch) String() string {
+	if w.matchValue {
+		return fmt.Sprintf("%s = 0x%02X", w.name, w.value)
+	}
+	return w.name
+}
+
+// registerChange notes the current value of every watched register and describes the first
+// that changed as its watch asks, or returns "" when none did
+func (c *Chip8) registerChange() string {
+	d := c.Debugger
+	change := ""
+	for _, w := range d.RegWatches {
+		now := w.get(c)
+		if now != w.last && change == "" && (!w.matchValue || now == w.value) {
+			change = fmt.Sprintf("%s changed from 0x%02X to 0x%02X", w.name, w.last, now)
+			if d.lastRan {
+				change += fmt.Sprintf(" after %04X: %s", d.lastPC, Mnemonic(c.word(d.lastPC), c.Symbols))
+			}
+		}
+		w.last = now
+	}
+	return change
+}
+
+// debugWatchReg adds a register watch, or lists them with no arguments
+func debugWatchReg(c *Chip8, args []string) error {
+	d := c.Debugger
+	switch len(args) {
+	case 0:
+		if len(d.RegWatches) == 0 {
+			fmt.Fprintln(d.out, "no register watches")
+		}
+		for _, w := range d.RegWatches {
+			fmt.Fprintf(d.out, "  %s\n", w)
+		}
+		return nil
+	case 1, 2:
+	default:
+		return fmt.Errorf("usage: %s", debugCommands["watchreg"].usage)
+	}
+
+	get, ok := watchableRegister(args[0])
+	if !ok {
+		return fmt.Errorf("cannot watch %s, only V0-VF, I, DT and ST", args[0])
+	}
+	w := &regWatch{name: strings.ToUpper(args[0]), get: get, last: get(c)}
+	if len(args) == 2 {
+		bits := 8
+		if w.name == "I" {
+			bits = 16
+		}
+		v, err := strconv.ParseUint(args[1], 0, bits)
+		if err != nil {
+			return fmt.Errorf("bad %d-bit value %q", bits, args[1])
+		}
+		w.matchValue, w.value = true, uint16(v)
+	}
+	d.RegWatches = append(d.RegWatches, w)
+	fmt.Fprintf(d.out, "watching %s\n", w)
+	return nil
+}
+
+// unwatchRegister removes the watches on a register, for unwatch
+func (c *Chip8) unwatchRegister(reg string) error {
+	d := c.Debugger
+	name := strings.ToUpper(reg)
+	kept := d.RegWatches[:0]
+	for _, w := range d.RegWatches {
+		if w.name != name {
+			kept = append(kept, w)
+		}
+	}
+	if len(kept) == len(d.RegWatches) {
+		return fmt.Errorf("no watch on %s", name)
+	}
+	d.RegWatches = kept
+	fmt.Fprintf(d.out, "deleted watches on %s\n", name)
+	return nil
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+)
+
+// remoteMessage is a key change sent by a remote controller, e.g. {"key": 5, "pressed": true}
+type remoteMessage struct {
+	Key     *uint8 `json:"key"`
+	Pressed bool   `json:"pressed"`
+}
+
+// remoteInput collects keys pressed by remote controllers connected over WebSocket and merges
+// them into the keyboard input once a frame
+type remoteInput struct {
+	mu sync.Mutex
+
+	// keys each connection holds down; keys pressed or released since the last frame, so a tap
+	// shorter than a frame still registers
+	held     map[any]uint16
+	tapped   uint16
+	released uint16
+}
+
+func newRemoteInput() *remoteInput {
+	return &remoteInput{held: map[any]uint16{}}
+}
+
+// handle registers the controller endpoint at /input and a keypad page for phones at /
+func (ri *remoteInput) handle(mux *http.ServeMux) {
+	mux.HandleFunc("/input", ri.serveInput)
+	mux.HandleFunc("/{$}", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, remoteKeypadPage)
+	})
+}
+
+// serveRemoteInput starts accepting controllers at ws://addr/input, with the keypad page at
+// http://addr/
+func serveRemoteInput(addr string) (*remoteInput, *http.Server, error) {
+	ri := newRemoteInput()
+	mux := http.NewServeMux()
+	ri.handle(mux)
+
+	srv, err := listenAndServe(addr, mux, "remote input")
+	if err != nil {
+		return nil, nil, err
+	}
+	return ri, srv, nil
+}
+
+// listenAndServe serves handler on addr in the background, logging what under name
+func listenAndServe(addr string, handler http.Handler, name string) (*http.Server, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	srv := &http.Server{Handler: handler}
+	go func() {
+		if err := srv.Serve(ln); err != http.ErrServerClosed {
+			log.Printf("%s: %v", name, err)
+		}
+	}()
+	log.Printf("%s: listening on %s", name, ln.Addr())
+	return srv, nil
+}
+
+// serveInput reads key messages from one controller until it disconnects, then lets go of the
+// keys it was holding
+func (ri *remoteInput) serveInput(w http.ResponseWriter, r *http.Request) {
+	ws, err := upgradeWebSocket(w, r)
+	if err != nil {
+		return
+	}
+	defer ws.Close()
+
+	ri.mu.Lock()
+	ri.held[ws] = 0
+	ri.mu.Unlock()
+
+	defer func() {
+		ri.mu.Lock()
+		ri.released |= ri.held[ws]
+		delete(ri.held, ws)
+		ri.mu.Unlock()
+	}()
+
+	for {
+		data, err := ws.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		var m remoteMessage
+		if err := json.Unmarshal(data, &m); err != nil || m.Key == nil || *m.Key > 0xF {
+			ws.WriteText([]byte(`{"error":"expected {\"key\": 0-15, \"pressed\": true|false}"}`))
+			continue
+		}
+
+		ri.press(ws, *m.Key, m.Pressed)
+	}
+}
+
+// press records a key going down or up on one of the controllers, identified by src
+func (ri *remoteInput) press(src any, key uint8, pressed bool) {
+	bit := uint16(1) << key
+	ri.mu.Lock()
+	defer ri.mu.Unlock()
+
+	if pressed {
+		ri.held[src] |= bit
+		ri.tapped |= bit
+	} else {
+		ri.held[src] &^= bit
+		ri.released |= bit
+	}
+}
+
+// Apply adds the remotely held keys to this frame's input
+func (ri *remoteInput) Apply(c *Chip8) {
+	ri.mu.Lock()
+	pressed, released := ri.tapped, ri.released
+	for _, keys := range ri.held {
+		pressed |= keys
+	}
+	ri.tapped, ri.released = 0, 0
+	ri.mu.Unlock()
+
+	for i := range c.KeyPressed {
+		bit := uint16(1) << i
+		if pressed&bit != 0 {
+			c.KeyPressed[i] = true
+		}
+		if released&bit != 0 {
+			c.KeyJustReleased[i] = true
+		}
+	}
+}
+
+// keypadURLs lists the addresses a phone on the local network can reach the keypad page at, for
+// a server listening on addr
+func keypadURLs(addr string) []string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil
+	}
+	if host != "" && host != "0.0.0.0" && host != "::" {
+		return []string{"http://" + net.JoinHostPort(host, port) + "/"}
+	}
+
+	ifaces, err := net.InterfaceAddrs()
+	if err != nil {
+		return nil
+	}
+	var urls []string
+	for _, a := range ifaces {
+		ipnet, ok := a.(*net.IPNet)
+		if !ok || ipnet.IP.IsLoopback() || ipnet.IP.To4() == nil {
+			continue
+		}
+		urls = append(urls, "http://"+net.JoinHostPort(ipnet.IP.String(), port)+"/")
+	}
+	return urls
+}
+
+// remoteKeypadPage is a touch keypad laid out like the COSMAC VIP's that drives /input. It
+// reconnects when the emulator restarts and buzzes on each press where phones support it.
+const remoteKeypadPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta name="viewport" content="width=device-width, initial-scale=1, maximum-scale=1, user-scalable=no">
+<meta name="mobile-web-app-capable" content="yes">
+<title>CHIP-8 keypad</title>
+<style>
+body { background: #222; margin: 0; display: grid; place-items: center; height: 100vh;
+       user-select: none; -webkit-user-select: none; -webkit-touch-callout: none; }
+#pad { display: grid; grid-template-columns: repeat(4, 20vmin); gap: 2vmin; }
+button { height: 20vmin; font: bold 8vmin monospace; border: 0; border-radius: 2vmin; touch-action: none; }
+button.down { background: #8c8; }
+#pad.offline button { opacity: 0.4; }
+</style>
+</head>
+<body>
+<div id="pad" class="offline"></div>
+<script>
+const pad = document.getElementById("pad");
+let ws;
+function connect() {
+  ws = new WebSocket("ws://" + location.host + "/input");
+  ws.onopen = () => pad.classList.remove("offline");
+  ws.onclose = () => { pad.classList.add("offline"); setTimeout(connect, 1000); };
+}
+connect();
+for (const key of [1, 2, 3, 12, 4, 5, 6, 13, 7, 8, 9, 14, 10, 0, 11, 15]) {
+  const b = document.createElement("button");
+  b.textContent = key.toString(16).toUpperCase();
+  const send = pressed => {
+    if (b.classList.contains("down") === pressed) return;
+    b.classList.toggle("down", pressed);
+    if (pressed && navigator.vibrate) navigator.vibrate(15);
+    if (ws.readyState === WebSocket.OPEN) ws.send(JSON.stringify({key, pressed}));
+  };
+  b.onpointerdown = e => { b.releasePointerCapture(e.pointerId); send(true); };
+  b.onpointerup = b.onpointerleave = b.onpointercancel = () => send(false);
+  b.oncontextmenu = e => e.preventDefault();
+  pad.appendChild(b);
+}
+</script>
+</body>
+</html>
+`
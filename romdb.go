@@ -0,0 +1,123 @@
+package main
+
+import (
+	"cmp"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// RomInfo describes a known ROM dump and the settings it needs to run correctly
+type RomInfo struct {
+	SHA1    string  `json:"sha1"`
+	Title   string  `json:"title"`
+	Variant string  `json:"variant,omitempty"`
+	Quirks  *Quirks `json:"quirks,omitempty"`
+	Font    string  `json:"font,omitempty"`
+
+	// Length of the dump and the name it usually goes by, for telling bad dumps apart from
+	// unknown ROMs, see Verify
+	Size int    `json:"size,omitempty"`
+	File string `json:"file,omitempty"`
+
+	// Shown over the game when it starts, see romCard
+	Author   string `json:"author,omitempty"`
+	Year     int    `json:"year,omitempty"`
+	Controls string `json:"controls,omitempty"`
+}
+
+// RomDatabase indexes known ROMs by the lower-case hex SHA-1 of their contents
+type RomDatabase map[string]RomInfo
+
+// LoadRomDatabase reads a JSON array of RomInfo entries
+func LoadRomDatabase(dbFile string) (RomDatabase, error) {
+	f, err := os.ReadFile(dbFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []RomInfo
+	if err := json.Unmarshal(f, &entries); err != nil {
+		return nil, err
+	}
+
+	db := make(RomDatabase, len(entries))
+	for _, e := range entries {
+		db[strings.ToLower(e.SHA1)] = e
+	}
+
+	return db, nil
+}
+
+// Lookup finds the database entry matching a ROM image
+func (db RomDatabase) Lookup(rom []byte) (RomInfo, bool) {
+	info, ok := db[romSHA1(rom)]
+	return info, ok
+}
+
+// badDump is how a ROM differs from the known dump it seems to be
+type badDump struct {
+	Info    RomInfo
+	Problem string // overlong, truncated or modified
+	Detail  string // by how much, when the size is off
+}
+
+func (b badDump) String() string {
+	if b.Detail == "" {
+		return fmt.Sprintf("%s dump of %q", b.Problem, b.Info.Title)
+	}
+	return fmt.Sprintf("%s dump of %q, %s", b.Problem, b.Info.Title, b.Detail)
+}
+
+// Verify looks for a known dump that a ROM not in the database is a bad copy of: the dump with
+// bytes added to the end, or a file named like a dump that is shorter than it, longer, or the
+// same size with different contents. Entries without a size or file name cannot be checked.
+func (db RomDatabase) Verify(name string, rom []byte) (badDump, bool) {
+	entries := make([]RomInfo, 0, len(db))
+	for _, e := range db {
+		entries = append(entries, e)
+	}
+	slices.SortFunc(entries, func(a, b RomInfo) int { return cmp.Compare(a.SHA1, b.SHA1) })
+
+	// each prefix is hashed once however many dumps have its length
+	prefixes := map[int]string{}
+	for _, e := range entries {
+		if e.Size <= 0 || e.Size >= len(rom) {
+			continue
+		}
+		sum, ok := prefixes[e.Size]
+		if !ok {
+			sum = romSHA1(rom[:e.Size])
+			prefixes[e.Size] = sum
+		}
+		if sum == strings.ToLower(e.SHA1) {
+			return badDump{e, "overlong", fmt.Sprintf("%d bytes added to the end", len(rom)-e.Size)}, true
+		}
+	}
+
+	base := filepath.Base(name)
+	for _, e := range entries {
+		if e.File == "" || !strings.EqualFold(e.File, base) || e.Size <= 0 {
+			continue
+		}
+		switch {
+		case len(rom) < e.Size:
+			return badDump{e, "truncated", fmt.Sprintf("%d bytes short", e.Size-len(rom))}, true
+		case len(rom) > e.Size:
+			return badDump{e, "modified", fmt.Sprintf("%d bytes longer", len(rom)-e.Size)}, true
+		default:
+			return badDump{e, "modified", ""}, true
+		}
+	}
+	return badDump{}, false
+}
+
+func romSHA1(rom []byte) string {
+	sum := sha1.Sum(rom)
+	return hex.EncodeToString(sum[:])
+}
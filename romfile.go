@@ -0,0 +1,190 @@
+package main
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// romExtensions lists the file extensions readRomFile loads, in directories and inside archives
+var romExtensions = []string{".ch8", ".sc8", ".xo8", ".c8x", ".c8b", ".8o", ".gif"}
+
+// maxArchivedRomSize caps how much of a file in an archive is read, the largest memory of any
+// variant, so an archive cannot claim a small file and unpack a huge one
+const maxArchivedRomSize = 0x10000
+
+// romImage is a ROM read from disk along with what was learned while loading it
+type romImage struct {
+	Data []byte
+
+	// file the image was taken from, the inner file name for archives
+	Name string
+
+	// labels and source lines, for ROMs assembled from source
+	Symbols *SymbolTable
+
+	// settings bundled with the ROM in a .c8b container or an Octo cartridge
+	Bundle      *c8bBundle
+	OctoOptions *octoOptions
+}
+
+// readRomFile reads a ROM image from disk. A path of the form "roms.zip" or
+// "roms.zip:inner/game.ch8" is read from inside the zip archive, and Octo source (.8o) is
+// assembled into a ROM. The bytecode is taken out of .c8b containers and the source out of Octo
+// cartridge GIFs, keeping their settings.
+func readRomFile(romFile string) (*romImage, error) {
+	img := &romImage{Name: romFile}
+	var err error
+
+	if archive, inner, ok := splitZipPath(romFile); ok {
+		img.Data, img.Name, err = readRomFromZip(archive, inner)
+	} else {
+		img.Data, err = os.ReadFile(romFile)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.EqualFold(path.Ext(img.Name), ".8o") {
+		img.Data, img.Symbols, err = assembleOcto(string(img.Data))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", img.Name, err)
+		}
+	}
+
+	if strings.EqualFold(path.Ext(img.Name), ".gif") {
+		cart, err := readOctoCart(img.Data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", img.Name, err)
+		}
+		img.Data, img.Symbols, err = assembleOcto(cart.Program)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", img.Name, err)
+		}
+		img.OctoOptions = &cart.Options
+	}
+
+	if strings.EqualFold(path.Ext(img.Name), ".c8b") {
+		img.Data, img.Bundle, err = readC8B(img.Data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", img.Name, err)
+		}
+	}
+
+	return img, nil
+}
+
+// configureForImage picks the settings for a ROM read by readRomFile, see configureForRom, then
+// applies any bundled with it
+func (c *Chip8) configureForImage(img *romImage) {
+	c.configureForRom(img.Name, img.Data)
+	if img.Bundle != nil {
+		c.applyC8B(img.Name, img.Bundle)
+	}
+	if img.OctoOptions != nil {
+		c.applyOctoOptions(img.Name, img.OctoOptions)
+	}
+}
+
+// splitZipPath splits "archive.zip:inner" into its archive and inner file components
+func splitZipPath(romFile string) (archive, inner string, ok bool) {
+	lower := strings.ToLower(romFile)
+
+	if strings.HasSuffix(lower, ".zip") {
+		return romFile, "", true
+	}
+
+	if idx := strings.LastIndex(lower, ".zip:"); idx >= 0 {
+		return romFile[:idx+len(".zip")], romFile[idx+len(".zip:"):], true
+	}
+
+	return "", "", false
+}
+
+// readRomFromZip extracts a ROM from a zip archive. When inner is empty the archive must contain
+// exactly one file with a known ROM extension.
+func readRomFromZip(archive, inner string) ([]byte, string, error) {
+	r, err := zip.OpenReader(archive)
+	if err != nil {
+		return nil, "", err
+	}
+	defer r.Close()
+
+	var candidates []*zip.File
+	for _, f := range r.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+
+		if inner != "" {
+			if f.Name == inner || path.Base(f.Name) == inner {
+				candidates = append(candidates, f)
+				break
+			}
+			continue
+		}
+
+		if isRomExtension(path.Ext(f.Name)) {
+			candidates = append(candidates, f)
+		}
+	}
+
+	switch {
+	case len(candidates) == 0 && inner != "":
+		return nil, "", fmt.Errorf("%s: no file named %q in archive", archive, inner)
+	case len(candidates) == 0:
+		return nil, "", fmt.Errorf("%s: archive contains no %s files", archive, strings.Join(romExtensions, "/"))
+	case len(candidates) > 1:
+		names := make([]string, len(candidates))
+		for i, f := range candidates {
+			names[i] = f.Name
+		}
+		return nil, "", fmt.Errorf("%s: archive contains several ROMs, pick one with %s:<name> (%s)",
+			archive, archive, strings.Join(names, ", "))
+	}
+
+	rc, err := candidates[0].Open()
+	if err != nil {
+		return nil, "", err
+	}
+	defer rc.Close()
+
+	data, err := io.ReadAll(io.LimitReader(rc, maxArchivedRomSize+1))
+	if err != nil {
+		return nil, "", err
+	}
+	if len(data) > maxArchivedRomSize {
+		return nil, "", fmt.Errorf("%s: %s is larger than %d bytes", archive, candidates[0].Name, maxArchivedRomSize)
+	}
+
+	return data, candidates[0].Name, nil
+}
+
+func isRomExtension(ext string) bool {
+	for _, e := range romExtensions {
+		if strings.EqualFold(ext, e) {
+			return true
+		}
+	}
+	return false
+}
+
+// listRoms returns the names of the files in dir that loadRomFile can load, sorted
+func listRoms(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+
+	var files []string
+	for _, e := range entries {
+		ext := strings.ToLower(filepath.Ext(e.Name()))
+		if !e.IsDir() && (isRomExtension(ext) || ext == ".zip") {
+			files = append(files, e.Name())
+		}
+	}
+	sort.Strings(files)
+	return files, err
+}
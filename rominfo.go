@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gopxl/pixel/v2"
+)
+
+// romCardDuration is how long the database entry of a ROM is shown for when it starts
+const romCardDuration = 5 * time.Second
+
+// romCard is the overlay in the top left corner naming the ROM being played and how to play it,
+// from its database entry
+type romCard struct {
+	info  *RomInfo
+	until time.Time
+
+	// text laid out for the info it was laid out from, so it is not rebuilt every frame
+	layout    string
+	laidOutOf *RomInfo
+
+	// set while it is drawn, so the screen is repainted once more after it goes
+	shown bool
+}
+
+// show puts the card up for romCardDuration
+func (r *romCard) show() {
+	r.until = time.Now().Add(romCardDuration)
+}
+
+// needsRedraw reports whether the screen must be repainted this frame to show or clear the card
+func (r *romCard) needsRedraw() bool {
+	return r.shown || r.info != nil && time.Now().Before(r.until)
+}
+
+// text lays out the card: title, then author and year, then the controls wrapped to fit
+func (r *romCard) text() string {
+	var b strings.Builder
+	b.WriteString(r.info.Title)
+	switch {
+	case r.info.Author != "" && r.info.Year != 0:
+		fmt.Fprintf(&b, "\nby %s, %d", r.info.Author, r.info.Year)
+	case r.info.Author != "":
+		fmt.Fprintf(&b, "\nby %s", r.info.Author)
+	case r.info.Year != 0:
+		fmt.Fprintf(&b, "\n%d", r.info.Year)
+	}
+	if r.info.Controls != "" {
+		b.WriteString("\n")
+		for _, line := range wrapText("Controls: "+r.info.Controls, errorWidth/2) {
+			b.WriteString("\n" + line)
+		}
+	}
+	return b.String()
+}
+
+// ToggleRomInfo shows the database entry of the running ROM again, or hides it
+func (c *Chip8) ToggleRomInfo() {
+	switch {
+	case c.romCard.info == nil:
+		c.Notify("ROM not in the database")
+	case time.Now().Before(c.romCard.until):
+		c.romCard.until = time.Time{}
+	default:
+		c.romCard.show()
+	}
+}
+
+// drawRomCard paints the card over the screen, which renderScreen has just redrawn
+func (c *Chip8) drawRomCard() {
+	r := &c.romCard
+	r.shown = r.info != nil && time.Now().Before(r.until)
+	if !r.shown {
+		return
+	}
+
+	if r.laidOutOf != r.info {
+		r.layout, r.laidOutOf = r.text(), r.info
+	}
+
+	o := c.beginOverlay(pixel.ZV)
+	o.txt.WriteString(r.layout)
+	// lines run downwards from the origin, so move the block to hang from the top edge
+	b := o.txt.Bounds()
+	at := pixel.V(8-b.Min.X, c.Screen.Bounds().H()-8-b.Max.Y)
+
+	o.shade.Push(b.Min.Add(at).Sub(pixel.V(4, 2)), b.Max.Add(at).Add(pixel.V(4, 2)))
+	o.shade.Rectangle(0)
+	o.shade.Draw(c.Screen)
+
+	o.txt.DrawColorMask(c.Screen, pixel.IM.Moved(at), overlayMask)
+}
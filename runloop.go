@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// Run emulates frame after frame at FrameDuration each until ctx is done, the machine stops or its
+// window is closed. It returns ctx's error when cancelled and the Fault when execution faulted.
+// Cancellation is checked between frames, so a program spinning on FX0A or a stalled peripheral
+// still stops within a frame.
+func (c *Chip8) Run(ctx context.Context) error {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for !c.IsStopped && (c.Screen == nil || !c.Screen.Closed()) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		frameStart := time.Now()
+		c.Lock()
+		c.runFrameRecovered()
+		c.budget.record(time.Since(frameStart))
+		if c.stats != nil {
+			c.stats.recordFrame(time.Since(frameStart))
+		}
+		c.checkFrameBudget()
+		c.Unlock()
+
+		if remaining := FrameDuration - time.Since(frameStart); remaining > 0 {
+			timer.Reset(remaining)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-timer.C:
+			}
+		}
+	}
+
+	if c.Fault != nil {
+		return c.Fault
+	}
+	return nil
+}
+
+// OnFrame registers fn to run at the end of every frame of Run, after input has been read, with
+// the machine locked
+func (c *Chip8) OnFrame(fn func(c *Chip8)) {
+	c.frameHooks = append(c.frameHooks, fn)
+}
+
+// runFrameRecovered runs a frame, turning a panic outside instruction execution, in a frame hook
+// or peripheral say, into a Fault so it stops the machine like any other
+func (c *Chip8) runFrameRecovered() {
+	defer func() {
+		if r := recover(); r != nil {
+			c.fault(c.PC, c.word(c.PC), r)
+		}
+	}()
+	c.runFrame()
+}
+
+// runFrame does one frame's worth of work: debugger commands, execution unless something has
+// paused it, drawing and input
+func (c *Chip8) runFrame() {
+	if c.Debugger != nil {
+		c.runDebugCommands()
+	}
+
+	switch {
+	case c.menu != nil || c.helpShown || c.Paused:
+	case c.Debugger != nil && c.Debugger.Paused:
+		c.stepPaused()
+	default:
+		start := time.Now()
+		c.StepFrame()
+		c.budget.stepped, c.budget.frameCPU = true, time.Since(start)
+		c.checkHalt()
+	}
+
+	if c.Debugger != nil {
+		c.publishView()
+	}
+
+	c.DrawScreen()
+
+	held := c.KeyPressed
+	c.handleInput()
+	for _, fn := range c.frameHooks {
+		fn(c)
+	}
+	if c.stateReplay != nil {
+		c.stateReplay.Apply(c)
+	}
+	if c.sinceSave != nil {
+		c.sinceSave.rec.Record(c)
+	}
+	c.publishKeyChanges(held)
+}
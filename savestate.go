@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// saveState is everything needed to pick a game up where it was left
+type saveState struct {
+	RomHash string    `json:"rom_hash"`
+	Saved   time.Time `json:"saved"`
+
+	Variant string `json:"variant"`
+	Quirks  Quirks `json:"quirks"`
+
+	Memory      []byte        `json:"memory"`
+	Vx          [16]uint8     `json:"v"`
+	I           uint16        `json:"i"`
+	DT          uint8         `json:"dt"`
+	ST          uint8         `json:"st"`
+	PC          uint16        `json:"pc"`
+	SP          uint8         `json:"sp"`
+	Stack       [16]uint16    `json:"stack"`
+	ScreenState [32][64]uint8 `json:"screen"`
+	Frame       uint64        `json:"frame"`
+
+	// Input recorded since the state was saved, empty for a plain save state
+	Input []byte `json:"input,omitempty"`
+}
+
+// captureState copies the machine state into a saveState
+func (c *Chip8) captureState() *saveState {
+	return &saveState{
+		RomHash:     c.RomHash,
+		Saved:       time.Now(),
+		Variant:     c.Variant.String(),
+		Quirks:      c.Quirks,
+		Memory:      slices.Clone(c.MainMemory),
+		Vx:          c.Vx,
+		I:           c.I,
+		DT:          c.DT,
+		ST:          c.ST,
+		PC:          c.PC,
+		SP:          c.SP,
+		Stack:       c.Stack,
+		ScreenState: c.ScreenState.unpack(),
+		Frame:       c.Frame,
+	}
+}
+
+// restoreState puts the machine back into a captured state, read from source, and redraws the
+// screen
+func (c *Chip8) restoreState(s *saveState, source string) error {
+	v, err := ParseVariant(s.Variant)
+	if err != nil {
+		return err
+	}
+	if len(s.Memory) > v.memorySize() {
+		return fmt.Errorf("save state holds %d bytes of memory, more than the %d %s has", len(s.Memory), v.memorySize(), v)
+	}
+	if int(s.SP) > len(c.Stack) {
+		return fmt.Errorf("save state stack pointer %d exceeds the stack", s.SP)
+	}
+
+	c.Variant, c.Quirks = v, s.Quirks
+	c.resizeMemory(v.memorySize())
+	clear(c.MainMemory)
+	copy(c.MainMemory, s.Memory)
+	c.invalidateAllDecoded()
+	if s.RomHash != c.RomHash {
+		c.transpiled = nil
+	}
+	c.Vx, c.I, c.DT, c.ST = s.Vx, s.I, s.DT, s.ST
+	c.PC, c.SP, c.Stack = s.PC, s.SP, s.Stack
+	c.ScreenState = packScreen(s.ScreenState)
+	c.Frame = s.Frame
+	c.KeyPressed = [16]bool{}
+	c.KeyJustReleased = [16]bool{}
+	c.stateReplay = nil
+
+	c.Fault = nil
+
+	c.renderScreen()
+	c.Events.Publish(StateLoadedEvent{source})
+	return nil
+}
+
+// autosavePath returns where the autosave for a ROM lives, in the autosave user directory
+func autosavePath(romHash string) (string, error) {
+	data, _, err := dataDirs()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(data, "autosave", romHash+".c8s"), nil
+}
+
+// legacyAutosavePaths lists where older versions kept the autosave for a ROM, under the user's
+// config directory: in the versioned container, and before it as .json. None are looked at with
+// -data-dir, which keeps runs apart from the user's own files.
+func legacyAutosavePaths(romHash string) []string {
+	dir, err := os.UserConfigDir()
+	if err != nil || *dataDirFlag != "" {
+		return nil
+	}
+	dir = filepath.Join(dir, "chip8", "autosave")
+	return []string{filepath.Join(dir, romHash+".c8s"), filepath.Join(dir, romHash+".json")}
+}
+
+// Autosave writes the current state to the loaded ROM's autosave file
+func (c *Chip8) Autosave() error {
+	if c.RomHash == "" {
+		return nil
+	}
+
+	path, err := autosavePath(c.RomHash)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	if err := c.WriteStateFile(path); err != nil {
+		return err
+	}
+
+	// any autosave where older versions kept it has been superseded
+	for _, legacy := range legacyAutosavePaths(c.RomHash) {
+		if legacy != path {
+			os.Remove(legacy)
+		}
+	}
+
+	log.Printf("saved state to %s", path)
+	return nil
+}
+
+// readAutosave loads the autosave for a ROM and the file it came from, returning nil when there
+// is none
+func readAutosave(romHash string) (*saveState, string, error) {
+	path, err := autosavePath(romHash)
+	if err != nil {
+		return nil, "", err
+	}
+	for _, path := range append([]string{path}, legacyAutosavePaths(romHash)...) {
+		s, err := readStateFile(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, "", err
+		}
+
+		if s.RomHash != romHash {
+			return nil, "", fmt.Errorf("%s: saved for a different ROM", path)
+		}
+		return s, path, nil
+	}
+	return nil, "", nil
+}
+
+// offerResume asks on the terminal whether to continue from the ROM's autosave. Without a terminal
+// to ask on the game starts fresh.
+func (c *Chip8) offerResume() {
+	s, path, err := readAutosave(c.RomHash)
+	if err != nil {
+		log.Printf("ignoring autosave: %v", err)
+		return
+	}
+	if s == nil {
+		return
+	}
+
+	if info, err := os.Stdin.Stat(); err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		log.Printf("autosave from %s found, starting fresh as there is no terminal to ask on", s.Saved.Format(time.DateTime))
+		return
+	}
+
+	fmt.Printf("Resume from autosave of %s? [Y/n] ", s.Saved.Format(time.DateTime))
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	if a := strings.ToLower(strings.TrimSpace(answer)); a != "" && a != "y" && a != "yes" {
+		return
+	}
+
+	if err := c.restoreState(s, path); err != nil {
+		log.Printf("ignoring autosave: %v", err)
+		return
+	}
+	c.Notify("Resumed from autosave")
+}
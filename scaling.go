@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"strings"
+
+	"github.com/gopxl/pixel/v2"
+	"github.com/gopxl/pixel/v2/backends/opengl"
+)
+
+// ScreenFilter is how the 64x32 display is scaled up to fill the window. The display canvas is
+// drawn onto a window-sized canvas whose fragment shader does the scaling, so filters cost the
+// GPU rather than the CPU.
+type ScreenFilter uint8
+
+const (
+	FilterNearest ScreenFilter = iota
+	FilterSmooth
+	FilterScanlines
+)
+
+var filterNames = []string{
+	FilterNearest:   "nearest",
+	FilterSmooth:    "smooth",
+	FilterScanlines: "scanlines",
+}
+
+func (f ScreenFilter) String() string {
+	if int(f) < len(filterNames) {
+		return filterNames[f]
+	}
+	return fmt.Sprintf("ScreenFilter(%d)", uint8(f))
+}
+
+// ParseScreenFilter looks up a filter by name, e.g. "nearest" or "scanlines"
+func ParseScreenFilter(name string) (ScreenFilter, error) {
+	for f, n := range filterNames {
+		if strings.EqualFold(n, name) {
+			return ScreenFilter(f), nil
+		}
+	}
+	return FilterNearest, fmt.Errorf("unknown filter %q, want one of %s", name, strings.Join(filterNames, ", "))
+}
+
+// upscaleSample is the shaders' shared filtering. The texel nearest a window pixel is picked
+// from the four around it, or with uSmooth at 1 they are blended by distance (bilinear).
+const upscaleSample = `
+#version 330 core
+
+in vec4  vColor;
+in vec2  vTexCoords;
+in float vIntensity;
+
+out vec4 fragColor;
+
+uniform vec4 uColorMask;
+uniform vec4 uTexBounds;
+uniform sampler2D uTexture;
+uniform float uScale;
+uniform float uSmooth;
+
+vec4 upscale() {
+	ivec2 size = textureSize(uTexture, 0);
+	vec2 p = (vTexCoords - uTexBounds.xy) / uTexBounds.zw * vec2(size) - 0.5;
+	ivec2 base = ivec2(floor(p));
+	vec2 f = mix(step(0.5, fract(p)), fract(p), uSmooth);
+
+	ivec2 hi = size - 1;
+	vec4 a = texelFetch(uTexture, clamp(base, ivec2(0), hi), 0);
+	vec4 b = texelFetch(uTexture, clamp(base + ivec2(1, 0), ivec2(0), hi), 0);
+	vec4 c = texelFetch(uTexture, clamp(base + ivec2(0, 1), ivec2(0), hi), 0);
+	vec4 d = texelFetch(uTexture, clamp(base + ivec2(1, 1), ivec2(0), hi), 0);
+	vec4 texel = mix(mix(a, b, f.x), mix(c, d, f.x), f.y);
+	return mix(vColor, vColor * texel, vIntensity) * uColorMask;
+}
+`
+
+// upscaleShader scales the display up for the nearest and smooth filters
+const upscaleShader = upscaleSample + `
+void main() {
+	fragColor = upscale();
+}
+`
+
+// scanlineShader is upscaleShader with the bottom rows of every display pixel darkened, like
+// the gaps between the lines of a CRT
+const scanlineShader = upscaleSample + `
+void main() {
+	fragColor = upscale();
+	if (mod(gl_FragCoord.y, uScale) < uScale / 4) {
+		fragColor.rgb *= 0.6;
+	}
+}
+`
+
+// upscaler is the window-sized canvas the display is scaled onto, made once and kept, and the
+// filter its shader is set up for
+type upscaler struct {
+	canvas *opengl.Canvas
+	filter ScreenFilter
+
+	// shader uniforms, read through pointers so changing them needs no recompile
+	scale, smooth float32
+}
+
+// drawUpscaled scales the display canvas onto the window through the filter's shader
+func (c *Chip8) drawUpscaled() {
+	bounds := c.Screen.Bounds()
+	u := &c.upscaler
+	switch {
+	case u.canvas == nil:
+		u.canvas = opengl.NewCanvas(bounds)
+		u.scale = ScalingFactor
+		u.canvas.SetUniform("uScale", &u.scale)
+		u.canvas.SetUniform("uSmooth", &u.smooth)
+		u.canvas.SetFragmentShader(shaderFor(c.Filter))
+		u.filter = c.Filter
+	case u.canvas.Bounds() != bounds:
+		u.canvas.SetBounds(bounds)
+	}
+	if u.filter != c.Filter {
+		// the texture filtering is done in the shader, which only changes for scanlines
+		if shaderFor(u.filter) != shaderFor(c.Filter) {
+			u.canvas.SetFragmentShader(shaderFor(c.Filter))
+		}
+		u.filter = c.Filter
+	}
+	u.smooth = 0
+	if c.Filter == FilterSmooth {
+		u.smooth = 1
+	}
+
+	if c.transparent() {
+		u.canvas.Clear(color.RGBA{})
+		c.Screen.Clear(color.RGBA{})
+	}
+	c.canvas.Draw(u.canvas, pixel.IM.Scaled(pixel.ZV, ScalingFactor).Moved(u.canvas.Bounds().Center()))
+	u.canvas.Draw(c.Screen, pixel.IM.Moved(bounds.Center()))
+}
+
+// shaderFor is the fragment shader drawing the display with a filter
+func shaderFor(f ScreenFilter) string {
+	if f == FilterScanlines {
+		return scanlineShader
+	}
+	return upscaleShader
+}
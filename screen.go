@@ -0,0 +1,62 @@
+package main
+
+import (
+	"io"
+	"math/bits"
+)
+
+// screenPlane is a 64x32 display plane packed one row to a uint64, the leftmost pixel in the top
+// bit, so DXYN, clearing and comparing work on whole rows at a time. XO-CHIP's second plane is
+// another of these.
+type screenPlane [ScreenHeight]uint64
+
+// pixel reports whether the pixel at x, y is lit
+func (s *screenPlane) pixel(x, y int) bool {
+	return s[y]&(1<<(63-x)) != 0
+}
+
+// screenDiff counts the pixels that differ between two planes
+func screenDiff(x, y *screenPlane) int {
+	n := 0
+	for row := range x {
+		n += bits.OnesCount64(x[row] ^ y[row])
+	}
+	return n
+}
+
+// unpack spreads the plane out a byte per pixel, 1 lit and 0 not, the layout of save states and
+// snapshots
+func (s *screenPlane) unpack() [ScreenHeight][ScreenWidth]uint8 {
+	var px [ScreenHeight][ScreenWidth]uint8
+	for y, row := range s {
+		for x := range px[y] {
+			px[y][x] = uint8(row >> (63 - x) & 1)
+		}
+	}
+	return px
+}
+
+// packScreen is the plane of a display stored a byte per pixel, any non-zero byte lit
+func packScreen(px [ScreenHeight][ScreenWidth]uint8) screenPlane {
+	var s screenPlane
+	for y := range px {
+		for x, p := range px[y] {
+			if p != 0 {
+				s[y] |= 1 << (63 - x)
+			}
+		}
+	}
+	return s
+}
+
+// writePixels writes the plane a byte per pixel, which keeps hashes of the display the same as
+// when it was stored that way
+func (s *screenPlane) writePixels(w io.Writer) {
+	var buf [ScreenWidth]byte
+	for _, row := range s {
+		for x := range buf {
+			buf[x] = byte(row >> (63 - x) & 1)
+		}
+		w.Write(buf[:])
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// errSelfTestFailed is returned once the reason a self-test failed has been reported
+var errSelfTestFailed = errors.New("self-test failed")
+
+// exitDetector claims SCHIP's 00FD exit instruction, which the core does not run, and parks the
+// program on it so it is still there when the frame ends
+type exitDetector struct {
+	exited bool
+}
+
+func (e *exitDetector) Name() string { return "exit" }
+
+func (e *exitDetector) HandlesOpcode(opcode uint16) bool { return opcode == 0x00FD }
+
+func (e *exitDetector) ExecuteOpcode(c *Chip8, opcode uint16) {
+	e.exited = true
+	c.PC -= 2
+}
+
+// screenExpectation is what a test ROM's display must show when it finishes: either the whole
+// display's ScreenHash or a region of pixels
+type screenExpectation struct {
+	hash   uint64
+	region []string
+	x, y   int
+}
+
+// parseExpectation reads -expect: a hex display hash, or "x,y:file" naming a file of rows of #
+// (on) and . (off) pixels the display must show with its top-left corner at x,y
+func parseExpectation(s string) (*screenExpectation, error) {
+	pos, file, ok := strings.Cut(s, ":")
+	if !ok {
+		hash, err := strconv.ParseUint(strings.TrimPrefix(s, "0x"), 16, 64)
+		if err != nil {
+			return nil, fmt.Errorf("bad -expect %q, want a display hash or x,y:file", s)
+		}
+		return &screenExpectation{hash: hash}, nil
+	}
+
+	e := &screenExpectation{}
+	if _, err := fmt.Sscanf(pos, "%d,%d", &e.x, &e.y); err != nil {
+		return nil, fmt.Errorf("bad -expect position %q, want x,y", pos)
+	}
+	if e.x < 0 || e.x >= ScreenWidth || e.y < 0 || e.y >= ScreenHeight {
+		return nil, fmt.Errorf("bad -expect position %q, the display is %dx%d", pos, ScreenWidth, ScreenHeight)
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := strings.TrimRight(scanner.Text(), " \r"); line != "" {
+			e.region = append(e.region, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(e.region) == 0 {
+		return nil, fmt.Errorf("%s: no pixel rows", file)
+	}
+	return e, nil
+}
+
+// mismatch describes how the display differs from the expectation, "" when it matches
+func (e *screenExpectation) mismatch(c *Chip8) string {
+	if e.region == nil {
+		if got := c.ScreenHash(); got != e.hash {
+			return fmt.Sprintf("display hash %016x, want %016x", got, e.hash)
+		}
+		return ""
+	}
+
+	var bad []string
+	for dy, row := range e.region {
+		for dx, ch := range row {
+			x, y := e.x+dx, e.y+dy
+			if x >= ScreenWidth || y >= ScreenHeight {
+				return fmt.Sprintf("expected region runs off the display at %d,%d", x, y)
+			}
+			if want := ch == '#'; c.ScreenState.pixel(x, y) != want {
+				bad = append(bad, fmt.Sprintf("%d,%d", x, y))
+			}
+		}
+	}
+	if n := len(bad); n > 0 {
+		if n > 8 {
+			bad = append(bad[:8], "...")
+		}
+		return fmt.Sprintf("%d pixels differ: %s", n, strings.Join(bad, " "))
+	}
+	return ""
+}
+
+// screenText draws the display as rows of # and . in the format -expect regions are written in
+func screenText(s *screenPlane) string {
+	var b strings.Builder
+	for y := range s {
+		for x := 0; x < ScreenWidth; x++ {
+			if s.pixel(x, y) {
+				b.WriteByte('#')
+			} else {
+				b.WriteByte('.')
+			}
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// selftestCommand implements "chip8 selftest -expect hash rom.ch8": it runs a test ROM headless
+// until it finishes with 00FD or a loop it cannot leave (see spinLoop) and checks what it left
+// on the display
+func selftestCommand(args []string) error {
+	fs := newFlagSet("selftest")
+	expect := fs.String("expect", "", "display hash or x,y:file region the finished ROM must show; without it the hash is printed")
+	frames := fs.Uint64("frames", 600, "frames to wait for the ROM to finish before calling it hung")
+	variant := fs.String("variant", "", "interpreter variant, defaults to the one detected for the ROM")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return usageError("selftest")
+	}
+
+	var want *screenExpectation
+	if *expect != "" {
+		var err error
+		if want, err = parseExpectation(*expect); err != nil {
+			return err
+		}
+	}
+
+	img, err := readRomFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	c := newMachine()
+	c.LoadDefaultSprites()
+	c.configureForImage(img)
+	c.LoadRom(img.Data)
+	c.SetSeed(1)
+	if *variant != "" {
+		v, err := ParseVariant(*variant)
+		if err != nil {
+			return err
+		}
+		c.SetVariant(v)
+	}
+
+	exit := &exitDetector{}
+	if err := c.AttachPeripheral(exit); err != nil {
+		return err
+	}
+
+	finished := func() bool {
+		_, spinning := c.spinLoop()
+		return exit.exited || spinning
+	}
+	for c.Frame < *frames && c.Fault == nil && !finished() {
+		c.StepFrame()
+	}
+
+	switch {
+	case c.Fault != nil:
+		fmt.Printf("FAIL %s: %v\n", fs.Arg(0), c.Fault)
+		return errSelfTestFailed
+	case !finished():
+		fmt.Printf("FAIL %s: still running after %d frames, PC=%04X\n", fs.Arg(0), c.Frame, c.PC)
+		return errSelfTestFailed
+	case want == nil:
+		fmt.Printf("%s finished at frame %d, display hash %016x\n", fs.Arg(0), c.Frame, c.ScreenHash())
+		fmt.Print(screenText(&c.ScreenState))
+		return nil
+	}
+
+	if reason := want.mismatch(c); reason != "" {
+		fmt.Printf("FAIL %s: %s\n", fs.Arg(0), reason)
+		fmt.Print(screenText(&c.ScreenState))
+		return errSelfTestFailed
+	}
+	fmt.Printf("PASS %s (frame %d)\n", fs.Arg(0), c.Frame)
+	return nil
+}
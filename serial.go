@@ -0,0 +1,29 @@
+package main
+
+import (
+	"bufio"
+	"io"
+)
+
+// serialPort is a write-only output port mapped at a single address: every byte stored there
+// with FX55 (or FX33) is written to out, flushed at the end of each frame. It lets teaching
+// programs print text without drawing it.
+type serialPort struct {
+	addr uint16
+	out  *bufio.Writer
+}
+
+func newSerialPort(addr uint16, out io.Writer) *serialPort {
+	return &serialPort{addr: addr, out: bufio.NewWriter(out)}
+}
+
+func (s *serialPort) Name() string { return "serial" }
+
+func (s *serialPort) MemoryRange() (start, end uint16) { return s.addr, s.addr }
+
+// Load reads 0, there is no input side
+func (s *serialPort) Load(addr uint16) byte { return 0 }
+
+func (s *serialPort) Store(addr uint16, v byte) { s.out.WriteByte(v) }
+
+func (s *serialPort) Frame(c *Chip8) { s.out.Flush() }
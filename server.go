@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+)
+
+// screenImage draws a display scaled up by scale in the machine's colors
+func screenImage(screen [32][64]uint8, on, off color.RGBA, scale int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, ScreenWidth*scale, ScreenHeight*scale))
+	for y := range img.Rect.Dy() {
+		for x := range img.Rect.Dx() {
+			c := off
+			if screen[y/scale][x/scale] != 0 {
+				c = on
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+// frameServer serves what a headless machine is displaying
+type frameServer struct {
+	c     *Chip8
+	scale int
+	fps   int
+}
+
+// frame takes a consistent picture of the display
+func (f *frameServer) frame() *image.RGBA {
+	s := f.c.Snapshot()
+	return screenImage(s.Screen, f.c.ColorOn, f.c.ColorOff, f.scale)
+}
+
+// servePNG answers /frame.png with the current display
+func (f *frameServer) servePNG(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "no-store")
+	png.Encode(w, f.frame())
+}
+
+// serveStream answers /stream with an MJPEG stream of the display at fps frames a second, until
+// the client goes away
+func (f *frameServer) serveStream(w http.ResponseWriter, r *http.Request) {
+	const boundary = "chip8frame"
+	w.Header().Set("Content-Type", "multipart/x-mixed-replace; boundary="+boundary)
+	w.Header().Set("Cache-Control", "no-store")
+	flusher, _ := w.(http.Flusher)
+
+	ticker := time.NewTicker(time.Second / time.Duration(f.fps))
+	defer ticker.Stop()
+
+	for {
+		fmt.Fprintf(w, "--%s\r\nContent-Type: image/jpeg\r\n\r\n", boundary)
+		if err := jpeg.Encode(w, f.frame(), &jpeg.Options{Quality: 90}); err != nil {
+			return
+		}
+		if _, err := fmt.Fprint(w, "\r\n"); err != nil {
+			return
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// serveCommand implements "chip8 serve rom.ch8": the machine runs without a window and is
+// watched and played over HTTP
+func serveCommand(args []string) error {
+	fs := newFlagSet("serve")
+	addr := fs.String("addr", "127.0.0.1:8080", "address to serve on, :8080 to serve the whole network")
+	scale := fs.Int("scale", 8, "size of a CHIP-8 pixel in served images")
+	fps := fs.Int("fps", 15, "frames a second sent on /stream")
+	variant := fs.String("variant", "", "interpreter variant, defaults to the one detected for the ROM")
+	seed := fs.Int64("seed", 0, "seed for CXNN random numbers, 0 picks one from the clock")
+	grpcAddr := fs.String("grpc", "", "also serve the gRPC control API on this address, e.g. 127.0.0.1:50051")
+	romDir := fs.String("rom-dir", "", "directory gRPC clients may load ROMs from by path; without it they must send the ROM")
+	fs.StringVar(dataDirFlag, "data-dir", "", "write crash dumps under this directory instead of the user's data directory")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 || *scale < 1 || *fps < 1 {
+		return usageError("serve")
+	}
+
+	c := newMachine()
+	c.LoadDefaultSprites()
+	if err := c.loadRomFile(fs.Arg(0)); err != nil {
+		return err
+	}
+	if err := c.LoadSidecar(fs.Arg(0)); err != nil {
+		return err
+	}
+	if *variant != "" {
+		v, err := ParseVariant(*variant)
+		if err != nil {
+			return err
+		}
+		c.SetVariant(v)
+	}
+	if *seed != 0 {
+		c.SetSeed(*seed)
+	}
+
+	frames := &frameServer{c: c, scale: *scale, fps: *fps}
+	remote := newRemoteInput()
+	c.OnFrame(remote.Apply)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /frame.png", frames.servePNG)
+	mux.HandleFunc("GET /stream", frames.serveStream)
+	remote.handle(mux)
+
+	srv, err := listenAndServe(*addr, mux, "serve")
+	if err != nil {
+		return err
+	}
+	defer srv.Close()
+	for _, u := range keypadURLs(*addr) {
+		log.Printf("serve: keypad at %s", u)
+	}
+
+	var control *controlServer
+	if *grpcAddr != "" {
+		control = newControlServer(c, remote, *romDir)
+		srv, err := serveControl(*grpcAddr, control)
+		if err != nil {
+			return err
+		}
+		defer srv.Stop()
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	for {
+		err := c.Run(ctx)
+		if errors.Is(err, context.Canceled) {
+			return nil
+		}
+
+		// a faulted machine stays up to be inspected and reloaded over the control API
+		var f *Fault
+		if errors.As(err, &f) {
+			c.reportCrash()
+		}
+		if control == nil || f == nil {
+			return err
+		}
+		log.Printf("waiting for a ROM to be loaded over the control API")
+		if !control.waitForReload(ctx) {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/binary"
+	"log"
+	"os"
+)
+
+// The -shm file mirrors the machine at the end of every frame for tools outside the emulator,
+// which map it into memory and read it as it changes: put it on a RAM disk such as /dev/shm
+// and nothing touches the disk. All numbers are little endian:
+//
+//	0    magic    "C8SM"
+//	4    version  uint16, 1
+//	6    variant  uint8, the Variant value
+//	7    flags    uint8, bit 0 set when faulted, bit 1 while the sound timer runs
+//	8    sequence uint32, odd while a frame is being written
+//	12   memory   uint32, the length of memory at offset 512
+//	16   frame    uint64
+//	24   PC, I    uint16 each
+//	28   SP, DT, ST uint8 each, then one byte unused
+//	32   V0-VF    16 bytes
+//	48   stack    16 uint16
+//	80   keys     uint16, bit n set while key n is held
+//	96   display  32 rows of uint64 from the top, the leftmost pixel in the top bit
+//	512  memory
+//
+// A reader takes a consistent copy by reading the sequence, copying what it needs and reading
+// the sequence again, and trying again if the two differ or are odd. The file only grows or
+// shrinks when the variant changes the size of memory.
+
+const (
+	shmMagic   = "C8SM"
+	shmVersion = 1
+
+	shmSequence = 8
+	shmDisplay  = 96
+	shmMemory   = 512
+)
+
+// sharedState is a peripheral writing the machine to a shared file each frame
+type sharedState struct {
+	f   *os.File
+	buf []byte
+	seq uint32
+
+	// set once writing has failed, which is only logged the first time
+	failed bool
+}
+
+// openSharedState creates or takes over the file to mirror the machine to
+func openSharedState(file string) (*sharedState, error) {
+	f, err := os.OpenFile(file, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &sharedState{f: f}, nil
+}
+
+func (s *sharedState) Name() string { return "shared state" }
+
+// Frame writes the machine out, the sequence marked odd until the rest of it is in place
+func (s *sharedState) Frame(c *Chip8) {
+	if s.failed {
+		return
+	}
+	if size := shmMemory + len(c.MainMemory); len(s.buf) != size {
+		s.buf = make([]byte, size)
+		copy(s.buf, shmMagic)
+		binary.LittleEndian.PutUint16(s.buf[4:], shmVersion)
+		s.check(s.f.Truncate(int64(size)))
+	}
+
+	s.seq |= 1
+	s.writeSequence()
+
+	b := s.buf
+	le := binary.LittleEndian
+	b[6] = uint8(c.Variant)
+	b[7] = 0
+	if c.Fault != nil {
+		b[7] |= 1
+	}
+	if c.ST > 0 {
+		b[7] |= 2
+	}
+	le.PutUint32(b[12:], uint32(len(c.MainMemory)))
+	le.PutUint64(b[16:], c.Frame)
+	le.PutUint16(b[24:], c.PC)
+	le.PutUint16(b[26:], c.I)
+	b[28], b[29], b[30] = c.SP, c.DT, c.ST
+	copy(b[32:48], c.Vx[:])
+	for i, v := range c.Stack {
+		le.PutUint16(b[48+2*i:], v)
+	}
+	le.PutUint16(b[80:], keyMask(c.KeyPressed))
+	for y, row := range c.ScreenState {
+		le.PutUint64(b[shmDisplay+8*y:], row)
+	}
+	copy(b[shmMemory:], c.MainMemory)
+
+	_, err := s.f.WriteAt(b[shmSequence+4:], shmSequence+4)
+	s.check(err)
+
+	s.seq++
+	s.writeSequence()
+}
+
+func (s *sharedState) writeSequence() {
+	binary.LittleEndian.PutUint32(s.buf[shmSequence:], s.seq)
+	_, err := s.f.WriteAt(s.buf[:shmSequence+4], 0)
+	s.check(err)
+}
+
+func (s *sharedState) check(err error) {
+	if err != nil && !s.failed {
+		log.Printf("shared state %s: %v", s.f.Name(), err)
+		s.failed = true
+	}
+}
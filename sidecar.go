@@ -0,0 +1,220 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"image/color"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/gopxl/pixel/v2"
+)
+
+// sidecarSettings mirrors the layout of a per-ROM "game.ch8.toml" settings file
+type sidecarSettings struct {
+	// Instructions executed per frame
+	Speed int `toml:"speed"`
+
+	Variant string `toml:"variant"`
+
+	// Bundled font name or font file, see LoadFont
+	Font string `toml:"font"`
+
+	// Decoded separately so only the quirks present in the file override the variant defaults
+	Quirks toml.Primitive `toml:"quirks"`
+
+	Palette struct {
+		On  string `toml:"on"`
+		Off string `toml:"off"`
+	} `toml:"palette"`
+
+	// Physical key name (as reported by pixel, e.g. "Space") to CHIP-8 key
+	Keymap map[string]uint8 `toml:"keymap"`
+
+	// Physical key name to a CHIP-8 key pressed for On frames and released for Off frames, over
+	// and over, while the physical key is held
+	Turbo map[string]struct {
+		Key uint8 `toml:"key"`
+		On  int   `toml:"on"`
+		Off int   `toml:"off"`
+	} `toml:"turbo"`
+
+	// Physical key name to a sequence of keys played when it is pressed, see parseMacro
+	Macros map[string]string `toml:"macros"`
+
+	Input struct {
+		// Minimum frames between releases of the same key, see releaseKey
+		Debounce int `toml:"debounce"`
+
+		// Whether the OS repeating a held key counts as pressing it again
+		KeyRepeat bool `toml:"key_repeat"`
+	} `toml:"input"`
+}
+
+// sidecarPath returns where the settings file for a ROM lives. ROMs inside an archive look for
+// the file next to the archive, named after the inner file.
+func sidecarPath(romFile string) string {
+	if archive, inner, ok := splitZipPath(romFile); ok && inner != "" {
+		return filepath.Join(filepath.Dir(archive), path.Base(inner)+".toml")
+	}
+	return romFile + ".toml"
+}
+
+// readSidecarFile reads a per-ROM file from next to the ROM, or failing that from the roms
+// directory of the user's settings, returning the path it was read from
+func readSidecarFile(name string) (string, []byte, error) {
+	f, err := os.ReadFile(name)
+	if dir, dirErr := configDir(); errors.Is(err, fs.ErrNotExist) && dirErr == nil {
+		name = filepath.Join(dir, "roms", filepath.Base(name))
+		f, err = os.ReadFile(name)
+	}
+	return name, f, err
+}
+
+// LoadSidecar applies the optional settings file stored next to a ROM, or failing that kept in
+// the roms directory of the user's settings for ROMs where files cannot be added. A missing file
+// is not an error.
+func (c *Chip8) LoadSidecar(romFile string) error {
+	sidecarFile, f, err := readSidecarFile(sidecarPath(romFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var settings sidecarSettings
+	md, err := toml.Decode(string(f), &settings)
+	if err != nil {
+		return fmt.Errorf("%s: %w", sidecarFile, err)
+	}
+
+	if settings.Speed > 0 {
+		c.CyclesPerFrame = settings.Speed
+	}
+
+	if settings.Variant != "" {
+		v, err := ParseVariant(settings.Variant)
+		if err != nil {
+			return fmt.Errorf("%s: %w", sidecarFile, err)
+		}
+		c.SetVariant(v)
+	}
+
+	if settings.Font != "" {
+		font, err := LoadFont(settings.Font)
+		if err != nil {
+			return fmt.Errorf("%s: %w", sidecarFile, err)
+		}
+		c.SetFont(font)
+	}
+
+	if settings.Input.Debounce > 0 {
+		c.KeyDebounce = settings.Input.Debounce
+	}
+	if settings.Input.KeyRepeat {
+		c.KeyRepeat = true
+	}
+
+	if md.IsDefined("quirks") {
+		if err := md.PrimitiveDecode(settings.Quirks, &c.Quirks); err != nil {
+			return fmt.Errorf("%s: quirks: %w", sidecarFile, err)
+		}
+	}
+
+	if settings.Palette.On != "" {
+		if c.ColorOn, err = parseHexColor(settings.Palette.On); err != nil {
+			return fmt.Errorf("%s: palette: %w", sidecarFile, err)
+		}
+	}
+
+	if settings.Palette.Off != "" {
+		if c.ColorOff, err = parseHexColor(settings.Palette.Off); err != nil {
+			return fmt.Errorf("%s: palette: %w", sidecarFile, err)
+		}
+	}
+
+	for name, chip8Key := range settings.Keymap {
+		button, ok := parseButton(name)
+		if !ok {
+			return fmt.Errorf("%s: keymap: unknown key %q", sidecarFile, name)
+		}
+		if chip8Key > 0xF {
+			return fmt.Errorf("%s: keymap: %s bound to 0x%X, keys range from 0x0 to 0xF", sidecarFile, name, chip8Key)
+		}
+		c.BindKey(button, chip8Key)
+	}
+
+	for name, t := range settings.Turbo {
+		button, ok := parseButton(name)
+		if !ok {
+			return fmt.Errorf("%s: turbo: unknown key %q", sidecarFile, name)
+		}
+		if t.Key > 0xF {
+			return fmt.Errorf("%s: turbo: %s bound to 0x%X, keys range from 0x0 to 0xF", sidecarFile, name, t.Key)
+		}
+		if t.On < 0 || t.Off < 0 {
+			return fmt.Errorf("%s: turbo: %s needs positive on and off frame counts", sidecarFile, name)
+		}
+		c.bindTurbo(button, t.Key, cmp.Or(t.On, 2), cmp.Or(t.Off, 2))
+	}
+
+	for name, steps := range settings.Macros {
+		button, ok := parseButton(name)
+		if !ok {
+			return fmt.Errorf("%s: macros: unknown key %q", sidecarFile, name)
+		}
+		m, err := parseMacro(steps)
+		if err != nil {
+			return fmt.Errorf("%s: macros: %s: %w", sidecarFile, name, err)
+		}
+		c.bindMacro(button, m)
+	}
+
+	log.Printf("%s: applied settings from %s", romFile, sidecarFile)
+
+	return nil
+}
+
+// resetSidecarSettings puts everything a settings file can change back the way the machine
+// started, so one ROM's settings do not carry over to the next. Variant and quirks are picked
+// again when the next ROM loads.
+func (c *Chip8) resetSidecarSettings() {
+	c.CyclesPerFrame = CyclesToExecute
+	c.ColorOn, c.ColorOff = colorOn, colorOff
+	c.SetKeyMap(defaultKeyMap())
+	c.Turbo, c.Macros = nil, nil
+	c.KeyDebounce = max(*debounce, 0)
+	c.KeyRepeat = *keyRepeat
+
+	// a font from -font stays, as the command line asked for it
+	if *fontName == "" && c.Font != nil {
+		c.variantFont = false
+		c.SetFont(nil)
+	}
+}
+
+// parseHexColor parses "#rrggbb" (the leading # is optional)
+func parseHexColor(s string) (color.RGBA, error) {
+	var r, g, b uint8
+	if _, err := fmt.Sscanf(strings.TrimPrefix(s, "#"), "%02x%02x%02x", &r, &g, &b); err != nil {
+		return color.RGBA{}, fmt.Errorf("invalid color %q, expected #rrggbb", s)
+	}
+	return color.RGBA{r, g, b, 255}, nil
+}
+
+// parseButton looks up a keyboard button by the name pixel gives it, ignoring case
+func parseButton(name string) (pixel.Button, bool) {
+	for b := pixel.KeySpace; b <= pixel.KeyMenu; b++ {
+		if strings.EqualFold(b.String(), name) {
+			return b, true
+		}
+	}
+	return pixel.UnknownButton, false
+}
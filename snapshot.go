@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// LoadSnapshot boots the machine from a raw memory image, such as one written by DumpMemory or
+// another emulator. Registers are restored from regFile, in the key=value format of the dump
+// summary; when regFile is empty the summary next to the image is used if there is one, otherwise
+// execution starts at RamGameStart.
+func (c *Chip8) LoadSnapshot(memFile, regFile string) error {
+	mem, err := os.ReadFile(memFile)
+	if err != nil {
+		return err
+	}
+
+	c.Reset()
+	c.PositionProgramCounter(RamGameStart)
+
+	explicit := regFile != ""
+	if !explicit {
+		regFile = strings.TrimSuffix(memFile, ".bin") + ".txt"
+	}
+
+	start := 0
+	summary, err := os.ReadFile(regFile)
+	switch {
+	case errors.Is(err, fs.ErrNotExist) && !explicit:
+	case err != nil:
+		return err
+	default:
+		if start, err = c.applyStateSummary(string(summary)); err != nil {
+			return fmt.Errorf("%s: %w", regFile, err)
+		}
+	}
+
+	if start+len(mem) > len(c.MainMemory) {
+		// images larger than memory, such as a 64KB XO-CHIP dump loaded as CHIP-8, are cut to fit
+		mem = mem[:len(c.MainMemory)-start]
+	}
+	copy(c.MainMemory[start:], mem)
+	c.invalidateAllDecoded()
+
+	c.Events.Publish(StateLoadedEvent{memFile})
+	return nil
+}
+
+// applyStateSummary restores the registers listed in a dump summary, returning the address the
+// accompanying memory image starts at
+func (c *Chip8) applyStateSummary(summary string) (int, error) {
+	start := 0
+	scanner := bufio.NewScanner(strings.NewReader(summary))
+
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return 0, fmt.Errorf("line %d: expected key=value, found %q", n, line)
+		}
+
+		num := func(bits int) (uint64, error) {
+			v, err := strconv.ParseUint(value, 0, bits)
+			if err != nil {
+				return 0, fmt.Errorf("line %d: bad value for %s: %w", n, key, err)
+			}
+			return v, nil
+		}
+
+		var v uint64
+		var err error
+		switch {
+		case key == "range":
+			from, _, _ := strings.Cut(value, "-")
+			r, perr := strconv.ParseUint(from, 0, 16)
+			if perr != nil {
+				return 0, fmt.Errorf("line %d: bad range %q", n, value)
+			}
+			start = int(r)
+		case key == "variant":
+			variant, verr := ParseVariant(value)
+			if verr != nil {
+				return 0, fmt.Errorf("line %d: %w", n, verr)
+			}
+			c.SetVariant(variant)
+		case key == "PC":
+			v, err = num(16)
+			c.PC = uint16(v)
+		case key == "I":
+			v, err = num(16)
+			c.I = uint16(v)
+		case key == "SP":
+			v, err = num(8)
+			if err == nil && v > uint64(len(c.Stack)) {
+				err = fmt.Errorf("line %d: SP %d exceeds the stack", n, v)
+			}
+			c.SP = uint8(v)
+		case key == "DT":
+			v, err = num(8)
+			c.DT = uint8(v)
+		case key == "ST":
+			v, err = num(8)
+			c.ST = uint8(v)
+		case len(key) == 2 && key[0] == 'V':
+			reg, rerr := strconv.ParseUint(key[1:], 16, 8)
+			if rerr != nil || reg > 0xF {
+				return 0, fmt.Errorf("line %d: unknown register %s", n, key)
+			}
+			v, err = num(8)
+			c.Vx[reg] = uint8(v)
+		case key == "stack":
+			for i, entry := range strings.Fields(value) {
+				if i >= len(c.Stack) {
+					return 0, fmt.Errorf("line %d: more than %d stack entries", n, len(c.Stack))
+				}
+				addr, serr := strconv.ParseUint(entry, 0, 16)
+				if serr != nil {
+					return 0, fmt.Errorf("line %d: bad stack entry %q", n, entry)
+				}
+				c.Stack[i] = uint16(addr)
+			}
+		default:
+			return 0, fmt.Errorf("line %d: unknown key %q", n, key)
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return start, scanner.Err()
+}
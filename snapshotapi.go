@@ -0,0 +1,75 @@
+package main
+
+import (
+	"slices"
+	"sync"
+)
+
+// Concurrency contract: the machine is driven from one goroutine, the emulator loop, and nothing
+// else may touch Chip8 fields directly while it runs. The loop holds the machine lock for the
+// whole of each frame, releasing it only while it sleeps until the next one, so other goroutines
+// (HTTP handlers, UIs, recorders) read the machine through Snapshot, which takes the same lock and
+// therefore always sees the state between two frames, never halfway through one. Code stepping
+// a machine by hand while other goroutines take snapshots holds the lock the same way, with
+// Lock and Unlock.
+
+// Snapshot is a copy of the machine state taken between frames. It shares nothing with the
+// machine and is never modified, so it can be handed to any number of goroutines.
+type Snapshot struct {
+	Frame   uint64
+	Variant Variant
+	Quirks  Quirks
+
+	Memory []byte
+	Vx     [16]uint8
+	I      uint16
+	PC     uint16
+	SP     uint8
+	DT, ST uint8
+	Stack  [16]uint16
+
+	Screen [32][64]uint8
+
+	// Fault the machine stopped on, nil while it runs
+	Fault *Fault
+}
+
+// machineLock is the lock described above, kept out of Chip8's exported API surface
+type machineLock struct {
+	mu sync.Mutex
+}
+
+// Lock takes the machine lock, see the concurrency contract above
+func (c *Chip8) Lock() {
+	c.lock.mu.Lock()
+}
+
+func (c *Chip8) Unlock() {
+	c.lock.mu.Unlock()
+}
+
+// Snapshot safely copies the machine state from any goroutine
+func (c *Chip8) Snapshot() *Snapshot {
+	c.Lock()
+	defer c.Unlock()
+	return c.snapshot()
+}
+
+// snapshot copies the state for code already holding the lock
+func (c *Chip8) snapshot() *Snapshot {
+	return &Snapshot{
+		Frame:   c.Frame,
+		Variant: c.Variant,
+		Quirks:  c.Quirks,
+		Memory:  slices.Clone(c.MainMemory),
+		Vx:      c.Vx,
+		I:       c.I,
+		PC:      c.PC,
+		SP:      c.SP,
+		DT:      c.DT,
+		ST:      c.ST,
+		Stack:   c.Stack,
+		Screen:  c.ScreenState.unpack(),
+		Fault:   c.Fault,
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"os"
+	"sort"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+const (
+	sheetCols  = 8
+	sheetPad   = 6
+	sheetLabel = 13 // height of a line of basicfont.Face7x13
+)
+
+// sheetSprite is a run of ROM bytes drawn as an 8 pixel wide sprite, or guessed to be one from
+// the analyzer when nothing was seen drawing it
+type sheetSprite struct {
+	addr    uint16
+	height  int
+	guessed bool
+}
+
+// drawnSprites runs the ROM headless for frames frames, without input, and notes the address
+// and height of every sprite DXYN draws from the ROM, keeping the tallest height seen for each
+func drawnSprites(img *romImage, frames uint64) map[uint16]int {
+	c := newMachine()
+	c.LoadDefaultSprites()
+	c.configureForImage(img)
+	c.LoadRom(img.Data)
+	c.SetSeed(1)
+
+	drawn := map[uint16]int{}
+	end := RamGameStart + uint16(len(img.Data))
+	c.Events.Subscribe(EventInstruction, func(e Event) {
+		ie := e.(InstructionEvent)
+		if n := int(ie.Opcode & 0xF); ie.Opcode&0xF000 == 0xD000 && n > 0 && c.I >= RamGameStart && c.I < end {
+			drawn[c.I] = max(drawn[c.I], n)
+		}
+	})
+	for c.Frame < frames && c.Fault == nil {
+		c.StepFrame()
+	}
+	return drawn
+}
+
+// guessedSprites are the addresses the analyzer finds loaded into I that are not code, each
+// taken to run up to the next such address or instruction, as far as the 15 rows DXYN can draw
+func guessedSprites(d *disassembly) []sheetSprite {
+	end := RamGameStart + uint16(len(d.rom))
+	var guesses []sheetSprite
+	for addr := range d.dataRefs {
+		if d.code[addr] || addr < RamGameStart || addr >= end {
+			continue
+		}
+		n := 1
+		for next := addr + 1; n < 15 && next < end && !d.code[next] && !d.dataRefs[next]; next++ {
+			n++
+		}
+		guesses = append(guesses, sheetSprite{addr: addr, height: n, guessed: true})
+	}
+	return guesses
+}
+
+// spriteSheet lays the sprites out sheetCols to a row, each scaled up and labelled with its
+// address and symbol; guessed ones have their address marked with a ?
+func spriteSheet(rom []byte, sprites []sheetSprite, syms *SymbolTable, scale int) *image.RGBA {
+	cellW := max(8*scale, 10*basicfont.Face7x13.Advance) + sheetPad
+	cellH := 15*scale + 2*sheetLabel + sheetPad
+	rows := (len(sprites) + sheetCols - 1) / sheetCols
+	sheet := image.NewRGBA(image.Rect(0, 0, sheetCols*cellW+sheetPad, max(rows, 1)*cellH+sheetPad))
+	draw.Draw(sheet, sheet.Bounds(), image.NewUniform(colorOff), image.Point{}, draw.Src)
+
+	label := &font.Drawer{Dst: sheet, Src: image.NewUniform(color.Black), Face: basicfont.Face7x13}
+	for i, s := range sprites {
+		x0 := sheetPad + i%sheetCols*cellW
+		y0 := sheetPad + i/sheetCols*cellH
+
+		draw.Draw(sheet, image.Rect(x0, y0, x0+8*scale, y0+s.height*scale), image.NewUniform(colorGutter), image.Point{}, draw.Src)
+		for row := 0; row < s.height; row++ {
+			b := byte(0)
+			if at := int(s.addr-RamGameStart) + row; at < len(rom) {
+				b = rom[at]
+			}
+			for bit := 0; bit < 8; bit++ {
+				if b&(0x80>>bit) != 0 {
+					px := image.Rect(x0+bit*scale, y0+row*scale, x0+(bit+1)*scale, y0+(row+1)*scale)
+					draw.Draw(sheet, px, image.NewUniform(colorOn), image.Point{}, draw.Src)
+				}
+			}
+		}
+
+		text := fmt.Sprintf("%03X 8x%d", s.addr, s.height)
+		if s.guessed {
+			text += "?"
+		}
+		label.Dot = fixed.P(x0, y0+15*scale+sheetLabel-2)
+		label.DrawString(text)
+		if name, ok := syms.LabelAt(s.addr); ok {
+			if len(name) > 10 {
+				name = name[:9] + "~"
+			}
+			label.Dot = fixed.P(x0, y0+15*scale+2*sheetLabel-2)
+			label.DrawString(name)
+		}
+	}
+	return sheet
+}
+
+// spritesCommand implements "chip8 sprites rom.ch8 sheet.png"
+func spritesCommand(args []string) error {
+	fs := newFlagSet("sprites")
+	frames := fs.Uint64("frames", 600, "frames to run the ROM for, without input, noting what it draws; 0 to only use the analyzer")
+	analyzer := fs.Bool("analyzer", true, "also include data the analyzer finds loaded into I that was not seen drawn, with guessed heights")
+	scale := fs.Int("scale", 4, "pixels per sprite pixel")
+	symbolFile := fs.String("symbols", "", "symbol listing to label sprites with, overriding any from an .8o source")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 || *scale < 1 {
+		return usageError("sprites")
+	}
+
+	img, err := readRomFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	syms := img.Symbols
+	if *symbolFile != "" {
+		if syms, err = LoadSymbols(*symbolFile); err != nil {
+			return err
+		}
+	}
+
+	var sprites []sheetSprite
+	drawn := map[uint16]int{}
+	if *frames > 0 {
+		drawn = drawnSprites(img, *frames)
+	}
+	for addr, n := range drawn {
+		sprites = append(sprites, sheetSprite{addr: addr, height: n})
+	}
+	if *analyzer {
+		for _, s := range guessedSprites(disassemble(img.Data, syms)) {
+			if _, ok := drawn[s.addr]; !ok {
+				sprites = append(sprites, s)
+			}
+		}
+	}
+	if len(sprites) == 0 {
+		return fmt.Errorf("%s: no sprites found", fs.Arg(0))
+	}
+	sort.Slice(sprites, func(i, j int) bool { return sprites[i].addr < sprites[j].addr })
+
+	f, err := os.Create(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := png.Encode(f, spriteSheet(img.Data, sprites, syms, *scale)); err != nil {
+		return err
+	}
+	guessed := 0
+	for _, s := range sprites {
+		if s.guessed {
+			guessed++
+		}
+	}
+	fmt.Printf("%d sprites, %d drawn and %d guessed, written to %s\n", len(sprites), len(sprites)-guessed, guessed, fs.Arg(1))
+	return f.Close()
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+
+	"github.com/gopxl/pixel/v2"
+	"github.com/gopxl/pixel/v2/backends/opengl"
+)
+
+const (
+	spriteViewerCols  = 16
+	spriteViewerRows  = 8
+	spriteViewerScale = 4
+
+	// each cell holds the tallest sprite plus a one pixel gutter
+	spriteViewerCellW = 8 + 1
+	spriteViewerCellH = 16 + 1
+)
+
+var colorGutter = color.RGBA{0x40, 0x40, 0x40, 255}
+
+// spriteViewer is a debug window rendering a range of memory as a grid of 8-pixel-wide sprites
+type spriteViewer struct {
+	win *opengl.Window
+
+	// first byte shown and the number of bytes making up each sprite
+	Addr   uint16
+	Height uint16
+}
+
+func newSpriteViewer(addr uint16) (*spriteViewer, error) {
+	win, err := opengl.NewWindow(opengl.WindowConfig{
+		Title: "Sprite Viewer",
+		Bounds: pixel.R(0, 0,
+			spriteViewerCols*spriteViewerCellW*spriteViewerScale,
+			spriteViewerRows*spriteViewerCellH*spriteViewerScale),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &spriteViewer{win: win, Addr: addr, Height: 8}, nil
+}
+
+// ToggleSpriteViewer opens the sprite viewer at I, or closes it if it is already open
+func (c *Chip8) ToggleSpriteViewer() {
+	if c.spriteViewer != nil {
+		c.spriteViewer.win.Destroy()
+		c.spriteViewer = nil
+		return
+	}
+
+	v, err := newSpriteViewer(c.I)
+	if err != nil {
+		panic(err)
+	}
+	c.spriteViewer = v
+}
+
+// updateSpriteViewer handles the viewer's own keyboard controls and redraws it:
+// left/right move by a byte, up/down by a row, page up/down by a screenful, +/- change the
+// sprite height and Home jumps to I
+func (c *Chip8) updateSpriteViewer() {
+	v := c.spriteViewer
+	if v.win.Closed() {
+		v.win.Destroy()
+		c.spriteViewer = nil
+		return
+	}
+
+	memSize := len(c.MainMemory)
+	row := v.Height * spriteViewerCols
+	page := row * spriteViewerRows
+
+	move := func(delta int) {
+		v.Addr = uint16((int(v.Addr) + delta + memSize) % memSize)
+	}
+
+	switch {
+	case v.win.JustPressed(pixel.KeyLeft) || v.win.Repeated(pixel.KeyLeft):
+		move(-1)
+	case v.win.JustPressed(pixel.KeyRight) || v.win.Repeated(pixel.KeyRight):
+		move(1)
+	case v.win.JustPressed(pixel.KeyUp) || v.win.Repeated(pixel.KeyUp):
+		move(-int(row))
+	case v.win.JustPressed(pixel.KeyDown) || v.win.Repeated(pixel.KeyDown):
+		move(int(row))
+	case v.win.JustPressed(pixel.KeyPageUp):
+		move(-int(page))
+	case v.win.JustPressed(pixel.KeyPageDown):
+		move(int(page))
+	case v.win.JustPressed(pixel.KeyEqual) || v.win.JustPressed(pixel.KeyKPAdd):
+		if v.Height < 16 {
+			v.Height++
+		}
+	case v.win.JustPressed(pixel.KeyMinus) || v.win.JustPressed(pixel.KeyKPSubtract):
+		if v.Height > 1 {
+			v.Height--
+		}
+	case v.win.JustPressed(pixel.KeyHome):
+		v.Addr = uint16(int(c.I) % memSize)
+	}
+
+	last := (int(v.Addr) + int(v.Height)*spriteViewerCols*spriteViewerRows - 1) % memSize
+	v.win.SetTitle(fmt.Sprintf("Sprite Viewer - 0x%03X-0x%03X, %d bytes per sprite (I=0x%03X)", v.Addr, last, v.Height, c.I))
+
+	w, h := spriteViewerCols*spriteViewerCellW, spriteViewerRows*spriteViewerCellH
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, colorGutter)
+		}
+	}
+
+	for cell := 0; cell < spriteViewerCols*spriteViewerRows; cell++ {
+		originX := (cell % spriteViewerCols) * spriteViewerCellW
+		originY := (cell / spriteViewerCols) * spriteViewerCellH
+		base := int(v.Addr) + cell*int(v.Height)
+
+		for j := 0; j < int(v.Height); j++ {
+			b := c.MainMemory[(base+j)%memSize]
+			for i := 0; i < 8; i++ {
+				col := c.ColorOff
+				if b&(0x80>>i) != 0 {
+					col = c.ColorOn
+				}
+				img.Set(originX+i, originY+j, col)
+			}
+		}
+	}
+
+	pic := pixel.PictureDataFromImage(img)
+	sprite := pixel.NewSprite(pic, pic.Bounds())
+
+	v.win.Clear(colorGutter)
+	sprite.Draw(v.win, pixel.IM.Scaled(pixel.ZV, spriteViewerScale).Moved(v.win.Bounds().Center()))
+	v.win.Update()
+}
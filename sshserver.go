@@ -0,0 +1,432 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"golang.org/x/crypto/ssh"
+)
+
+// "chip8 ssh roms/" lets anyone with an SSH client play: "ssh -p 2222 play@host" opens a menu
+// of the ROMs served and plays the one picked in the terminal, the display drawn in half
+// blocks. Each session gets its own machine. Terminals send keys as they are typed rather
+// than as they go down and up, so every key press holds its keypad key for -hold frames and
+// holding a key down relies on the terminal repeating it.
+
+// sshFrameMsg runs a frame of the session's game; gen tells frames scheduled for a game since
+// left from the current one's
+type sshFrameMsg struct{ gen int }
+
+type sshModel struct {
+	roms     []string
+	selected int
+
+	// the game being played, nil in the menu
+	c      *Chip8
+	gen    int
+	paused bool
+	held   [16]int
+	hold   int
+
+	// why the last ROM picked did not start, shown in the menu
+	err error
+
+	width, height int
+}
+
+func (m *sshModel) Init() tea.Cmd {
+	if len(m.roms) == 1 {
+		return m.start()
+	}
+	return nil
+}
+
+func (m *sshModel) frame() tea.Cmd {
+	gen := m.gen
+	return tea.Tick(FrameDuration, func(time.Time) tea.Msg { return sshFrameMsg{gen} })
+}
+
+// start loads the selected ROM into a new machine and begins running it
+func (m *sshModel) start() tea.Cmd {
+	rom := m.roms[m.selected]
+	c := newMachine()
+	c.LoadDefaultSprites()
+	err := c.loadRomFile(rom)
+	if err == nil {
+		err = c.LoadSidecar(rom)
+	}
+	if err != nil {
+		m.err = err
+		return nil
+	}
+
+	m.c, m.err, m.paused, m.held = c, nil, false, [16]int{}
+	m.gen++
+	return m.frame()
+}
+
+func (m *sshModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+
+	case sshFrameMsg:
+		if msg.gen != m.gen || m.c == nil || m.paused || m.c.Fault != nil {
+			return m, nil
+		}
+		m.step()
+		return m, m.frame()
+
+	case tea.KeyMsg:
+		if msg.String() == "ctrl+c" {
+			return m, tea.Quit
+		}
+		if m.c == nil {
+			return m, m.menuKey(msg.String())
+		}
+		if key, ok := teachKeys[msg.String()]; ok {
+			m.held[key] = m.hold
+			return m, nil
+		}
+		switch msg.String() {
+		case "esc":
+			m.c = nil
+			m.gen++
+		case "p":
+			m.paused = !m.paused
+			if !m.paused {
+				return m, m.frame()
+			}
+		}
+	}
+	return m, nil
+}
+
+func (m *sshModel) menuKey(key string) tea.Cmd {
+	switch key {
+	case "up", "k":
+		m.selected = (m.selected + len(m.roms) - 1) % len(m.roms)
+	case "down", "j":
+		m.selected = (m.selected + 1) % len(m.roms)
+	case "enter", " ":
+		return m.start()
+	case "q", "esc":
+		return tea.Quit
+	}
+	return nil
+}
+
+// step runs a frame with the keys still held from the terminal, a key's release reported the
+// frame after its hold runs out
+func (m *sshModel) step() {
+	c := m.c
+	c.KeyPressed, c.KeyJustReleased = [16]bool{}, [16]bool{}
+	for key, n := range m.held {
+		switch {
+		case n > 1:
+			c.KeyPressed[key] = true
+		case n == 1:
+			c.KeyJustReleased[key] = true
+		}
+		m.held[key] = max(n-1, 0)
+	}
+	c.StepFrame()
+}
+
+func (m *sshModel) View() string {
+	if m.c == nil {
+		return m.menuView()
+	}
+	c := m.c
+
+	status := filepath.Base(c.RomFile)
+	switch {
+	case c.Fault != nil:
+		status += "  " + c.Fault.Error()
+	case m.paused:
+		status += "  paused"
+	case c.ST > 0:
+		status += "  ♪"
+	}
+	view := status + "\n" + tuiPane.Render(teachDisplay(&c.ScreenState)) + "\n" +
+		"1-4 q-r a-f z-v keypad  p pause  esc menu  ctrl+c quit"
+	if m.width > 0 && (m.width < ScreenWidth+4 || m.height < ScreenHeight/2+4) {
+		view = fmt.Sprintf("make the terminal at least %dx%d to see the whole display\n", ScreenWidth+4, ScreenHeight/2+4) + view
+	}
+	return view
+}
+
+func (m *sshModel) menuView() string {
+	var b strings.Builder
+	b.WriteString("CHIP-8: pick a ROM\n\n")
+
+	// keep the selection on screen when there are more ROMs than lines
+	rows := len(m.roms)
+	if m.height > 0 {
+		rows = max(m.height-6, 1)
+	}
+	first := min(max(m.selected-rows/2, 0), max(len(m.roms)-rows, 0))
+	for i := first; i < len(m.roms) && i < first+rows; i++ {
+		cursor := "  "
+		if i == m.selected {
+			cursor = "> "
+		}
+		b.WriteString(cursor + filepath.Base(m.roms[i]) + "\n")
+	}
+
+	if m.err != nil {
+		fmt.Fprintf(&b, "\n%v\n", m.err)
+	}
+	b.WriteString("\nup/down choose  enter play  q quit")
+	return b.String()
+}
+
+// sshHandshakeTimeout is how long a client has to finish connecting and logging in
+const sshHandshakeTimeout = 30 * time.Second
+
+// sshServer accepts connections and runs a game session on each terminal opened over them
+type sshServer struct {
+	config *ssh.ServerConfig
+	roms   []string
+	hold   int
+
+	// a slot for each session that may run at once, each running one holding a slot
+	sessions chan struct{}
+}
+
+func (s *sshServer) serveConn(ctx context.Context, conn net.Conn) {
+	conn.SetDeadline(time.Now().Add(sshHandshakeTimeout))
+	sconn, chans, reqs, err := ssh.NewServerConn(conn, s.config)
+	if err != nil {
+		log.Printf("ssh: %s: %v", conn.RemoteAddr(), err)
+		conn.Close()
+		return
+	}
+	defer sconn.Close()
+	conn.SetDeadline(time.Time{})
+	log.Printf("ssh: %s connected as %s", sconn.RemoteAddr(), sconn.User())
+	go ssh.DiscardRequests(reqs)
+
+	for nc := range chans {
+		if nc.ChannelType() != "session" {
+			nc.Reject(ssh.UnknownChannelType, "only sessions are served")
+			continue
+		}
+		select {
+		case s.sessions <- struct{}{}:
+		default:
+			nc.Reject(ssh.ResourceShortage, "the server is full, try again later")
+			continue
+		}
+		ch, requests, err := nc.Accept()
+		if err != nil {
+			<-s.sessions
+			log.Printf("ssh: %s: %v", sconn.RemoteAddr(), err)
+			continue
+		}
+		go func() {
+			defer func() { <-s.sessions }()
+			s.serveSession(ctx, ch, requests)
+		}()
+	}
+	log.Printf("ssh: %s disconnected", sconn.RemoteAddr())
+}
+
+// serveSession waits for the client to ask for a terminal and a shell, then plays in it until
+// the player quits or the channel closes
+func (s *sshServer) serveSession(ctx context.Context, ch ssh.Channel, requests <-chan *ssh.Request) {
+	defer ch.Close()
+
+	var pty struct {
+		Term          string
+		Columns, Rows uint32
+		Width, Height uint32
+		Modes         string
+	}
+	var program *tea.Program
+	done := make(chan struct{})
+
+	for {
+		select {
+		case <-done:
+			ch.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{0}))
+			return
+		case req, ok := <-requests:
+			if !ok {
+				if program != nil {
+					program.Quit()
+					<-done
+				}
+				return
+			}
+			switch req.Type {
+			case "pty-req":
+				err := ssh.Unmarshal(req.Payload, &pty)
+				req.Reply(err == nil, nil)
+			case "window-change":
+				var size struct{ Columns, Rows, Width, Height uint32 }
+				if ssh.Unmarshal(req.Payload, &size) == nil && program != nil {
+					go program.Send(tea.WindowSizeMsg{Width: int(size.Columns), Height: int(size.Rows)})
+				}
+			case "shell":
+				if program != nil {
+					req.Reply(false, nil)
+					continue
+				}
+				req.Reply(true, nil)
+				if pty.Term == "" {
+					fmt.Fprint(ch.Stderr(), "a terminal is needed to play, connect with ssh -t\r\n")
+					ch.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{1}))
+					return
+				}
+
+				m := &sshModel{roms: s.roms, hold: s.hold, width: int(pty.Columns), height: int(pty.Rows)}
+				program = tea.NewProgram(m, tea.WithInput(ch), tea.WithOutput(ch), tea.WithAltScreen(),
+					tea.WithContext(ctx), tea.WithoutSignalHandler(), tea.WithEnvironment([]string{"TERM=" + pty.Term}))
+				go func() {
+					if _, err := program.Run(); err != nil && !errors.Is(err, tea.ErrProgramKilled) {
+						log.Printf("ssh: session: %v", err)
+					}
+					close(done)
+				}()
+			default:
+				if req.WantReply {
+					req.Reply(false, nil)
+				}
+			}
+		}
+	}
+}
+
+// loadHostKey reads the server's private key, creating one in the data directory when file is
+// empty and there is none yet, so clients see the same server each time it starts
+func loadHostKey(file string) (ssh.Signer, error) {
+	if file == "" {
+		dir, err := userDir("ssh")
+		if err != nil {
+			return nil, err
+		}
+		file = filepath.Join(dir, "host_ed25519_key")
+		if _, err := os.Stat(file); errors.Is(err, fs.ErrNotExist) {
+			_, key, err := ed25519.GenerateKey(rand.Reader)
+			if err != nil {
+				return nil, err
+			}
+			block, err := ssh.MarshalPrivateKey(key, "chip8 ssh")
+			if err != nil {
+				return nil, err
+			}
+			if err := os.WriteFile(file, pem.EncodeToMemory(block), 0o600); err != nil {
+				return nil, err
+			}
+			log.Printf("ssh: created host key %s", file)
+		}
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return ssh.ParsePrivateKey(data)
+}
+
+// sshRoms lists the ROMs given on the command line, each directory standing for the ROMs in it
+func sshRoms(args []string) ([]string, error) {
+	var roms []string
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			roms = append(roms, arg)
+			continue
+		}
+		files, err := listRoms(arg)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range files {
+			roms = append(roms, filepath.Join(arg, f))
+		}
+	}
+	if len(roms) == 0 {
+		return nil, fmt.Errorf("no ROMs in %s", strings.Join(args, ", "))
+	}
+	return roms, nil
+}
+
+// sshCommand implements "chip8 ssh roms/", serving the ROMs to SSH clients until interrupted
+func sshCommand(args []string) error {
+	fs := newFlagSet("ssh")
+	addr := fs.String("addr", "127.0.0.1:2222", "address to accept SSH connections on, :2222 to serve the whole network")
+	user := fs.String("user", "play", "user name clients log in as, without a password; empty lets any name in")
+	hostKey := fs.String("host-key", "", "private key the server identifies itself with, by default one created under the data directory")
+	hold := fs.Int("hold", 8, "frames each key typed holds its keypad key down for, terminals not reporting releases")
+	maxSessions := fs.Int("max-sessions", 16, "games that may be played at once, each running its own machine")
+	fs.StringVar(dataDirFlag, "data-dir", "", "keep the host key under this directory instead of the user's data directory")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 || *hold < 2 || *maxSessions < 1 {
+		return usageError("ssh")
+	}
+
+	roms, err := sshRoms(fs.Args())
+	if err != nil {
+		return err
+	}
+	signer, err := loadHostKey(*hostKey)
+	if err != nil {
+		return fmt.Errorf("host key: %w", err)
+	}
+
+	config := &ssh.ServerConfig{
+		NoClientAuth: true,
+		NoClientAuthCallback: func(meta ssh.ConnMetadata) (*ssh.Permissions, error) {
+			if *user != "" && meta.User() != *user {
+				return nil, fmt.Errorf("log in as %s", *user)
+			}
+			return nil, nil
+		},
+	}
+	config.AddHostKey(signer)
+	s := &sshServer{config: config, roms: roms, hold: *hold, sessions: make(chan struct{}, *maxSessions)}
+
+	ln, err := net.Listen("tcp", *addr)
+	if err != nil {
+		return err
+	}
+	log.Printf("ssh: serving %d ROMs on %s", len(roms), ln.Addr())
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		go s.serveConn(ctx, conn)
+	}
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Save state files start with a fixed header so they can be recognised and migrated:
+//
+//	magic    "C8ST"
+//	version  uint16, big endian
+//	variant  uint8, the Variant value
+//	quirks   uint8, one bit per quirk in stateQuirkBits order
+//	payload  gzip compressed, layout depends on version
+//
+// Version 2 adds an input recording to the end of the payload, made from the moment the state was
+// saved, so a file can reproduce what happened after it as well as resume from it. Version 3 widens
+// the memory length to a uint32, to hold XO-CHIP's 64KB. Variant numbers and quirk bits are part
+// of the format, new ones must only ever be appended.
+const (
+	stateMagic   = "C8ST"
+	stateVersion = 3
+)
+
+// stateQuirkBits lists the quirks in bit order, bit 0 first
+func stateQuirkBits(q *Quirks) []*bool {
+	return []*bool{&q.VFReset, &q.LoadStoreIncrementsI, &q.ShiftUsesVy, &q.JumpUsesVx, &q.SpritesWrap, &q.IOverflowSetsVF}
+}
+
+// stateHeader is the uncompressed start of a save state file
+type stateHeader struct {
+	Magic   [4]byte
+	Version uint16
+	Variant uint8
+	Quirks  uint8
+}
+
+// stateRegistersV1 is the fixed size register block of a version 1 payload. It is followed by a
+// memory length, a uint16 before version 3 and a uint32 since, and the memory itself, so the
+// payload does not depend on the size of MainMemory.
+type stateRegistersV1 struct {
+	RomHash [20]byte
+	Saved   int64
+	Frame   uint64
+	Vx      [16]uint8
+	I       uint16
+	DT, ST  uint8
+	PC      uint16
+	SP      uint8
+	Stack   [16]uint16
+	Screen  [32][64]uint8
+}
+
+// stateLoaders decode the payload of each supported version; version 0 is the JSON autosave
+// format that predates the container and has no header
+var stateLoaders = map[uint16]func(h stateHeader, r io.Reader) (*saveState, error){
+	0: loadStateV0,
+	1: loadStateV1,
+	2: loadStateV2,
+	3: loadStateV2,
+}
+
+// WriteStateFile writes the current machine state to a save state file, as JSON when its name
+// ends in .json. Input is recorded from then on for WriteReplayStateFile.
+func (c *Chip8) WriteStateFile(file string) error {
+	s := c.captureState()
+	if err := writeStateToFile(file, s); err != nil {
+		return err
+	}
+	c.startBundle(s)
+	return nil
+}
+
+// ReadStateFile restores the machine from a save state file of any supported version. Input is
+// recorded from then on for WriteReplayStateFile.
+func (c *Chip8) ReadStateFile(file string) error {
+	s, err := readStateFile(file)
+	if err != nil {
+		return err
+	}
+	if err := c.restoreState(s, file); err != nil {
+		return err
+	}
+	s.Input = nil
+	c.startBundle(s)
+	return nil
+}
+
+func writeStateToFile(file string, s *saveState) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+
+	write := writeState
+	if strings.EqualFold(filepath.Ext(file), ".json") {
+		write = writeStateJSON
+	}
+	if err := write(f, s); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func readStateFile(file string) (*saveState, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	s, err := readState(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	return s, nil
+}
+
+// writeState encodes a save state in the current version of the format
+func writeState(w io.Writer, s *saveState) error {
+	v, err := ParseVariant(s.Variant)
+	if err != nil {
+		return err
+	}
+
+	h := stateHeader{Version: stateVersion, Variant: uint8(v)}
+	copy(h.Magic[:], stateMagic)
+	for i, q := range stateQuirkBits(&s.Quirks) {
+		if *q {
+			h.Quirks |= 1 << i
+		}
+	}
+
+	regs := stateRegistersV1{
+		Saved:  s.Saved.Unix(),
+		Frame:  s.Frame,
+		Vx:     s.Vx,
+		I:      s.I,
+		DT:     s.DT,
+		ST:     s.ST,
+		PC:     s.PC,
+		SP:     s.SP,
+		Stack:  s.Stack,
+		Screen: s.ScreenState,
+	}
+	if s.RomHash != "" {
+		if _, err := hex.Decode(regs.RomHash[:], []byte(s.RomHash)); err != nil {
+			return fmt.Errorf("bad ROM hash %q: %w", s.RomHash, err)
+		}
+	}
+
+	if err := binary.Write(w, binary.BigEndian, h); err != nil {
+		return err
+	}
+
+	zw := gzip.NewWriter(w)
+	if err := binary.Write(zw, binary.BigEndian, regs); err != nil {
+		return err
+	}
+	if err := binary.Write(zw, binary.BigEndian, uint32(len(s.Memory))); err != nil {
+		return err
+	}
+	if _, err := zw.Write(s.Memory); err != nil {
+		return err
+	}
+	if err := binary.Write(zw, binary.BigEndian, uint32(len(s.Input))); err != nil {
+		return err
+	}
+	if _, err := zw.Write(s.Input); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// readState decodes a save state, dispatching on the version in its header
+func readState(r io.Reader) (*saveState, error) {
+	br := bufio.NewReader(r)
+
+	magic, err := br.Peek(len(stateMagic))
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	var h stateHeader
+	if bytes.Equal(magic, []byte(stateMagic)) {
+		if err := binary.Read(br, binary.BigEndian, &h); err != nil {
+			return nil, fmt.Errorf("reading header: %w", err)
+		}
+	}
+
+	load, ok := stateLoaders[h.Version]
+	if !ok {
+		return nil, fmt.Errorf("save state version %d is newer than this emulator supports", h.Version)
+	}
+	return load(h, br)
+}
+
+// loadStateV0 reads headerless JSON, either the documented format in statejson.go or the old
+// autosave layout of saveState's own fields
+func loadStateV0(h stateHeader, r io.Reader) (*saveState, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if isStateJSON(data) {
+		return readStateJSON(data)
+	}
+
+	var s saveState
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("not a save state: %w", err)
+	}
+	return &s, nil
+}
+
+func loadStateV1(h stateHeader, r io.Reader) (*saveState, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	return readPayloadV1(h, zr)
+}
+
+func loadStateV2(h stateHeader, r io.Reader) (*saveState, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	s, err := readPayloadV1(h, zr)
+	if err != nil {
+		return nil, err
+	}
+
+	var size uint32
+	if err := binary.Read(zr, binary.BigEndian, &size); err != nil {
+		return nil, fmt.Errorf("reading input: %w", err)
+	}
+	if size > 0 {
+		// read rather than preallocate, so a corrupt size cannot claim gigabytes up front
+		if s.Input, err = io.ReadAll(io.LimitReader(zr, int64(size))); err != nil {
+			return nil, fmt.Errorf("reading input: %w", err)
+		}
+		if len(s.Input) != int(size) {
+			return nil, fmt.Errorf("reading input: %w", io.ErrUnexpectedEOF)
+		}
+	}
+	return s, nil
+}
+
+// readPayloadV1 reads the registers and memory that start every payload since version 1
+func readPayloadV1(h stateHeader, zr io.Reader) (*saveState, error) {
+	var regs stateRegistersV1
+	err := binary.Read(zr, binary.BigEndian, &regs)
+	if err != nil {
+		return nil, fmt.Errorf("reading registers: %w", err)
+	}
+
+	var size uint32
+	if h.Version < 3 {
+		var short uint16
+		err = binary.Read(zr, binary.BigEndian, &short)
+		size = uint32(short)
+	} else {
+		err = binary.Read(zr, binary.BigEndian, &size)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading memory: %w", err)
+	}
+	if size > 0x10000 {
+		return nil, fmt.Errorf("reading memory: %d bytes is more than any variant has", size)
+	}
+	mem := make([]byte, size)
+	if _, err := io.ReadFull(zr, mem); err != nil {
+		return nil, fmt.Errorf("reading memory: %w", err)
+	}
+
+	s := &saveState{
+		Saved:       time.Unix(regs.Saved, 0),
+		Variant:     Variant(h.Variant).String(),
+		Memory:      mem,
+		Vx:          regs.Vx,
+		I:           regs.I,
+		DT:          regs.DT,
+		ST:          regs.ST,
+		PC:          regs.PC,
+		SP:          regs.SP,
+		Stack:       regs.Stack,
+		ScreenState: regs.Screen,
+		Frame:       regs.Frame,
+	}
+	if regs.RomHash != [20]byte{} {
+		s.RomHash = hex.EncodeToString(regs.RomHash[:])
+	}
+	for i, q := range stateQuirkBits(&s.Quirks) {
+		*q = h.Quirks&(1<<i) != 0
+	}
+	return s, nil
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// sampleState is a save state with every field set, memory sized for the variant
+func sampleState(v Variant) *saveState {
+	s := &saveState{
+		RomHash: "0123456789abcdef0123456789abcdef01234567",
+		Saved:   time.Unix(1700000000, 0),
+		Variant: v.String(),
+		Quirks:  Quirks{VFReset: true, ShiftUsesVy: true, SpritesWrap: true},
+		Memory:  make([]byte, v.memorySize()),
+		I:       0x2A4,
+		DT:      12,
+		ST:      3,
+		PC:      0x21C,
+		SP:      2,
+		Frame:   987,
+	}
+	for i := range s.Memory {
+		s.Memory[i] = byte(i * 7)
+	}
+	for i := range s.Vx {
+		s.Vx[i] = byte(i * 17)
+	}
+	s.Stack[0], s.Stack[1] = 0x204, 0x312
+	s.ScreenState[5][9], s.ScreenState[31][63] = 1, 1
+	return s
+}
+
+// writeStateVersion encodes s the way version 1 and 2 files were written: the memory length a
+// uint16, and before version 2 no input recording
+func writeStateVersion(t *testing.T, version uint16, s *saveState) []byte {
+	t.Helper()
+	v, err := ParseVariant(s.Variant)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := stateHeader{Version: version, Variant: uint8(v)}
+	copy(h.Magic[:], stateMagic)
+	for i, q := range stateQuirkBits(&s.Quirks) {
+		if *q {
+			h.Quirks |= 1 << i
+		}
+	}
+	regs := stateRegistersV1{
+		Saved: s.Saved.Unix(), Frame: s.Frame, Vx: s.Vx, I: s.I, DT: s.DT, ST: s.ST,
+		PC: s.PC, SP: s.SP, Stack: s.Stack, Screen: s.ScreenState,
+	}
+	hex.Decode(regs.RomHash[:], []byte(s.RomHash))
+
+	var b bytes.Buffer
+	binary.Write(&b, binary.BigEndian, h)
+	zw := gzip.NewWriter(&b)
+	binary.Write(zw, binary.BigEndian, regs)
+	binary.Write(zw, binary.BigEndian, uint16(len(s.Memory)))
+	zw.Write(s.Memory)
+	if version >= 2 {
+		binary.Write(zw, binary.BigEndian, uint32(len(s.Input)))
+		zw.Write(s.Input)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+// checkState compares a state read back with the one written
+func checkState(t *testing.T, name string, got, want *saveState) {
+	t.Helper()
+	if !got.Saved.Equal(want.Saved) {
+		t.Errorf("%s: saved %v, want %v", name, got.Saved, want.Saved)
+	}
+	g, w := *got, *want
+	g.Saved, w.Saved = time.Time{}, time.Time{}
+	if !reflect.DeepEqual(&g, &w) {
+		t.Errorf("%s: read back\n%+v\nwant\n%+v", name, g, w)
+	}
+}
+
+// TestStateRoundTrip reads back a state written in each version of the format
+func TestStateRoundTrip(t *testing.T) {
+	// version 0, the JSON autosave with no header
+	s := sampleState(VariantChip8)
+	data, err := json.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := readState(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("version 0: %v", err)
+	}
+	checkState(t, "version 0", got, s)
+
+	// version 1, without an input recording
+	s = sampleState(VariantSChip)
+	got, err = readState(bytes.NewReader(writeStateVersion(t, 1, s)))
+	if err != nil {
+		t.Fatalf("version 1: %v", err)
+	}
+	checkState(t, "version 1", got, s)
+
+	// version 2, its memory length a uint16
+	s = sampleState(VariantChip8)
+	s.Input = []byte("# chip8 input recording\nseed 5\n0 0001 0000\n")
+	got, err = readState(bytes.NewReader(writeStateVersion(t, 2, s)))
+	if err != nil {
+		t.Fatalf("version 2: %v", err)
+	}
+	checkState(t, "version 2", got, s)
+
+	// version 3, the current one, holding XO-CHIP's 64KB
+	s = sampleState(VariantXOChip)
+	s.Input = []byte("# chip8 input recording\nseed 5\n")
+	var b bytes.Buffer
+	if err := writeState(&b, s); err != nil {
+		t.Fatal(err)
+	}
+	got, err = readState(&b)
+	if err != nil {
+		t.Fatalf("version 3: %v", err)
+	}
+	if len(got.Memory) != 0x10000 {
+		t.Errorf("version 3: %d bytes of memory, want 65536", len(got.Memory))
+	}
+	checkState(t, "version 3", got, s)
+}
+
+// TestStateNewerVersion refuses a file from a later version of the format
+func TestStateNewerVersion(t *testing.T) {
+	h := stateHeader{Version: stateVersion + 1}
+	copy(h.Magic[:], stateMagic)
+	var b bytes.Buffer
+	binary.Write(&b, binary.BigEndian, h)
+	if _, err := readState(&b); err == nil {
+		t.Error("read a state from a newer version")
+	}
+}
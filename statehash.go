@@ -0,0 +1,39 @@
+package main
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+)
+
+// StateHash hashes the registers, memory and framebuffer with FNV-1a, chained onto prev so a
+// single value covers every sampled frame so far. Two runs with the same seed and inputs produce
+// the same sequence of hashes.
+func (c *Chip8) StateHash(prev uint64) uint64 {
+	h := fnv.New64a()
+
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], prev)
+	h.Write(buf[:])
+
+	h.Write(c.Vx[:])
+	binary.BigEndian.PutUint16(buf[:], c.I)
+	binary.BigEndian.PutUint16(buf[2:], c.PC)
+	buf[4], buf[5], buf[6] = c.SP, c.DT, c.ST
+	h.Write(buf[:7])
+	for _, addr := range c.Stack {
+		binary.BigEndian.PutUint16(buf[:], addr)
+		h.Write(buf[:2])
+	}
+
+	h.Write(c.MainMemory[:])
+	c.ScreenState.writePixels(h)
+
+	return h.Sum64()
+}
+
+// ScreenHash hashes just the display, for comparing a finished test ROM's output
+func (c *Chip8) ScreenHash() uint64 {
+	h := fnv.New64a()
+	c.ScreenState.writePixels(h)
+	return h.Sum64()
+}
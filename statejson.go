@@ -0,0 +1,253 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Save states can also be written as JSON, for reading, diffing and editing by hand in bug
+// reports and tests. Any save state file ending in .json is written this way, and reading a save
+// state accepts it whatever the name. An example, shortened:
+//
+//	{
+//	  "format": "chip8-state",
+//	  "version": 1,
+//	  "rom_sha1": "6e0ea4c586313fe7606ebadb5a53d247d60f0c35",
+//	  "saved": "2026-10-14T18:04:41Z",
+//	  "variant": "chip8",
+//	  "quirks": {"vf_reset": true, "load_store_increments_i": true, ...},
+//	  "frame": 120,
+//	  "pc": "0x204", "i": "0x050", "sp": 0, "dt": 0, "st": 0,
+//	  "v": ["0x03", "0x00", ...],
+//	  "stack": [],
+//	  "memory_size": 4095,
+//	  "memory": {"0x000": "F0 90 90 90 F0 20 60 20 20 70 F0 10 F0 80 F0 F0", ...},
+//	  "screen": ["....####....", ...],
+//	  "input": "# chip8 input recording\n..."
+//	}
+//
+// Addresses and registers are hex strings, though plain numbers are accepted too. Memory is
+// listed 16 bytes to a row keyed by the row's address, leaving out rows that are all zero. The
+// screen is one string per row, # for a lit pixel and . for a dark one. Input, present in states
+// written by the debugger's report command, is the text of an input recording made from the
+// moment the state was saved.
+
+// stateJSONFormat identifies the JSON save state format
+const (
+	stateJSONFormat  = "chip8-state"
+	stateJSONVersion = 1
+)
+
+// stateJSON is the layout of a JSON save state
+type stateJSON struct {
+	Format     string            `json:"format"`
+	Version    int               `json:"version"`
+	RomHash    string            `json:"rom_sha1,omitempty"`
+	Saved      time.Time         `json:"saved"`
+	Variant    string            `json:"variant"`
+	Quirks     Quirks            `json:"quirks"`
+	Frame      uint64            `json:"frame"`
+	PC         hexValue          `json:"pc"`
+	I          hexValue          `json:"i"`
+	SP         uint8             `json:"sp"`
+	DT         uint8             `json:"dt"`
+	ST         uint8             `json:"st"`
+	V          [16]hexValue      `json:"v"`
+	Stack      []hexValue        `json:"stack"`
+	MemorySize int               `json:"memory_size"`
+	Memory     map[string]string `json:"memory"`
+	Screen     []string          `json:"screen"`
+	Input      string            `json:"input,omitempty"`
+}
+
+// hexValue is a register or address, written as a hex string and read from one or a number
+type hexValue uint16
+
+func (h hexValue) MarshalJSON() ([]byte, error) {
+	return json.Marshal(fmt.Sprintf("0x%02X", uint16(h)))
+}
+
+func (h *hexValue) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		s = unquoted
+	}
+	v, err := strconv.ParseUint(s, 0, 16)
+	if err != nil {
+		return fmt.Errorf("bad value %s, expected a number such as 0x200", data)
+	}
+	*h = hexValue(v)
+	return nil
+}
+
+// isStateJSON reports whether data is a JSON save state rather than an old JSON autosave
+func isStateJSON(data []byte) bool {
+	var probe struct {
+		Format string `json:"format"`
+	}
+	return json.Unmarshal(data, &probe) == nil && probe.Format == stateJSONFormat
+}
+
+// writeStateJSON encodes a save state as indented JSON
+func writeStateJSON(w io.Writer, s *saveState) error {
+	j := stateJSON{
+		Format:     stateJSONFormat,
+		Version:    stateJSONVersion,
+		RomHash:    s.RomHash,
+		Saved:      s.Saved.UTC().Truncate(time.Second),
+		Variant:    s.Variant,
+		Quirks:     s.Quirks,
+		Frame:      s.Frame,
+		PC:         hexValue(s.PC),
+		I:          hexValue(s.I),
+		SP:         s.SP,
+		DT:         s.DT,
+		ST:         s.ST,
+		Stack:      []hexValue{},
+		MemorySize: len(s.Memory),
+		Memory:     map[string]string{},
+		Input:      string(s.Input),
+	}
+	for i, v := range s.Vx {
+		j.V[i] = hexValue(v)
+	}
+	for _, addr := range s.Stack[:min(int(s.SP), len(s.Stack))] {
+		j.Stack = append(j.Stack, hexValue(addr))
+	}
+
+	for row := 0; row < len(s.Memory); row += 16 {
+		data := s.Memory[row:min(row+16, len(s.Memory))]
+		if !slices.ContainsFunc(data, func(b byte) bool { return b != 0 }) {
+			continue
+		}
+		j.Memory[fmt.Sprintf("0x%03X", row)] = fmt.Sprintf("% X", data)
+	}
+
+	for _, row := range s.ScreenState {
+		var b strings.Builder
+		for _, px := range row {
+			if px != 0 {
+				b.WriteByte('#')
+			} else {
+				b.WriteByte('.')
+			}
+		}
+		j.Screen = append(j.Screen, b.String())
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(j)
+}
+
+// readStateJSON decodes a JSON save state, checking it describes a machine that can exist
+func readStateJSON(data []byte) (*saveState, error) {
+	var j stateJSON
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&j); err != nil {
+		return nil, fmt.Errorf("JSON save state: %w", err)
+	}
+	if j.Version != stateJSONVersion {
+		return nil, fmt.Errorf("JSON save state version %d is not supported", j.Version)
+	}
+	if j.MemorySize <= 0 || j.MemorySize > 0x10000 {
+		return nil, fmt.Errorf("bad memory_size %d", j.MemorySize)
+	}
+	if len(j.Stack) > 16 {
+		return nil, fmt.Errorf("stack holds %d addresses, at most 16 fit", len(j.Stack))
+	}
+
+	s := &saveState{
+		RomHash: j.RomHash,
+		Saved:   j.Saved,
+		Variant: j.Variant,
+		Quirks:  j.Quirks,
+		Frame:   j.Frame,
+		PC:      uint16(j.PC),
+		I:       uint16(j.I),
+		SP:      j.SP,
+		DT:      j.DT,
+		ST:      j.ST,
+		Memory:  make([]byte, j.MemorySize),
+	}
+	if j.Input != "" {
+		s.Input = []byte(j.Input)
+	}
+	for i, v := range j.V {
+		if v > 0xFF {
+			return nil, fmt.Errorf("V%X = %#x does not fit in a byte", i, v)
+		}
+		s.Vx[i] = uint8(v)
+	}
+	for i, addr := range j.Stack {
+		s.Stack[i] = uint16(addr)
+	}
+	if int(j.SP) != len(j.Stack) {
+		return nil, fmt.Errorf("sp is %d but the stack lists %d addresses", j.SP, len(j.Stack))
+	}
+
+	for key, row := range j.Memory {
+		addr, err := strconv.ParseUint(key, 0, 16)
+		if err != nil {
+			return nil, fmt.Errorf("memory: bad address %q", key)
+		}
+		for i, field := range strings.Fields(row) {
+			v, err := strconv.ParseUint(field, 16, 8)
+			if err != nil {
+				return nil, fmt.Errorf("memory %s: bad byte %q", key, field)
+			}
+			if int(addr)+i >= len(s.Memory) {
+				return nil, fmt.Errorf("memory %s runs past memory_size", key)
+			}
+			s.Memory[int(addr)+i] = byte(v)
+		}
+	}
+
+	if len(j.Screen) > len(s.ScreenState) {
+		return nil, fmt.Errorf("screen has %d rows, the display %d", len(j.Screen), len(s.ScreenState))
+	}
+	for y, row := range j.Screen {
+		if len(row) > len(s.ScreenState[y]) {
+			return nil, fmt.Errorf("screen row %d is %d pixels wide, the display %d", y, len(row), len(s.ScreenState[y]))
+		}
+		for x, px := range row {
+			switch px {
+			case '#':
+				s.ScreenState[y][x] = 1
+			case '.':
+			default:
+				return nil, fmt.Errorf("screen row %d: %q is neither # nor .", y, px)
+			}
+		}
+	}
+	return s, nil
+}
+
+// stateCommand converts a save state of either format to the one out's name asks for, or prints
+// it as JSON
+func stateCommand(args []string) error {
+	fs := newFlagSet("state")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() < 1 || fs.NArg() > 2 {
+		return usageError("state")
+	}
+
+	s, err := readStateFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if fs.NArg() == 1 {
+		return writeStateJSON(os.Stdout, s)
+	}
+	return writeStateToFile(fs.Arg(1), s)
+}
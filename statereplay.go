@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"slices"
+)
+
+// stateBundle is the last save state written and the input recorded since, which together make
+// a save state that replays what happened after it
+type stateBundle struct {
+	state *saveState
+	input bytes.Buffer
+	rec   *inputRecorder
+}
+
+// startBundle keeps s and starts recording input against it. The recording notes where the
+// random number generator has got to, so a replay picks the sequence up from there.
+func (c *Chip8) startBundle(s *saveState) {
+	s.Memory = slices.Clone(s.Memory)
+
+	b := &stateBundle{state: s}
+	b.rec = recordInput(&b.input, c)
+	c.sinceSave = b
+}
+
+// WriteReplayStateFile writes the last save state with the input recorded since it was saved
+func (c *Chip8) WriteReplayStateFile(file string) error {
+	b := c.sinceSave
+	if b == nil {
+		return errors.New("no state saved yet to record input from")
+	}
+	if err := b.rec.w.Flush(); err != nil {
+		return err
+	}
+
+	s := *b.state
+	s.Input = b.input.Bytes()
+	return writeStateToFile(file, &s)
+}
+
+// ReplayStateFile restores a save state carrying an input recording and plays the recording back
+// from there in place of the keyboard
+func (c *Chip8) ReplayStateFile(file string) error {
+	s, err := readStateFile(file)
+	if err != nil {
+		return err
+	}
+	if len(s.Input) == 0 {
+		return fmt.Errorf("%s: no input recorded with this state", file)
+	}
+
+	p, err := parseInputReplay(bytes.NewReader(s.Input), file)
+	if err != nil {
+		return err
+	}
+	if err := c.restoreState(s, file); err != nil {
+		return err
+	}
+
+	p.Start(c)
+	c.stateReplay = p
+	log.Printf("%s: replaying input from frame %d", file, c.Frame)
+	c.Notify("Replaying %s", filepath.Base(file))
+	return nil
+}
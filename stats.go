@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sessionStats counts what a session did, from the event bus and the run loop, for -stats.
+// Counting instructions means publishing an event for each, which rules out the block cache
+// and transpiled code, so it is only done when asked for.
+type sessionStats struct {
+	file    string
+	started time.Time
+	frame   uint64 // c.Frame when counting started
+
+	instructions uint64
+	opcodes      [opcodeInvalid + 1]uint64
+
+	sprites, clears, collisions uint64
+	keys                        [16]uint64
+
+	// frames Run went through, drawn whether or not the game was paused, and the time they took
+	// to emulate and draw
+	frames           uint64
+	frameTime, worst time.Duration
+}
+
+// sessionReport is the file -stats writes
+type sessionReport struct {
+	Rom      string    `json:"rom,omitempty"`
+	RomHash  string    `json:"rom_hash,omitempty"`
+	Started  time.Time `json:"started"`
+	Duration float64   `json:"duration_seconds"`
+
+	Instructions   uint64  `json:"instructions"`
+	FramesEmulated uint64  `json:"frames_emulated"`
+	FramesRendered uint64  `json:"frames_rendered"`
+	AverageFrameMS float64 `json:"average_frame_ms"`
+	WorstFrameMS   float64 `json:"worst_frame_ms"`
+
+	SpritesDrawn uint64 `json:"sprites_drawn"`
+	Clears       uint64 `json:"clears"`
+	Collisions   uint64 `json:"collisions"`
+
+	// by pattern, e.g. "8XY4", and by key in hex; those never seen are left out
+	Opcodes    map[string]uint64 `json:"opcodes"`
+	KeyPresses map[string]uint64 `json:"key_presses"`
+}
+
+// startStats counts the session from here on, for writing to file
+func (c *Chip8) startStats(file string) {
+	s := &sessionStats{file: file, started: time.Now(), frame: c.Frame}
+	c.stats = s
+
+	c.Events.Subscribe(EventInstruction, func(e Event) {
+		s.instructions++
+		s.opcodes[c.decode(e.(InstructionEvent).Opcode)]++
+	})
+	c.Events.Subscribe(EventDraw, func(e Event) {
+		switch d := e.(DrawEvent); {
+		case d.Clear:
+			s.clears++
+		case d.Collision:
+			s.collisions++
+			fallthrough
+		default:
+			s.sprites++
+		}
+	})
+	c.Events.Subscribe(EventKey, func(e Event) {
+		if k := e.(KeyEvent); k.Pressed {
+			s.keys[k.Key&0xF]++
+		}
+	})
+}
+
+// recordFrame adds a frame Run took d over
+func (s *sessionStats) recordFrame(d time.Duration) {
+	s.frames++
+	s.frameTime += d
+	s.worst = max(s.worst, d)
+}
+
+func (c *Chip8) statsReport() *sessionReport {
+	s := c.stats
+	r := &sessionReport{
+		Rom:            c.RomFile,
+		RomHash:        c.RomHash,
+		Started:        s.started,
+		Duration:       time.Since(s.started).Seconds(),
+		Instructions:   s.instructions,
+		FramesEmulated: c.Frame - s.frame,
+		FramesRendered: s.frames,
+		WorstFrameMS:   s.worst.Seconds() * 1000,
+		SpritesDrawn:   s.sprites,
+		Clears:         s.clears,
+		Collisions:     s.collisions,
+		Opcodes:        map[string]uint64{},
+		KeyPresses:     map[string]uint64{},
+	}
+	if s.frames > 0 {
+		r.AverageFrameMS = s.frameTime.Seconds() * 1000 / float64(s.frames)
+	}
+	for op, n := range s.opcodes {
+		if n > 0 {
+			r.Opcodes[strings.TrimPrefix(opcodeNames[op], "opcode")] = n
+		}
+	}
+	for key, n := range s.keys {
+		if n > 0 {
+			r.KeyPresses[fmt.Sprintf("%X", key)] = n
+		}
+	}
+	return r
+}
+
+// WriteStats writes the session statistics so far to the -stats file, as CSV when its name ends
+// in .csv and JSON otherwise
+func (c *Chip8) WriteStats() error {
+	r := c.statsReport()
+	f, err := os.Create(c.stats.file)
+	if err != nil {
+		return err
+	}
+
+	if strings.EqualFold(filepath.Ext(c.stats.file), ".csv") {
+		err = r.writeCSV(f)
+	} else {
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(r)
+	}
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// writeCSV writes the report as rows of a name and a value, opcodes and keys in order
+func (r *sessionReport) writeCSV(f io.Writer) error {
+	w := csv.NewWriter(f)
+	u := func(n uint64) string { return strconv.FormatUint(n, 10) }
+	ms := func(v float64) string { return strconv.FormatFloat(v, 'f', 3, 64) }
+	rows := [][]string{
+		{"stat", "value"},
+		{"rom", r.Rom},
+		{"rom_hash", r.RomHash},
+		{"started", r.Started.Format(time.RFC3339)},
+		{"duration_seconds", ms(r.Duration)},
+		{"instructions", u(r.Instructions)},
+		{"frames_emulated", u(r.FramesEmulated)},
+		{"frames_rendered", u(r.FramesRendered)},
+		{"average_frame_ms", ms(r.AverageFrameMS)},
+		{"worst_frame_ms", ms(r.WorstFrameMS)},
+		{"sprites_drawn", u(r.SpritesDrawn)},
+		{"clears", u(r.Clears)},
+		{"collisions", u(r.Collisions)},
+	}
+	for op := range opcodeInvalid + 1 {
+		name := strings.TrimPrefix(opcodeNames[op], "opcode")
+		if n, ok := r.Opcodes[name]; ok {
+			rows = append(rows, []string{"opcode " + name, u(n)})
+		}
+	}
+	for key := range 16 {
+		if n, ok := r.KeyPresses[fmt.Sprintf("%X", key)]; ok {
+			rows = append(rows, []string{fmt.Sprintf("key %X", key), u(n)})
+		}
+	}
+	return w.WriteAll(rows)
+}
+
+// writeStatsHotkey writes the statistics so far without waiting for the session to end
+func (c *Chip8) writeStatsHotkey() {
+	if c.stats == nil {
+		c.Notify("Run with -stats to collect session statistics")
+		return
+	}
+	if err := c.WriteStats(); err != nil {
+		log.Printf("writing statistics failed: %v", err)
+		c.Notify("Writing statistics failed")
+		return
+	}
+	c.Notify("Statistics written to %s", c.stats.file)
+}
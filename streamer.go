@@ -0,0 +1,39 @@
+package main
+
+import (
+	"image/color"
+	"strings"
+)
+
+// Streaming software such as OBS can capture the window as a layer over others: -on-top keeps it
+// above the windows open alongside it, and -chroma-key draws unlit pixels in a key color for the
+// capture to remove, or leaves them transparent where the window manager composites windows.
+// Only the window is affected; -matrix, -shm and the other outputs keep the ROM's colors.
+
+// windowOptions are how the main window is set up beyond its size
+type windowOptions struct {
+	OnTop       bool
+	Transparent bool
+}
+
+// parseChromaKey reads a -chroma-key value: a #rrggbb color, or "transparent"
+func parseChromaKey(s string) (color.RGBA, error) {
+	if strings.EqualFold(s, "transparent") {
+		return color.RGBA{}, nil
+	}
+	return parseHexColor(s)
+}
+
+// windowOff is the color unlit pixels are drawn in on the window
+func (c *Chip8) windowOff() color.RGBA {
+	if c.ChromaKey != nil {
+		return *c.ChromaKey
+	}
+	return c.ColorOff
+}
+
+// transparent reports whether unlit pixels leave the window see-through, which means clearing
+// what was drawn before rather than drawing over it
+func (c *Chip8) transparent() bool {
+	return c.ChromaKey != nil && c.ChromaKey.A == 0
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// opcodeTemplate is a valid instruction shape: fixed bits plus a mask of the operand bits that
+// may take any value
+type opcodeTemplate struct {
+	fixed, free uint16
+}
+
+// validOpcodes lists every instruction the core decodes, for generating ROMs that exercise the
+// interpreter rather than its unknown-opcode path
+var validOpcodes = []opcodeTemplate{
+	{0x00E0, 0x0000}, {0x00EE, 0x0000}, {0x1000, 0x0FFF}, {0x2000, 0x0FFF},
+	{0x3000, 0x0FFF}, {0x4000, 0x0FFF}, {0x5000, 0x0FF0}, {0x6000, 0x0FFF},
+	{0x7000, 0x0FFF}, {0x8000, 0x0FF0}, {0x8001, 0x0FF0}, {0x8002, 0x0FF0},
+	{0x8003, 0x0FF0}, {0x8004, 0x0FF0}, {0x8005, 0x0FF0}, {0x8006, 0x0FF0},
+	{0x8007, 0x0FF0}, {0x800E, 0x0FF0}, {0x9000, 0x0FF0}, {0xA000, 0x0FFF},
+	{0xB000, 0x0FFF}, {0xC000, 0x0FFF}, {0xD000, 0x0FFF}, {0xE09E, 0x0F00},
+	{0xE0A1, 0x0F00}, {0xF007, 0x0F00}, {0xF00A, 0x0F00}, {0xF015, 0x0F00},
+	{0xF018, 0x0F00}, {0xF01E, 0x0F00}, {0xF029, 0x0F00}, {0xF033, 0x0F00},
+	{0xF055, 0x0F00}, {0xF065, 0x0F00},
+}
+
+// randomRom fills size bytes with noise, or with valid instructions when valid is set
+func randomRom(r *rand.Rand, size int, valid bool) []byte {
+	rom := make([]byte, size)
+	if !valid {
+		r.Read(rom)
+		return rom
+	}
+
+	for i := 0; i+1 < size; i += 2 {
+		t := validOpcodes[r.Intn(len(validOpcodes))]
+		op := t.fixed | uint16(r.Intn(0x10000))&t.free
+		rom[i], rom[i+1] = byte(op>>8), byte(op)
+	}
+	return rom
+}
+
+// stressResult is how one random ROM ended, nil error meaning it ran all its frames
+type stressResult struct {
+	err    error
+	frames uint64
+}
+
+// stressRun runs rom headless for the given number of frames. Faults are caught by the core; a
+// panic anywhere else is turned into an error here.
+func stressRun(rom []byte, frames uint64, seed int64) (res stressResult) {
+	c := newMachine()
+	defer func() {
+		if r := recover(); r != nil {
+			res = stressResult{fmt.Errorf("panic: %v", r), c.Frame}
+		}
+	}()
+
+	c.LoadDefaultSprites()
+	c.LoadRom(rom)
+	c.SetSeed(seed)
+	for c.Frame < frames && c.Fault == nil {
+		c.StepFrame()
+
+		// nobody presses keys, so release FX0A waits now and then
+		c.KeyJustReleased = [16]bool{}
+		if c.Frame%8 == 0 {
+			c.KeyJustReleased[seed&0xF] = true
+		}
+	}
+	if c.Fault != nil {
+		return stressResult{c.Fault, c.Frame}
+	}
+	return stressResult{nil, c.Frame}
+}
+
+// stressCommand implements "chip8 stress", running random ROMs until stopped or -count is reached
+// and logging those that panic, fault or hang
+func stressCommand(args []string) error {
+	fs := newFlagSet("stress")
+	count := fs.Int("count", 0, "ROMs to run, 0 runs until interrupted")
+	frames := fs.Uint64("frames", 600, "frames to run each ROM for")
+	size := fs.Int("size", 512, "bytes of random code per ROM")
+	valid := fs.Bool("valid", true, "generate only decodable instructions instead of raw noise")
+	faults := fs.Bool("faults", false, "also log ROMs that fault, which random code often does legitimately")
+	timeout := fs.Duration("timeout", 5*time.Second, "wall clock time after which a ROM is reported as hung")
+	seed := fs.Int64("seed", 0, "seed for the ROM generator, 0 picks one from the clock")
+	out := fs.String("o", "", "directory to save offending ROMs to")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 || *size < 2 || int(RamGameStart)+*size > len(newMachine().MainMemory) {
+		return usageError("stress")
+	}
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+	if *out != "" {
+		if err := os.MkdirAll(*out, 0o755); err != nil {
+			return err
+		}
+	}
+
+	// the core logs every fault it catches, far too many for random code, so report separately
+	report := log.New(os.Stderr, "", log.LstdFlags)
+	log.SetOutput(io.Discard)
+
+	report.Printf("stress testing with seed %d", *seed)
+	gen := rand.New(rand.NewSource(*seed))
+
+	var bad int
+	for n := 1; *count == 0 || n <= *count; n++ {
+		rom := randomRom(gen, *size, *valid)
+		romSeed := gen.Int63()
+
+		// a hung run is abandoned rather than stopped, there is no way to interrupt it
+		done := make(chan stressResult, 1)
+		go func() { done <- stressRun(rom, *frames, romSeed) }()
+
+		var problem string
+		select {
+		case res := <-done:
+			switch {
+			case res.err == nil:
+			case *faults || !isFault(res.err):
+				problem = fmt.Sprintf("frame %d: %v", res.frames, res.err)
+			}
+		case <-time.After(*timeout):
+			problem = fmt.Sprintf("hung for %v", *timeout)
+		}
+
+		if problem == "" {
+			if n%1000 == 0 {
+				report.Printf("%d ROMs run, %d problems", n, bad)
+			}
+			continue
+		}
+
+		bad++
+		name := fmt.Sprintf("stress-%d-%d.ch8", *seed, n)
+		report.Printf("ROM %d (%s, run seed %d): %s", n, name, romSeed, problem)
+		if *out != "" {
+			if err := os.WriteFile(filepath.Join(*out, name), rom, 0o644); err != nil {
+				return err
+			}
+		}
+	}
+
+	report.Printf("%d ROMs run, %d problems", *count, bad)
+	if bad > 0 {
+		return fmt.Errorf("%d of %d ROMs had problems", bad, *count)
+	}
+	return nil
+}
+
+// isFault reports whether err is a fault the core caught, as opposed to a panic that escaped it
+func isFault(err error) bool {
+	_, ok := err.(*Fault)
+	return ok
+}
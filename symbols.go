@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SymbolTable maps assembler labels and source lines to ROM addresses
+type SymbolTable struct {
+	Labels map[string]uint16
+	Lines  map[uint16]int
+
+	// sorted label addresses, used to describe an address as label+offset
+	sorted []uint16
+	names  map[uint16]string
+}
+
+func newSymbolTable() *SymbolTable {
+	return &SymbolTable{Labels: map[string]uint16{}, Lines: map[uint16]int{}}
+}
+
+// LoadSymbols reads a symbol listing. Each line holds a label and its address in either order
+// ("main 0x202" or "0x202 main"), or an address followed by "line" and the source line it was
+// assembled from ("0x202 line 7"). Blank lines and lines starting with # or ; are ignored.
+func LoadSymbols(symbolFile string) (*SymbolTable, error) {
+	f, err := os.Open(symbolFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	s := newSymbolTable()
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		fields := strings.Fields(strings.ReplaceAll(line, "=", " "))
+		switch {
+		case len(fields) == 3 && fields[1] == "line":
+			addr, ok := parseSymbolAddr(fields[0])
+			src, err := strconv.Atoi(fields[2])
+			if !ok || err != nil {
+				return nil, fmt.Errorf("%s:%d: malformed line entry %q", symbolFile, n, line)
+			}
+			s.Lines[addr] = src
+		case len(fields) == 2:
+			// a label can be spelt in hex digits ("face 0x300"), so an address with a prefix
+			// settles the order before a bare one is looked for
+			if addr, ok := parseSymbolAddr(fields[1]); ok && hasAddrPrefix(fields[1]) {
+				s.addLabel(fields[0], addr)
+			} else if addr, ok := parseSymbolAddr(fields[0]); ok {
+				s.addLabel(fields[1], addr)
+			} else if addr, ok := parseSymbolAddr(fields[1]); ok {
+				s.addLabel(fields[0], addr)
+			} else {
+				return nil, fmt.Errorf("%s:%d: no address in %q", symbolFile, n, line)
+			}
+		default:
+			return nil, fmt.Errorf("%s:%d: unrecognised symbol entry %q", symbolFile, n, line)
+		}
+	}
+
+	return s, scanner.Err()
+}
+
+// Write lists the table in the format LoadSymbols reads: labels by address, then source lines
+func (s *SymbolTable) Write(w io.Writer) error {
+	names := make([]string, 0, len(s.Labels))
+	for name := range s.Labels {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		a, b := s.Labels[names[i]], s.Labels[names[j]]
+		return a < b || a == b && names[i] < names[j]
+	})
+
+	bw := bufio.NewWriter(w)
+	for _, name := range names {
+		fmt.Fprintf(bw, "0x%03X %s\n", s.Labels[name], name)
+	}
+
+	addrs := make([]uint16, 0, len(s.Lines))
+	for addr := range s.Lines {
+		addrs = append(addrs, addr)
+	}
+	sort.Slice(addrs, func(i, j int) bool { return addrs[i] < addrs[j] })
+	for _, addr := range addrs {
+		fmt.Fprintf(bw, "0x%03X line %d\n", addr, s.Lines[addr])
+	}
+
+	return bw.Flush()
+}
+
+// parseSymbolAddr accepts 0x-prefixed, $-prefixed or bare hexadecimal addresses
+func parseSymbolAddr(s string) (uint16, bool) {
+	s = strings.TrimPrefix(strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X"), "$")
+	n, err := strconv.ParseUint(s, 16, 16)
+	return uint16(n), err == nil
+}
+
+// hasAddrPrefix reports whether s is written as an address rather than possibly a label
+func hasAddrPrefix(s string) bool {
+	return strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") || strings.HasPrefix(s, "$")
+}
+
+func (s *SymbolTable) addLabel(name string, addr uint16) {
+	s.Labels[name] = addr
+	s.sorted, s.names = nil, nil
+}
+
+func (s *SymbolTable) index() {
+	if s.names != nil {
+		return
+	}
+	s.names = make(map[uint16]string, len(s.Labels))
+	for name, addr := range s.Labels {
+		// prefer the alphabetically first name when several labels share an address
+		if existing, ok := s.names[addr]; !ok || name < existing {
+			s.names[addr] = name
+		}
+	}
+	s.sorted = make([]uint16, 0, len(s.names))
+	for addr := range s.names {
+		s.sorted = append(s.sorted, addr)
+	}
+	sort.Slice(s.sorted, func(i, j int) bool { return s.sorted[i] < s.sorted[j] })
+}
+
+// LabelAt returns the label defined exactly at addr
+func (s *SymbolTable) LabelAt(addr uint16) (string, bool) {
+	if s == nil {
+		return "", false
+	}
+	s.index()
+	name, ok := s.names[addr]
+	return name, ok
+}
+
+// Describe renders an address as the nearest preceding label plus an offset, e.g. "game-loop+6"
+func (s *SymbolTable) Describe(addr uint16) string {
+	if s == nil {
+		return ""
+	}
+	s.index()
+
+	i := sort.Search(len(s.sorted), func(i int) bool { return s.sorted[i] > addr }) - 1
+	if i < 0 {
+		return ""
+	}
+	base := s.sorted[i]
+	if base == addr {
+		return s.names[base]
+	}
+	return fmt.Sprintf("%s+%d", s.names[base], addr-base)
+}
+
+// Resolve turns a label, "label+offset" or a hexadecimal address into an address
+func (s *SymbolTable) Resolve(expr string) (uint16, error) {
+	name, offset := expr, uint64(0)
+	if idx := strings.LastIndex(expr, "+"); idx > 0 {
+		off, err := strconv.ParseUint(expr[idx+1:], 0, 16)
+		if err != nil {
+			return 0, fmt.Errorf("bad offset in %q", expr)
+		}
+		name, offset = expr[:idx], off
+	}
+
+	if s != nil {
+		if addr, ok := s.Labels[name]; ok {
+			return addr + uint16(offset), nil
+		}
+	}
+
+	if addr, ok := parseSymbolAddr(name); ok {
+		return addr + uint16(offset), nil
+	}
+
+	return 0, fmt.Errorf("unknown address or label %q", expr)
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestLoadSymbolsHexLabels reads labels spelt only in hex digits, which could be taken for
+// addresses, in both orders
+func TestLoadSymbolsHexLabels(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "game.sym")
+	listing := "add 0x202\nface $300\n0x210 dec\nmain 204\n"
+	if err := os.WriteFile(file, []byte(listing), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := LoadSymbols(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]uint16{"add": 0x202, "face": 0x300, "dec": 0x210, "main": 0x204}
+	for name, addr := range want {
+		if got, ok := s.Labels[name]; !ok || got != addr {
+			t.Errorf("%s at %03X (found %v), want %03X", name, got, ok, addr)
+		}
+	}
+	if len(s.Labels) != len(want) {
+		t.Errorf("labels %v, want %v", s.Labels, want)
+	}
+}
@@ -0,0 +1,544 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// "chip8 teach" runs a ROM in the terminal slowly enough to follow, for showing a class how a
+// CPU works. Each instruction is taken through three stages, each held on screen for -delay:
+// fetch highlights the two bytes read at PC in the memory view, decode shows the pattern the
+// opcode matches, its mnemonic and what it will do, and execute lists the registers and
+// memory it changed, before and after. The display and keypad are shown alongside, and the
+// timers count down once every CyclesPerFrame instructions as they would in a frame.
+
+type teachStage int
+
+const (
+	teachFetch teachStage = iota
+	teachDecode
+	teachExecute
+)
+
+var teachStageNames = [...]string{teachFetch: "fetch", teachDecode: "decode", teachExecute: "execute"}
+
+const teachMemoryRows = 8
+
+var (
+	teachFetchedStyle = lipgloss.NewStyle().Reverse(true)
+	teachChangedStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("3")).Bold(true)
+	teachStageStyle   = lipgloss.NewStyle().Reverse(true).Bold(true).Padding(0, 1)
+	teachOtherStyle   = lipgloss.NewStyle().Faint(true).Padding(0, 1)
+	teachNoteStyle    = tuiPane.Width(88).Foreground(lipgloss.Color("6"))
+)
+
+// teachKeys maps the keyboard to the keypad, laid out as defaultKeyMap lays it out
+var teachKeys = map[string]uint8{
+	"1": 0x1, "2": 0x2, "3": 0x3, "4": 0xC,
+	"q": 0x4, "w": 0x5, "e": 0x6, "r": 0xD,
+	"a": 0x7, "s": 0x8, "d": 0x9, "f": 0xE,
+	"z": 0xA, "x": 0x0, "c": 0xB, "v": 0xF,
+}
+
+// teachRegs is the registers an instruction can change, kept to show them before and after
+type teachRegs struct {
+	Vx     [16]uint8
+	I, PC  uint16
+	SP     uint8
+	DT, ST uint8
+}
+
+func captureTeachRegs(c *Chip8) teachRegs {
+	return teachRegs{Vx: c.Vx, I: c.I, PC: c.PC, SP: c.SP, DT: c.DT, ST: c.ST}
+}
+
+// teachTickMsg moves on a stage; gen tells ticks scheduled before a pause from the current ones
+type teachTickMsg struct{ gen int }
+
+type teachModel struct {
+	c      *Chip8
+	stage  teachStage
+	delay  time.Duration
+	paused bool
+	gen    int
+
+	// the instruction going through the stages
+	pc, opcode uint16
+	op         Opcode
+	count      int
+
+	// state before the last execute, and the memory it changed
+	before  teachRegs
+	after   teachRegs
+	changed []uint16
+
+	// keys pressed since the last execute, held for the next instruction and then released
+	pressed, released [16]bool
+
+	// captions by instruction address, see parseNotes
+	notes map[uint16]string
+}
+
+func (m *teachModel) Init() tea.Cmd {
+	m.fetch()
+	return m.tick()
+}
+
+func (m *teachModel) tick() tea.Cmd {
+	if m.paused {
+		return nil
+	}
+	gen := m.gen
+	return tea.Tick(m.delay, func(time.Time) tea.Msg { return teachTickMsg{gen} })
+}
+
+func (m *teachModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case teachTickMsg:
+		if msg.gen != m.gen || m.paused {
+			return m, nil
+		}
+		m.advance()
+		return m, m.tick()
+
+	case tea.KeyMsg:
+		if key, ok := teachKeys[msg.String()]; ok {
+			m.pressed[key] = true
+			return m, nil
+		}
+		switch msg.String() {
+		case "ctrl+c", "esc":
+			return m, tea.Quit
+		case " ":
+			m.paused = !m.paused
+			m.gen++
+			return m, m.tick()
+		case "n", "right", "enter":
+			m.advance()
+		case "+", "=":
+			m.delay = max(m.delay/2, 50*time.Millisecond)
+		case "-":
+			m.delay = min(m.delay*2, 10*time.Second)
+		}
+	}
+	return m, nil
+}
+
+// advance moves the instruction on to its next stage, or fetches the one after it
+func (m *teachModel) advance() {
+	switch m.stage {
+	case teachFetch:
+		m.op = m.c.decode(m.opcode)
+		m.stage = teachDecode
+	case teachDecode:
+		m.execute()
+		m.stage = teachExecute
+	case teachExecute:
+		m.fetch()
+	}
+}
+
+func (m *teachModel) fetch() {
+	c := m.c
+	m.stage = teachFetch
+	m.pc, m.opcode = c.PC, c.word(c.PC)
+	m.changed = nil
+}
+
+func (m *teachModel) execute() {
+	c := m.c
+	if c.Fault != nil {
+		return
+	}
+	c.KeyPressed, c.KeyJustReleased = m.pressed, m.released
+	m.released, m.pressed = m.pressed, [16]bool{}
+
+	m.before = captureTeachRegs(c)
+	mem := slices.Clone(c.MainMemory)
+	c.ExecuteCPU(1)
+	m.count++
+	if m.count%c.CyclesPerFrame == 0 {
+		c.endFrame()
+	}
+	m.after = captureTeachRegs(c)
+
+	for addr := range mem {
+		if mem[addr] != c.MainMemory[addr] {
+			m.changed = append(m.changed, uint16(addr))
+		}
+	}
+}
+
+// explain says in words what the stage is doing
+func (m *teachModel) explain() string {
+	c := m.c
+	switch m.stage {
+	case teachFetch:
+		hi, lo := m.opcode>>8, m.opcode&0xFF
+		return fmt.Sprintf("The CPU reads the two bytes at PC, %03X and %03X: %02X and %02X.\n"+
+			"Together, high byte first, they are the instruction %04X.", m.pc, m.pc+1, hi, lo, m.opcode)
+
+	case teachDecode:
+		if m.op == opcodeInvalid {
+			return fmt.Sprintf("%04X matches none of the instruction patterns.\nIt is %s here: %s.",
+				m.opcode, c.Variant, explainOpcode(c, m.op, m.opcode))
+		}
+		return fmt.Sprintf("%04X matches the pattern %s%s.\nIn assembly it is %s: %s.",
+			m.opcode, strings.TrimPrefix(opcodeNames[m.op], "opcode"), opcodeFields(m.op, m.opcode),
+			Mnemonic(m.opcode, c.Symbols), explainOpcode(c, m.op, m.opcode))
+	}
+
+	if c.Fault != nil {
+		return fmt.Sprintf("The instruction faulted: %v", c.Fault)
+	}
+	var changes []string
+	b, a := m.before, m.after
+	for i := range a.Vx {
+		if a.Vx[i] != b.Vx[i] {
+			changes = append(changes, fmt.Sprintf("V%X %02X -> %02X", i, b.Vx[i], a.Vx[i]))
+		}
+	}
+	if a.I != b.I {
+		changes = append(changes, fmt.Sprintf("I %03X -> %03X", b.I, a.I))
+	}
+	if a.SP != b.SP {
+		changes = append(changes, fmt.Sprintf("SP %X -> %X", b.SP, a.SP))
+	}
+	if a.DT != b.DT || a.ST != b.ST {
+		changes = append(changes, fmt.Sprintf("DT %02X -> %02X, ST %02X -> %02X", b.DT, a.DT, b.ST, a.ST))
+	}
+	if len(m.changed) > 0 {
+		changes = append(changes, fmt.Sprintf("%d bytes of memory from %03X", len(m.changed), m.changed[0]))
+	}
+	next := fmt.Sprintf("PC %03X -> %03X", b.PC, a.PC)
+	switch {
+	case m.op == opcode2NNN:
+		next += ", into the subroutine"
+	case m.op == opcode00EE:
+		next += ", back to just after the call"
+	case a.PC == b.PC+2:
+		next += ", on to the next instruction"
+	case a.PC == b.PC+4 || a.PC == b.PC+6:
+		next += ", skipping an instruction"
+	case a.PC == b.PC:
+		next += ", so it runs again"
+	default:
+		next += ", a jump"
+	}
+	if len(changes) == 0 {
+		return "Nothing else changed.\n" + next
+	}
+	return "Changed: " + strings.Join(changes, ", ") + ".\n" + next
+}
+
+// opcodeFields spells out the values the letters of a pattern stand for in an opcode
+func opcodeFields(op Opcode, opcode uint16) string {
+	pattern := opcodeNames[op]
+	var fields []string
+	if strings.Contains(pattern, "X") {
+		fields = append(fields, fmt.Sprintf("X = %X", opcode>>8&0xF))
+	}
+	if strings.Contains(pattern, "Y") {
+		fields = append(fields, fmt.Sprintf("Y = %X", opcode>>4&0xF))
+	}
+	switch {
+	case strings.Contains(pattern, "NNN"):
+		fields = append(fields, fmt.Sprintf("NNN = %03X", opcode&0xFFF))
+	case strings.Contains(pattern, "NN"):
+		fields = append(fields, fmt.Sprintf("NN = %02X", opcode&0xFF))
+	case strings.Contains(pattern, "N"):
+		fields = append(fields, fmt.Sprintf("N = %X", opcode&0xF))
+	}
+	if len(fields) == 0 {
+		return ""
+	}
+	return " with " + strings.Join(fields, ", ")
+}
+
+// explainOpcode says what an instruction does, with the values it works on now
+func explainOpcode(c *Chip8, op Opcode, opcode uint16) string {
+	x, y := opcode>>8&0xF, opcode>>4&0xF
+	nn, nnn := opcode&0xFF, opcode&0xFFF
+	vx, vy := c.Vx[x], c.Vx[y]
+
+	switch op {
+	case opcode00E0:
+		return "clear the display"
+	case opcode00EE:
+		return "return from a subroutine to the address on top of the stack"
+	case opcode1NNN:
+		return fmt.Sprintf("jump to %03X", nnn)
+	case opcode2NNN:
+		return fmt.Sprintf("push the address of the next instruction on the stack and jump to %03X", nnn)
+	case opcode3XNN:
+		return fmt.Sprintf("skip the next instruction if V%X (%02X) equals %02X", x, vx, nn)
+	case opcode4XNN:
+		return fmt.Sprintf("skip the next instruction unless V%X (%02X) equals %02X", x, vx, nn)
+	case opcode5XY0:
+		return fmt.Sprintf("skip the next instruction if V%X (%02X) equals V%X (%02X)", x, vx, y, vy)
+	case opcode9XY0:
+		return fmt.Sprintf("skip the next instruction unless V%X (%02X) equals V%X (%02X)", x, vx, y, vy)
+	case opcode6XNN:
+		return fmt.Sprintf("set V%X to %02X", x, nn)
+	case opcode7XNN:
+		return fmt.Sprintf("add %02X to V%X (%02X), dropping any carry", nn, x, vx)
+	case opcode8XY0:
+		return fmt.Sprintf("copy V%X (%02X) into V%X", y, vy, x)
+	case opcode8XY1, opcode8XY2, opcode8XY3:
+		name := "OR"
+		if op == opcode8XY2 {
+			name = "AND"
+		} else if op == opcode8XY3 {
+			name = "XOR"
+		}
+		s := fmt.Sprintf("set V%X to V%X (%02X) %s V%X (%02X), bit by bit", x, x, vx, name, y, vy)
+		if c.Quirks.VFReset {
+			s += ", and clear VF"
+		}
+		return s
+	case opcode8XY4:
+		return fmt.Sprintf("add V%X (%02X) to V%X (%02X), setting VF to 1 if the sum carries past FF", y, vy, x, vx)
+	case opcode8XY5:
+		return fmt.Sprintf("subtract V%X (%02X) from V%X (%02X), setting VF to 0 if it borrows", y, vy, x, vx)
+	case opcode8XY7:
+		return fmt.Sprintf("set V%X to V%X (%02X) minus V%X (%02X), setting VF to 0 if it borrows", x, y, vy, x, vx)
+	case opcode8XY6, opcode8XYE:
+		src, v := x, vx
+		if c.Quirks.ShiftUsesVy {
+			src, v = y, vy
+		}
+		if op == opcode8XY6 {
+			return fmt.Sprintf("shift V%X (%02X) right a bit into V%X, the bit shifted out going to VF", src, v, x)
+		}
+		return fmt.Sprintf("shift V%X (%02X) left a bit into V%X, the bit shifted out going to VF", src, v, x)
+	case opcodeANNN:
+		return fmt.Sprintf("point I at %03X", nnn)
+	case opcodeBNNN:
+		if c.Quirks.JumpUsesVx {
+			return fmt.Sprintf("jump to %03X plus V%X (%02X)", nnn, x, vx)
+		}
+		return fmt.Sprintf("jump to %03X plus V0 (%02X)", nnn, c.Vx[0])
+	case opcodeCXNN:
+		return fmt.Sprintf("set V%X to a random number, keeping only the bits set in %02X", x, nn)
+	case opcodeDXYN:
+		return fmt.Sprintf("draw the %d rows of sprite at I (%03X) at V%X, V%X (%d, %d), flipping pixels and setting VF to 1 if any lit one goes out",
+			opcode&0xF, c.I, x, y, vx, vy)
+	case opcodeEX9E:
+		return fmt.Sprintf("skip the next instruction if key V%X (%X) is held", x, vx&0xF)
+	case opcodeEXA1:
+		return fmt.Sprintf("skip the next instruction unless key V%X (%X) is held", x, vx&0xF)
+	case opcodeFX07:
+		return fmt.Sprintf("copy the delay timer (%02X) into V%X", c.DT, x)
+	case opcodeFX0A:
+		return fmt.Sprintf("wait for a key to be pressed and released, putting it in V%X; press one to go on", x)
+	case opcodeFX15:
+		return fmt.Sprintf("set the delay timer to V%X (%02X)", x, vx)
+	case opcodeFX18:
+		return fmt.Sprintf("set the sound timer to V%X (%02X), beeping until it runs out", x, vx)
+	case opcodeFX1E:
+		return fmt.Sprintf("add V%X (%02X) to I (%03X)", x, vx, c.I)
+	case opcodeFX29:
+		return fmt.Sprintf("point I at the font sprite for the digit in V%X", x)
+	case opcodeFX33:
+		return fmt.Sprintf("write V%X (%d) as three decimal digits to I (%03X) onwards", x, vx, c.I)
+	case opcodeFX55, opcodeFX65:
+		s := fmt.Sprintf("copy V0 to V%X into memory from I (%03X)", x, c.I)
+		if op == opcodeFX65 {
+			s = fmt.Sprintf("copy memory from I (%03X) into V0 to V%X", c.I, x)
+		}
+		if c.Quirks.LoadStoreIncrementsI {
+			s += ", moving I past them"
+		}
+		return s
+	case opcodeF000:
+		return "point I at the 16-bit address in the next two bytes"
+	}
+	return "not an instruction, so it is skipped"
+}
+
+func (m *teachModel) View() string {
+	c := m.c
+
+	var stages []string
+	for s, name := range teachStageNames {
+		if teachStage(s) == m.stage {
+			stages = append(stages, teachStageStyle.Render(strings.ToUpper(name)))
+		} else {
+			stages = append(stages, teachOtherStyle.Render(name))
+		}
+	}
+	state := fmt.Sprintf("instruction %d, %v a stage", m.count+1, m.delay)
+	if m.stage == teachExecute {
+		state = fmt.Sprintf("instruction %d, %v a stage", m.count, m.delay)
+	}
+	if m.paused {
+		state += ", paused"
+	}
+	header := lipgloss.JoinHorizontal(lipgloss.Top, append(stages, "  "+state)...)
+
+	top := lipgloss.JoinHorizontal(lipgloss.Top,
+		tuiPane.Render(m.memoryView()),
+		tuiPane.Render(m.registerView()),
+		tuiPane.Render(teachKeypad(c)),
+	)
+	bottom := lipgloss.JoinHorizontal(lipgloss.Top,
+		tuiPane.Render(teachDisplay(&c.ScreenState)),
+	)
+	if note, ok := m.notes[m.pc]; ok {
+		header = lipgloss.JoinVertical(lipgloss.Left, header, teachNoteStyle.Render(note))
+	}
+	help := "space pause/continue  n next stage  + faster  - slower  1-4 q-r a-f z-v keypad  esc quit"
+	return lipgloss.JoinVertical(lipgloss.Left, header, tuiPane.Render(m.explain()), top, bottom, help)
+}
+
+// memoryView shows the memory around the instruction, the fetched bytes highlighted and, after
+// executing, the bytes it wrote
+func (m *teachModel) memoryView() string {
+	mem := m.c.MainMemory
+	first := max(int(m.pc)&^7-8*(teachMemoryRows/2-1), 0)
+	first = min(first, len(mem)-8*teachMemoryRows)
+
+	var b strings.Builder
+	b.WriteString("memory\n")
+	for row := 0; row < teachMemoryRows; row++ {
+		addr := first + 8*row
+		fmt.Fprintf(&b, "%03X ", addr)
+		for i := addr; i < addr+8; i++ {
+			cell := fmt.Sprintf("%02X", mem[i])
+			switch {
+			case m.stage != teachExecute && (i == int(m.pc) || i == int(m.pc)+1):
+				cell = teachFetchedStyle.Render(cell)
+			case slices.Contains(m.changed, uint16(i)):
+				cell = teachChangedStyle.Render(cell)
+			}
+			b.WriteString(" " + cell)
+		}
+		if row < teachMemoryRows-1 {
+			b.WriteByte('\n')
+		}
+	}
+	return b.String()
+}
+
+// registerView lists the registers, after executing each changed one shown as it was and is
+func (m *teachModel) registerView() string {
+	b, a := m.before, captureTeachRegs(m.c)
+	show := m.stage == teachExecute
+	reg := func(name string, before, after, digits int) string {
+		s := fmt.Sprintf("%-2s %0*X", name, digits, after)
+		if show && before != after {
+			return teachChangedStyle.Render(fmt.Sprintf("%-2s %0*X→%0*X", name, digits, before, digits, after))
+		}
+		return s + strings.Repeat(" ", digits+1)
+	}
+
+	var s strings.Builder
+	s.WriteString("registers\n")
+	for i := 0; i < 8; i++ {
+		fmt.Fprintf(&s, "%s  %s\n", reg(fmt.Sprintf("V%X", i), int(b.Vx[i]), int(a.Vx[i]), 2),
+			reg(fmt.Sprintf("V%X", i+8), int(b.Vx[i+8]), int(a.Vx[i+8]), 2))
+	}
+	fmt.Fprintf(&s, "%s  %s\n", reg("PC", int(b.PC), int(a.PC), 3), reg("I", int(b.I), int(a.I), 3))
+	fmt.Fprintf(&s, "%s  %s  %s", reg("SP", int(b.SP), int(a.SP), 1), reg("DT", int(b.DT), int(a.DT), 2), reg("ST", int(b.ST), int(a.ST), 2))
+	return s.String()
+}
+
+// teachKeypad shows the keypad with the keyboard key for each, held keys highlighted
+func teachKeypad(c *Chip8) string {
+	keyboard := map[uint8]string{}
+	for k, key := range teachKeys {
+		keyboard[key] = k
+	}
+	var b strings.Builder
+	b.WriteString("keypad (key)\n")
+	for _, row := range keypadLayout {
+		for _, key := range row {
+			cell := fmt.Sprintf("%X(%s)", key, keyboard[key])
+			if c.KeyPressed[key] {
+				cell = teachFetchedStyle.Render(cell)
+			}
+			b.WriteString(cell + " ")
+		}
+		b.WriteByte('\n')
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// teachDisplay draws the display two rows to a line of half blocks
+func teachDisplay(s *screenPlane) string {
+	var b strings.Builder
+	for y := 0; y < ScreenHeight; y += 2 {
+		for x := 0; x < ScreenWidth; x++ {
+			switch top, bottom := s.pixel(x, y), s.pixel(x, y+1); {
+			case top && bottom:
+				b.WriteString("█")
+			case top:
+				b.WriteString("▀")
+			case bottom:
+				b.WriteString("▄")
+			default:
+				b.WriteByte(' ')
+			}
+		}
+		if y < ScreenHeight-2 {
+			b.WriteByte('\n')
+		}
+	}
+	return b.String()
+}
+
+// teachCommand implements "chip8 teach rom.ch8", and "chip8 teach" for the bundled tutorial
+func teachCommand(args []string) error {
+	fs := newFlagSet("teach")
+	delay := fs.Duration("delay", time.Second, "how long each stage of an instruction is shown")
+	variant := fs.String("variant", "", "interpreter variant, defaults to the one detected for the ROM")
+	notesFile := fs.String("notes", "", "captions for the ROM's instructions, defaults to rom.ch8.notes; see tutorial.go for the format")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		return usageError("teach")
+	}
+
+	c := newMachine()
+	c.LoadDefaultSprites()
+	var notes map[uint16]string
+	var err error
+	if fs.NArg() == 0 {
+		notes, err = c.loadTutorial()
+	} else if err = c.loadRomFile(fs.Arg(0)); err == nil {
+		err = c.LoadSidecar(fs.Arg(0))
+	}
+	if err != nil {
+		return err
+	}
+	switch {
+	case *notesFile != "":
+		var src []byte
+		if src, err = os.ReadFile(*notesFile); err == nil {
+			notes, err = parseNotes(*notesFile, string(src), c.Symbols)
+		}
+	case fs.NArg() == 1:
+		notes, err = loadNotes(fs.Arg(0), c.Symbols)
+	}
+	if err != nil {
+		return err
+	}
+	if *variant != "" {
+		v, err := ParseVariant(*variant)
+		if err != nil {
+			return err
+		}
+		c.SetVariant(v)
+	}
+
+	m := &teachModel{c: c, delay: max(*delay, 50*time.Millisecond), notes: notes}
+	_, err = tea.NewProgram(m, tea.WithAltScreen()).Run()
+	return err
+}
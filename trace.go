@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// traceInstruction writes one line describing the machine just before an instruction executes:
+// address, opcode, registers, then the disassembly and its symbolic location
+func (c *Chip8) traceInstruction(pc, opcode uint16) {
+	var line strings.Builder
+
+	fmt.Fprintf(&line, "%04X %04X V=%X I=%04X SP=%X DT=%02X ST=%02X | %s",
+		pc, opcode, c.Vx[:], c.I, c.SP, c.DT, c.ST, Mnemonic(opcode, c.Symbols))
+
+	if where := c.Symbols.Describe(pc); where != "" {
+		fmt.Fprintf(&line, " ; %s", where)
+	}
+	if c.Symbols != nil {
+		if src, ok := c.Symbols.Lines[pc]; ok {
+			fmt.Fprintf(&line, " (line %d)", src)
+		}
+	}
+
+	line.WriteByte('\n')
+	c.Trace.Write([]byte(line.String()))
+}
+
+// SetTracing starts or stops writing a line to Trace for every instruction executed
+func (c *Chip8) SetTracing(on bool) {
+	if on == (c.untrace != nil) {
+		return
+	}
+	if !on {
+		c.untrace()
+		c.untrace = nil
+		return
+	}
+	c.untrace = c.Events.Subscribe(EventInstruction, func(e Event) {
+		ev := e.(InstructionEvent)
+		c.traceInstruction(ev.PC, ev.Opcode)
+	})
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// transpiledRom is a ROM compiled ahead of time by "chip8 transpile" for one variant. Step runs
+// the instruction at PC when it was compiled, reporting false so the interpreter can take over
+// when it wasn't.
+type transpiledRom struct {
+	variant Variant
+	step    func(c *Chip8) bool
+	code    [0x10000]bool
+}
+
+// transpiledRoms holds the compiled ROMs built into this binary, keyed by SHA-1
+var transpiledRoms = map[string]*transpiledRom{}
+
+// registerTranspiled is called from the init function of generated files
+func registerTranspiled(sha1 string, v Variant, step func(c *Chip8) bool, code []uint16) {
+	t := &transpiledRom{variant: v, step: step}
+	for _, addr := range code {
+		t.code[addr] = true
+		if int(addr)+1 < len(t.code) {
+			t.code[addr+1] = true
+		}
+	}
+	transpiledRoms[sha1] = t
+}
+
+// covers reports whether a written byte belongs to compiled code
+func (t *transpiledRom) covers(addr uint16) bool {
+	return int(addr) < len(t.code) && t.code[addr]
+}
+
+// dropTranspiledAt falls back to the interpreter for good once a program overwrites its own
+// compiled code
+func (c *Chip8) dropTranspiledAt(addr uint16) {
+	if c.transpiled != nil && c.transpiled.covers(addr) {
+		c.transpiled = nil
+		log.Printf("code at %03X modified, leaving transpiled code for the interpreter", addr)
+	}
+}
+
+// writeTranspiled generates Go source running a ROM's reachable code as a switch over PC. Each
+// case is the instruction there compiled for variant v: loads, adds, jumps and skips become
+// plain Go with their operands and targets worked out, everything else a direct call to the
+// core's handler, skipping the fetch and decode. Quirks are still looked up at run time, so the
+// build behaves like the interpreter.
+func writeTranspiled(rom []byte, v Variant, source string) ([]byte, error) {
+	d := disassemble(rom, nil)
+	c := &Chip8{Variant: v}
+
+	addrs := make([]uint16, 0, len(d.code))
+	for addr := range d.code {
+		addrs = append(addrs, addr)
+	}
+	sort.Slice(addrs, func(i, j int) bool { return addrs[i] < addrs[j] })
+
+	hash := romSHA1(rom)
+	step := "transpiledStep" + strings.ToUpper(hash[:8])
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by chip8 transpile from %s; DO NOT EDIT.\n\n", filepath.Base(source))
+	b.WriteString("package main\n\n")
+	fmt.Fprintf(&b, "func init() {\n\tregisterTranspiled(%q, Variant(%d), %s, []uint16{ // %s", hash, v, step, v)
+	for i, addr := range addrs {
+		if i%12 == 0 {
+			b.WriteString("\n\t\t")
+		}
+		fmt.Fprintf(&b, "0x%03X, ", addr)
+	}
+	b.WriteString("\n\t})\n}\n\n")
+
+	fmt.Fprintf(&b, "func %s(c *Chip8) bool {\n\tswitch c.PC {\n", step)
+	for _, addr := range addrs {
+		opcode := d.word(addr)
+		fmt.Fprintf(&b, "\tcase 0x%03X: // %s\n", addr, Mnemonic(opcode, d.syms))
+		writeTranspiledInstruction(&b, c, d, addr, opcode)
+	}
+	b.WriteString("\tdefault:\n\t\treturn false\n\t}\n\treturn true\n}\n")
+
+	return format.Source(b.Bytes())
+}
+
+// writeTranspiledInstruction writes the body of the case running the instruction at addr, which
+// c decodes for its variant
+func writeTranspiledInstruction(b *bytes.Buffer, c *Chip8, d *disassembly, addr, opcode uint16) {
+	op := c.decode(opcode)
+	x, y := (opcode&0x0F00)>>8, (opcode&0x00F0)>>4
+	nn, nnn := opcode&0x00FF, opcode&0x0FFF
+
+	next := addr + 2
+	if op == opcodeF000 {
+		next += 2
+	}
+	// a skip passes over the whole of the next instruction, the targets are both compiled code so
+	// a program changing them drops the compiled code before it can run stale
+	skip := next + 2
+	if c.decode(d.word(next)) == opcodeF000 {
+		skip += 2
+	}
+	skipIf := func(cond string) {
+		fmt.Fprintf(b, "\t\tc.PC = 0x%03X\n\t\tif %s {\n\t\t\tc.PC = 0x%03X\n\t\t}\n", next, cond, skip)
+	}
+
+	switch op {
+	case opcode1NNN:
+		fmt.Fprintf(b, "\t\tc.PC = 0x%03X\n", nnn)
+		return
+	case opcode3XNN:
+		skipIf(fmt.Sprintf("c.Vx[0x%X] == 0x%02X", x, nn))
+		return
+	case opcode4XNN:
+		skipIf(fmt.Sprintf("c.Vx[0x%X] != 0x%02X", x, nn))
+		return
+	case opcode5XY0:
+		skipIf(fmt.Sprintf("c.Vx[0x%X] == c.Vx[0x%X]", x, y))
+		return
+	case opcode9XY0:
+		skipIf(fmt.Sprintf("c.Vx[0x%X] != c.Vx[0x%X]", x, y))
+		return
+	}
+
+	fmt.Fprintf(b, "\t\tc.PC = 0x%03X\n", addr+2)
+	switch op {
+	case opcode00E0:
+		b.WriteString("\t\tc.eraseScreen()\n")
+	case opcode00EE:
+		b.WriteString("\t\tc.exitSubroutine()\n")
+	case opcodeF000:
+		b.WriteString("\t\tc.setILong()\n")
+	case opcode6XNN:
+		fmt.Fprintf(b, "\t\tc.Vx[0x%X] = 0x%02X\n", x, nn)
+	case opcode7XNN:
+		fmt.Fprintf(b, "\t\tc.Vx[0x%X] += 0x%02X\n", x, nn)
+	case opcode8XY0:
+		fmt.Fprintf(b, "\t\tc.Vx[0x%X] = c.Vx[0x%X]\n", x, y)
+	case opcodeANNN:
+		fmt.Fprintf(b, "\t\tc.I = 0x%03X\n", nnn)
+	case opcodeFX07:
+		fmt.Fprintf(b, "\t\tc.Vx[0x%X] = c.DT\n", x)
+	case opcodeFX15:
+		fmt.Fprintf(b, "\t\tc.DT = c.Vx[0x%X]\n", x)
+	case opcodeFX18:
+		fmt.Fprintf(b, "\t\tc.ST = c.Vx[0x%X]\n", x)
+	default:
+		fmt.Fprintf(b, "\t\tc.%s(0x%04X)\n", opcodeHandlers[op], opcode)
+	}
+}
+
+// transpileCommand implements "chip8 transpile rom.ch8 -o rom_gen.go"
+func transpileCommand(args []string) error {
+	fs := newFlagSet("transpile")
+	out := fs.String("o", "", "Go file to write, defaults to the ROM name with a _gen.go suffix")
+	variant := fs.String("variant", "", "interpreter variant to compile for, defaults to the one detected for the ROM")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return usageError("transpile")
+	}
+
+	img, err := readRomFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	c := newMachine()
+	c.configureForImage(img)
+	if *variant != "" {
+		v, err := ParseVariant(*variant)
+		if err != nil {
+			return err
+		}
+		c.SetVariant(v)
+	}
+
+	src, err := writeTranspiled(img.Data, c.Variant, img.Name)
+	if err != nil {
+		return err
+	}
+
+	if *out == "" {
+		base := strings.TrimSuffix(filepath.Base(img.Name), filepath.Ext(img.Name))
+		*out = strings.NewReplacer("-", "_", " ", "_", ".", "_").Replace(base) + "_gen.go"
+	}
+	if err := os.WriteFile(*out, src, 0o644); err != nil {
+		return err
+	}
+
+	fmt.Printf("wrote %s; copy it next to main.go and rebuild to run %s from compiled code\n", *out, filepath.Base(img.Name))
+	return nil
+}
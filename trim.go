@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// trimRom returns the ROM with trailing pad bytes removed. Memory past the end of a ROM is
+// zeroed when it is loaded, so trailing zeros can always go; any other pad value is only safe
+// to strip if the program never reads it.
+func trimRom(rom []byte, pad byte) []byte {
+	end := len(rom)
+	for end > 0 && (rom[end-1] == 0 || rom[end-1] == pad) {
+		end--
+	}
+	return rom[:end]
+}
+
+// unreachableTail reports where the bytes nothing in the ROM reaches start: after the last
+// reachable instruction, and after dataLen bytes from the last address I is pointed at, since
+// how far data runs from there cannot be told. Computed jumps into tables the analyzer cannot
+// follow make the tail unknowable, which is reported as the reason nothing can be cut.
+func unreachableTail(rom []byte, dataLen int) (int, string) {
+	d := disassemble(rom, nil)
+	end := 0
+	for addr := range d.code {
+		if opcode := d.word(addr); opcode&0xF000 == 0xB000 {
+			return len(rom), fmt.Sprintf("computed jump at 0x%03X", addr)
+		}
+		end = max(end, int(addr-RamGameStart)+int(instructionSize(d.word(addr))))
+	}
+	for addr := range d.dataRefs {
+		end = max(end, int(addr)-int(RamGameStart)+dataLen)
+	}
+	return min(end, len(rom)), ""
+}
+
+// trimCommand implements "chip8 trim rom.ch8 [out.ch8]"
+func trimCommand(args []string) error {
+	fs := newFlagSet("trim")
+	padFlag := fs.String("pad", "0x00", "byte value of the padding, as well as the zeros that are always stripped")
+	unreachable := fs.Bool("unreachable", false, "also cut data and code at the end of the ROM that the analyzer finds nothing reaches")
+	dataLen := fs.Int("data-len", 32, "with -unreachable, how many bytes of data to keep after the last address I is set to")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() < 1 || fs.NArg() > 2 {
+		return usageError("trim")
+	}
+	pad, err := strconv.ParseUint(*padFlag, 0, 8)
+	if err != nil {
+		return fmt.Errorf("-pad: %w", err)
+	}
+
+	img, err := readRomFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	rom := trimRom(img.Data, byte(pad))
+	fmt.Printf("padding:      %d bytes\n", len(img.Data)-len(rom))
+
+	if *unreachable {
+		end, reason := unreachableTail(rom, *dataLen)
+		if reason != "" {
+			fmt.Printf("unreachable:  not cut, %s\n", reason)
+		} else {
+			fmt.Printf("unreachable:  %d bytes\n", len(rom)-end)
+			// the cut may leave padding that was hidden behind the data
+			rom = trimRom(rom[:end], byte(pad))
+		}
+	}
+
+	delta := len(rom) - len(img.Data)
+	space := romSpace(img.Name, img.Data)
+	fmt.Printf("size:         %d -> %d bytes (%+d), %d free of %d\n", len(img.Data), len(rom), delta, space-len(rom), space)
+
+	if fs.NArg() < 2 {
+		return nil
+	}
+	return os.WriteFile(fs.Arg(1), rom, 0o644)
+}
@@ -0,0 +1,369 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"unicode/utf8"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// The terminal UI runs on its own goroutine alongside the window. It never touches the machine:
+// it draws the debugView copies published each frame and drives the emulator by sending the same
+// text commands the plain debugger reads, so everything it does is also available by typing.
+
+const (
+	tuiListingRows = 18
+	tuiMemoryRows  = 8
+	tuiLogRows     = 6
+)
+
+var tuiPane = lipgloss.NewStyle().Border(lipgloss.NormalBorder()).Padding(0, 1)
+
+type tuiViewMsg debugView
+
+type tuiLogMsg string
+
+// tuiLog forwards debugger output and log lines to the UI's log pane
+type tuiLog struct {
+	lines chan string
+}
+
+func (l tuiLog) Write(p []byte) (int, error) {
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		l.lines <- line
+	}
+	return len(p), nil
+}
+
+type tuiModel struct {
+	debugger *Debugger
+	lines    chan string
+
+	view   debugView
+	loaded bool
+	log    []string
+
+	// first address of the listing, centred on PC unless the user has moved the cursor away
+	top    uint16
+	cursor uint16
+	follow bool
+
+	// rows is the address on each line of the listing as last drawn and listingWidth its width
+	// with the border, for finding the line a click is on
+	rows         []uint16
+	listingWidth int
+
+	// hex is the memory pane, which has the keys while editing
+	hex     tuiHex
+	editing bool
+
+	// command being typed after : or w, sent to the debugger on enter
+	prompt    string
+	prompting bool
+}
+
+// NewTUIDebugger starts a debugger driven by a full screen terminal UI on in/out. Log output is
+// redirected into the UI while it runs.
+func NewTUIDebugger(in io.Reader, out io.Writer) *Debugger {
+	lines := make(chan string, 256)
+	d := newDebugger(tuiLog{lines})
+	d.views = make(chan debugView, 1)
+	log.SetOutput(d.out)
+
+	m := &tuiModel{debugger: d, lines: lines, follow: true, hex: tuiHex{follow: true}}
+	go func() {
+		_, err := tea.NewProgram(m, tea.WithInput(in), tea.WithOutput(out), tea.WithAltScreen(), tea.WithMouseCellMotion()).Run()
+		log.SetOutput(out)
+		if err != nil {
+			log.Printf("debugger UI: %v", err)
+		}
+		d.commands <- "quit"
+	}()
+
+	return d
+}
+
+func (m *tuiModel) waitView() tea.Msg {
+	return tuiViewMsg(<-m.debugger.views)
+}
+
+func (m *tuiModel) waitLog() tea.Msg {
+	return tuiLogMsg(<-m.lines)
+}
+
+func (m *tuiModel) Init() tea.Cmd {
+	return tea.Batch(m.waitView, m.waitLog)
+}
+
+func (m *tuiModel) send(command string) {
+	m.debugger.commands <- command
+}
+
+func (m *tuiModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tuiViewMsg:
+		m.view, m.loaded = debugView(msg), true
+		if m.follow {
+			m.followPC()
+		} else {
+			m.scrollToCursor()
+		}
+		m.hex.viewed(&m.view)
+		return m, m.waitView
+
+	case tea.MouseMsg:
+		if m.loaded && !m.prompting {
+			m.click(tea.MouseEvent(msg))
+		}
+		return m, nil
+
+	case tuiLogMsg:
+		m.log = append(m.log, string(msg))
+		if len(m.log) > tuiLogRows {
+			m.log = m.log[len(m.log)-tuiLogRows:]
+		}
+		return m, m.waitLog
+
+	case tea.KeyMsg:
+		if !m.loaded {
+			if s := msg.String(); s == "q" || s == "ctrl+c" {
+				return m, tea.Quit
+			}
+			return m, nil
+		}
+		if m.prompting {
+			m.typePrompt(msg)
+			return m, nil
+		}
+		if msg.String() == "tab" {
+			m.editing, m.hex.typing = !m.editing, false
+			return m, nil
+		}
+		if m.editing && m.hex.key(m, msg) {
+			return m, nil
+		}
+		switch msg.String() {
+		case "q", "ctrl+c":
+			return m, tea.Quit
+		case ":":
+			m.prompting, m.prompt = true, ""
+		case "w":
+			m.prompting, m.prompt = true, "watch "
+		case " ":
+			if m.view.Paused {
+				m.send("continue")
+			} else {
+				m.send("pause")
+			}
+		case "s", "n":
+			m.send("step")
+		case "enter", "r":
+			m.send(fmt.Sprintf("until %x", m.cursor))
+		case "b":
+			if m.view.Breakpoints[m.cursor] {
+				m.send(fmt.Sprintf("delete %x", m.cursor))
+			} else {
+				m.send(fmt.Sprintf("break %x", m.cursor))
+			}
+		case "up", "k":
+			m.moveCursor(-2)
+		case "down", "j":
+			m.moveCursor(2)
+		case "pgup":
+			m.moveCursor(-2 * tuiListingRows)
+		case "pgdown":
+			m.moveCursor(2 * tuiListingRows)
+		case "f":
+			m.follow = true
+			m.followPC()
+		case "d":
+			m.send("dump")
+		}
+	}
+	return m, nil
+}
+
+// typePrompt edits the command line, sending it on enter
+func (m *tuiModel) typePrompt(msg tea.KeyMsg) {
+	switch msg.Type {
+	case tea.KeyEnter:
+		m.send(m.prompt)
+		m.prompting = false
+	case tea.KeyEsc, tea.KeyCtrlC:
+		m.prompting = false
+	case tea.KeyBackspace:
+		_, size := utf8.DecodeLastRuneInString(m.prompt)
+		m.prompt = m.prompt[:len(m.prompt)-size]
+	case tea.KeyRunes, tea.KeySpace:
+		m.prompt += string(msg.Runes)
+	}
+}
+
+func (m *tuiModel) moveCursor(delta int) {
+	addr := int(m.cursor) + delta
+	addr = max(0, min(addr, len(m.view.Memory)-2))
+	m.cursor, m.follow = uint16(addr), false
+	m.scrollToCursor()
+}
+
+// click selects the listing line under the mouse, running to it if it was already selected,
+// and scrolls the listing with the wheel
+func (m *tuiModel) click(e tea.MouseEvent) {
+	switch {
+	case e.Button == tea.MouseButtonWheelUp:
+		m.moveCursor(-2)
+	case e.Button == tea.MouseButtonWheelDown:
+		m.moveCursor(2)
+	case e.Button == tea.MouseButtonLeft && e.Action == tea.MouseActionPress:
+		// the listing's top border is the first line of the screen
+		row := e.Y - 1
+		if row < 0 || row >= len(m.rows) || e.X >= m.listingWidth {
+			return
+		}
+		if m.rows[row] == m.cursor {
+			m.send(fmt.Sprintf("until %x", m.cursor))
+			return
+		}
+		m.cursor, m.follow = m.rows[row], false
+	}
+}
+
+// followPC puts the cursor on PC and the listing around it
+func (m *tuiModel) followPC() {
+	m.cursor = m.view.PC
+	m.top = m.cursor - min(m.cursor, tuiListingRows)
+}
+
+func (m *tuiModel) scrollToCursor() {
+	if m.cursor < m.top || int(m.cursor) >= int(m.top)+2*tuiListingRows {
+		// keep a few instructions of context above the cursor
+		m.top = uint16(max(0, int(m.cursor)-6))
+	}
+}
+
+// tuiKeypad draws the keypad as the program sees it, [held] keys and (released) ones, which
+// are what FX0A waits for, with the timers underneath
+func tuiKeypad(v *debugView) string {
+	var b strings.Builder
+	b.WriteString("keypad\n")
+	for _, row := range keypadLayout {
+		for _, key := range row {
+			switch {
+			case v.KeyPressed[key]:
+				fmt.Fprintf(&b, "[%X]", key)
+			case v.KeyJustReleased[key]:
+				fmt.Fprintf(&b, "(%X)", key)
+			default:
+				fmt.Fprintf(&b, " %X ", key)
+			}
+		}
+		b.WriteByte('\n')
+	}
+
+	hz := float64(v.Variant.timerHz())
+	fmt.Fprintf(&b, "\nDT %02X %5.2fs\nST %02X %5.2fs", v.DT, float64(v.DT)/hz, v.ST, float64(v.ST)/hz)
+	if int(v.PC)+1 >= len(v.Memory) {
+		return b.String()
+	}
+	if opcode := uint16(v.Memory[v.PC])<<8 | uint16(v.Memory[v.PC+1]); opcode&0xF0FF == 0xF00A {
+		fmt.Fprintf(&b, "\nFX0A waiting, V%X", opcode>>8&0xF)
+	}
+	return b.String()
+}
+
+func (m *tuiModel) View() string {
+	if !m.loaded {
+		return "waiting for the emulator...\n"
+	}
+	v := &m.view
+
+	var listing strings.Builder
+	m.rows = m.rows[:0]
+	for addr := m.top; len(m.rows) < tuiListingRows && int(addr)+1 < len(v.Memory); addr += 2 {
+		opcode := uint16(v.Memory[addr])<<8 | uint16(v.Memory[addr+1])
+
+		marker := "  "
+		switch {
+		case addr == v.PC && v.Breakpoints[addr]:
+			marker = "*>"
+		case addr == v.PC:
+			marker = " >"
+		case v.Breakpoints[addr]:
+			marker = "* "
+		}
+		cursor := " "
+		if addr == m.cursor {
+			cursor = "|"
+		}
+		if v.Coverage != nil {
+			// with -coverage, instructions that have run are marked +
+			if v.Coverage.has(addr) {
+				cursor += "+"
+			} else {
+				cursor += " "
+			}
+		}
+		if label, ok := v.Symbols.LabelAt(addr); ok {
+			fmt.Fprintf(&listing, "   %s:\n", label)
+			m.rows = append(m.rows, addr)
+		}
+		m.rows = append(m.rows, addr)
+		fmt.Fprintf(&listing, "%s%s%03X  %04X  %s\n", cursor, marker, addr, opcode, Mnemonic(opcode, v.Symbols))
+	}
+
+	var regs strings.Builder
+	state := "running"
+	if v.Paused {
+		state = "paused"
+	}
+	fmt.Fprintf(&regs, "%s, frame %d\n\n", state, v.Frame)
+	fmt.Fprintf(&regs, "PC %04X  I %04X\nDT %02X    ST %02X\n\n", v.PC, v.I, v.DT, v.ST)
+	for i := 0; i < 8; i++ {
+		fmt.Fprintf(&regs, "V%X %02X    V%X %02X\n", i, v.Vx[i], i+8, v.Vx[i+8])
+	}
+	fmt.Fprintf(&regs, "\nstack (SP %X)\n", v.SP)
+	for i := int(v.SP) - 1; i >= 0; i-- {
+		fmt.Fprintf(&regs, "  %04X %s\n", v.Stack[i], v.Symbols.Describe(v.Stack[i]))
+	}
+
+	var bps strings.Builder
+	bps.WriteString("breakpoints\n")
+	for addr := 0; addr < len(v.Memory); addr++ {
+		if v.Breakpoints[uint16(addr)] {
+			fmt.Fprintf(&bps, "  %03X %s\n", addr, v.Symbols.Describe(uint16(addr)))
+		}
+	}
+
+	var watches strings.Builder
+	watches.WriteString("watches\n")
+	for i, w := range v.Watches {
+		fmt.Fprintf(&watches, "  %d: %s\n", i+1, w)
+	}
+
+	listingPane := tuiPane.Render(strings.TrimRight(listing.String(), "\n"))
+	m.listingWidth = lipgloss.Width(listingPane)
+	top := lipgloss.JoinHorizontal(lipgloss.Top,
+		listingPane,
+		tuiPane.Render(strings.TrimRight(regs.String(), "\n")),
+		tuiPane.Render(strings.TrimRight(bps.String(), "\n")),
+	)
+	bottom := lipgloss.JoinHorizontal(lipgloss.Top,
+		tuiPane.Render(strings.TrimRight(m.hex.render(v, m.editing), "\n")),
+		tuiPane.Render(tuiKeypad(v)),
+		tuiPane.Render(strings.TrimRight(watches.String(), "\n")),
+	)
+	logPane := tuiPane.Render(strings.Join(m.log, "\n") + strings.Repeat("\n", tuiLogRows-len(m.log)))
+	help := "space pause/continue  s step  b breakpoint  up/down/click move  enter run to cursor  f follow PC  tab edit memory  w watch  : command  d dump  q quit"
+	if m.editing {
+		help = "hex digits write  arrows move  v mark  x fill  y copy  p paste  i follow I  g PC  tab done"
+	}
+	if m.prompting {
+		help = ": " + m.prompt + "_"
+	}
+
+	return lipgloss.JoinVertical(lipgloss.Left, top, bottom, logPane, help)
+}
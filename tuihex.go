@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Colours of the hex editor: the font, the ROM as loaded, the bytes at I and PC and the cursor.
+// Anything else, memory the program has not been given, is left plain.
+var (
+	tuiFontStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("6"))
+	tuiRomStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("2"))
+	tuiIStyle      = lipgloss.NewStyle().Foreground(lipgloss.Color("3")).Bold(true)
+	tuiPCStyle     = lipgloss.NewStyle().Foreground(lipgloss.Color("1")).Bold(true)
+	tuiCursorStyle = lipgloss.NewStyle().Reverse(true)
+)
+
+// tuiHex is the memory pane, which tab turns into a hex editor. Like the rest of the UI it
+// edits by sending monitor commands, w, f and t, so every change shows in the log.
+type tuiHex struct {
+	// byte being edited and the first row shown; the cursor follows I until moved
+	cursor uint16
+	top    uint16
+	follow bool
+
+	// high is the first digit of a byte being typed, while typing
+	typing bool
+	high   byte
+
+	// the other end of the selection from the cursor, while marked
+	marked bool
+	mark   uint16
+
+	// range copied with y, end not included, for p to paste
+	copied             bool
+	copyStart, copyEnd uint16
+}
+
+// viewed moves the cursor along with I if it is following it
+func (h *tuiHex) viewed(v *debugView) {
+	if h.follow && int(v.I) < len(v.Memory) {
+		h.cursor = v.I
+		h.top = v.I &^ 0xF
+	}
+}
+
+// selection is the marked range, or the byte under the cursor, end not included
+func (h *tuiHex) selection() (uint16, uint16) {
+	if !h.marked {
+		return h.cursor, h.cursor + 1
+	}
+	return min(h.mark, h.cursor), max(h.mark, h.cursor) + 1
+}
+
+func (h *tuiHex) move(delta int, size int) {
+	addr := max(0, min(int(h.cursor)+delta, size-1))
+	h.cursor, h.follow, h.typing = uint16(addr), false, false
+	if h.cursor < h.top {
+		h.top = h.cursor &^ 0xF
+	} else if int(h.cursor) >= int(h.top)+16*tuiMemoryRows {
+		h.top = (h.cursor &^ 0xF) - 16*(tuiMemoryRows-1)
+	}
+}
+
+// key handles a key pressed while editing, reporting false for the ones it leaves to the rest
+// of the UI
+func (h *tuiHex) key(m *tuiModel, msg tea.KeyMsg) bool {
+	size := len(m.view.Memory)
+	if msg.Type == tea.KeyRunes && len(msg.Runes) == 1 {
+		if digit, err := strconv.ParseUint(string(msg.Runes), 16, 4); err == nil {
+			if !h.typing {
+				h.typing, h.high = true, byte(digit)
+				return true
+			}
+			m.send(fmt.Sprintf("w %x %02x", h.cursor, h.high<<4|byte(digit)))
+			h.move(1, size)
+			return true
+		}
+	}
+
+	switch msg.String() {
+	case "left", "h":
+		h.move(-1, size)
+	case "right", "l":
+		h.move(1, size)
+	case "up", "k":
+		h.move(-16, size)
+	case "down", "j":
+		h.move(16, size)
+	case "pgup":
+		h.move(-16*tuiMemoryRows, size)
+	case "pgdown":
+		h.move(16*tuiMemoryRows, size)
+	case "i":
+		h.follow, h.typing = true, false
+		h.viewed(&m.view)
+	case "g":
+		h.move(int(m.view.PC)-int(h.cursor), size)
+	case "v":
+		h.marked, h.mark = !h.marked, h.cursor
+	case "x":
+		start, end := h.selection()
+		m.prompting, m.prompt = true, fmt.Sprintf("f %x %x ", start, end)
+		h.marked = false
+	case "y":
+		h.copyStart, h.copyEnd = h.selection()
+		h.copied, h.marked = true, false
+	case "p":
+		if h.copied {
+			m.send(fmt.Sprintf("t %x %x %x", h.copyStart, h.copyEnd, h.cursor))
+		}
+	case "esc":
+		h.typing, h.marked = false, false
+	default:
+		return false
+	}
+	return true
+}
+
+// render draws tuiMemoryRows rows of 16 bytes from top
+func (h *tuiHex) render(v *debugView, editing bool) string {
+	var b strings.Builder
+	if editing {
+		b.WriteString("memory, editing\n")
+	} else {
+		b.WriteString("memory at I, tab to edit\n")
+	}
+	start, end := h.selection()
+	for row := 0; row < tuiMemoryRows; row++ {
+		addr := int(h.top) + 16*row
+		if addr >= len(v.Memory) {
+			break
+		}
+		fmt.Fprintf(&b, "%03X ", addr)
+		for a := addr; a < addr+16 && a < len(v.Memory); a++ {
+			cell := fmt.Sprintf("%02X", v.Memory[a])
+			style := lipgloss.NewStyle()
+			switch {
+			case editing && a == int(h.cursor):
+				style = tuiCursorStyle
+				if h.typing {
+					cell = fmt.Sprintf("%X_", h.high)
+				}
+			case a == int(v.PC) || a == int(v.PC)+1:
+				style = tuiPCStyle
+			case a == int(v.I):
+				style = tuiIStyle
+			case a >= int(RamGameStart) && a < int(RamGameStart)+v.RomLen:
+				style = tuiRomStyle
+			case a < v.FontLen:
+				style = tuiFontStyle
+			}
+			if editing && h.marked && a >= int(start) && a < int(end) {
+				style = style.Underline(true)
+			}
+			b.WriteString(" " + style.Render(cell))
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+)
+
+// Annotation files hold captions for a ROM's instructions, which "chip8 teach" shows while each
+// runs. An unindented line names an instruction, by address, label or label+offset, and the
+// indented lines under it are its caption, a blank one starting a new paragraph:
+//
+//	# comments start with # or ;
+//	main
+//		Clear the screen before drawing anything.
+//	main+2
+//		Registers V0 and V1 will hold where the face is drawn.
+//
+// Labels come from the ROM's symbols, so annotating Octo source is a matter of adding labels
+// where captions go. The file for game.ch8 is game.ch8.notes, next to it or in the roms
+// directory of the user's settings like game.ch8.toml.
+
+// notesPath returns where the annotation file for a ROM lives
+func notesPath(romFile string) string {
+	return strings.TrimSuffix(sidecarPath(romFile), ".toml") + ".notes"
+}
+
+// loadNotes reads the annotations for a ROM, if it has any
+func loadNotes(romFile string, syms *SymbolTable) (map[uint16]string, error) {
+	notesFile, f, err := readSidecarFile(notesPath(romFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseNotes(notesFile, string(f), syms)
+}
+
+// parseNotes reads annotations in the format above from src, named name in errors
+func parseNotes(name, src string, syms *SymbolTable) (map[uint16]string, error) {
+	notes := map[uint16]string{}
+	var caption *strings.Builder
+	var addr uint16
+	flush := func() {
+		if caption != nil {
+			notes[addr] = strings.TrimSpace(caption.String())
+		}
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(src))
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		text := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(text, "#") || strings.HasPrefix(text, ";"):
+		case text == "":
+			if caption != nil && caption.Len() > 0 {
+				caption.WriteString("\n\n")
+			}
+		case line[0] == ' ' || line[0] == '\t':
+			if caption == nil {
+				return nil, fmt.Errorf("%s:%d: caption before any address", name, n)
+			}
+			if s := caption.String(); s != "" && !strings.HasSuffix(s, "\n") {
+				caption.WriteByte(' ')
+			}
+			caption.WriteString(text)
+		default:
+			flush()
+			var err error
+			if addr, err = syms.Resolve(text); err != nil {
+				return nil, fmt.Errorf("%s:%d: %w", name, n, err)
+			}
+			if _, ok := notes[addr]; ok {
+				return nil, fmt.Errorf("%s:%d: %s has a caption already", name, n, text)
+			}
+			caption = &strings.Builder{}
+		}
+	}
+	flush()
+	return notes, scanner.Err()
+}
+
+// tutorialSource is the ROM "chip8 teach" runs when given none: it draws a face and a digit,
+// then moves the face left and right with keys 4 and 6
+const tutorialSource = `
+: main
+	clear
+: place
+	v0 := 28
+	v1 := 12
+	draw-face
+: digit
+	v7 := 7
+	i := hex v7
+	v3 := 2
+	v4 := 2
+	sprite v3 v4 5
+: wait
+	v5 := key
+: erase
+	draw-face
+: left
+	if v5 == 4 then v0 += 252
+: right
+	if v5 == 6 then v0 += 4
+: redraw
+	draw-face
+	jump wait
+
+: draw-face
+	i := face
+	sprite v0 v1 8
+	return
+
+: face
+	0x3C 0x42 0xA5 0x81 0xA5 0x99 0x42 0x3C
+`
+
+// tutorialNotes are the captions for tutorialSource
+const tutorialNotes = `
+0x200
+	Welcome! The CHIP-8 starts running at address 200, where programs are loaded. This
+	program was written in Octo, whose programs begin with a jump to the code labelled main.
+
+	Watch PC, the program counter: it holds the address of the next instruction, and moves
+	on by two after each fetch because every instruction is two bytes long. Press n to move
+	on a stage yourself, space to pause.
+
+main
+	00E0 clears the display. The CHIP-8 has a single 64x32 display, each pixel on or off.
+
+place
+	The sixteen variable registers V0 to VF are the CPU's working memory. 6XNN puts a number
+	straight into one: here V0 is the face's x position, 28 pixels across.
+
+place+2
+	And V1 is the face's y position, 12 pixels down.
+
+place+4
+	2NNN calls a subroutine. The address of the next instruction is pushed on the stack, SP
+	counts up, and PC jumps to the subroutine at NNN.
+
+draw-face
+	ANNN points the index register I at memory, here at the face's sprite: eight bytes, one
+	per row, each bit a pixel.
+
+draw-face+2
+	DXYN draws N rows of the sprite at I, at the position in VX and VY. Pixels are flipped
+	rather than set, so drawing a sprite twice in the same place erases it. VF is set to 1
+	if a lit pixel was turned off, which is how games detect collisions.
+
+draw-face+4
+	00EE returns from the subroutine: PC is taken back off the stack, to the instruction
+	after the call.
+
+digit
+	Now a digit. Its value goes in V7...
+
+digit+2
+	...and FX29 points I at the built-in font sprite for the digit in VX. The font is kept
+	in memory below 200, five bytes a digit.
+
+digit+4
+	V3 and V4 are the digit's position.
+
+digit+8
+	This time the sprite is drawn directly, five rows of the font.
+
+wait
+	FX0A waits for a key. PC stays on this instruction until one of the sixteen keys is
+	pressed and released; the key's number then goes in V5. Press 4 or 6 (the q and e keys
+	on the keyboard) to move the face.
+
+erase
+	Drawing the face again where it is erases it, before it moves.
+
+left
+	Octo's if compiles to a skip: 4XNN skips the next instruction unless VX equals NN. If
+	key 4 was pressed the next instruction runs...
+
+left+2
+	...adding 252 to V0. Registers are 8 bits, so the sum wraps past 255: adding 252 is
+	the same as taking away 4.
+
+right
+	The same test for key 6, with 4XNN skipping the move unless it matches...
+
+right+2
+	...which takes the face 4 pixels right.
+
+redraw
+	The face is drawn at its new position.
+
+redraw+2
+	1NNN jumps back to wait for the next key, so the program loops forever.
+`
+
+// loadTutorial assembles the tutorial into a machine, returning its captions
+func (c *Chip8) loadTutorial() (map[uint16]string, error) {
+	rom, syms, err := assembleOcto(tutorialSource)
+	if err != nil {
+		return nil, fmt.Errorf("tutorial: %w", err)
+	}
+	c.LoadRom(rom)
+	c.Symbols = syms
+	return parseNotes("tutorial", tutorialNotes, syms)
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+)
+
+// Variant identifies the CHIP-8 dialect a ROM was written for
+type Variant uint8
+
+const (
+	VariantChip8 Variant = iota
+	VariantSChip
+	VariantXOChip
+	VariantChip8X
+	VariantDream6800
+)
+
+var variantNames = map[Variant]string{
+	VariantChip8:     "chip8",
+	VariantSChip:     "schip",
+	VariantXOChip:    "xo-chip",
+	VariantChip8X:    "chip8x",
+	VariantDream6800: "dream6800",
+}
+
+// variantExtensions maps ROM file extensions to the dialect they conventionally target
+var variantExtensions = map[string]Variant{
+	".ch8": VariantChip8,
+	".sc8": VariantSChip,
+	".xo8": VariantXOChip,
+	".c8x": VariantChip8X,
+	".8o":  VariantXOChip,
+}
+
+func (v Variant) String() string {
+	if name, ok := variantNames[v]; ok {
+		return name
+	}
+	return fmt.Sprintf("Variant(%d)", uint8(v))
+}
+
+// ParseVariant looks up a variant by name, e.g. "schip" or "xo-chip"
+func ParseVariant(name string) (Variant, error) {
+	normalized := strings.ReplaceAll(strings.ToLower(name), "-", "")
+	for v, n := range variantNames {
+		if strings.ReplaceAll(n, "-", "") == normalized {
+			return v, nil
+		}
+	}
+	return VariantChip8, fmt.Errorf("unknown variant %q", name)
+}
+
+// variantFromExtension picks the variant conventionally associated with a ROM file name
+func variantFromExtension(romFile string) (Variant, bool) {
+	v, ok := variantExtensions[strings.ToLower(filepath.Ext(romFile))]
+	return v, ok
+}
+
+// Quirks toggles the behaviours that differ between CHIP-8 interpreters
+type Quirks struct {
+	// VFReset clears VF after 8XY1, 8XY2 and 8XY3
+	VFReset bool `json:"vf_reset" toml:"vf_reset"`
+
+	// LoadStoreIncrementsI leaves I pointing past the last register touched by FX55/FX65
+	LoadStoreIncrementsI bool `json:"load_store_increments_i" toml:"load_store_increments_i"`
+
+	// ShiftUsesVy sets Vx to Vy before shifting in 8XY6/8XYE instead of shifting Vx in place
+	ShiftUsesVy bool `json:"shift_uses_vy" toml:"shift_uses_vy"`
+
+	// JumpUsesVx makes BNNN jump to XNN + Vx instead of NNN + V0
+	JumpUsesVx bool `json:"jump_uses_vx" toml:"jump_uses_vx"`
+
+	// SpritesWrap wraps sprites around the screen edges instead of clipping them
+	SpritesWrap bool `json:"sprites_wrap" toml:"sprites_wrap"`
+
+	// IOverflowSetsVF sets VF when FX1E takes I past the end of memory and clears it otherwise, as
+	// the Amiga interpreter did; Spacefight 2091! relies on it, other games break with it
+	IOverflowSetsVF bool `json:"i_overflow_sets_vf" toml:"i_overflow_sets_vf"`
+}
+
+// DefaultQuirks returns the quirk preset matching the original interpreter of a variant. Plain
+// CHIP-8 keeps every quirk off, as the interpreter behaved before quirks could be chosen, so
+// existing ROMs run as they always have; the VIP's behaviours are there to turn on by hand.
+func DefaultQuirks(v Variant) Quirks {
+	switch v {
+	case VariantSChip:
+		return Quirks{JumpUsesVx: true}
+	case VariantXOChip:
+		return Quirks{LoadStoreIncrementsI: true, ShiftUsesVy: true, SpritesWrap: true}
+	case VariantDream6800:
+		// CHIPOS leaves VF alone after logic ops and wraps sprites around the screen. Nothing
+		// to hand says what it does with I after FX55/FX65 or with VY in shifts, so those stay
+		// off as for plain CHIP-8.
+		return Quirks{SpritesWrap: true}
+	default:
+		return Quirks{}
+	}
+}
+
+// variantFonts names the font of the interpreters whose font differs from the usual one
+var variantFonts = map[Variant]string{
+	VariantDream6800: "dream6800",
+}
+
+// timerHz is how many times a second the delay and sound timers count down. The DREAM 6800 was
+// an Australian machine and took its timing from the 50 Hz PAL television it drew on.
+func (v Variant) timerHz() int {
+	if v == VariantDream6800 {
+		return 50
+	}
+	return FramesPerSecond
+}
+
+// memorySize is how much memory the variant addresses: 64KB for XO-CHIP, 4KB for the rest. Both
+// are powers of two, so data addresses wrap around by masking.
+func (v Variant) memorySize() int {
+	if v == VariantXOChip {
+		return 0x10000
+	}
+	return 0x1000
+}
+
+// fetchWraps reports whether fetching past the end of memory carries on from address 0, as on
+// the VIP, whose 4KB repeats through its address space, and in Octo's XO-CHIP. The others fault.
+func (v Variant) fetchWraps() bool {
+	switch v {
+	case VariantChip8, VariantChip8X, VariantXOChip:
+		return true
+	}
+	return false
+}
+
+// SetVariant switches the interpreter dialect and resets quirks to that dialect's defaults. A
+// variant with a font of its own loads it, unless a font was picked some other way.
+func (c *Chip8) SetVariant(v Variant) {
+	c.Variant = v
+	c.Quirks = DefaultQuirks(v)
+	// compiled code decoded the ROM for the variant it was built for
+	if c.transpiled != nil && c.transpiled.variant != v {
+		c.transpiled = nil
+	}
+	c.resizeMemory(v.memorySize())
+	// F000 only decodes as XO-CHIP's long load there
+	c.invalidateAllDecoded()
+	if room := len(c.MainMemory) - int(RamGameStart); len(c.rom) > room {
+		log.Printf("ROM is %d bytes, %s only has room for %d, the rest is cut off", len(c.rom), v, room)
+		c.Notify("ROM too big for %s", v)
+	}
+
+	if c.Font != nil && !c.variantFont {
+		return
+	}
+	if name, ok := variantFonts[v]; ok {
+		c.SetFont(fontSets[name])
+		c.variantFont = true
+	} else if c.variantFont {
+		c.SetFont(nil)
+		c.variantFont = false
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// vipInterpreterSize is the size of the COSMAC VIP CHIP-8 interpreter, which sat in the 512 bytes
+// below RamGameStart
+const vipInterpreterSize = 0x200
+
+// vipRandom follows the COSMAC VIP interpreter's random routine rather than math/rand, for ROMs
+// and test suites that expect its exact sequence. The VIP kept the state in register R9, which
+// the interrupt routine bumps every frame; CXNN adds the interpreter byte in page 1 that the low
+// half of R9 points at to the high half, and that is the random byte. The interpreter itself is
+// not distributed here, so its image has to be supplied.
+type vipRandom struct {
+	interpreter []byte
+	r9          uint16
+}
+
+// loadVIPRandom reads a dump of the VIP's CHIP-8 interpreter for its random routine
+func loadVIPRandom(file string) (*vipRandom, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) != vipInterpreterSize {
+		return nil, fmt.Errorf("%s: the VIP interpreter is %d bytes, file has %d", file, vipInterpreterSize, len(data))
+	}
+	return &vipRandom{interpreter: data}, nil
+}
+
+// seed sets R9, from the low 16 bits of a -seed value
+func (r *vipRandom) seed(seed int64) {
+	r.r9 = uint16(seed)
+}
+
+// frame is the interrupt routine's increment
+func (r *vipRandom) frame() {
+	r.r9++
+}
+
+// next is the byte CXNN masks with NN
+func (r *vipRandom) next() byte {
+	hi := byte(r.r9>>8) + r.interpreter[0x100|r.r9&0xFF]
+	r.r9 = uint16(hi)<<8 | r.r9&0xFF
+	return hi
+}
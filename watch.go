@@ -0,0 +1,98 @@
+package main
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// watchInterval is how often a watched source file is checked for changes
+const watchInterval = 500 * time.Millisecond
+
+// sourceWatcher notices when a ROM's source file is rewritten on disk
+type sourceWatcher struct {
+	path      string
+	modTime   time.Time
+	lastCheck time.Time
+}
+
+func newSourceWatcher(path string) *sourceWatcher {
+	w := &sourceWatcher{path: path}
+	if info, err := os.Stat(path); err == nil {
+		w.modTime = info.ModTime()
+	}
+	return w
+}
+
+// Changed reports whether the file was modified since the last call, checking at most once per
+// watchInterval so it is cheap to call every frame
+func (w *sourceWatcher) Changed() bool {
+	if time.Since(w.lastCheck) < watchInterval {
+		return false
+	}
+	w.lastCheck = time.Now()
+
+	info, err := os.Stat(w.path)
+	if err != nil || !info.ModTime().After(w.modTime) {
+		return false
+	}
+
+	w.modTime = info.ModTime()
+	return true
+}
+
+// ReloadRomFile recompiles a ROM from disk and restarts it, keeping the current variant, quirks
+// and other settings. A ROM that fails to load is reported and the running program left alone.
+func (c *Chip8) ReloadRomFile(romFile string) {
+	img, err := readRomFile(romFile)
+	if err != nil {
+		log.Printf("reload failed: %v", err)
+		return
+	}
+
+	c.Reset()
+	c.LoadRom(img.Data)
+	if img.Symbols != nil {
+		c.remapBreakpoints(img)
+		c.Symbols = img.Symbols
+	}
+	log.Printf("%s: reloaded (%d bytes)", romFile, len(img.Data))
+	c.Notify("Reloaded %s", filepath.Base(romFile))
+}
+
+// remapBreakpoints moves the breakpoints, and the actions and hit counts that go with them, to
+// where the reloaded source put their code. One on a label follows the label; one on a label
+// that is gone, or left inside an instruction by the edit, is dropped.
+func (c *Chip8) remapBreakpoints(img *romImage) {
+	d := c.Debugger
+	if d == nil || len(d.Breakpoints) == 0 {
+		return
+	}
+	listing := disassemble(img.Data, img.Symbols)
+
+	breakpoints := make(map[uint16]bool, len(d.Breakpoints))
+	actions := make(map[uint16][]breakAction, len(d.Actions))
+	hits := make(map[uint16]int, len(d.hits))
+	for addr := range d.Breakpoints {
+		to := addr
+		if name, ok := c.Symbols.LabelAt(addr); ok {
+			if to, ok = img.Symbols.Labels[name]; !ok {
+				log.Printf("breakpoint at %s dropped, the label is gone", name)
+				continue
+			}
+		}
+		if listing.midInstruction(to) {
+			log.Printf("breakpoint at %03X dropped, it is now inside an instruction", to)
+			continue
+		}
+		breakpoints[to] = true
+		if a, ok := d.Actions[addr]; ok {
+			actions[to] = a
+		}
+		if n, ok := d.hits[addr]; ok {
+			hits[to] = n
+		}
+	}
+	d.Breakpoints, d.Actions, d.hits = breakpoints, actions, hits
+}
@@ -0,0 +1,387 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Watch expressions are evaluated against the machine after every step and frame the debugger
+// shows. They are integer arithmetic over numbers (decimal, or hex with 0x or $), labels, the
+// registers V0-VF, I, PC, SP, DT and ST, and bytes of memory written [addr], with Go's operators
+// and precedence: * / % << >> & then + - | ^ then == != < <= > >= then && then ||, unary -, ~
+// and !, and parentheses. Comparisons and logic give 1 for true and 0 for false, so an
+// expression can also serve as a condition, true when non-zero.
+
+// watchExpr is a parsed watch expression
+type watchExpr struct {
+	src  string
+	root exprNode
+}
+
+type exprNode interface {
+	eval(c *Chip8) (int, error)
+}
+
+type exprNum int
+
+// exprReg is a register: 0-15 for V0-VF, or one of the named ones below
+type exprReg int
+
+const (
+	regI exprReg = 16 + iota
+	regPC
+	regSP
+	regDT
+	regST
+)
+
+var exprRegNames = map[string]exprReg{"I": regI, "PC": regPC, "SP": regSP, "DT": regDT, "ST": regST}
+
+// exprMem is the byte of memory at an address
+type exprMem struct{ addr exprNode }
+
+type exprUnary struct {
+	op string
+	x  exprNode
+}
+
+type exprBinary struct {
+	op   string
+	l, r exprNode
+}
+
+func (n exprNum) eval(c *Chip8) (int, error) { return int(n), nil }
+
+func (r exprReg) eval(c *Chip8) (int, error) {
+	switch r {
+	case regI:
+		return int(c.I), nil
+	case regPC:
+		return int(c.PC), nil
+	case regSP:
+		return int(c.SP), nil
+	case regDT:
+		return int(c.DT), nil
+	case regST:
+		return int(c.ST), nil
+	}
+	return int(c.Vx[r]), nil
+}
+
+func (m exprMem) eval(c *Chip8) (int, error) {
+	addr, err := m.addr.eval(c)
+	if err != nil {
+		return 0, err
+	}
+	if addr < 0 || addr >= len(c.MainMemory) {
+		return 0, fmt.Errorf("[%X] is outside memory", addr)
+	}
+	return int(c.MainMemory[addr]), nil
+}
+
+func (u exprUnary) eval(c *Chip8) (int, error) {
+	x, err := u.x.eval(c)
+	switch u.op {
+	case "-":
+		return -x, err
+	case "!":
+		return exprBool(x == 0), err
+	}
+	return ^x, err
+}
+
+func exprBool(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func (b exprBinary) eval(c *Chip8) (int, error) {
+	l, err := b.l.eval(c)
+	if err != nil {
+		return 0, err
+	}
+	// the right of && and || is only evaluated when it decides the result, as in Go
+	if b.op == "&&" && l == 0 || b.op == "||" && l != 0 {
+		return exprBool(l != 0), nil
+	}
+	r, err := b.r.eval(c)
+	if err != nil {
+		return 0, err
+	}
+
+	switch b.op {
+	case "+":
+		return l + r, nil
+	case "-":
+		return l - r, nil
+	case "*":
+		return l * r, nil
+	case "&":
+		return l & r, nil
+	case "|":
+		return l | r, nil
+	case "^":
+		return l ^ r, nil
+	case "==":
+		return exprBool(l == r), nil
+	case "!=":
+		return exprBool(l != r), nil
+	case "<":
+		return exprBool(l < r), nil
+	case "<=":
+		return exprBool(l <= r), nil
+	case ">":
+		return exprBool(l > r), nil
+	case ">=":
+		return exprBool(l >= r), nil
+	case "&&", "||":
+		return exprBool(r != 0), nil
+	case "<<", ">>":
+		if r < 0 || r > 63 {
+			return 0, fmt.Errorf("bad shift count %d", r)
+		}
+		if b.op == "<<" {
+			return l << r, nil
+		}
+		return l >> r, nil
+	}
+	if r == 0 {
+		return 0, fmt.Errorf("division by zero")
+	}
+	if b.op == "/" {
+		return l / r, nil
+	}
+	return l % r, nil
+}
+
+// exprPrecedence gives each binary operator's binding strength, as in Go
+var exprPrecedence = map[string]int{
+	"*": 5, "/": 5, "%": 5, "<<": 5, ">>": 5, "&": 5,
+	"+": 4, "-": 4, "|": 4, "^": 4,
+	"==": 3, "!=": 3, "<": 3, "<=": 3, ">": 3, ">=": 3,
+	"&&": 2,
+	"||": 1,
+}
+
+// exprOperators are the operators of two characters, tried before those of one
+var exprOperators = []string{"<<", ">>", "==", "!=", "<=", ">=", "&&", "||"}
+
+// parseWatchExpr parses src, resolving labels in it with syms
+func parseWatchExpr(src string, syms *SymbolTable) (*watchExpr, error) {
+	tokens, err := exprTokens(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &exprParser{tokens: tokens, syms: syms}
+	root, err := p.binary(1)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q in %q", p.tokens[p.pos], src)
+	}
+	return &watchExpr{src: src, root: root}, nil
+}
+
+// exprTokens splits an expression into numbers, names and operators
+func exprTokens(src string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(src); {
+		ch := src[i]
+		switch {
+		case ch == ' ' || ch == '\t':
+			i++
+		case isExprWord(ch) || ch == '$':
+			j := i + 1
+			for j < len(src) && isExprWord(src[j]) {
+				j++
+			}
+			tokens = append(tokens, src[i:j])
+			i = j
+		case i+1 < len(src) && slices.Contains(exprOperators, src[i:i+2]):
+			tokens = append(tokens, src[i:i+2])
+			i += 2
+		case strings.IndexByte("+-*/%&|^~!<>()[]", ch) >= 0:
+			tokens = append(tokens, src[i:i+1])
+			i++
+		default:
+			return nil, fmt.Errorf("unexpected %q in %q", ch, src)
+		}
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("empty expression")
+	}
+	return tokens, nil
+}
+
+func isExprWord(ch byte) bool {
+	return ch == '_' || ch == '.' || ch >= '0' && ch <= '9' || ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z'
+}
+
+type exprParser struct {
+	tokens []string
+	pos    int
+	syms   *SymbolTable
+}
+
+func (p *exprParser) next() string {
+	if p.pos == len(p.tokens) {
+		return ""
+	}
+	p.pos++
+	return p.tokens[p.pos-1]
+}
+
+func (p *exprParser) expect(tok string) error {
+	if got := p.next(); got != tok {
+		if got == "" {
+			return fmt.Errorf("missing %q", tok)
+		}
+		return fmt.Errorf("expected %q, found %q", tok, got)
+	}
+	return nil
+}
+
+// binary parses operators binding at least as tightly as prec
+func (p *exprParser) binary(prec int) (exprNode, error) {
+	l, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for p.pos < len(p.tokens) {
+		op := p.tokens[p.pos]
+		opPrec, ok := exprPrecedence[op]
+		if !ok || opPrec < prec {
+			break
+		}
+		p.pos++
+		r, err := p.binary(opPrec + 1)
+		if err != nil {
+			return nil, err
+		}
+		l = exprBinary{op, l, r}
+	}
+	return l, nil
+}
+
+func (p *exprParser) unary() (exprNode, error) {
+	tok := p.next()
+	switch tok {
+	case "":
+		return nil, fmt.Errorf("expression ends early")
+	case "-", "~", "!":
+		x, err := p.unary()
+		return exprUnary{tok, x}, err
+	case "(":
+		x, err := p.binary(1)
+		if err != nil {
+			return nil, err
+		}
+		return x, p.expect(")")
+	case "[":
+		x, err := p.binary(1)
+		if err != nil {
+			return nil, err
+		}
+		return exprMem{x}, p.expect("]")
+	}
+
+	upper := strings.ToUpper(tok)
+	if r, ok := exprRegNames[upper]; ok {
+		return r, nil
+	}
+	if len(upper) == 2 && upper[0] == 'V' {
+		if n, err := strconv.ParseUint(upper[1:], 16, 4); err == nil {
+			return exprReg(n), nil
+		}
+	}
+	if p.syms != nil {
+		if addr, ok := p.syms.Labels[tok]; ok {
+			return exprNum(addr), nil
+		}
+	}
+	if hex, ok := strings.CutPrefix(tok, "$"); ok {
+		tok = "0x" + hex
+	}
+	n, err := strconv.ParseInt(tok, 0, 64)
+	if err != nil {
+		return nil, fmt.Errorf("unknown register, label or number %q", tok)
+	}
+	return exprNum(n), nil
+}
+
+// watchValue is a watch expression's value at one point, for display
+type watchValue struct {
+	Expr  string
+	Value int
+	Err   error
+}
+
+func (v watchValue) String() string {
+	if v.Err != nil {
+		return fmt.Sprintf("%s: %v", v.Expr, v.Err)
+	}
+	if v.Value < 0 {
+		return fmt.Sprintf("%s = %d", v.Expr, v.Value)
+	}
+	return fmt.Sprintf("%s = %d (0x%X)", v.Expr, v.Value, v.Value)
+}
+
+// watchValues evaluates every watch expression against the machine
+func (c *Chip8) watchValues() []watchValue {
+	values := make([]watchValue, len(c.Debugger.Watches))
+	for i, w := range c.Debugger.Watches {
+		values[i].Expr = w.src
+		values[i].Value, values[i].Err = w.root.eval(c)
+	}
+	return values
+}
+
+// printWatches shows the watch expressions on the debugger's output, after a step or break
+func (c *Chip8) printWatches() {
+	for i, v := range c.watchValues() {
+		fmt.Fprintf(c.Debugger.out, "  %d: %s\n", i+1, v)
+	}
+}
+
+func debugWatch(c *Chip8, args []string) error {
+	if len(args) == 0 {
+		c.printWatches()
+		return nil
+	}
+	src := strings.Join(args, " ")
+	w, err := parseWatchExpr(src, c.Symbols)
+	if err != nil {
+		return err
+	}
+
+	c.Debugger.Watches = append(c.Debugger.Watches, w)
+	fmt.Fprintf(c.Debugger.out, "  %d: %s\n", len(c.Debugger.Watches), c.watchValues()[len(c.Debugger.Watches)-1])
+	return nil
+}
+
+func debugUnwatch(c *Chip8, args []string) error {
+	d := c.Debugger
+	switch len(args) {
+	case 0:
+		d.Watches, d.RegWatches = nil, nil
+		fmt.Fprintln(d.out, "deleted all watches")
+	case 1:
+		if _, ok := watchableRegister(args[0]); ok {
+			return c.unwatchRegister(args[0])
+		}
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n < 1 || n > len(d.Watches) {
+			return fmt.Errorf("no watch %q, watch with no expression lists them", args[0])
+		}
+		d.Watches = append(d.Watches[:n-1], d.Watches[n:]...)
+		fmt.Fprintf(d.out, "deleted watch %d\n", n)
+	default:
+		return fmt.Errorf("usage: %s", debugCommands["unwatch"].usage)
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// Just enough of RFC 6455 to accept WebSocket connections from browsers and scripts: the
+// handshake, reading masked frames, answering pings and writing unfragmented messages.
+
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// wsMaxMessage caps the size of a message a client may send
+const wsMaxMessage = 64 << 10
+
+const (
+	wsOpText  = 0x1
+	wsOpClose = 0x8
+	wsOpPing  = 0x9
+	wsOpPong  = 0xA
+)
+
+// wsConn is the server end of a WebSocket connection. Reads must come from one goroutine; writes
+// may come from any.
+type wsConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+
+	mu sync.Mutex
+}
+
+// upgradeWebSocket completes the handshake for a WebSocket request and takes over its connection
+func upgradeWebSocket(w http.ResponseWriter, r *http.Request) (*wsConn, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") || key == "" {
+		http.Error(w, "expected a WebSocket upgrade", http.StatusUpgradeRequired)
+		return nil, errors.New("not a WebSocket request")
+	}
+	if !sameOrigin(r) {
+		http.Error(w, "cross-origin WebSocket requests are not allowed", http.StatusForbidden)
+		return nil, errors.New("cross-origin WebSocket request")
+	}
+
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "connection cannot be upgraded", http.StatusInternalServerError)
+		return nil, errors.New("response does not support hijacking")
+	}
+	conn, rw, err := hj.Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha1.Sum([]byte(key + websocketGUID))
+	fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n",
+		base64.StdEncoding.EncodeToString(sum[:]))
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &wsConn{conn: conn, r: rw.Reader}, nil
+}
+
+// sameOrigin reports whether a browser made the request from a page served by this host. Clients
+// other than browsers send no Origin and are let through.
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+// ReadMessage returns the next data message, reassembling fragments and answering pings on the
+// way. It returns io.EOF once the client closes the connection.
+func (ws *wsConn) ReadMessage() ([]byte, error) {
+	var msg []byte
+	for {
+		var h [2]byte
+		if _, err := io.ReadFull(ws.r, h[:]); err != nil {
+			return nil, err
+		}
+		fin, op, masked := h[0]&0x80 != 0, h[0]&0x0F, h[1]&0x80 != 0
+
+		size := uint64(h[1] & 0x7F)
+		switch size {
+		case 126:
+			var b [2]byte
+			if _, err := io.ReadFull(ws.r, b[:]); err != nil {
+				return nil, err
+			}
+			size = uint64(binary.BigEndian.Uint16(b[:]))
+		case 127:
+			var b [8]byte
+			if _, err := io.ReadFull(ws.r, b[:]); err != nil {
+				return nil, err
+			}
+			size = binary.BigEndian.Uint64(b[:])
+		}
+		if !masked {
+			return nil, errors.New("websocket: client frame not masked")
+		}
+		if size > wsMaxMessage-uint64(len(msg)) {
+			return nil, fmt.Errorf("websocket: message larger than %d bytes", wsMaxMessage)
+		}
+
+		var mask [4]byte
+		if _, err := io.ReadFull(ws.r, mask[:]); err != nil {
+			return nil, err
+		}
+		payload := make([]byte, size)
+		if _, err := io.ReadFull(ws.r, payload); err != nil {
+			return nil, err
+		}
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+
+		switch op {
+		case wsOpClose:
+			ws.writeFrame(wsOpClose, payload)
+			return nil, io.EOF
+		case wsOpPing:
+			if err := ws.writeFrame(wsOpPong, payload); err != nil {
+				return nil, err
+			}
+			continue
+		case wsOpPong:
+			continue
+		}
+
+		msg = append(msg, payload...)
+		if fin {
+			return msg, nil
+		}
+	}
+}
+
+// WriteText sends a text message
+func (ws *wsConn) WriteText(p []byte) error {
+	return ws.writeFrame(wsOpText, p)
+}
+
+func (ws *wsConn) writeFrame(op byte, p []byte) error {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	h := []byte{0x80 | op}
+	switch n := len(p); {
+	case n < 126:
+		h = append(h, byte(n))
+	case n <= 0xFFFF:
+		h = append(h, 126)
+		h = binary.BigEndian.AppendUint16(h, uint16(n))
+	default:
+		h = append(h, 127)
+		h = binary.BigEndian.AppendUint64(h, uint64(n))
+	}
+	if _, err := ws.conn.Write(h); err != nil {
+		return err
+	}
+	_, err := ws.conn.Write(p)
+	return err
+}
+
+func (ws *wsConn) Close() error {
+	return ws.conn.Close()
+}